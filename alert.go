@@ -0,0 +1,72 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AlertKind is the kind of an Alert
+type AlertKind string
+
+const (
+	// AlertCircuitOpen is raised when a circuit breaker stops sending
+	AlertCircuitOpen AlertKind = "circuit_open"
+	// AlertCredentials is raised when Expo rejects the access token
+	AlertCredentials AlertKind = "credentials"
+	// AlertErrorRate is raised when the share of failed deliveries trips a
+	// guardrail, see ErrorRateGuard
+	AlertErrorRate AlertKind = "error_rate"
+	// AlertApprovalRequired is raised when a campaign awaits approval, see
+	// ApprovalGate
+	AlertApprovalRequired AlertKind = "approval_required"
+)
+
+// Alert is a production signal worth a human's attention
+type Alert struct {
+	Kind    AlertKind
+	Time    time.Time
+	Message string
+	Err     error
+	Labels  map[string]string
+}
+
+// AlertSink delivers alerts, e.g. to an ops chat channel
+type AlertSink interface {
+	SendAlert(ctx context.Context, alert Alert) error
+}
+
+// AlertSinkFunc adapts a function to the AlertSink interface
+type AlertSinkFunc func(ctx context.Context, alert Alert) error
+
+// SendAlert calls f
+func (f AlertSinkFunc) SendAlert(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// alertFailure raises an alert if a request failed in a way that needs
+// attention
+func (c *PushClient) alertFailure(ctx context.Context, err error) {
+	if c.alertSink == nil {
+		return
+	}
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		return
+	}
+	c.raiseAlert(ctx, Alert{
+		Kind:    AlertCredentials,
+		Time:    time.Now(),
+		Message: "Expo rejected the access token",
+		Err:     err,
+		Labels:  CallLabels(ctx),
+	})
+}
+
+func (c *PushClient) raiseAlert(ctx context.Context, alert Alert) {
+	// The alert must go out even if the call that raised it was canceled
+	err := c.alertSink.SendAlert(context.WithoutCancel(ctx), alert)
+	if err != nil && c.errorReporter != nil {
+		c.errorReporter(err)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialAlert(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	var alerts []Alert
+	client := NewPushClient(&ClientConfig{
+		Host: srv.URL,
+		AlertSink: AlertSinkFunc(func(ctx context.Context, alert Alert) error {
+			alerts = append(alerts, alert)
+			return nil
+		}),
+	})
+	if _, err := client.Publish(&testMessages(1)[0]); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(alerts) != 1 || alerts[0].Kind != AlertCredentials {
+		t.Errorf("Expected a credentials alert, got %+v", alerts)
+	}
+}
+
+func TestErrorRateGuard(t *testing.T) {
+	alerts := make(chan Alert, 2)
+	guard := &ErrorRateGuard{
+		Threshold: 0.5,
+		Window:    time.Minute,
+		MinEvents: 4,
+		Sink: AlertSinkFunc(func(ctx context.Context, alert Alert) error {
+			alerts <- alert
+			return nil
+		}),
+	}
+	now := time.Now()
+	for i, typ := range []EventType{EventSent, EventFailed, EventFailed, EventSent, EventFailed, EventFailed} {
+		guard.HandleEvent(DeliveryEvent{Type: typ, Time: now.Add(time.Duration(i) * time.Second)})
+	}
+	select {
+	case alert := <-alerts:
+		if alert.Kind != AlertErrorRate {
+			t.Errorf("Unexpected alert %+v", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an error rate alert")
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("Expected a single alert per window, got %+v", alert)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSlackAlertSink(t *testing.T) {
+	var text string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		text = body["text"]
+	}))
+	defer srv.Close()
+	sink := NewSlackAlertSink(srv.URL)
+	err := sink.SendAlert(context.Background(), Alert{
+		Kind:    AlertCircuitOpen,
+		Message: "sending paused",
+		Labels:  map[string]string{LabelApp: "shop"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "circuit_open") || !strings.Contains(text, "shop") {
+		t.Errorf("Unexpected Slack message %q", text)
+	}
+}
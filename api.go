@@ -0,0 +1,128 @@
+package expo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// APIVersion is a version of the Expo push API
+type APIVersion string
+
+const (
+	// APIVersionV2 is the /--/api/v2 push API
+	APIVersionV2 APIVersion = "v2"
+	// DefaultAPIVersion is the API version used unless configured otherwise
+	DefaultAPIVersion = APIVersionV2
+)
+
+// ErrUnsupportedAPIVersion is returned when the client has no routes for the
+// configured API version
+var ErrUnsupportedAPIVersion = newCodedError(ErrorCodeUnsupportedAPIVersion, "unsupported Expo API version")
+
+// BaseAPIURL returns the path prefix of requests to this API version
+func (v APIVersion) BaseAPIURL() string {
+	return "/--/api/" + string(v)
+}
+
+// Operations of the push API. Each API version maps them to its own route,
+// so a new version can be added next to the existing ones.
+const (
+	opSend        = "send"
+	opGetReceipts = "getReceipts"
+)
+
+var apiRoutes = map[APIVersion]map[string]string{
+	APIVersionV2: {
+		opSend:        "/push/send",
+		opGetReceipts: "/push/getReceipts",
+	},
+}
+
+// endpoint returns the request path of an operation for the client's API version
+func (c *PushClient) endpoint(op string) (string, error) {
+	route, ok := apiRoutes[c.apiVersion][op]
+	if !ok {
+		return "", fmt.Errorf("%w: %s does not support %s", ErrUnsupportedAPIVersion, c.apiVersion, op)
+	}
+	return c.apiURL + route, nil
+}
+
+// post sends payload as JSON to an operation and checks the response status.
+// It records the request size in stats.
+func (c *PushClient) post(ctx context.Context, op string, payload any, stats *BatchStats) (*http.Response, error) {
+	path, err := c.endpoint(op)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.postJSON(ctx, path, data, stats)
+}
+
+// postJSON sends data, a JSON payload, to path and checks the response status
+func (c *PushClient) postJSON(ctx context.Context, path string, data []byte, stats *BatchStats) (*http.Response, error) {
+	stats.PayloadBytes = len(data)
+	ctx, done := c.logRequest(ctx, path, stats)
+	compress := c.compressionEnabled() && len(data) >= c.compressionThreshold
+	resp, err := c.postBody(ctx, path, data, compress, stats)
+	if compress && rejectsCompression(err) {
+		// Something between us and Expo doesn't take compressed bodies, so
+		// stop compressing for a while and send the batch as is
+		c.disableCompression()
+		resp, err = c.postBody(ctx, path, data, false, stats)
+	}
+	var authErr *AuthError
+	if c.tokenSource != nil && errors.As(err, &authErr) {
+		// The token may have been rotated since it was cached
+		c.tokenSource.Invalidate()
+	}
+	done(statusCode(resp), err)
+	return resp, err
+}
+
+func (c *PushClient) postBody(ctx context.Context, path string, data []byte, compress bool, stats *BatchStats) (*http.Response, error) {
+	body, encoding, err := encodeRequest(data, compress)
+	if err != nil {
+		return nil, err
+	}
+	stats.WireBytes = len(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	token := c.accessToken
+	if c.tokenSource != nil {
+		token, err = c.tokenSource.Secret(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("access token: %w", err)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Check that we didn't receive an invalid response
+	return resp, checkStatus(resp, c.errorBodyLimit)
+}
+
+// statusCode returns the status of resp, or 0 if there is none
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
@@ -0,0 +1,245 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultApprovalSkew is the clock skew ApprovalGate tolerates on signed
+// confirmations unless MaxSkew says otherwise
+const DefaultApprovalSkew = 5 * time.Minute
+
+var (
+	// ErrApprovalRequired is returned by Campaign.Run when the campaign
+	// needs an approval it doesn't have yet. Run it again once approved.
+	ErrApprovalRequired = newCodedError(ErrorCodeApprovalRequired, "campaign awaits approval")
+	// ErrSelfApproval is returned when the requester of a campaign
+	// approves it, breaking the two-person rule
+	ErrSelfApproval = newCodedError(ErrorCodeApprovalRequired, "campaign approved by its requester")
+	// ErrIncompleteApproval is returned for an approval without a job ID or
+	// an approver
+	ErrIncompleteApproval = newCodedError(ErrorCodeInvalidArgument, "approval without job or approver")
+)
+
+// ApprovalRequest is a campaign waiting for approval
+type ApprovalRequest struct {
+	JobID       string
+	RequestedBy string
+	// Notifications is the size of the campaign, one per recipient
+	Notifications int
+	At            time.Time
+}
+
+// Approval records who approved a campaign, for audits
+type Approval struct {
+	JobID       string
+	RequestedBy string
+	Approver    string
+	At          time.Time
+}
+
+// ApprovalStore persists approvals.
+// Implementations must be safe for concurrent use.
+type ApprovalStore interface {
+	// Approval returns the approval of a job, or nil if it has none
+	Approval(ctx context.Context, jobID string) (*Approval, error)
+	// SaveApproval stores the approval, replacing any previous one
+	SaveApproval(ctx context.Context, approval *Approval) error
+}
+
+// ApprovalGate enforces a two-person rule on mass sends: a campaign with
+// more than Threshold notifications starts only once someone other than
+// its requester approved it, with Approve or a signed confirmation posted
+// to ServeHTTP.
+type ApprovalGate struct {
+	// Threshold is the number of notifications, one per recipient, above
+	// which campaigns need approval
+	Threshold int
+	// Store keeps approvals. Defaults to an in-memory store.
+	Store ApprovalStore
+	// AlertSink is told when a campaign awaits approval, e.g. to ping
+	// approvers in an ops channel, with an AlertApprovalRequired alert
+	AlertSink AlertSink
+	// Key authenticates the confirmations posted to ServeHTTP, signed like
+	// SigningTransport signs requests. Without it, ServeHTTP rejects them.
+	Key []byte
+	// MaxSkew bounds the clock skew of confirmations. Defaults to
+	// DefaultApprovalSkew.
+	MaxSkew time.Duration
+	// ErrorReporter receives the errors of AlertSink
+	ErrorReporter ErrorReporter
+
+	mu       sync.Mutex
+	requests map[string]ApprovalRequest
+}
+
+// Approve approves a campaign on behalf of approver, who must not be its
+// requester. A campaign may be approved before it first runs.
+func (g *ApprovalGate) Approve(jobID, approver string) error {
+	return g.ApproveContext(context.Background(), jobID, approver)
+}
+
+// ApproveContext approves a campaign with a context
+func (g *ApprovalGate) ApproveContext(ctx context.Context, jobID, approver string) error {
+	if jobID == "" || approver == "" {
+		return ErrIncompleteApproval
+	}
+	g.mu.Lock()
+	request, ok := g.requests[jobID]
+	g.mu.Unlock()
+	if ok && request.RequestedBy == approver {
+		return ErrSelfApproval
+	}
+	err := g.store().SaveApproval(ctx, &Approval{
+		JobID:       jobID,
+		RequestedBy: request.RequestedBy,
+		Approver:    approver,
+		At:          time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	delete(g.requests, jobID)
+	g.mu.Unlock()
+	return nil
+}
+
+// Pending returns the campaigns waiting for approval, oldest first
+func (g *ApprovalGate) Pending() []ApprovalRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	requests := make([]ApprovalRequest, 0, len(g.requests))
+	for _, request := range g.requests {
+		requests = append(requests, request)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].At.Before(requests[j].At) })
+	return requests
+}
+
+// approvalConfirmation is the JSON body of a confirmation posted to ServeHTTP
+type approvalConfirmation struct {
+	JobID    string `json:"jobId"`
+	Approver string `json:"approver"`
+}
+
+// ServeHTTP approves a campaign from a signed POST of
+// {"jobId": "...", "approver": "..."}, e.g. sent by a ticketing system
+// once a change request is signed off
+func (g *ApprovalGate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	maxSkew := g.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = DefaultApprovalSkew
+	}
+	if len(g.Key) == 0 || VerifySignature(r, g.Key, maxSkew) != nil {
+		http.Error(w, ErrInvalidSignature.Error(), http.StatusUnauthorized)
+		return
+	}
+	var confirmation approvalConfirmation
+	if err := json.NewDecoder(r.Body).Decode(&confirmation); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch err := g.ApproveContext(r.Context(), confirmation.JobID, confirmation.Approver); {
+	case errors.Is(err, ErrSelfApproval):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case err != nil && (confirmation.JobID == "" || confirmation.Approver == ""):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// check returns nil if campaign may start, or ErrApprovalRequired after
+// recording its request
+func (g *ApprovalGate) check(ctx context.Context, campaign *Campaign, n int) error {
+	if n <= g.Threshold {
+		return nil
+	}
+	approval, err := g.store().Approval(ctx, campaign.ID)
+	if err != nil {
+		return err
+	}
+	if approval != nil {
+		if approval.Approver == campaign.RequestedBy {
+			return ErrSelfApproval
+		}
+		return nil
+	}
+	request := ApprovalRequest{JobID: campaign.ID, RequestedBy: campaign.RequestedBy, Notifications: n, At: time.Now()}
+	g.mu.Lock()
+	if g.requests == nil {
+		g.requests = make(map[string]ApprovalRequest)
+	}
+	_, waiting := g.requests[campaign.ID]
+	if !waiting {
+		g.requests[campaign.ID] = request
+	}
+	g.mu.Unlock()
+	if !waiting && g.AlertSink != nil {
+		err := g.AlertSink.SendAlert(ctx, Alert{
+			Kind:    AlertApprovalRequired,
+			Time:    request.At,
+			Message: fmt.Sprintf("campaign %s of %d notifications requested by %s awaits approval", campaign.ID, n, campaign.RequestedBy),
+			Labels:  map[string]string{LabelCampaign: campaign.ID},
+		})
+		if err != nil && g.ErrorReporter != nil {
+			g.ErrorReporter(err)
+		}
+	}
+	return ErrApprovalRequired
+}
+
+func (g *ApprovalGate) store() ApprovalStore {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.Store == nil {
+		g.Store = NewMemoryApprovalStore()
+	}
+	return g.Store
+}
+
+// MemoryApprovalStore is an in-process ApprovalStore
+type MemoryApprovalStore struct {
+	mu        sync.Mutex
+	approvals map[string]Approval
+}
+
+// NewMemoryApprovalStore creates an empty in-memory ApprovalStore
+func NewMemoryApprovalStore() *MemoryApprovalStore {
+	return &MemoryApprovalStore{approvals: make(map[string]Approval)}
+}
+
+// Approval returns a copy of the approval of a job, or nil if it has none
+func (s *MemoryApprovalStore) Approval(_ context.Context, jobID string) (*Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	approval, ok := s.approvals[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return &approval, nil
+}
+
+// SaveApproval stores a copy of the approval
+func (s *MemoryApprovalStore) SaveApproval(_ context.Context, approval *Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvals[approval.JobID] = *approval
+	return nil
+}
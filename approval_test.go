@@ -0,0 +1,101 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCampaignApproval(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	defer srv.Close()
+	var alerts []Alert
+	gate := &ApprovalGate{
+		Threshold: 2,
+		AlertSink: AlertSinkFunc(func(_ context.Context, alert Alert) error {
+			alerts = append(alerts, alert)
+			return nil
+		}),
+	}
+	campaign := &Campaign{
+		ID:          "launch",
+		Messages:    testMessages(3),
+		Client:      NewPushClient(&ClientConfig{Host: srv.URL}),
+		Approval:    gate,
+		RequestedBy: "alice",
+	}
+	ctx := context.Background()
+	for range 2 {
+		if err := campaign.Run(ctx); !errors.Is(err, ErrApprovalRequired) {
+			t.Fatalf("Expected ErrApprovalRequired, got %v", err)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Errorf("Expected nothing sent before approval, got %d requests", calls.Load())
+	}
+	if len(alerts) != 1 || alerts[0].Kind != AlertApprovalRequired {
+		t.Errorf("Expected one approval alert, got %+v", alerts)
+	}
+	if pending := gate.Pending(); len(pending) != 1 || pending[0].Notifications != 3 {
+		t.Errorf("Incorrect pending requests: %+v", pending)
+	}
+	if err := gate.Approve("launch", "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("Expected ErrSelfApproval, got %v", err)
+	}
+	if err := gate.Approve("launch", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := campaign.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	approval, _ := gate.Store.Approval(ctx, "launch")
+	if approval == nil || approval.Approver != "bob" || approval.RequestedBy != "alice" {
+		t.Errorf("Incorrect audit record: %+v", approval)
+	}
+	if len(gate.Pending()) != 0 {
+		t.Error("Expected no pending requests after approval")
+	}
+}
+
+func TestCampaignBelowApprovalThreshold(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	defer srv.Close()
+	campaign := &Campaign{
+		ID:       "small",
+		Messages: testMessages(2),
+		Client:   NewPushClient(&ClientConfig{Host: srv.URL}),
+		Approval: &ApprovalGate{Threshold: 2},
+	}
+	if err := campaign.Run(context.Background()); err != nil {
+		t.Errorf("Expected small campaign to start, got %v", err)
+	}
+}
+
+func TestApprovalWebhook(t *testing.T) {
+	key := []byte("secret")
+	gate := &ApprovalGate{Threshold: 1, Key: key}
+	post := func(key []byte, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/approve", bytes.NewReader([]byte(body)))
+		setSignature(req, key, []byte(body))
+		rec := httptest.NewRecorder()
+		gate.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post([]byte("forged"), `{"jobId":"launch","approver":"bob"}`); code != http.StatusUnauthorized {
+		t.Errorf("Expected forged confirmation rejected, got %d", code)
+	}
+	if code := post(key, `{"jobId":"launch"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected confirmation without approver rejected, got %d", code)
+	}
+	if code := post(key, `{"jobId":"launch","approver":"bob"}`); code != http.StatusNoContent {
+		t.Errorf("Expected confirmation accepted, got %d", code)
+	}
+	if approval, _ := gate.Store.Approval(context.Background(), "launch"); approval == nil || approval.Approver != "bob" {
+		t.Errorf("Incorrect approval: %+v", approval)
+	}
+}
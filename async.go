@@ -0,0 +1,333 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is how often an AsyncPublisher sends queued messages
+// unless configured otherwise
+const DefaultFlushInterval = time.Second
+
+// AsyncPublisher queues messages and sends them in batches from Run, with
+// up to Workers batches in flight, so callers don't wait on Expo. Queued
+// messages with the same CollapseKey for the same token supersede each
+// other: only the newest is sent.
+type AsyncPublisher struct {
+	Client *PushClient
+	// BatchSize is the number of queued messages that triggers a flush
+	// before FlushInterval elapses. Defaults to MaxMessagesPerRequest.
+	BatchSize int
+	// FlushInterval is how often queued messages are sent.
+	// Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+	// OnSuperseded is called for every token a queued message won't be sent
+	// to because a newer message with the same CollapseKey was enqueued.
+	// The client's EventSink also receives a suppressed event.
+	OnSuperseded func(message PushMessage, token ExponentPushToken)
+	// Workers is the number of batches sent at once. Defaults to 1. With
+	// more than one, messages to the same token may reach Expo out of
+	// order unless PerTokenOrder is set.
+	Workers int
+	// PerTokenOrder delivers messages to the same token to Expo in
+	// enqueue order: a batch is only sent once every earlier batch with a
+	// recipient in common has completed, and concurrent Flush calls run
+	// one after the other.
+	PerTokenOrder bool
+	// OnResult is called with the outcome of every message sent, from the
+	// worker that sent it. Match it to Enqueue's return value with the
+	// CorrelationID.
+	OnResult func(result AsyncResult)
+
+	// flushing serializes Flush calls when PerTokenOrder is set
+	flushing sync.Mutex
+	mu       sync.Mutex
+	queue    []*PushMessage
+	collapse map[collapseSlot]*PushMessage
+	full     chan struct{}
+	// inFlight are the messages taken from the queue by each running Flush
+	inFlight map[*[]PushMessage]struct{}
+	// idempotencyKeys maps the keys of queued and in-flight messages to
+	// their correlation IDs
+	idempotencyKeys map[string]string
+}
+
+// AsyncResult is the outcome of a message sent by an AsyncPublisher
+type AsyncResult struct {
+	CorrelationID string
+	Message       PushMessage
+	// Response is the ticket of the message, unless Err is set
+	Response PushResponse
+	// Err is the error of the request carrying the message
+	Err error
+}
+
+type collapseSlot struct {
+	token ExponentPushToken
+	key   string
+}
+
+// Enqueue queues a copy of message for sending and returns its correlation
+// ID. A message whose IdempotencyKey is already queued or being sent is
+// dropped, reported as a duplicate to the client's EventSink, and the
+// correlation ID of the queued one is returned.
+func (p *AsyncPublisher) Enqueue(message PushMessage) string {
+	message.To = slices.Clone(message.To)
+	if message.CorrelationID == "" {
+		message.CorrelationID = p.Client.NewID()
+	}
+	id := message.CorrelationID
+	type superseded struct {
+		message PushMessage
+		token   ExponentPushToken
+	}
+	var dropped []superseded
+
+	p.mu.Lock()
+	p.init()
+	if key := message.IdempotencyKey; key != "" {
+		if queued, ok := p.idempotencyKeys[key]; ok {
+			p.mu.Unlock()
+			if c := p.Client; c.eventSink != nil {
+				now := time.Now()
+				for _, token := range message.To {
+					c.emitSuppressed(now, token, SuppressedDuplicate, &message, c.labels)
+				}
+			}
+			return queued
+		}
+		p.idempotencyKeys[key] = id
+	}
+	if message.CollapseKey != "" {
+		for _, token := range message.To {
+			slot := collapseSlot{token, message.CollapseKey}
+			if older, ok := p.collapse[slot]; ok {
+				d := superseded{*older, token}
+				d.message.To = slices.Clone(older.To)
+				dropped = append(dropped, d)
+				older.To = slices.DeleteFunc(older.To, func(t ExponentPushToken) bool { return t == token })
+			}
+			p.collapse[slot] = &message
+		}
+	}
+	p.queue = append(p.queue, &message)
+	if len(p.queue) >= p.batchSize() {
+		select {
+		case p.full <- struct{}{}:
+		default:
+		}
+	}
+	p.mu.Unlock()
+
+	c := p.Client
+	now := time.Now()
+	for _, d := range dropped {
+		if c.eventSink != nil {
+			c.emitSuppressed(now, d.token, SuppressedSuperseded, &d.message, c.labels)
+		}
+		if p.OnSuperseded != nil {
+			p.OnSuperseded(d.message, d.token)
+		}
+	}
+	return id
+}
+
+// Len returns the number of queued messages
+func (p *AsyncPublisher) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// Pending returns a snapshot of the queued messages, oldest first, without
+// the tokens superseded since they were enqueued
+func (p *AsyncPublisher) Pending() []PushMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := make([]PushMessage, 0, len(p.queue))
+	for _, message := range p.queue {
+		if len(message.To) > 0 {
+			pending = append(pending, cloneMessage(message))
+		}
+	}
+	return pending
+}
+
+// InFlight returns a snapshot of the messages being sent by Flush
+func (p *AsyncPublisher) InFlight() []PushMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var inFlight []PushMessage
+	for messages := range p.inFlight {
+		for i := range *messages {
+			inFlight = append(inFlight, cloneMessage(&(*messages)[i]))
+		}
+	}
+	return inFlight
+}
+
+// cloneMessage copies a message with its recipients and data
+func cloneMessage(message *PushMessage) PushMessage {
+	clone := *message
+	clone.To = slices.Clone(message.To)
+	clone.Data = maps.Clone(message.Data)
+	return clone
+}
+
+// Flush sends all queued messages and returns the first error
+func (p *AsyncPublisher) Flush(ctx context.Context) error {
+	if p.PerTokenOrder {
+		p.flushing.Lock()
+		defer p.flushing.Unlock()
+	}
+	messages := make([]PushMessage, 0)
+	p.mu.Lock()
+	for _, message := range p.queue {
+		// Messages superseded for all their tokens have nothing left to send
+		if len(message.To) > 0 {
+			messages = append(messages, *message)
+		} else if key := message.IdempotencyKey; key != "" && p.idempotencyKeys[key] == message.CorrelationID {
+			delete(p.idempotencyKeys, key)
+		}
+	}
+	p.queue = nil
+	clear(p.collapse)
+	if p.inFlight == nil {
+		p.inFlight = make(map[*[]PushMessage]struct{})
+	}
+	p.inFlight[&messages] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.inFlight, &messages)
+		for i := range messages {
+			if key := messages[i].IdempotencyKey; key != "" && p.idempotencyKeys[key] == messages[i].CorrelationID {
+				delete(p.idempotencyKeys, key)
+			}
+		}
+		p.mu.Unlock()
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	chunks := chunkMessages(messages, chunkSize(p.BatchSize))
+	var deps [][]int
+	var done []chan struct{}
+	if p.PerTokenOrder {
+		deps = chunkDependencies(chunks)
+		done = make([]chan struct{}, len(chunks))
+		for i := range done {
+			done[i] = make(chan struct{})
+		}
+	}
+	sem := make(chan struct{}, max(p.Workers, 1))
+	for i, chunk := range chunks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, chunk []PushMessage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if done != nil {
+				defer close(done[i])
+			}
+			// Chunks are started in order, so the chunks waited on are
+			// already running and this can't deadlock
+			err := waitChunks(ctx, done, deps, i)
+			if err == nil {
+				err = p.send(ctx, chunk)
+			} else {
+				p.report(chunk, nil, err)
+			}
+			if err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// send publishes a batch and reports the outcome of its messages
+func (p *AsyncPublisher) send(ctx context.Context, chunk []PushMessage) error {
+	responses, err := p.Client.PublishMultipleContext(ctx, chunk)
+	p.report(chunk, responses, err)
+	return err
+}
+
+// report passes the outcome of a batch to OnResult
+func (p *AsyncPublisher) report(chunk []PushMessage, responses []PushResponse, err error) {
+	if p.OnResult != nil {
+		for i := range chunk {
+			result := AsyncResult{CorrelationID: chunk[i].CorrelationID, Message: chunk[i]}
+			// Messages of failed requests have no response
+			if i < len(responses) && responses[i].Status != "" {
+				result.Response = responses[i]
+			} else {
+				result.Err = err
+			}
+			p.OnResult(result)
+		}
+	}
+}
+
+// Run sends queued messages every FlushInterval, or sooner once BatchSize
+// messages are queued, until ctx is done. Messages still queued are sent
+// before it returns. Send errors go to the client's ErrorReporter.
+func (p *AsyncPublisher) Run(ctx context.Context) error {
+	p.mu.Lock()
+	p.init()
+	p.mu.Unlock()
+	return supervise(ctx, "async publisher", p.Client.errorReporter, p.run)
+}
+
+func (p *AsyncPublisher) run(ctx context.Context) error {
+	interval := p.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.flush(context.WithoutCancel(ctx))
+			return ctx.Err()
+		case <-ticker.C:
+		case <-p.full:
+		}
+		p.flush(ctx)
+	}
+}
+
+func (p *AsyncPublisher) flush(ctx context.Context) {
+	if err := p.Flush(ctx); err != nil && p.Client.errorReporter != nil {
+		p.Client.errorReporter(err)
+	}
+}
+
+func (p *AsyncPublisher) init() {
+	if p.collapse == nil {
+		p.collapse = make(map[collapseSlot]*PushMessage)
+		p.idempotencyKeys = make(map[string]string)
+		p.full = make(chan struct{}, 1)
+	}
+}
+
+func (p *AsyncPublisher) batchSize() int {
+	if p.BatchSize <= 0 {
+		return MaxMessagesPerRequest
+	}
+	return p.BatchSize
+}
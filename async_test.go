@@ -0,0 +1,186 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncPublisherCollapse(t *testing.T) {
+	var sent []PushMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		sent = append(sent, messages...)
+		return okTickets(messages)
+	})
+	var events []DeliveryEvent
+	client := NewPushClient(&ClientConfig{
+		Host:      srv.URL,
+		EventSink: EventSinkFunc(func(event DeliveryEvent) { events = append(events, event) }),
+	})
+	var superseded []string
+	p := &AsyncPublisher{
+		Client: client,
+		OnSuperseded: func(message PushMessage, token ExponentPushToken) {
+			superseded = append(superseded, message.Body)
+		},
+	}
+	a, b := ExponentPushToken("ExponentPushToken[a]"), ExponentPushToken("ExponentPushToken[b]")
+	p.Enqueue(PushMessage{To: []ExponentPushToken{a, b}, Body: "1-0", CollapseKey: "score"})
+	p.Enqueue(PushMessage{To: []ExponentPushToken{a}, Body: "2-0", CollapseKey: "score"})
+	p.Enqueue(PushMessage{To: []ExponentPushToken{a}, Body: "goal!"})
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 3 || len(sent[0].To) != 1 || sent[0].To[0] != b || sent[1].Body != "2-0" {
+		t.Errorf("Unexpected messages sent: %+v", sent)
+	}
+	if len(superseded) != 1 || superseded[0] != "1-0" {
+		t.Errorf("Expected 1-0 to be superseded, got %v", superseded)
+	}
+	if len(events) == 0 || events[0].ErrorCode != SuppressedSuperseded || events[0].Token != a {
+		t.Errorf("Expected a superseded event first, got %+v", events)
+	}
+	if p.Len() != 0 {
+		t.Errorf("Expected an empty queue, got %d", p.Len())
+	}
+}
+
+func TestAsyncPublisherIdempotencyKey(t *testing.T) {
+	var sent []PushMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		sent = append(sent, messages...)
+		return okTickets(messages)
+	})
+	var events []DeliveryEvent
+	client := NewPushClient(&ClientConfig{
+		Host:      srv.URL,
+		EventSink: EventSinkFunc(func(event DeliveryEvent) { events = append(events, event) }),
+	})
+	p := &AsyncPublisher{Client: client}
+	message := PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "Shipped", IdempotencyKey: "order-42"}
+	first := p.Enqueue(message)
+	if again := p.Enqueue(message); again != first {
+		t.Errorf("Expected the queued message's ID %s, got %s", first, again)
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected one message sent, got %d", len(sent))
+	}
+	if len(events) == 0 || events[0].ErrorCode != SuppressedDuplicate {
+		t.Errorf("Expected a duplicate event first, got %+v", events)
+	}
+
+	// Once sent, the key can be queued again; the client's DedupWindow
+	// decides whether it goes out
+	if p.Enqueue(message) == first {
+		t.Error("Expected a new ID after the flush")
+	}
+}
+
+func TestAsyncPublisherIntrospection(t *testing.T) {
+	var p *AsyncPublisher
+	var inFlight, pending []PushMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		inFlight, pending = p.InFlight(), p.Pending()
+		return okTickets(messages)
+	})
+	p = &AsyncPublisher{Client: NewPushClient(&ClientConfig{Host: srv.URL})}
+	for _, message := range testMessages(2) {
+		p.Enqueue(message)
+	}
+	if queued := p.Pending(); len(queued) != 2 || queued[1].To[0] != "ExponentPushToken[1]" {
+		t.Errorf("Expected the queued messages in order, got %+v", queued)
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(inFlight) != 2 || len(pending) != 0 {
+		t.Errorf("Expected the messages in flight while sent, got %d in flight, %d pending", len(inFlight), len(pending))
+	}
+	if len(p.InFlight()) != 0 {
+		t.Error("Expected nothing in flight after Flush")
+	}
+}
+
+func TestAsyncPublisherWorkers(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return okTickets(messages)
+	})
+	results := make(map[string]AsyncResult)
+	p := &AsyncPublisher{
+		Client:    NewPushClient(&ClientConfig{Host: srv.URL}),
+		BatchSize: 2,
+		Workers:   3,
+		OnResult: func(result AsyncResult) {
+			mu.Lock()
+			results[result.CorrelationID] = result
+			mu.Unlock()
+		},
+	}
+	var ids []string
+	for _, message := range testMessages(8) {
+		ids = append(ids, p.Enqueue(message))
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if peak != 3 {
+		t.Errorf("Expected 3 batches in flight at most, got %d", peak)
+	}
+	for i, id := range ids {
+		r, ok := results[id]
+		if !ok || r.Err != nil || r.Response.Status != SuccessStatus || r.Message.To[0] != testMessages(8)[i].To[0] {
+			t.Errorf("Incorrect result for message %d: %+v", i, r)
+		}
+	}
+}
+
+func TestAsyncPublisherPerTokenOrder(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		// The first batch is slow, so without ordering the update to the
+		// same token in the second batch would arrive first
+		if messages[0].Body == "first" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		received = append(received, messages[0].Body)
+		mu.Unlock()
+		return okTickets(messages)
+	})
+	p := &AsyncPublisher{
+		Client:        NewPushClient(&ClientConfig{Host: srv.URL}),
+		BatchSize:     1,
+		Workers:       2,
+		PerTokenOrder: true,
+	}
+	messages := testMessages(2)
+	messages[0].Body = "first"
+	messages[1].To = messages[0].To
+	messages[1].Body = "second"
+	for _, message := range messages {
+		p.Enqueue(message)
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[0] != "first" {
+		t.Errorf("Expected messages in enqueue order, got %v", received)
+	}
+}
@@ -0,0 +1,151 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrUnknownDelivery is returned when an open reported by the app matches
+// no message the Tracker saw sent
+var ErrUnknownDelivery = newCodedError(ErrorCodeNotFound, "no delivery matches the open")
+
+// maxOpenReportBytes bounds the body of the reports posted to OpenHandler
+const maxOpenReportBytes = 4 << 10
+
+// OpenReport is what the app reports when the user opens a notification.
+// The correlation ID comes from Data[DataKeyCorrelationID], see
+// ClientConfig.SendCorrelationID.
+type OpenReport struct {
+	CorrelationID string            `json:"correlationId"`
+	Token         ExponentPushToken `json:"token"`
+	// Time defaults to when the report is recorded
+	Time time.Time `json:"time,omitempty"`
+}
+
+// RecordOpen joins an open onto the delivery of the message to the token,
+// recording an EventOpened with the labels of the sent event, so opens
+// count towards its campaign. Repeated reports of an open are ignored. It
+// returns ErrUnknownDelivery when the Tracker has no matching sent event.
+func (t *Tracker) RecordOpen(report OpenReport) error {
+	if report.Time.IsZero() {
+		report.Time = time.Now()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var sent *DeliveryEvent
+	for i := range t.events {
+		event := &t.events[i]
+		if event.CorrelationID != report.CorrelationID || event.Token != report.Token {
+			continue
+		}
+		switch event.Type {
+		case EventOpened:
+			return nil
+		case EventSent:
+			sent = event
+		}
+	}
+	if report.CorrelationID == "" || sent == nil {
+		return ErrUnknownDelivery
+	}
+	t.events = append(t.events, DeliveryEvent{
+		Type:          EventOpened,
+		Time:          report.Time,
+		Token:         report.Token,
+		TicketID:      sent.TicketID,
+		Locale:        sent.Locale,
+		Labels:        sent.Labels,
+		CorrelationID: sent.CorrelationID,
+	})
+	return nil
+}
+
+// OpenHandler returns an HTTP handler the app posts an OpenReport to, as
+// JSON, when the user opens a notification. Reports need no credentials:
+// only the recipient of a message knows its correlation ID, and reports
+// matching no delivery are refused with 404.
+func (t *Tracker) OpenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var report OpenReport
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxOpenReportBytes)).Decode(&report); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The app's clock can't be trusted
+		report.Time = time.Time{}
+		switch err := t.RecordOpen(report); {
+		case errors.Is(err, ErrUnknownDelivery):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// OpenRate is the share of the notifications of a campaign that were opened
+type OpenRate struct {
+	// Campaign is the LabelCampaign of the sends, empty for sends outside
+	// campaigns
+	Campaign  string
+	Delivered int
+	Opened    int
+}
+
+// Rate returns Opened/Delivered, or 0 if nothing was delivered
+func (r OpenRate) Rate() float64 {
+	if r.Delivered == 0 {
+		return 0
+	}
+	return float64(r.Opened) / float64(r.Delivered)
+}
+
+// OpenRates returns the open rates, by campaign, of the notifications sent
+// in [start, end), whenever they were opened. Rates are ordered by campaign.
+func (t *Tracker) OpenRates(start, end time.Time) []OpenRate {
+	type delivery struct {
+		correlationID string
+		token         ExponentPushToken
+	}
+	t.mu.Lock()
+	campaigns := make(map[delivery]string)
+	rates := make(map[string]*OpenRate)
+	for _, event := range t.events {
+		if event.Type != EventSent || event.Time.Before(start) || !event.Time.Before(end) {
+			continue
+		}
+		campaign := event.Labels[LabelCampaign]
+		campaigns[delivery{event.CorrelationID, event.Token}] = campaign
+		if rates[campaign] == nil {
+			rates[campaign] = &OpenRate{Campaign: campaign}
+		}
+		rates[campaign].Delivered++
+	}
+	for _, event := range t.events {
+		if event.Type != EventOpened {
+			continue
+		}
+		if campaign, ok := campaigns[delivery{event.CorrelationID, event.Token}]; ok {
+			rates[campaign].Opened++
+		}
+	}
+	t.mu.Unlock()
+	result := make([]OpenRate, 0, len(rates))
+	for _, rate := range rates {
+		result = append(result, *rate)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Campaign < result[j].Campaign })
+	return result
+}
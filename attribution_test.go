@@ -0,0 +1,60 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAttribution(t *testing.T) {
+	var received []PushMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		received = append(received, messages...)
+		return okTickets(messages)
+	})
+	tracker := NewTracker()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, EventSink: tracker, SendCorrelationID: true})
+	messages := testMessages(4)
+	messages[0].Data = map[string]string{"a": "b"}
+	_, err := client.PublishMultipleContext(context.Background(), messages,
+		WithLabels(map[string]string{LabelCampaign: "spring"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].Data[DataKeyCorrelationID] != "" {
+		t.Error("Caller's data modified")
+	}
+	if received[0].Data["a"] != "b" || received[0].Data[DataKeyCorrelationID] == "" {
+		t.Fatalf("Correlation ID not sent: %v", received[0].Data)
+	}
+
+	handler := tracker.OpenHandler()
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/opened", strings.NewReader(body)))
+		return w.Code
+	}
+	open := `{"correlationId":"` + received[0].Data[DataKeyCorrelationID] + `","token":"` + string(received[0].To[0]) + `"}`
+	if code := post(open); code != http.StatusNoContent {
+		t.Errorf("Open refused with %d", code)
+	}
+	// Repeated reports count once
+	post(open)
+	if code := post(`{"correlationId":"forged","token":"ExponentPushToken[0]"}`); code != http.StatusNotFound {
+		t.Errorf("Forged open answered with %d", code)
+	}
+	if err := tracker.RecordOpen(OpenReport{CorrelationID: received[1].Data[DataKeyCorrelationID], Token: received[2].To[0]}); !errors.Is(err, ErrUnknownDelivery) {
+		t.Errorf("Expected ErrUnknownDelivery for another token, got %v", err)
+	}
+
+	rates := tracker.OpenRates(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if len(rates) != 1 || rates[0].Campaign != "spring" || rates[0].Delivered != 4 || rates[0].Opened != 1 || rates[0].Rate() != 0.25 {
+		t.Errorf("Incorrect open rates %+v", rates)
+	}
+}
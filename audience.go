@@ -0,0 +1,152 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Audience yields the recipients of a campaign at send time. A campaign
+// queries it again for every chunk, after the last token it sent, so a
+// long-running campaign reaches the devices registered after it started
+// and skips the ones pruned meanwhile.
+type Audience interface {
+	// Tokens returns up to limit tokens greater than after, in ascending
+	// order. An empty after starts from the first token.
+	Tokens(ctx context.Context, after ExponentPushToken, limit int) ([]ExponentPushToken, error)
+}
+
+// AudienceFunc adapts a function to the Audience interface
+type AudienceFunc func(ctx context.Context, after ExponentPushToken, limit int) ([]ExponentPushToken, error)
+
+// Tokens calls f
+func (f AudienceFunc) Tokens(ctx context.Context, after ExponentPushToken, limit int) ([]ExponentPushToken, error) {
+	return f(ctx, after, limit)
+}
+
+// StoreAudience is the tokens of store matching filter, e.g. the active
+// ones. It lists the store for every chunk; stores able to query a range
+// of tokens should implement Audience themselves.
+func StoreAudience(store TokenStore, filter TokenFilter) Audience {
+	return AudienceFunc(func(ctx context.Context, after ExponentPushToken, limit int) ([]ExponentPushToken, error) {
+		records, err := store.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		// List is ordered by token
+		start := sort.Search(len(records), func(i int) bool { return records[i].Token > after })
+		end := min(start+limit, len(records))
+		tokens := make([]ExponentPushToken, 0, end-start)
+		for i := start; i < end; i++ {
+			tokens = append(tokens, records[i].Token)
+		}
+		return tokens, nil
+	})
+}
+
+// countAudience counts the tokens of the audience after cursor
+func (c *Campaign) countAudience(ctx context.Context, cursor ExponentPushToken) (int, error) {
+	limit := chunkSize(c.ChunkSize)
+	n := 0
+	for {
+		tokens, err := c.Audience.Tokens(ctx, cursor, limit)
+		if err != nil || len(tokens) == 0 {
+			return n, err
+		}
+		n += len(tokens)
+		cursor = tokens[len(tokens)-1]
+	}
+}
+
+// runAudience sends Template to the audience chunk by chunk, recording the
+// last token sent in the checkpoint
+func (c *Campaign) runAudience(ctx context.Context, checkpoint *Checkpoint) error {
+	fresh := checkpoint == nil
+	if fresh {
+		checkpoint = &Checkpoint{CampaignID: c.ID}
+	}
+	limit := chunkSize(c.ChunkSize)
+	// The count is an estimate for progress, and what Approval checks
+	remaining, err := c.countAudience(ctx, checkpoint.Cursor)
+	if err != nil {
+		return err
+	}
+	if fresh && c.Approval != nil {
+		if err := c.Approval.check(ctx, c, remaining); err != nil {
+			return err
+		}
+	}
+	chunks := (remaining + limit - 1) / limit
+	c.startProgress(checkpoint.Chunks+chunks, checkpoint.Chunks)
+	var lastSent time.Time
+	for sent := 0; ; sent++ {
+		if ctx.Err() != nil {
+			return ErrCampaignPaused
+		}
+		// The audience may have grown past the estimate
+		if err := c.awaitWindow(ctx, lastSent, max(chunks-sent, 1)); err != nil {
+			return err
+		}
+		tokens, err := c.Audience.Tokens(ctx, checkpoint.Cursor, limit)
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+		messages := make([]PushMessage, len(tokens))
+		for i := range tokens {
+			messages[i] = c.Template
+			messages[i].To = tokens[i : i+1 : i+1]
+		}
+		if c.Holdout > 0 {
+			var heldOut []ExponentPushToken
+			messages, heldOut = holdOut(c.ID, messages, c.Holdout)
+			c.mu.Lock()
+			c.heldOut = append(c.heldOut, heldOut...)
+			c.mu.Unlock()
+			c.emitHeldOut(heldOut)
+		}
+		if c.Quota != nil && len(messages) > 0 {
+			decision, err := c.Quota.Admit(ctx, c.Tenant, messages)
+			if decision != nil {
+				c.mu.Lock()
+				c.decision = decision
+				c.mu.Unlock()
+			}
+			if err != nil {
+				return err
+			}
+			messages = decision.Messages
+		}
+		var responses []PushResponse
+		if len(messages) > 0 {
+			if until := c.Blackout.deferChunk(time.Now(), messages); !until.IsZero() {
+				if err := sleepUntil(ctx, until); err != nil {
+					return ErrCampaignPaused
+				}
+			}
+			// Pausing cancels ctx, but a chunk already started is completed
+			responses, err = c.Client.PublishMultipleContext(context.WithoutCancel(ctx), messages,
+				WithLabels(map[string]string{LabelCampaign: c.ID}))
+			if err != nil {
+				return err
+			}
+		}
+		chunk := checkpoint.Chunks
+		checkpoint.Cursor = tokens[len(tokens)-1]
+		checkpoint.Chunks++
+		checkpoint.UpdatedAt = time.Now()
+		lastSent = checkpoint.UpdatedAt
+		c.advanceProgress()
+		// Use a fresh context so pausing mid-chunk still records the chunk
+		if err := c.Checkpoints.Save(context.WithoutCancel(ctx), checkpoint); err != nil {
+			return err
+		}
+		if c.OnChunk != nil && len(responses) > 0 {
+			c.OnChunk(chunk, responses)
+		}
+	}
+}
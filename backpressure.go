@@ -0,0 +1,65 @@
+package expo
+
+import "context"
+
+// SlowConsumerPolicy decides what happens to a result when the reader of a
+// result channel falls behind
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerBlock waits for the reader, stalling the producer. It is
+	// the default.
+	SlowConsumerBlock SlowConsumerPolicy = "block"
+	// SlowConsumerDrop drops the result and reports it to OnDrop, e.g. to
+	// count it in a metric
+	SlowConsumerDrop SlowConsumerPolicy = "drop"
+	// SlowConsumerSpill hands the result to Spill, e.g. to write it to a
+	// store the caller reads later
+	SlowConsumerSpill SlowConsumerPolicy = "spill"
+)
+
+// Backpressure bounds what a result channel holds when its reader is slow,
+// so memory stays predictable. Results of type T go to the channel until
+// it holds Buffer of them; the Policy then applies.
+type Backpressure[T any] struct {
+	Policy SlowConsumerPolicy
+	// Buffer is the capacity of the result channels created with this
+	// Backpressure. Channels provided by the caller keep their own.
+	Buffer int
+	// Spill receives the results the reader didn't take under
+	// SlowConsumerSpill
+	Spill func(result T) error
+	// OnDrop is called with the results dropped under SlowConsumerDrop, or
+	// that Spill failed to store
+	OnDrop func(result T)
+}
+
+// channel creates a result channel of Buffer capacity
+func (b *Backpressure[T]) channel() chan T {
+	return make(chan T, max(b.Buffer, 0))
+}
+
+// deliver sends result on out unless the channel is full and the policy
+// says otherwise. It returns false if ctx was done first.
+func (b *Backpressure[T]) deliver(ctx context.Context, out chan<- T, result T) bool {
+	if b.Policy == "" || b.Policy == SlowConsumerBlock {
+		select {
+		case out <- result:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	select {
+	case out <- result:
+		return true
+	default:
+	}
+	if b.Policy == SlowConsumerSpill && b.Spill != nil && b.Spill(result) == nil {
+		return true
+	}
+	if b.OnDrop != nil {
+		b.OnDrop(result)
+	}
+	return true
+}
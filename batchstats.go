@@ -0,0 +1,139 @@
+package expo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// BatchStats describes one request sent to Expo
+type BatchStats struct {
+	Messages   int
+	Recipients int
+	// PayloadBytes is the size of the JSON payload
+	PayloadBytes int
+	// WireBytes is the size of the request body sent, after compression
+	WireBytes int
+	// ResponseBytes is the size of the response body read
+	ResponseBytes int
+	Duration      time.Duration
+	Err           error
+}
+
+// CompressionSavings returns the bytes compression saved
+func (s BatchStats) CompressionSavings() int {
+	return s.PayloadBytes - s.WireBytes
+}
+
+// BatchObserver receives the stats of every request the client sends.
+// ObserveBatch is called synchronously, so it must not block for long.
+type BatchObserver interface {
+	ObserveBatch(stats BatchStats)
+}
+
+// BatchObserverFunc adapts a function to the BatchObserver interface
+type BatchObserverFunc func(stats BatchStats)
+
+// ObserveBatch calls f
+func (f BatchObserverFunc) ObserveBatch(stats BatchStats) {
+	f(stats)
+}
+
+// BatchSummary aggregates the stats of many requests
+type BatchSummary struct {
+	Requests       int64
+	FailedRequests int64
+	Messages       int64
+	Recipients     int64
+	PayloadBytes   int64
+	WireBytes      int64
+	ResponseBytes  int64
+	Duration       time.Duration
+}
+
+// CompressionSavings returns the bytes compression saved
+func (s BatchSummary) CompressionSavings() int64 {
+	return s.PayloadBytes - s.WireBytes
+}
+
+// CompressionRatio returns the share of payload bytes compression saved
+func (s BatchSummary) CompressionRatio() float64 {
+	if s.PayloadBytes == 0 {
+		return 0
+	}
+	return float64(s.CompressionSavings()) / float64(s.PayloadBytes)
+}
+
+// MessagesPerRequest returns the average batch size
+func (s BatchSummary) MessagesPerRequest() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Messages) / float64(s.Requests)
+}
+
+// WireBytesPerMessage returns the average bandwidth a message costs
+func (s BatchSummary) WireBytesPerMessage() float64 {
+	if s.Messages == 0 {
+		return 0
+	}
+	return float64(s.WireBytes) / float64(s.Messages)
+}
+
+// BatchReport is a BatchObserver summarizing requests, to quantify
+// bandwidth and tune batching settings. It counts with atomics, so
+// concurrent requests don't contend on it.
+type BatchReport struct {
+	requests       atomic.Int64
+	failedRequests atomic.Int64
+	messages       atomic.Int64
+	recipients     atomic.Int64
+	payloadBytes   atomic.Int64
+	wireBytes      atomic.Int64
+	responseBytes  atomic.Int64
+	duration       atomic.Int64
+}
+
+// ObserveBatch adds stats to the summary
+func (r *BatchReport) ObserveBatch(stats BatchStats) {
+	r.requests.Add(1)
+	if stats.Err != nil {
+		r.failedRequests.Add(1)
+	}
+	r.messages.Add(int64(stats.Messages))
+	r.recipients.Add(int64(stats.Recipients))
+	r.payloadBytes.Add(int64(stats.PayloadBytes))
+	r.wireBytes.Add(int64(stats.WireBytes))
+	r.responseBytes.Add(int64(stats.ResponseBytes))
+	r.duration.Add(int64(stats.Duration))
+}
+
+// Summary returns the summary so far. Requests observed while it runs may
+// be partially counted.
+func (r *BatchReport) Summary() BatchSummary {
+	return BatchSummary{
+		Requests:       r.requests.Load(),
+		FailedRequests: r.failedRequests.Load(),
+		Messages:       r.messages.Load(),
+		Recipients:     r.recipients.Load(),
+		PayloadBytes:   r.payloadBytes.Load(),
+		WireBytes:      r.wireBytes.Load(),
+		ResponseBytes:  r.responseBytes.Load(),
+		Duration:       time.Duration(r.duration.Load()),
+	}
+}
+
+// Reset clears the summary and returns what it held, e.g. to report
+// per interval. Nothing observed is lost; a request observed while it runs
+// may be split between this summary and the next.
+func (r *BatchReport) Reset() BatchSummary {
+	return BatchSummary{
+		Requests:       r.requests.Swap(0),
+		FailedRequests: r.failedRequests.Swap(0),
+		Messages:       r.messages.Swap(0),
+		Recipients:     r.recipients.Swap(0),
+		PayloadBytes:   r.payloadBytes.Swap(0),
+		WireBytes:      r.wireBytes.Swap(0),
+		ResponseBytes:  r.responseBytes.Swap(0),
+		Duration:       time.Duration(r.duration.Swap(0)),
+	}
+}
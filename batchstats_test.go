@@ -0,0 +1,91 @@
+package expo
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBatchReportCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected a gzipped body, got encoding %q", r.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages []PushMessage
+		if err := json.NewDecoder(zr).Decode(&messages); err != nil {
+			t.Error(err)
+		}
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	report := &BatchReport{}
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Compress: true, BatchObserver: report})
+	for i := 0; i < 2; i++ {
+		if _, err := client.PublishMultiple(testMessages(50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := report.Summary()
+	if s.Requests != 2 || s.Messages != 100 || s.MessagesPerRequest() != 50 {
+		t.Errorf("Unexpected summary %+v", s)
+	}
+	if s.WireBytes >= s.PayloadBytes || s.CompressionRatio() <= 0 || s.ResponseBytes == 0 {
+		t.Errorf("Expected compression savings, got %+v", s)
+	}
+}
+
+func TestCompressionFallback(t *testing.T) {
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Compress: true})
+	for i := 0; i < 2; i++ {
+		if _, err := client.PublishMultiple(testMessages(2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The rejection is remembered, so the second batch goes out uncompressed
+	if len(encodings) != 3 || encodings[0] != "gzip" || encodings[1] != "" || encodings[2] != "" {
+		t.Errorf("Unexpected encodings %q", encodings)
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		var messages []PushMessage
+		json.NewDecoder(body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Compress: true, CompressionThreshold: 1024})
+	for _, n := range []int{2, 50} {
+		if _, err := client.PublishMultiple(testMessages(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(encodings) != 2 || encodings[0] != "" || encodings[1] != "gzip" {
+		t.Errorf("Expected only the large batch to be compressed, got %q", encodings)
+	}
+}
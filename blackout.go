@@ -0,0 +1,59 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"slices"
+	"time"
+)
+
+// BlackoutWindow is a period, e.g. a holiday or maintenance, during which
+// non-urgent notifications are deferred
+type BlackoutWindow struct {
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+// BlackoutCalendar is a set of blackout windows with per-priority exemptions
+type BlackoutCalendar struct {
+	Windows []BlackoutWindow
+	// Exempt lists the priorities sent even during a blackout,
+	// e.g. HighPriority for transactional notifications
+	Exempt []string
+}
+
+// DeferUntil returns when a message with the given priority may be sent if
+// it is due at the given time, or the zero time if it may be sent right away.
+// Overlapping and adjacent windows are treated as a single blackout.
+func (b *BlackoutCalendar) DeferUntil(at time.Time, priority string) time.Time {
+	if b == nil || slices.Contains(b.Exempt, priority) {
+		return time.Time{}
+	}
+	until := at
+	for moved := true; moved; {
+		moved = false
+		for _, w := range b.Windows {
+			if !until.Before(w.Start) && until.Before(w.End) {
+				until = w.End
+				moved = true
+			}
+		}
+	}
+	if until.Equal(at) {
+		return time.Time{}
+	}
+	return until
+}
+
+// deferChunk returns when a chunk may be sent: after the blackout of its
+// latest deferred message, or the zero time if none is deferred
+func (b *BlackoutCalendar) deferChunk(at time.Time, messages []PushMessage) time.Time {
+	var until time.Time
+	for i := range messages {
+		if t := b.DeferUntil(at, messages[i].Priority); t.After(until) {
+			until = t
+		}
+	}
+	return until
+}
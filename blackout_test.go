@@ -0,0 +1,29 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlackoutCalendarDeferUntil(t *testing.T) {
+	start := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	calendar := &BlackoutCalendar{
+		Windows: []BlackoutWindow{
+			{Start: start, End: start.Add(24 * time.Hour), Reason: "Christmas Eve"},
+			{Start: start.Add(24 * time.Hour), End: start.Add(48 * time.Hour), Reason: "Christmas"},
+		},
+		Exempt: []string{HighPriority},
+	}
+	at := start.Add(time.Hour)
+	if until := calendar.DeferUntil(at, DefaultPriority); !until.Equal(start.Add(48 * time.Hour)) {
+		t.Errorf("Expected deferral past adjacent windows, got %v", until)
+	}
+	if until := calendar.DeferUntil(at, HighPriority); !until.IsZero() {
+		t.Error("Exempt priority was deferred")
+	}
+	if until := calendar.DeferUntil(start.Add(-time.Hour), DefaultPriority); !until.IsZero() {
+		t.Error("Message outside blackout was deferred")
+	}
+}
@@ -0,0 +1,58 @@
+package expo
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// DefaultMaxResponseBytes is the largest response body the client reads
+// unless configured otherwise
+const DefaultMaxResponseBytes = 4 << 20
+
+// ErrResponseTooLarge is returned when a response body exceeds the configured limit
+var ErrResponseTooLarge = newCodedError(ErrorCodeResponseTooLarge, "response body too large")
+
+const (
+	// maxDrainBytes is how much of an unread body is discarded so the
+	// connection can be reused; larger bodies are cheaper to abandon
+	maxDrainBytes = 64 << 10
+	// maxPooledBuffer is the capacity above which buffers are not pooled,
+	// so one huge response doesn't pin its memory
+	maxPooledBuffer = 1 << 20
+)
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// readBody reads at most limit bytes of body into a pooled buffer and closes
+// body. Callers must release the buffer with putBuffer once they no longer
+// use its bytes.
+func readBody(body io.ReadCloser, limit int64) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	_, err := buf.ReadFrom(io.LimitReader(body, limit+1))
+	if err == nil && int64(buf.Len()) > limit {
+		err = ErrResponseTooLarge
+	}
+	closeBody(body)
+	if err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// closeBody drains what is left of body, up to a limit, and closes it so
+// the underlying connection goes back to the pool instead of being torn down
+func closeBody(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
@@ -0,0 +1,45 @@
+package expo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestReadBodyLimit(t *testing.T) {
+	body := &trackingBody{Reader: strings.NewReader("0123456789")}
+	if _, err := readBody(body, 5); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Expected ErrResponseTooLarge, got %v", err)
+	}
+	if !body.closed {
+		t.Error("Body was not closed")
+	}
+	buf, err := readBody(io.NopCloser(strings.NewReader("01234")), 5)
+	if err != nil || buf.String() != "01234" {
+		t.Errorf("Incorrect body %q (%v)", buf, err)
+	}
+}
+
+func BenchmarkReadBody(b *testing.B) {
+	payload := bytes.Repeat([]byte(`{"status":"ok","id":"XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX"},`), 100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, err := readBody(io.NopCloser(bytes.NewReader(payload)), DefaultMaxResponseBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(buf)
+	}
+}
@@ -0,0 +1,137 @@
+//go:build !expominimal
+
+package expo
+
+import "strings"
+
+// ErrUnclosedPlaceholder is returned by NewMessageBuilder when a template
+// opens a placeholder with {{ and never closes it
+var ErrUnclosedPlaceholder = newCodedError(ErrorCodeInvalidArgument, "unclosed {{ placeholder in template")
+
+// Recipient is a token and the values of the placeholders of its message
+type Recipient struct {
+	Token  ExponentPushToken
+	Values map[string]string
+}
+
+// MessageBuilder renders personalized copies of a template message for the
+// recipients of a campaign. The template's Title and Body can hold {{key}}
+// placeholders, replaced by each recipient's values; missing keys render
+// empty. The builder renders into buffers it reuses across calls, and the
+// messages of one Append share one allocation for their texts and one for
+// their recipients, so fanning out to millions of recipients costs a few
+// allocations per chunk rather than several per message. The messages
+// share the template's Data map, which must not be modified. A
+// MessageBuilder is not safe for concurrent use.
+type MessageBuilder struct {
+	template PushMessage
+	title    []segment
+	body     []segment
+	buf      []byte
+	// bounds holds the end of every rendered text in buf
+	bounds []int
+}
+
+// segment is a literal text, or a placeholder of key
+type segment struct {
+	text        string
+	key         string
+	placeholder bool
+}
+
+// NewMessageBuilder parses the placeholders of template. Its recipients
+// are ignored.
+func NewMessageBuilder(template PushMessage) (*MessageBuilder, error) {
+	title, err := parseTemplate(template.Title)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseTemplate(template.Body)
+	if err != nil {
+		return nil, err
+	}
+	template.To = nil
+	return &MessageBuilder{template: template, title: title, body: body}, nil
+}
+
+func parseTemplate(s string) ([]segment, error) {
+	var segments []segment
+	for {
+		i := strings.Index(s, "{{")
+		if i < 0 {
+			if s != "" {
+				segments = append(segments, segment{text: s})
+			}
+			return segments, nil
+		}
+		if i > 0 {
+			segments = append(segments, segment{text: s[:i]})
+		}
+		s = s[i+2:]
+		j := strings.Index(s, "}}")
+		if j < 0 {
+			return nil, ErrUnclosedPlaceholder
+		}
+		segments = append(segments, segment{key: strings.TrimSpace(s[:j]), placeholder: true})
+		s = s[j+2:]
+	}
+}
+
+// Append renders a message per recipient and appends them to dst. The
+// messages don't reference the builder's buffers, so it can be reused
+// right away, e.g. for the next chunk of recipients read from a store.
+func (b *MessageBuilder) Append(dst []PushMessage, recipients ...Recipient) []PushMessage {
+	titled, bodied := hasPlaceholder(b.title), hasPlaceholder(b.body)
+	b.buf = b.buf[:0]
+	b.bounds = b.bounds[:0]
+	for i := range recipients {
+		if titled {
+			b.buf = render(b.buf, b.title, recipients[i].Values)
+		}
+		b.bounds = append(b.bounds, len(b.buf))
+		if bodied {
+			b.buf = render(b.buf, b.body, recipients[i].Values)
+		}
+		b.bounds = append(b.bounds, len(b.buf))
+	}
+	// One string holds the texts of every message, which slice it
+	texts := string(b.buf)
+	tokens := make([]ExponentPushToken, len(recipients))
+	start := 0
+	for i := range recipients {
+		tokens[i] = recipients[i].Token
+		message := b.template
+		// The capacity is capped so appending to To doesn't overwrite the
+		// next message's token
+		message.To = tokens[i : i+1 : i+1]
+		if titled {
+			message.Title = texts[start:b.bounds[2*i]]
+		}
+		if bodied {
+			message.Body = texts[b.bounds[2*i]:b.bounds[2*i+1]]
+		}
+		start = b.bounds[2*i+1]
+		dst = append(dst, message)
+	}
+	return dst
+}
+
+func hasPlaceholder(segments []segment) bool {
+	for i := range segments {
+		if segments[i].placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+func render(buf []byte, segments []segment, values map[string]string) []byte {
+	for i := range segments {
+		if segments[i].placeholder {
+			buf = append(buf, values[segments[i].key]...)
+		} else {
+			buf = append(buf, segments[i].text...)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,65 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	b, err := NewMessageBuilder(PushMessage{Title: "Hi {{ name }}", Body: "{{count}} new items", Sound: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := b.Append(nil,
+		Recipient{Token: "ExponentPushToken[a]", Values: map[string]string{"name": "Ana", "count": "3"}},
+		Recipient{Token: "ExponentPushToken[b]", Values: map[string]string{"name": "Bo"}},
+	)
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	want := []PushMessage{
+		{To: []ExponentPushToken{"ExponentPushToken[a]"}, Title: "Hi Ana", Body: "3 new items", Sound: "default"},
+		{To: []ExponentPushToken{"ExponentPushToken[b]"}, Title: "Hi Bo", Body: " new items", Sound: "default"},
+	}
+	for i := range want {
+		got := messages[i]
+		if got.Title != want[i].Title || got.Body != want[i].Body || got.Sound != want[i].Sound ||
+			len(got.To) != 1 || got.To[0] != want[i].To[0] {
+			t.Errorf("Message %d is %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	// Messages outlive the buffers they were rendered in
+	b.Append(nil, Recipient{Token: "ExponentPushToken[c]", Values: map[string]string{"name": "Zed", "count": "9"}})
+	messages[0].To = append(messages[0].To, "ExponentPushToken[d]")
+	if messages[0].Title != "Hi Ana" || messages[1].To[0] != "ExponentPushToken[b]" {
+		t.Errorf("Messages changed after reuse: %+v", messages)
+	}
+
+	if _, err := NewMessageBuilder(PushMessage{Body: "Hi {{name"}); !errors.Is(err, ErrUnclosedPlaceholder) {
+		t.Errorf("Expected ErrUnclosedPlaceholder, got %v", err)
+	}
+}
+
+func TestMessageBuilderAllocs(t *testing.T) {
+	b, err := NewMessageBuilder(PushMessage{Title: "Hi {{name}}", Body: "Your order {{order}} shipped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := make([]Recipient, 100)
+	for i := range recipients {
+		recipients[i] = Recipient{
+			Token:  ExponentPushToken(fmt.Sprintf("ExponentPushToken[%d]", i)),
+			Values: map[string]string{"name": "Ana", "order": fmt.Sprint(i)},
+		}
+	}
+	dst := make([]PushMessage, 0, len(recipients))
+	b.Append(dst, recipients...)
+	// The texts and the tokens of the whole chunk
+	if allocs := testing.AllocsPerRun(100, func() { b.Append(dst[:0], recipients...) }); allocs > 2 {
+		t.Errorf("Append allocated %v times for %d messages, want 2", allocs, len(recipients))
+	}
+}
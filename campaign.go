@@ -0,0 +1,302 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ErrCampaignPaused is returned by Campaign.Run when the campaign was paused
+// before all chunks were sent
+var ErrCampaignPaused = newCodedError(ErrorCodeCampaignPaused, "campaign paused")
+
+// ErrCampaignRunning is returned when starting a campaign that is already running
+var ErrCampaignRunning = newCodedError(ErrorCodeConflict, "campaign already running")
+
+// CampaignState is the lifecycle state of a Campaign
+type CampaignState string
+
+const (
+	// CampaignPending is a campaign that has not started yet
+	CampaignPending CampaignState = "pending"
+	// CampaignRunning is a campaign that is sending chunks
+	CampaignRunning CampaignState = "running"
+	// CampaignPaused is a campaign stopped by Pause, a cancelled context or a
+	// failed chunk. Calling Run again resumes it.
+	CampaignPaused CampaignState = "paused"
+	// CampaignCompleted is a campaign whose chunks were all sent
+	CampaignCompleted CampaignState = "completed"
+)
+
+// Checkpoint records the progress of a campaign so it can resume where it stopped
+type Checkpoint struct {
+	CampaignID string
+	// Chunks is the total number of chunks in the campaign, or the number
+	// of chunks sent for a campaign with an Audience
+	Chunks int
+	// Sent holds the indexes of the chunks already accepted by Expo
+	Sent      map[int]bool
+	UpdatedAt time.Time
+	// Cursor is the last token sent to by a campaign with an Audience
+	Cursor ExponentPushToken
+	// Admitted is the number of leading Messages the Quota admitted when
+	// the campaign started. A resumed campaign with a Quota only sends them.
+	Admitted int
+}
+
+// CheckpointStore persists campaign checkpoints.
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns the checkpoint of a campaign, or nil if it has none
+	Load(ctx context.Context, campaignID string) (*Checkpoint, error)
+	// Save stores the checkpoint, replacing any previous one
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+}
+
+// Campaign sends a large list of messages in chunks. It can be paused, e.g.
+// during an app outage, and resumed later from the chunk where it stopped.
+type Campaign struct {
+	// ID identifies the campaign in the checkpoint store
+	ID       string
+	Messages []PushMessage
+	// Audience, when set, replaces Messages: Template is sent to each of
+	// its tokens, queried chunk by chunk at send time rather than frozen
+	// when the campaign is created. Resuming continues after the last
+	// token sent. Approval counts the audience when the campaign starts;
+	// Quota admits every chunk as it is sent.
+	Audience Audience
+	Template PushMessage
+	// ChunkSize is the number of messages per request, at most MaxMessagesPerRequest
+	ChunkSize int
+	Client    *PushClient
+	// Checkpoints stores progress. Defaults to an in-memory store.
+	Checkpoints CheckpointStore
+	// OnChunk is called with the responses of every chunk sent
+	OnChunk func(chunk int, responses []PushResponse)
+	// Blackout defers chunks holding non-exempt messages until the current
+	// blackout window ends
+	Blackout *BlackoutCalendar
+	// Quota checks the campaign against daily budgets when it starts, not
+	// when it resumes. Messages over budget are removed from Messages, on
+	// resume too, as recorded in the checkpoint.
+	Quota *Quota
+	// Tenant is the tenant whose budget the campaign counts against
+	Tenant string
+	// Approval holds large campaigns until someone other than RequestedBy
+	// approves them. It is checked when the campaign starts.
+	Approval *ApprovalGate
+	// RequestedBy is who launched the campaign
+	RequestedBy string
+	// Window restricts sending to a recurring period, e.g. 9:00 to 20:00,
+	// spreading the chunks evenly over it
+	Window *SendWindow
+	// Holdout is the share of recipients, from 0 to 1, left out of the
+	// campaign as a control group to measure its effect, e.g. 0.05. They
+	// are picked by a hash of the campaign ID and token, so a resumed
+	// campaign holds out the same ones, and reported to the client's
+	// EventSink as EventHeldOut events when the campaign starts.
+	Holdout float64
+
+	mu       sync.Mutex
+	heldOut  []ExponentPushToken
+	decision *QuotaDecision
+	state    CampaignState
+	cancel   context.CancelFunc
+	progress CampaignProgress
+	// resumedAt is the number of chunks already sent when the run started
+	resumedAt int
+}
+
+// State returns the lifecycle state of the campaign
+func (c *Campaign) State() CampaignState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == "" {
+		return CampaignPending
+	}
+	return c.state
+}
+
+// Pause stops the campaign after the chunk currently being sent
+func (c *Campaign) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Run sends the chunks not yet recorded as sent in the checkpoint store.
+// It returns ErrCampaignPaused if Pause was called before it finished; calling
+// Run again resumes the campaign.
+func (c *Campaign) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.mu.Lock()
+	if c.state == CampaignRunning {
+		c.mu.Unlock()
+		return ErrCampaignRunning
+	}
+	if c.Checkpoints == nil {
+		c.Checkpoints = NewMemoryCheckpointStore()
+	}
+	c.state = CampaignRunning
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	err := c.run(ctx)
+
+	c.mu.Lock()
+	c.cancel = nil
+	if err == nil {
+		c.state = CampaignCompleted
+	} else {
+		c.state = CampaignPaused
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// QuotaDecision returns how Quota handled the campaign, or nil if it wasn't
+// checked
+func (c *Campaign) QuotaDecision() *QuotaDecision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.decision
+}
+
+func (c *Campaign) run(ctx context.Context) error {
+	checkpoint, err := c.Checkpoints.Load(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	if c.Audience != nil {
+		return c.runAudience(ctx, checkpoint)
+	}
+	// Held out recipients are picked the same way on every run, so a
+	// resumed campaign has the same chunks
+	if c.Holdout > 0 {
+		var heldOut []ExponentPushToken
+		c.Messages, heldOut = holdOut(c.ID, c.Messages, c.Holdout)
+		c.mu.Lock()
+		c.heldOut = append(c.heldOut, heldOut...)
+		c.mu.Unlock()
+		if checkpoint == nil {
+			c.emitHeldOut(heldOut)
+		}
+	}
+	if checkpoint == nil && c.Approval != nil {
+		if err := c.Approval.check(ctx, c, countRecipients(c.Messages)); err != nil {
+			return err
+		}
+	}
+	if checkpoint == nil && c.Quota != nil {
+		decision, err := c.Quota.Admit(ctx, c.Tenant, c.Messages)
+		if decision != nil {
+			c.mu.Lock()
+			c.decision = decision
+			c.mu.Unlock()
+		}
+		if err != nil {
+			return err
+		}
+		c.Messages = decision.Messages
+	} else if checkpoint != nil && c.Quota != nil {
+		// Resuming from the untrimmed messages would shift the chunks and
+		// send the overflow
+		c.Messages = c.Messages[:min(checkpoint.Admitted, len(c.Messages))]
+	}
+	chunks := c.chunks()
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{CampaignID: c.ID, Chunks: len(chunks), Sent: make(map[int]bool)}
+		if c.Quota != nil {
+			checkpoint.Admitted = len(c.Messages)
+			// Resuming must not count the campaign against the budgets again
+			checkpoint.UpdatedAt = time.Now()
+			if err := c.Checkpoints.Save(ctx, checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+	c.startProgress(len(chunks), len(checkpoint.Sent))
+	var lastSent time.Time
+	for i, chunk := range chunks {
+		if checkpoint.Sent[i] {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ErrCampaignPaused
+		}
+		if err := c.awaitWindow(ctx, lastSent, len(chunks)-len(checkpoint.Sent)); err != nil {
+			return err
+		}
+		if until := c.Blackout.deferChunk(time.Now(), chunk); !until.IsZero() {
+			if err := sleepUntil(ctx, until); err != nil {
+				return ErrCampaignPaused
+			}
+		}
+		// Pausing cancels ctx, but a chunk already started is completed
+		responses, err := c.Client.PublishMultipleContext(context.WithoutCancel(ctx), chunk,
+			WithLabels(map[string]string{LabelCampaign: c.ID}))
+		if err != nil {
+			return err
+		}
+		checkpoint.Sent[i] = true
+		checkpoint.UpdatedAt = time.Now()
+		lastSent = checkpoint.UpdatedAt
+		c.advanceProgress()
+		// Use a fresh context so pausing mid-chunk still records the chunk
+		if err := c.Checkpoints.Save(context.WithoutCancel(ctx), checkpoint); err != nil {
+			return err
+		}
+		if c.OnChunk != nil {
+			c.OnChunk(i, responses)
+		}
+	}
+	return nil
+}
+
+func (c *Campaign) chunks() [][]PushMessage {
+	return chunkMessages(c.Messages, chunkSize(c.ChunkSize))
+}
+
+// MemoryCheckpointStore is an in-process CheckpointStore
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]*Checkpoint
+}
+
+// NewMemoryCheckpointStore creates an empty in-memory CheckpointStore
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]*Checkpoint)}
+}
+
+// Load returns a copy of the checkpoint of a campaign, or nil if it has none
+func (s *MemoryCheckpointStore) Load(_ context.Context, campaignID string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoint, ok := s.checkpoints[campaignID]
+	if !ok {
+		return nil, nil
+	}
+	return copyCheckpoint(checkpoint), nil
+}
+
+// Save stores a copy of the checkpoint
+func (s *MemoryCheckpointStore) Save(_ context.Context, checkpoint *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[checkpoint.CampaignID] = copyCheckpoint(checkpoint)
+	return nil
+}
+
+func copyCheckpoint(checkpoint *Checkpoint) *Checkpoint {
+	c := *checkpoint
+	c.Sent = make(map[int]bool, len(checkpoint.Sent))
+	for k, v := range checkpoint.Sent {
+		c.Sent[k] = v
+	}
+	return &c
+}
@@ -0,0 +1,97 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCampaignPauseAndResume(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	campaign := &Campaign{
+		ID:        "spring-sale",
+		Messages:  testMessages(25),
+		ChunkSize: 10,
+		Client:    NewPushClient(&ClientConfig{Host: srv.URL}),
+	}
+	campaign.OnChunk = func(chunk int, _ []PushResponse) {
+		if chunk == 0 {
+			campaign.Pause()
+		}
+	}
+	if err := campaign.Run(context.Background()); !errors.Is(err, ErrCampaignPaused) {
+		t.Fatalf("Expected pause, got %v", err)
+	}
+	if campaign.State() != CampaignPaused || calls.Load() != 1 {
+		t.Fatalf("Expected 1 chunk before pause, got %d (%s)", calls.Load(), campaign.State())
+	}
+
+	campaign.OnChunk = nil
+	if err := campaign.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if campaign.State() != CampaignCompleted || calls.Load() != 3 {
+		t.Errorf("Expected 3 chunks in total, got %d (%s)", calls.Load(), campaign.State())
+	}
+	checkpoint, _ := campaign.Checkpoints.Load(context.Background(), "spring-sale")
+	if checkpoint == nil || len(checkpoint.Sent) != 3 {
+		t.Errorf("Checkpoint not recorded: %+v", checkpoint)
+	}
+}
+
+func TestCampaignAudienceRefresh(t *testing.T) {
+	var sent []ExponentPushToken
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		for _, message := range messages {
+			sent = append(sent, message.To...)
+		}
+		return okTickets(messages)
+	})
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	put := func(token ExponentPushToken) {
+		store.Put(ctx, &TokenRecord{Token: token, State: TokenStateActive})
+	}
+	for i := range 15 {
+		put(ExponentPushToken(fmt.Sprintf("ExponentPushToken[%02d]", i)))
+	}
+	campaign := &Campaign{
+		ID:        "weekly-digest",
+		Audience:  StoreAudience(store, TokenFilter{State: TokenStateActive}),
+		Template:  PushMessage{Body: "Your week"},
+		ChunkSize: 10,
+		Client:    NewPushClient(&ClientConfig{Host: srv.URL}),
+	}
+	campaign.OnChunk = func(chunk int, _ []PushResponse) {
+		// The audience changes while the campaign is paused
+		put("ExponentPushToken[99]")
+		store.Delete(ctx, "ExponentPushToken[12]")
+		campaign.Pause()
+	}
+	if err := campaign.Run(ctx); !errors.Is(err, ErrCampaignPaused) {
+		t.Fatalf("Expected pause, got %v", err)
+	}
+	if p := campaign.Progress(); p.Chunks != 2 || p.ChunksSent != 1 {
+		t.Errorf("Unexpected progress %+v", p)
+	}
+
+	campaign.OnChunk = nil
+	if err := campaign.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 15 || sent[10] != "ExponentPushToken[10]" || sent[14] != "ExponentPushToken[99]" {
+		t.Errorf("Unexpected recipients %v", sent)
+	}
+	for _, token := range sent {
+		if token == "ExponentPushToken[12]" {
+			t.Error("Sent to a token deleted before its chunk")
+		}
+	}
+	checkpoint, _ := campaign.Checkpoints.Load(ctx, "weekly-digest")
+	if checkpoint == nil || checkpoint.Cursor != "ExponentPushToken[99]" || checkpoint.Chunks != 2 {
+		t.Errorf("Unexpected checkpoint %+v", checkpoint)
+	}
+}
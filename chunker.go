@@ -0,0 +1,241 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// ErrInvalidChunks is returned when a Chunker leaves out or repeats a
+// message, or returns a chunk larger than MaxMessagesPerRequest
+var ErrInvalidChunks = newCodedError(ErrorCodeInvalidArgument, "invalid chunks")
+
+// Chunker groups the messages of a publish call into requests. Each chunk
+// holds indices into messages; every index must be in exactly one chunk,
+// and a chunk holds at most MaxMessagesPerRequest messages. Chunks are
+// sent in order, and responses are returned in the order of the messages
+// whatever the grouping. Implementations must be deterministic, so a
+// retried call is grouped the same way.
+type Chunker interface {
+	Chunk(messages []PushMessage) [][]int
+}
+
+// ChunkerFunc adapts a function to the Chunker interface
+type ChunkerFunc func(messages []PushMessage) [][]int
+
+// Chunk calls f
+func (f ChunkerFunc) Chunk(messages []PushMessage) [][]int {
+	return f(messages)
+}
+
+// CountChunker splits messages, in order, into chunks of Size messages,
+// MaxMessagesPerRequest if zero. It is the default Chunker.
+type CountChunker struct {
+	Size int
+}
+
+// Chunk splits messages into consecutive chunks
+func (c CountChunker) Chunk(messages []PushMessage) [][]int {
+	return chunkIndices(sequence(len(messages)), chunkSize(c.Size))
+}
+
+// PayloadChunker splits messages, in order, into chunks whose JSON payload
+// stays under MaxBytes, for relays and proxies limiting request sizes.
+// A message larger than MaxBytes is sent alone.
+type PayloadChunker struct {
+	MaxBytes int
+}
+
+// Chunk splits messages into consecutive chunks of bounded size
+func (c PayloadChunker) Chunk(messages []PushMessage) [][]int {
+	var chunks [][]int
+	var chunk []int
+	// The brackets of the JSON array
+	size := 2
+	for i := range messages {
+		n := 1
+		if data, err := json.Marshal(&messages[i]); err == nil {
+			// The message and its comma
+			n = len(data) + 1
+		}
+		if len(chunk) > 0 && (size+n > c.MaxBytes || len(chunk) == MaxMessagesPerRequest) {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 2
+		}
+		chunk = append(chunk, i)
+		size += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// GroupChunker groups messages by Key before chunking each group with
+// Next, CountChunker if nil. Groups are sent in the order their first
+// message appears, unless Less orders them.
+type GroupChunker struct {
+	Key  func(message *PushMessage) string
+	Less func(a, b string) bool
+	Next Chunker
+}
+
+// Chunk groups messages by key and chunks every group
+func (c GroupChunker) Chunk(messages []PushMessage) [][]int {
+	var keys []string
+	groups := make(map[string][]int)
+	for i := range messages {
+		key := c.Key(&messages[i])
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	if c.Less != nil {
+		sort.SliceStable(keys, func(i, j int) bool { return c.Less(keys[i], keys[j]) })
+	}
+	next := c.Next
+	if next == nil {
+		next = CountChunker{}
+	}
+	var chunks [][]int
+	for _, key := range keys {
+		group := groups[key]
+		members := make([]PushMessage, len(group))
+		for i, index := range group {
+			members[i] = messages[index]
+		}
+		for _, chunk := range next.Chunk(members) {
+			for i := range chunk {
+				chunk[i] = group[chunk[i]]
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// ByExperience keeps the messages of different Expo projects in separate
+// requests, since Expo rejects requests mixing them. experience returns the
+// project of a message, e.g. "@owner/app" looked up from its token.
+func ByExperience(experience func(message *PushMessage) string) Chunker {
+	return GroupChunker{Key: experience}
+}
+
+// ByPriority sends high priority messages in chunks of their own, ahead of
+// the others, so transactional notifications don't wait behind bulk ones
+func ByPriority() Chunker {
+	return GroupChunker{
+		Key: func(message *PushMessage) string {
+			if message.Priority == HighPriority {
+				return HighPriority
+			}
+			return ""
+		},
+		Less: func(a, b string) bool { return a == HighPriority && b != HighPriority },
+	}
+}
+
+// splitGroups splits the chunks of messages whose JSON is larger than
+// maxBytes, keeping their order
+func splitGroups(messages []PushMessage, groups [][]int, maxBytes int) [][]int {
+	if maxBytes <= 0 {
+		return groups
+	}
+	split := make([][]int, 0, len(groups))
+	for _, group := range groups {
+		members := make([]PushMessage, len(group))
+		for i, index := range group {
+			members[i] = messages[index]
+		}
+		for _, chunk := range (PayloadChunker{MaxBytes: maxBytes}).Chunk(members) {
+			for i := range chunk {
+				chunk[i] = group[chunk[i]]
+			}
+			split = append(split, chunk)
+		}
+	}
+	return split
+}
+
+// sendSplitting sends chunk, in halves if the host rejects it as too large.
+// Later requests stay under half the rejected size. If a half fails, the
+// tickets of the messages delivered before it are returned with the error.
+func (c *PushClient) sendSplitting(ctx context.Context, chunk []PushMessage) ([]PushResponse, error) {
+	var tickets []PushResponse
+	err := c.retry(ctx, func() error {
+		var err error
+		tickets, err = c.send(ctx, chunk)
+		return err
+	})
+	var clientErr *ClientError
+	if len(chunk) < 2 || !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusRequestEntityTooLarge {
+		return tickets, err
+	}
+	if data, err := json.Marshal(chunk); err == nil {
+		c.lowerRequestBytes(int64(len(data) / 2))
+	}
+	half := len(chunk) / 2
+	first, err := c.sendSplitting(ctx, chunk[:half])
+	if err != nil {
+		return first, err
+	}
+	second, err := c.sendSplitting(ctx, chunk[half:])
+	return append(first, second...), err
+}
+
+// lowerRequestBytes lowers the request size bound to n
+func (c *PushClient) lowerRequestBytes(n int64) {
+	for {
+		current := c.requestBytes.Load()
+		if current > 0 && current <= n {
+			return
+		}
+		if c.requestBytes.CompareAndSwap(current, n) {
+			return
+		}
+	}
+}
+
+// chunkMessagesWith groups messages with chunker and checks the result
+func chunkMessagesWith(chunker Chunker, messages []PushMessage) ([][]int, error) {
+	chunks := chunker.Chunk(messages)
+	seen := make([]bool, len(messages))
+	count := 0
+	for _, chunk := range chunks {
+		if len(chunk) == 0 || len(chunk) > MaxMessagesPerRequest {
+			return nil, ErrInvalidChunks
+		}
+		for _, i := range chunk {
+			if i < 0 || i >= len(messages) || seen[i] {
+				return nil, ErrInvalidChunks
+			}
+			seen[i] = true
+			count++
+		}
+	}
+	if count != len(messages) {
+		return nil, ErrInvalidChunks
+	}
+	return chunks, nil
+}
+
+// sequence returns the indices 0 to n-1
+func sequence(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// chunkIndices splits indices into consecutive chunks of at most size
+func chunkIndices(indices []int, size int) [][]int {
+	var chunks [][]int
+	for start := 0; start < len(indices); start += size {
+		chunks = append(chunks, indices[start:min(start+size, len(indices))])
+	}
+	return chunks
+}
@@ -0,0 +1,177 @@
+package expo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkers(t *testing.T) {
+	messages := testMessages(5)
+	messages[1].Priority = HighPriority
+	messages[3].Priority = HighPriority
+	messages[2].Body = strings.Repeat("x", 200)
+	for i := range messages {
+		messages[i].Data = map[string]string{"app": []string{"a", "b"}[i%2]}
+	}
+	tests := []struct {
+		name    string
+		chunker Chunker
+		want    [][]int
+	}{
+		{"count", CountChunker{Size: 2}, [][]int{{0, 1}, {2, 3}, {4}}},
+		{"payload", PayloadChunker{MaxBytes: 200}, [][]int{{0, 1}, {2}, {3, 4}}},
+		{"priority", ByPriority(), [][]int{{1, 3}, {0, 2, 4}}},
+		{"experience", ByExperience(func(m *PushMessage) string { return m.Data["app"] }), [][]int{{0, 2, 4}, {1, 3}}},
+		{"nested", GroupChunker{Key: func(m *PushMessage) string { return m.Data["app"] }, Next: CountChunker{Size: 2}}, [][]int{{0, 2}, {4}, {1, 3}}},
+	}
+	for _, tt := range tests {
+		if got := tt.chunker.Chunk(messages); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPublishWithChunker(t *testing.T) {
+	var first []PushMessage
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		if first == nil {
+			first = messages
+		}
+		r := okTickets(messages)
+		for i := range r.Data {
+			r.Data[i].ID = string(messages[i].To[0])
+		}
+		return r
+	})
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Chunker: ByPriority()})
+	messages := testMessages(4)
+	messages[2].Priority = HighPriority
+	responses, err := client.PublishMultiple(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || len(first) != 1 || first[0].Priority != HighPriority {
+		t.Errorf("Expected the high priority message to be sent first, alone")
+	}
+	for i, r := range responses {
+		if r.ID != string(messages[i].To[0]) {
+			t.Errorf("Expected responses in message order, got %s at %d", r.ID, i)
+		}
+	}
+
+	invalid := ChunkerFunc(func(messages []PushMessage) [][]int { return [][]int{{0, 0}} })
+	client = NewPushClient(&ClientConfig{Host: srv.URL, Chunker: invalid})
+	if _, err := client.PublishMultiple(testMessages(2)); !errors.Is(err, ErrInvalidChunks) {
+		t.Errorf("Expected ErrInvalidChunks, got %v", err)
+	}
+}
+
+func TestPublishMaxRequestBytes(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	defer srv.Close()
+	messages := testMessages(6)
+	for i := range messages {
+		messages[i].Body = strings.Repeat("x", 1000)
+	}
+	client := NewPushClient(&ClientConfig{Host: srv.URL, MaxRequestBytes: 2500})
+	responses, err := client.PublishMultiple(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 || len(responses) != 6 {
+		t.Errorf("Expected 3 requests of 2 messages, got %d requests", calls.Load())
+	}
+}
+
+func TestPublishSplitsRejectedChunks(t *testing.T) {
+	const limit = 3000
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		sizes = append(sizes, len(data))
+		if len(data) > limit {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		var messages []PushMessage
+		json.Unmarshal(data, &messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	messages := testMessages(8)
+	for i := range messages {
+		messages[i].Body = strings.Repeat("x", 600)
+	}
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	responses, err := client.PublishMultiple(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range responses {
+		if r.Status != SuccessStatus || r.PushMessage.To[0] != messages[i].To[0] {
+			t.Errorf("Incorrect response %d: %+v", i, r)
+		}
+	}
+	// Later calls are split from the start
+	sizes = nil
+	if _, err := client.PublishMultiple(messages); err != nil {
+		t.Fatal(err)
+	}
+	if sizes[0] > limit {
+		t.Errorf("Expected the rejected size remembered, got a %d bytes request first", sizes[0])
+	}
+}
+
+func TestPublishSplitKeepsDeliveredHalf(t *testing.T) {
+	const limit = 3000
+	messages := testMessages(8)
+	for i := range messages {
+		messages[i].Body = strings.Repeat("x", 600)
+	}
+	failing := true
+	var sent []ExponentPushToken
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if len(data) > limit {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		var batch []PushMessage
+		json.Unmarshal(data, &batch)
+		if failing && batch[0].To[0] == messages[4].To[0] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, m := range batch {
+			sent = append(sent, m.To...)
+		}
+		json.NewEncoder(w).Encode(okTickets(batch))
+	}))
+	defer srv.Close()
+	client := New(WithHost(srv.URL), WithDedup(time.Hour, nil))
+	responses, err := client.PublishMultiple(messages)
+	if err == nil || len(responses) != 8 {
+		t.Fatalf("Expected the second half to fail, got %d responses, %v", len(responses), err)
+	}
+	for i, r := range responses {
+		if delivered := i < 4; delivered != (r.Status == SuccessStatus) {
+			t.Errorf("Incorrect response %d: %+v", i, r)
+		}
+	}
+	// The retry only sends the half that failed
+	failing = false
+	sent = nil
+	if _, err := client.PublishMultiple(messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 4 || sent[0] != messages[4].To[0] {
+		t.Errorf("Expected only the failed half sent again, got %v", sent)
+	}
+}
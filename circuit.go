@@ -0,0 +1,163 @@
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults of CircuitBreaker
+const (
+	DefaultCircuitFailures = 5
+	DefaultCircuitCooldown = 30 * time.Second
+)
+
+// DivertedStatus is the status of the tickets of messages a Diverter took
+// while the circuit was open. They were not sent yet.
+const DivertedStatus = "diverted"
+
+// ErrCircuitOpen fails the publish calls made while the circuit is open,
+// unless a Diverter takes their messages
+var ErrCircuitOpen = newCodedError(ErrorCodeCircuitOpen, "circuit open: requests to expo are failing")
+
+// CircuitBreaker stops sending to Expo after consecutive failed requests,
+// so calls fail fast during an incident instead of piling up in timeouts
+// and retries. Server errors, network errors, timeouts and invalid
+// responses count as failures; other errors, e.g. a 400 or 429, show that
+// Expo is up. Once Cooldown passed calls go through again: a success closes
+// the circuit, a failure opens it for another Cooldown.
+type CircuitBreaker struct {
+	// Failures is the number of consecutive failed requests that opens the
+	// circuit. Defaults to DefaultCircuitFailures.
+	Failures int
+	// Cooldown is how long the circuit stays open. Defaults to
+	// DefaultCircuitCooldown.
+	Cooldown time.Duration
+	// OnChange is called when the circuit opens or closes
+	OnChange func(open bool)
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+// Open reports whether the circuit is open, i.e. calls fail or are diverted
+// without reaching Expo
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.clock().Before(b.openUntil)
+}
+
+// Reset closes the circuit, e.g. once a health check shows that Expo
+// recovered
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	tripped := b.failures >= b.threshold()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.mu.Unlock()
+	if tripped && b.OnChange != nil {
+		b.OnChange(false)
+	}
+}
+
+// record records the outcome of a request, reporting whether it opened the
+// circuit
+func (b *CircuitBreaker) record(err error) bool {
+	if err != nil && !isIncident(err) {
+		err = nil
+	}
+	if err == nil {
+		b.Reset()
+		return false
+	}
+	b.mu.Lock()
+	b.failures++
+	now := b.clock()
+	opened := b.failures >= b.threshold() && !now.Before(b.openUntil)
+	if opened {
+		cooldown := b.Cooldown
+		if cooldown <= 0 {
+			cooldown = DefaultCircuitCooldown
+		}
+		b.openUntil = now.Add(cooldown)
+	}
+	b.mu.Unlock()
+	if opened && b.OnChange != nil {
+		b.OnChange(true)
+	}
+	return opened
+}
+
+func (b *CircuitBreaker) threshold() int {
+	if b.Failures > 0 {
+		return b.Failures
+	}
+	return DefaultCircuitFailures
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// isIncident reports whether err shows that Expo is down or unreachable
+func isIncident(err error) bool {
+	switch ErrorCodeOf(err) {
+	case ErrorCodeServerError, ErrorCodeNetwork, ErrorCodeTimeout, ErrorCodeInvalidResponse:
+		return true
+	}
+	return false
+}
+
+// Diverter takes the messages of publish calls made while the circuit is
+// open, e.g. to send them once Expo recovers, see IncidentQueue. It returns
+// a response per message, usually with DivertedStatus.
+type Diverter interface {
+	Divert(ctx context.Context, messages []PushMessage) ([]PushResponse, error)
+}
+
+// DiverterFunc adapts a function to the Diverter interface
+type DiverterFunc func(ctx context.Context, messages []PushMessage) ([]PushResponse, error)
+
+// Divert calls f
+func (f DiverterFunc) Divert(ctx context.Context, messages []PushMessage) ([]PushResponse, error) {
+	return f(ctx, messages)
+}
+
+type noDivertKey struct{}
+
+// withoutDivert makes the publish calls of ctx fail while the circuit is
+// open, e.g. so replayed messages aren't diverted again
+func withoutDivert(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noDivertKey{}, true)
+}
+
+// sendGuarded sends a chunk unless the circuit is open, in which case the
+// chunk is diverted or fails with ErrCircuitOpen
+func (c *PushClient) sendGuarded(ctx context.Context, messages []PushMessage) ([]PushResponse, error) {
+	if c.breaker == nil {
+		return c.sendSplitting(ctx, messages)
+	}
+	if c.breaker.Open() {
+		if c.diverter != nil && ctx.Value(noDivertKey{}) == nil {
+			return c.diverter.Divert(ctx, messages)
+		}
+		return nil, ErrCircuitOpen
+	}
+	tickets, err := c.sendSplitting(ctx, messages)
+	if c.breaker.record(err) && c.alertSink != nil {
+		c.raiseAlert(ctx, Alert{
+			Kind:    AlertCircuitOpen,
+			Time:    time.Now(),
+			Message: "requests to Expo keep failing, circuit opened",
+			Err:     err,
+			Labels:  CallLabels(ctx),
+		})
+	}
+	return tickets, err
+}
@@ -0,0 +1,77 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := true
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"data":[{"status":"ok","id":"t"}]}`))
+	}))
+	defer srv.Close()
+	now := time.Now()
+	var changes []bool
+	breaker := &CircuitBreaker{Failures: 2, Cooldown: time.Minute, OnChange: func(open bool) {
+		changes = append(changes, open)
+	}}
+	breaker.now = func() time.Time { return now }
+	var alerts []Alert
+	client := New(WithHost(srv.URL), WithCircuitBreaker(breaker, nil),
+		WithAlertSink(AlertSinkFunc(func(_ context.Context, alert Alert) error {
+			alerts = append(alerts, alert)
+			return nil
+		})))
+
+	for range 2 {
+		if _, err := client.Publish(&testMessages(1)[0]); ErrorCodeOf(err) != ErrorCodeServerError {
+			t.Fatalf("Expected a server error, got %v", err)
+		}
+	}
+	if !breaker.Open() || len(alerts) != 1 || alerts[0].Kind != AlertCircuitOpen {
+		t.Fatalf("Expected the circuit to open with an alert, got %v", alerts)
+	}
+	if _, err := client.Publish(&testMessages(1)[0]); !errors.Is(err, ErrCircuitOpen) || calls != 2 {
+		t.Errorf("Expected ErrCircuitOpen without a request, got %v after %d calls", err, calls)
+	}
+
+	// After the cooldown a call goes through, and its failure opens the circuit again
+	now = now.Add(time.Minute)
+	if _, err := client.Publish(&testMessages(1)[0]); ErrorCodeOf(err) != ErrorCodeServerError || !breaker.Open() {
+		t.Errorf("Expected the probe to fail and reopen the circuit, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	failing = false
+	if _, err := client.Publish(&testMessages(1)[0]); err != nil || breaker.Open() {
+		t.Errorf("Expected the probe to close the circuit, got %v", err)
+	}
+	if len(changes) != 3 || !changes[0] || !changes[1] || changes[2] {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+}
+
+func TestCircuitBreakerIgnoresRejections(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	breaker := &CircuitBreaker{Failures: 1}
+	client := New(WithHost(srv.URL), WithCircuitBreaker(breaker, nil))
+	if _, err := client.Publish(&testMessages(1)[0]); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if breaker.Open() {
+		t.Error("Expected a rejected request not to open the circuit")
+	}
+}
@@ -0,0 +1,210 @@
+package expo
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a PushClient created by New. Options set the
+// fields of a ClientConfig, so both styles stay interchangeable.
+type ClientOption func(*ClientConfig)
+
+// New creates a push client from options, applied in order. Without
+// options it talks to DefaultHost unauthenticated, like NewPushClient(nil).
+//
+//	client := expo.New(
+//		expo.WithAccessToken(token),
+//		expo.WithRetries(3),
+//		expo.WithRequestTimeout(10*time.Second),
+//	)
+func New(opts ...ClientOption) *PushClient {
+	var config ClientConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return NewPushClient(&config)
+}
+
+// WithConfig starts from an existing config, e.g. one loaded from a file.
+// Later options override its fields.
+func WithConfig(config ClientConfig) ClientOption {
+	return func(c *ClientConfig) {
+		*c = config
+	}
+}
+
+// WithHost sets the host requests are sent to, see ClientConfig.Host
+func WithHost(host string) ClientOption {
+	return func(c *ClientConfig) {
+		c.Host = host
+	}
+}
+
+// WithAPIVersion selects the Expo push API version
+func WithAPIVersion(version APIVersion) ClientOption {
+	return func(c *ClientConfig) {
+		c.APIVersion = version
+	}
+}
+
+// WithAccessToken authenticates requests with a fixed access token
+func WithAccessToken(token string) ClientOption {
+	return func(c *ClientConfig) {
+		c.AccessToken = token
+	}
+}
+
+// WithAccessTokenSource fetches the access token from source, see
+// ClientConfig.AccessTokenSource
+func WithAccessTokenSource(source SecretSource) ClientOption {
+	return func(c *ClientConfig) {
+		c.AccessTokenSource = source
+	}
+}
+
+// WithHTTPClient sends requests with client
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *ClientConfig) {
+		c.HTTPClient = client
+	}
+}
+
+// WithTransport carries requests over transport
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *ClientConfig) {
+		c.Transport = transport
+	}
+}
+
+// WithMiddleware wraps every request in middleware, see PushClient.Use
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(c *ClientConfig) {
+		c.Middleware = append(c.Middleware, middleware...)
+	}
+}
+
+// WithDryRun answers every request with synthetic results instead of
+// calling the host, see ClientConfig.DryRun
+func WithDryRun() ClientOption {
+	return func(c *ClientConfig) {
+		c.DryRun = true
+	}
+}
+
+// WithDeviceNotRegistered calls fn with every token reported dead, see
+// ClientConfig.OnDeviceNotRegistered
+func WithDeviceNotRegistered(fn func(token ExponentPushToken)) ClientOption {
+	return func(c *ClientConfig) {
+		c.OnDeviceNotRegistered = fn
+	}
+}
+
+// WithCircuitBreaker stops sending after consecutive failed requests, see
+// CircuitBreaker. A non-nil diverter takes the messages of the calls made
+// while the circuit is open instead of failing them.
+func WithCircuitBreaker(breaker *CircuitBreaker, diverter Diverter) ClientOption {
+	return func(c *ClientConfig) {
+		c.CircuitBreaker = breaker
+		c.Diverter = diverter
+	}
+}
+
+// WithRetries makes up to attempts attempts of requests that fail with a
+// transient error, with the default backoff. See WithRetryPolicy to tune it.
+func WithRetries(attempts int) ClientOption {
+	return func(c *ClientConfig) {
+		c.Retry.MaxAttempts = attempts
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *ClientConfig) {
+		c.Retry = policy
+	}
+}
+
+// WithRequestTimeout bounds every call, see ClientConfig.Timeout
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(c *ClientConfig) {
+		c.Timeout = timeout
+	}
+}
+
+// WithRateLimiter throttles requests by the number of notifications they hold
+func WithRateLimiter(limiter RateLimiter) ClientOption {
+	return func(c *ClientConfig) {
+		c.RateLimiter = limiter
+	}
+}
+
+// WithChunker groups the messages of a publish call into requests sent
+// concurrency at a time
+func WithChunker(chunker Chunker, concurrency int) ClientOption {
+	return func(c *ClientConfig) {
+		c.Chunker = chunker
+		c.ChunkConcurrency = concurrency
+	}
+}
+
+// WithCompression gzips request bodies of at least threshold bytes
+func WithCompression(threshold int) ClientOption {
+	return func(c *ClientConfig) {
+		c.Compress = true
+		c.CompressionThreshold = threshold
+	}
+}
+
+// WithDedup suppresses identical messages to a token within window, see
+// ClientConfig.DedupWindow. A nil cache keeps them in memory.
+func WithDedup(window time.Duration, cache DedupCache) ClientOption {
+	return func(c *ClientConfig) {
+		c.DedupWindow = window
+		c.DedupCache = cache
+	}
+}
+
+// WithEventSink receives a DeliveryEvent for every recipient
+func WithEventSink(sink EventSink) ClientOption {
+	return func(c *ClientConfig) {
+		c.EventSink = sink
+	}
+}
+
+// WithAlertSink receives alerts worth a human's attention
+func WithAlertSink(sink AlertSink) ClientOption {
+	return func(c *ClientConfig) {
+		c.AlertSink = sink
+	}
+}
+
+// WithLogger writes structured logs to logger, with push tokens redacted
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *ClientConfig) {
+		c.Logger = logger
+	}
+}
+
+// WithSlowRequestThreshold logs a warning for every request taking
+// threshold or longer, see ClientConfig.SlowRequestThreshold
+func WithSlowRequestThreshold(threshold time.Duration) ClientOption {
+	return func(c *ClientConfig) {
+		c.SlowRequestThreshold = threshold
+	}
+}
+
+// WithIDGenerator creates the client's identifiers with ids, see
+// ClientConfig.IDGenerator
+func WithIDGenerator(ids IDGenerator) ClientOption {
+	return func(c *ClientConfig) {
+		c.IDGenerator = ids
+	}
+}
+
+// WithErrorReporter receives errors from background workers using the client
+func WithErrorReporter(reporter ErrorReporter) ClientOption {
+	return func(c *ClientConfig) {
+		c.ErrorReporter = reporter
+	}
+}
@@ -0,0 +1,71 @@
+// Command expo provides tools around the expo package.
+//
+// Usage:
+//
+//	expo loadgen [flags]
+//
+// loadgen soak-tests a push pipeline, see package loadgen. Without -host it
+// targets an in-process fake Expo server, which measures the client alone.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	expo "github.com/montovaneli/go-expo-notification"
+	"github.com/montovaneli/go-expo-notification/expotest"
+	"github.com/montovaneli/go-expo-notification/loadgen"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var err error
+	switch os.Args[1] {
+	case "loadgen":
+		err = runLoadgen(ctx, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "expo:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: expo loadgen [flags]")
+	os.Exit(2)
+}
+
+func runLoadgen(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	host := flags.String("host", "", "base URL of a staging relay; an in-process fake server if empty")
+	accessToken := flags.String("access-token", "", "access token sent to the host")
+	config := loadgen.Config{}
+	flags.IntVar(&config.BatchSize, "batch", expo.MaxMessagesPerRequest, "messages per request")
+	flags.Float64Var(&config.QPS, "qps", loadgen.DefaultQPS, "requests started per second")
+	flags.DurationVar(&config.Duration, "duration", loadgen.DefaultDuration, "how long requests are started for")
+	flags.IntVar(&config.Concurrency, "concurrency", loadgen.DefaultConcurrency, "maximum requests in flight")
+	flags.Parse(args)
+
+	clientConfig := &expo.ClientConfig{Host: *host, AccessToken: *accessToken}
+	if *host == "" {
+		srv := expotest.NewServer()
+		defer srv.Close()
+		clientConfig = srv.Config()
+	}
+	config.Client = expo.NewPushClient(clientConfig)
+	report, err := loadgen.Run(ctx, config)
+	if err != nil {
+		return err
+	}
+	fmt.Println(report)
+	return nil
+}
@@ -0,0 +1,47 @@
+package expo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// compressionReprobe is how long compression stays off after a rejection
+// before it is tried again, in case the proxy rejecting it was fixed
+const compressionReprobe = time.Hour
+
+// compressionEnabled reports whether request bodies are gzipped
+func (c *PushClient) compressionEnabled() bool {
+	return c.compression && time.Now().UnixNano() >= c.compressionOffUntil.Load()
+}
+
+// disableCompression remembers that the host rejected a compressed body
+func (c *PushClient) disableCompression() {
+	c.compressionOffUntil.Store(time.Now().Add(compressionReprobe).UnixNano())
+}
+
+// rejectsCompression reports whether err is a rejection of the content
+// encoding of the request rather than of its content
+func rejectsCompression(err error) bool {
+	var clientErr *ClientError
+	return errors.As(err, &clientErr) && clientErr.StatusCode == http.StatusUnsupportedMediaType
+}
+
+// encodeRequest gzips the JSON body data if compress is set. It returns the
+// body and its content encoding.
+func encodeRequest(data []byte, compress bool) ([]byte, string, error) {
+	if !compress {
+		return data, "", nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "gzip", nil
+}
@@ -0,0 +1,150 @@
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SuppressedNoConsent is the suppression reason for a message to a token
+// without granted consent
+const SuppressedNoConsent = "NoConsent"
+
+// ErrConsentNotRequested is returned when confirming consent that was never
+// requested, or was revoked since
+var ErrConsentNotRequested = newCodedError(ErrorCodeNotFound, "consent not requested")
+
+// ConsentStatus is the opt-in status of a token
+type ConsentStatus string
+
+const (
+	// ConsentUnknown is the status of a token without a consent record
+	ConsentUnknown ConsentStatus = ""
+	// ConsentPending is a requested opt-in awaiting confirmation, the first
+	// step of a double opt-in
+	ConsentPending ConsentStatus = "pending"
+	// ConsentGranted allows notifications to the token
+	ConsentGranted ConsentStatus = "granted"
+	// ConsentRevoked is an opt-out
+	ConsentRevoked ConsentStatus = "revoked"
+)
+
+// ConsentChange is a change of consent status, kept as evidence
+type ConsentChange struct {
+	Status ConsentStatus
+	At     time.Time
+	// Proof references the evidence of the change, e.g. the ID of the
+	// confirmation email or of the settings screen event
+	Proof string
+}
+
+// ConsentRecord is the consent of the user owning a token
+type ConsentRecord struct {
+	Token  ExponentPushToken
+	UserID string
+	Status ConsentStatus
+	// History lists every change, oldest first
+	History []ConsentChange
+}
+
+// UpdatedAt returns the time of the last change
+func (r *ConsentRecord) UpdatedAt() time.Time {
+	if len(r.History) == 0 {
+		return time.Time{}
+	}
+	return r.History[len(r.History)-1].At
+}
+
+// ConsentStore persists consent records. Implementations must be safe for
+// concurrent use.
+type ConsentStore interface {
+	// Consent returns nil when the token has no record
+	Consent(ctx context.Context, token ExponentPushToken) (*ConsentRecord, error)
+	SaveConsent(ctx context.Context, record *ConsentRecord) error
+}
+
+// RequestConsent records a pending opt-in for token, to be confirmed with
+// ConfirmConsent
+func RequestConsent(ctx context.Context, store ConsentStore, token ExponentPushToken, userID, proof string) error {
+	return changeConsent(ctx, store, token, userID, ConsentPending, proof)
+}
+
+// ConfirmConsent grants consent requested with RequestConsent
+func ConfirmConsent(ctx context.Context, store ConsentStore, token ExponentPushToken, proof string) error {
+	record, err := store.Consent(ctx, token)
+	if err != nil {
+		return err
+	}
+	if record == nil || record.Status != ConsentPending {
+		return ErrConsentNotRequested
+	}
+	return changeConsent(ctx, store, token, record.UserID, ConsentGranted, proof)
+}
+
+// GrantConsent grants consent directly, for markets that only require a
+// single opt-in
+func GrantConsent(ctx context.Context, store ConsentStore, token ExponentPushToken, userID, proof string) error {
+	return changeConsent(ctx, store, token, userID, ConsentGranted, proof)
+}
+
+// RevokeConsent records an opt-out for token
+func RevokeConsent(ctx context.Context, store ConsentStore, token ExponentPushToken, proof string) error {
+	return changeConsent(ctx, store, token, "", ConsentRevoked, proof)
+}
+
+func changeConsent(ctx context.Context, store ConsentStore, token ExponentPushToken, userID string, status ConsentStatus, proof string) error {
+	record, err := store.Consent(ctx, token)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &ConsentRecord{Token: token}
+	}
+	if userID != "" {
+		record.UserID = userID
+	}
+	record.Status = status
+	record.History = append(record.History, ConsentChange{Status: status, At: time.Now(), Proof: proof})
+	return store.SaveConsent(ctx, record)
+}
+
+// MemoryConsentStore is an in-process ConsentStore
+type MemoryConsentStore struct {
+	mu      sync.RWMutex
+	records map[ExponentPushToken]ConsentRecord
+}
+
+// NewMemoryConsentStore creates an empty MemoryConsentStore
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{records: make(map[ExponentPushToken]ConsentRecord)}
+}
+
+// Consent returns the record of token
+func (s *MemoryConsentStore) Consent(_ context.Context, token ExponentPushToken) (*ConsentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[token]
+	if !ok {
+		return nil, nil
+	}
+	// Callers append to the history, which must not alias the stored one
+	record.History = append([]ConsentChange(nil), record.History...)
+	return &record, nil
+}
+
+// SaveConsent stores record
+func (s *MemoryConsentStore) SaveConsent(_ context.Context, record *ConsentRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Token] = *record
+	return nil
+}
+
+// consented reports whether token may receive notifications
+func (c *PushClient) consented(ctx context.Context, token ExponentPushToken) (bool, error) {
+	record, err := c.consent.Consent(ctx, token)
+	if err != nil || record == nil {
+		return false, err
+	}
+	return record.Status == ConsentGranted, nil
+}
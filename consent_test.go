@@ -0,0 +1,44 @@
+package expo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConsentDoubleOptIn(t *testing.T) {
+	ctx := context.Background()
+	srv, calls := newTestServer(t, okTickets)
+	store := NewMemoryConsentStore()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Consent: store})
+	message := testMessages(1)[0]
+	token := message.To[0]
+
+	if err := ConfirmConsent(ctx, store, token, "email-1"); err != ErrConsentNotRequested {
+		t.Errorf("Expected ErrConsentNotRequested, got %v", err)
+	}
+	if err := RequestConsent(ctx, store, token, "user-1", "signup-form"); err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.Publish(&message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Details["reason"] != SuppressedNoConsent || calls.Load() != 0 {
+		t.Errorf("Expected pending consent to block the send, got %+v", response)
+	}
+
+	if err := ConfirmConsent(ctx, store, token, "email-1"); err != nil {
+		t.Fatal(err)
+	}
+	if response, err = client.Publish(&message); err != nil || !response.isSuccess() {
+		t.Errorf("Expected the send to succeed, got %+v, %v", response, err)
+	}
+
+	if err := RevokeConsent(ctx, store, token, "settings"); err != nil {
+		t.Fatal(err)
+	}
+	record, _ := store.Consent(ctx, token)
+	if record.Status != ConsentRevoked || len(record.History) != 3 || record.UserID != "user-1" {
+		t.Errorf("Unexpected consent record %+v", record)
+	}
+}
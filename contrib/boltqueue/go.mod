@@ -0,0 +1,12 @@
+module github.com/montovaneli/go-expo-notification/contrib/boltqueue
+
+go 1.22.1
+
+require (
+	github.com/montovaneli/go-expo-notification v0.0.0-00010101000000-000000000000
+	go.etcd.io/bbolt v1.3.11
+)
+
+require golang.org/x/sys v0.22.0 // indirect
+
+replace github.com/montovaneli/go-expo-notification => ../..
@@ -0,0 +1,177 @@
+// Package boltqueue provides an expo.MessageQueue persisted in a bbolt
+// file, so the notifications waiting in an expo.QueuePublisher or an
+// expo.IncidentQueue survive restarts and deploys:
+//
+//	queue, err := boltqueue.Open("/var/lib/sender/queue.db", nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer queue.Close()
+//	publisher := &expo.QueuePublisher{Client: client, Queue: queue}
+package boltqueue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// messagesBucket holds the messages by sequence number, so a cursor
+	// walks them oldest first
+	messagesBucket = []byte("expo_messages")
+	// idsBucket maps the IDs of the messages to their sequence numbers
+	idsBucket = []byte("expo_message_ids")
+	// keysBucket maps the idempotency keys of the messages to their IDs
+	keysBucket = []byte("expo_message_keys")
+)
+
+// Queue is an expo.MessageQueue in three buckets of a bbolt database
+type Queue struct {
+	db *bolt.DB
+}
+
+var _ expo.MessageQueue = (*Queue)(nil)
+
+// Open opens, or creates, the database file at path and the queue in it.
+// Options default to a one second timeout to lock the file, which another
+// process may hold.
+func Open(path string, options *bolt.Options) (*Queue, error) {
+	if options == nil {
+		options = &bolt.Options{Timeout: time.Second}
+	}
+	db, err := bolt.Open(path, 0o600, options)
+	if err != nil {
+		return nil, err
+	}
+	q, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// New creates the queue in an open database, e.g. one shared with other
+// stores of the program
+func New(db *bolt.DB) (*Queue, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{messagesBucket, idsBucket, keysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{db: db}, nil
+}
+
+// Push appends messages to the queue in a single transaction, synced to
+// disk before it returns. Messages whose idempotency key is already queued
+// are dropped.
+func (q *Queue) Push(_ context.Context, messages []expo.QueuedMessage) ([]string, error) {
+	var duplicates []string
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		duplicates = nil
+		b, ids, keys := tx.Bucket(messagesBucket), tx.Bucket(idsBucket), tx.Bucket(keysBucket)
+		for i := range messages {
+			if key := messages[i].Message.IdempotencyKey; key != "" {
+				if keys.Get([]byte(key)) != nil {
+					duplicates = append(duplicates, messages[i].ID)
+					continue
+				}
+				if err := keys.Put([]byte(key), []byte(messages[i].ID)); err != nil {
+					return err
+				}
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(messages[i])
+			if err != nil {
+				return err
+			}
+			key := binary.BigEndian.AppendUint64(nil, seq)
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+			if err := ids.Put([]byte(messages[i].ID), key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// Peek returns up to n messages from the head of the queue
+func (q *Queue) Peek(_ context.Context, n int) ([]expo.QueuedMessage, error) {
+	var messages []expo.QueuedMessage
+	err := q.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(messagesBucket).Cursor()
+		for k, v := c.First(); k != nil && len(messages) < n; k, v = c.Next() {
+			var m expo.QueuedMessage
+			if err := json.Unmarshal(v, &m); err != nil {
+				return err
+			}
+			messages = append(messages, m)
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// Remove deletes messages by ID
+func (q *Queue) Remove(_ context.Context, ids []string) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		b, index, keys := tx.Bucket(messagesBucket), tx.Bucket(idsBucket), tx.Bucket(keysBucket)
+		for _, id := range ids {
+			key := index.Get([]byte(id))
+			if key == nil {
+				continue
+			}
+			var m expo.QueuedMessage
+			if err := json.Unmarshal(b.Get(key), &m); err != nil {
+				return err
+			}
+			if m.Message.IdempotencyKey != "" {
+				if err := keys.Delete([]byte(m.Message.IdempotencyKey)); err != nil {
+					return err
+				}
+			}
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+			if err := index.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Len returns the number of queued messages
+func (q *Queue) Len(context.Context) (int, error) {
+	var n int
+	err := q.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(messagesBucket).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// Close closes the database
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
@@ -0,0 +1,78 @@
+package boltqueue
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func TestQueueSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.db")
+	q, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []expo.QueuedMessage
+	for _, id := range []string{"c", "a", "b"} {
+		messages = append(messages, expo.QueuedMessage{ID: id, EnqueuedAt: time.Now(), Message: expo.PushMessage{
+			To: []expo.ExponentPushToken{"ExponentPushToken[" + expo.ExponentPushToken(id) + "]"}, Body: id, CorrelationID: "corr-" + id}})
+	}
+	if _, err := q.Push(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Remove(ctx, []string{"a", "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q, err = Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if n, err := q.Len(ctx); n != 2 || err != nil {
+		t.Errorf("Expected 2 messages, got %d, %v", n, err)
+	}
+	head, err := q.Peek(ctx, 10)
+	if err != nil || len(head) != 2 || head[0].ID != "c" || head[1].ID != "b" || head[1].Message.CorrelationID != "corr-b" {
+		t.Errorf("Expected c then b, got %+v, %v", head, err)
+	}
+	if head, _ := q.Peek(ctx, 1); len(head) != 1 || head[0].ID != "c" {
+		t.Errorf("Expected the oldest message, got %+v", head)
+	}
+}
+
+func TestQueueIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	q, err := Open(filepath.Join(t.TempDir(), "queue.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	message := expo.PushMessage{To: []expo.ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi", IdempotencyKey: "k"}
+	push := func(id string) []string {
+		duplicates, err := q.Push(ctx, []expo.QueuedMessage{{ID: id, Message: message}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return duplicates
+	}
+	push("a")
+	if duplicates := push("b"); len(duplicates) != 1 || duplicates[0] != "b" {
+		t.Errorf("Expected b dropped, got %v", duplicates)
+	}
+	if head, _ := q.Peek(ctx, 10); len(head) != 1 || head[0].ID != "a" {
+		t.Fatalf("Expected only the first message queued, got %+v", head)
+	}
+	if err := q.Remove(ctx, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	push("c")
+	if n, _ := q.Len(ctx); n != 1 {
+		t.Errorf("Expected the key queued again once removed, got %d messages", n)
+	}
+}
@@ -0,0 +1,160 @@
+// Package collector exposes the activity of expo push clients as
+// Prometheus metrics: notifications by outcome, ticket errors by code,
+// request latency and retries.
+//
+//	metrics := collector.New("myapp")
+//	prometheus.MustRegister(metrics)
+//	config := expo.ClientConfig{AccessToken: token}
+//	metrics.Instrument(&config)
+//	client := expo.NewPushClient(&config)
+//
+// One Collector can instrument many clients.
+package collector
+
+import (
+	"errors"
+	"strconv"
+
+	expo "github.com/montovaneli/go-expo-notification"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector fed by the hooks of push clients. It
+// is an expo.EventSink and an expo.BatchObserver, and counts retries with
+// ObserveRetry.
+type Collector struct {
+	notifications *prometheus.CounterVec
+	ticketErrors  *prometheus.CounterVec
+	requests      *prometheus.HistogramVec
+	retries       *prometheus.CounterVec
+}
+
+// New creates a Collector whose metrics are prefixed with namespace, if
+// not empty
+func New(namespace string) *Collector {
+	return &Collector{
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "expo",
+			Name:      "notifications_total",
+			Help:      "Notifications by outcome: sent, failed or suppressed, one per recipient.",
+		}, []string{"outcome"}),
+		ticketErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "expo",
+			Name:      "ticket_errors_total",
+			Help:      "Failed notifications by error code.",
+		}, []string{"code"}),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "expo",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests sent to Expo, one per chunk, by status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"status"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "expo",
+			Name:      "retries_total",
+			Help:      "Retried requests by the error code that caused the retry.",
+		}, []string{"code"}),
+	}
+}
+
+// Instrument sets the hooks of config to feed the collector, keeping the
+// event sink, batch observer and retry hook already set
+func (c *Collector) Instrument(config *expo.ClientConfig) {
+	if config.EventSink != nil {
+		config.EventSink = expo.MultiEventSink(config.EventSink, c)
+	} else {
+		config.EventSink = c
+	}
+	if observer := config.BatchObserver; observer != nil {
+		config.BatchObserver = expo.BatchObserverFunc(func(stats expo.BatchStats) {
+			observer.ObserveBatch(stats)
+			c.ObserveBatch(stats)
+		})
+	} else {
+		config.BatchObserver = c
+	}
+	if onRetry := config.Retry.OnRetry; onRetry != nil {
+		config.Retry.OnRetry = func(attempt int, err error) {
+			onRetry(attempt, err)
+			c.ObserveRetry(attempt, err)
+		}
+	} else {
+		config.Retry.OnRetry = c.ObserveRetry
+	}
+}
+
+// HandleEvent counts a notification by outcome, and its error code if it failed
+func (c *Collector) HandleEvent(event expo.DeliveryEvent) {
+	switch event.Type {
+	case expo.EventSent, expo.EventFailed, expo.EventSuppressed:
+		c.notifications.WithLabelValues(string(event.Type)).Inc()
+	}
+	if event.Type == expo.EventFailed {
+		c.ticketErrors.WithLabelValues(event.ErrorCode).Inc()
+	}
+}
+
+// ObserveBatch records the latency of a request
+func (c *Collector) ObserveBatch(stats expo.BatchStats) {
+	c.requests.WithLabelValues(statusLabel(stats.Err)).Observe(stats.Duration.Seconds())
+}
+
+// ObserveRetry counts a retry, for use as expo.RetryPolicy.OnRetry
+func (c *Collector) ObserveRetry(_ int, err error) {
+	c.retries.WithLabelValues(string(expo.ErrorCodeOf(err))).Inc()
+}
+
+// statusLabel returns the HTTP status of a request's outcome: "200" on
+// success, the status of an HTTP error, or the error code of other
+// failures, e.g. "Network"
+func statusLabel(err error) string {
+	if err == nil {
+		return "200"
+	}
+	if status := httpStatus(err); status != 0 {
+		return strconv.Itoa(status)
+	}
+	return string(expo.ErrorCodeOf(err))
+}
+
+// httpStatus returns the status of the HTTP error in err's chain, or 0
+func httpStatus(err error) int {
+	var auth *expo.AuthError
+	var rateLimit *expo.RateLimitError
+	var server *expo.ServerError
+	var client *expo.ClientError
+	var other *expo.HTTPError
+	switch {
+	case errors.As(err, &auth):
+		return auth.StatusCode
+	case errors.As(err, &rateLimit):
+		return rateLimit.StatusCode
+	case errors.As(err, &server):
+		return server.StatusCode
+	case errors.As(err, &client):
+		return client.StatusCode
+	case errors.As(err, &other):
+		return other.StatusCode
+	}
+	return 0
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.notifications.Describe(ch)
+	c.ticketErrors.Describe(ch)
+	c.requests.Describe(ch)
+	c.retries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.notifications.Collect(ch)
+	c.ticketErrors.Collect(ch)
+	c.requests.Collect(ch)
+	c.retries.Collect(ch)
+}
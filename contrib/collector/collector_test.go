@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	expo "github.com/montovaneli/go-expo-notification"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(expo.Response{Data: []expo.PushResponse{
+			{Status: expo.SuccessStatus, ID: "a"},
+			{Status: "error", Details: map[string]string{"error": expo.ErrorDeviceNotRegistered}},
+		}})
+	}))
+	defer srv.Close()
+
+	metrics := New("test")
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics)
+	config := expo.ClientConfig{Host: srv.URL, Retry: expo.RetryPolicy{MaxAttempts: 2, BaseDelay: 1}}
+	metrics.Instrument(&config)
+	client := expo.NewPushClient(&config)
+	_, err := client.PublishMultiple([]expo.PushMessage{
+		{To: []expo.ExponentPushToken{"ExponentPushToken[a]"}},
+		{To: []expo.ExponentPushToken{"ExponentPushToken[b]"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := testutil.ToFloat64(metrics.notifications.WithLabelValues("sent")); n != 1 {
+		t.Errorf("%v notifications sent, want 1", n)
+	}
+	if n := testutil.ToFloat64(metrics.ticketErrors.WithLabelValues(expo.ErrorDeviceNotRegistered)); n != 1 {
+		t.Errorf("%v DeviceNotRegistered errors, want 1", n)
+	}
+	if n := testutil.ToFloat64(metrics.retries.WithLabelValues(string(expo.ErrorCodeServerError))); n != 1 {
+		t.Errorf("%v retries, want 1", n)
+	}
+	if n := testutil.CollectAndCount(metrics, "test_expo_request_duration_seconds"); n != 2 {
+		t.Errorf("%d request series, want 2 (502 and 200)", n)
+	}
+}
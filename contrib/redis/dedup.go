@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// addScript sets KEYS[1] for ARGV[1] milliseconds unless it exists, and
+// reports whether it did
+const addScript = `
+if redis.call('SET', KEYS[1], '1', 'NX', 'PX', ARGV[1]) then
+	return 1
+end
+return 0
+`
+
+const forgetScript = `return redis.call('DEL', KEYS[1])`
+
+// DedupCache is an expo.DedupCache shared by every process using the same
+// prefix. It outlives them, so with idempotency keys a message sent right
+// before a crash isn't sent again once the process restarts.
+type DedupCache struct {
+	client Evaler
+	prefix string
+	// OnError is called with the errors of Redis. Add then reports the key
+	// as new, so messages are sent rather than lost while Redis is down.
+	OnError func(err error)
+}
+
+var _ expo.DedupCache = (*DedupCache)(nil)
+
+// NewDedupCache creates a cache storing its keys under prefix
+func NewDedupCache(client Evaler, prefix string) *DedupCache {
+	return &DedupCache{client: client, prefix: prefix}
+}
+
+// Add records key for ttl and reports whether it was not already present
+func (c *DedupCache) Add(key string, ttl time.Duration) bool {
+	result, err := c.client.Eval(context.Background(), addScript, []string{c.prefix + key}, max(ttl.Milliseconds(), 1))
+	if err != nil {
+		c.report(err)
+		return true
+	}
+	added, _ := result.(int64)
+	return added == 1
+}
+
+// Remove forgets key
+func (c *DedupCache) Remove(key string) {
+	if _, err := c.client.Eval(context.Background(), forgetScript, []string{c.prefix + key}); err != nil {
+		c.report(err)
+	}
+}
+
+func (c *DedupCache) report(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
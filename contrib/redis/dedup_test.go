@@ -0,0 +1,27 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	m, client := newTestRedis(t)
+	cache := NewDedupCache(client, "dedup:")
+	// A second process sees the keys of the first
+	other := NewDedupCache(client, "dedup:")
+	if !cache.Add("k", time.Minute) {
+		t.Fatal("Expected a new key")
+	}
+	if other.Add("k", time.Minute) {
+		t.Error("Expected the key to be present for the other process")
+	}
+	other.Remove("k")
+	if !cache.Add("k", time.Minute) {
+		t.Error("Expected the key added again once removed")
+	}
+	m.FastForward(2 * time.Minute)
+	if !other.Add("k", time.Minute) {
+		t.Error("Expected the key to expire")
+	}
+}
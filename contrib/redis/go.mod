@@ -0,0 +1,12 @@
+module github.com/montovaneli/go-expo-notification/contrib/redis
+
+go 1.22.1
+
+require (
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/montovaneli/go-expo-notification v0.0.0-00010101000000-000000000000
+)
+
+require github.com/yuin/gopher-lua v1.1.1 // indirect
+
+replace github.com/montovaneli/go-expo-notification => ../..
@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// testNow is the time of the Redis of the tests
+var testNow = time.Unix(1_700_000_000, 0)
+
+// newTestRedis starts an in-process Redis, frozen at a fixed time so
+// tests control expiries, and returns it with an Evaler speaking RESP to it
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, Evaler) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	m.SetTime(testNow)
+	conn, err := net.Dial("tcp", m.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	r := bufio.NewReader(conn)
+	var mu sync.Mutex
+	return m, EvalFunc(func(_ context.Context, script string, keys []string, args ...any) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		command := append([]any{"EVAL", script, len(keys)}, toAny(keys)...)
+		command = append(command, args...)
+		fmt.Fprintf(conn, "*%d\r\n", len(command))
+		for _, arg := range command {
+			s := fmt.Sprint(arg)
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(s), s)
+		}
+		return readReply(r)
+	})
+}
+
+func toAny(keys []string) []any {
+	out := make([]any, len(keys))
+	for i, key := range keys {
+		out[i] = key
+	}
+	return out
+}
+
+// readReply decodes a RESP reply the way Redis clients do: integers as
+// int64, bulk strings as string and arrays as []any
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("short reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, errors.New(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, _ := strconv.Atoi(body)
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, _ := strconv.Atoi(body)
+		items := make([]any, max(n, 0))
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
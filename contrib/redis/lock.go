@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// acquireScript takes the lock if it is free, or extends it if this owner
+// already holds it
+const acquireScript = `
+local owner = redis.call('GET', KEYS[1])
+if owner == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if owner == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`
+
+// releaseScript deletes the lock only if this owner holds it
+const releaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// LeaderLock is an expo.LeaderLock held through a Redis key that expires
+// unless the leader keeps renewing it
+type LeaderLock struct {
+	client Evaler
+	key    string
+	owner  string
+}
+
+// NewLeaderLock creates a lock on key with a random owner ID for this replica
+func NewLeaderLock(client Evaler, key string) *LeaderLock {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return &LeaderLock{client: client, key: key, owner: hex.EncodeToString(b[:])}
+}
+
+// TryAcquire tries to become, or remain, the leader for ttl
+func (l *LeaderLock) TryAcquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	result, err := l.client.Eval(ctx, acquireScript, []string{l.key}, l.owner, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	acquired, _ := result.(int64)
+	return acquired == 1, nil
+}
+
+// Release gives up leadership if this replica holds it
+func (l *LeaderLock) Release(ctx context.Context) error {
+	_, err := l.client.Eval(ctx, releaseScript, []string{l.key}, l.owner)
+	return err
+}
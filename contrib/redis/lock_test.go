@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaderLock(t *testing.T) {
+	m, client := newTestRedis(t)
+	ctx := context.Background()
+	a, b := NewLeaderLock(client, "leader"), NewLeaderLock(client, "leader")
+	if ok, err := a.TryAcquire(ctx, time.Second); !ok || err != nil {
+		t.Fatalf("Expected a to lead, got %v, %v", ok, err)
+	}
+	if ok, _ := b.TryAcquire(ctx, time.Second); ok {
+		t.Error("Expected b to follow while a leads")
+	}
+	if ok, _ := a.TryAcquire(ctx, time.Second); !ok {
+		t.Error("Expected a to keep leading")
+	}
+	// b can't release a's lock
+	b.Release(ctx)
+	if ok, _ := b.TryAcquire(ctx, time.Second); ok {
+		t.Error("Expected b's release to leave a's lock")
+	}
+	m.FastForward(2 * time.Second)
+	if ok, _ := b.TryAcquire(ctx, time.Second); !ok {
+		t.Error("Expected b to lead once a's lock expired")
+	}
+	b.Release(ctx)
+	if ok, _ := a.TryAcquire(ctx, time.Second); !ok {
+		t.Error("Expected a to lead once b released")
+	}
+}
@@ -0,0 +1,201 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// DefaultLease is how long a message peeked from a Queue stays hidden from
+// the other processes
+const DefaultLease = time.Minute
+
+// pushScript appends the messages in ARGV, triples of ID, JSON and
+// idempotency key, to the queue, numbering them with the counter in KEYS[4].
+// Messages whose key is already in KEYS[5] are dropped, and their IDs
+// returned.
+const pushScript = `
+local duplicates = {}
+for i = 1, #ARGV, 3 do
+	local key = ARGV[i + 2]
+	if key == '' or redis.call('HSETNX', KEYS[5], key, ARGV[i]) == 1 then
+		local seq = redis.call('INCR', KEYS[4])
+		redis.call('ZADD', KEYS[1], seq, ARGV[i])
+		redis.call('HSET', KEYS[2], ARGV[i], ARGV[i + 1])
+	else
+		table.insert(duplicates, ARGV[i])
+	end
+end
+return duplicates
+`
+
+// peekScript returns up to ARGV[1] messages from the head of the queue that
+// no process holds a lease on, and leases them for ARGV[2] milliseconds.
+// Expired leases are dropped first, so the messages of a process that died
+// are delivered again.
+const peekScript = `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local n = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', now)
+local ids = redis.call('ZRANGE', KEYS[1], 0, redis.call('ZCARD', KEYS[3]) + n - 1)
+local out = {}
+for _, id in ipairs(ids) do
+	if #out >= n then
+		break
+	end
+	if not redis.call('ZSCORE', KEYS[3], id) then
+		local message = redis.call('HGET', KEYS[2], id)
+		if message then
+			redis.call('ZADD', KEYS[3], now + tonumber(ARGV[2]), id)
+			table.insert(out, message)
+		else
+			redis.call('ZREM', KEYS[1], id)
+		end
+	end
+end
+return out
+`
+
+// removeScript deletes the messages whose IDs are in ARGV, and their
+// idempotency keys
+const removeScript = `
+for _, id in ipairs(ARGV) do
+	local message = redis.call('HGET', KEYS[2], id)
+	if message then
+		local key = cjson.decode(message).idempotencyKey
+		if key and redis.call('HGET', KEYS[5], key) == id then
+			redis.call('HDEL', KEYS[5], key)
+		end
+	end
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+	redis.call('ZREM', KEYS[3], id)
+end
+return 0
+`
+
+const lenScript = `return redis.call('ZCARD', KEYS[1])`
+
+// Queue is an expo.MessageQueue shared by every process using the same key,
+// e.g. by the QueuePublishers of several instances of a service. Peek
+// leases the messages it returns: the other processes skip them until they
+// are removed or the lease expires, so a message whose sender died before
+// removing it is sent again. Delivery is at least once, and in order within
+// a process but only roughly across processes. Messages whose idempotency
+// key is already queued are dropped.
+type Queue struct {
+	client Evaler
+	keys   []string
+	lease  time.Duration
+}
+
+var _ expo.MessageQueue = (*Queue)(nil)
+
+// NewQueue creates a queue stored under key. Its keys share a hash tag, so
+// it works with Redis Cluster. A zero lease defaults to DefaultLease; it
+// must be longer than sending a batch takes.
+func NewQueue(client Evaler, key string, lease time.Duration) *Queue {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	prefix := "{" + key + "}:"
+	return &Queue{
+		client: client,
+		keys:   []string{prefix + "queue", prefix + "messages", prefix + "leases", prefix + "seq", prefix + "keys"},
+		lease:  lease,
+	}
+}
+
+// Push appends messages to the queue, dropping those whose idempotency key
+// is already queued
+func (q *Queue) Push(ctx context.Context, messages []expo.QueuedMessage) ([]string, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	args := make([]any, 0, 3*len(messages))
+	for i := range messages {
+		data, err := json.Marshal(messages[i])
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, messages[i].ID, string(data), messages[i].Message.IdempotencyKey)
+	}
+	result, err := q.client.Eval(ctx, pushScript, q.keys, args...)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected queue result %T", result)
+	}
+	var duplicates []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			duplicates = append(duplicates, v)
+		case []byte:
+			duplicates = append(duplicates, string(v))
+		default:
+			return nil, fmt.Errorf("redis: unexpected duplicate ID %T", item)
+		}
+	}
+	return duplicates, nil
+}
+
+// Peek returns and leases up to n messages no other process holds
+func (q *Queue) Peek(ctx context.Context, n int) ([]expo.QueuedMessage, error) {
+	result, err := q.client.Eval(ctx, peekScript, q.keys, n, q.lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected queue result %T", result)
+	}
+	messages := make([]expo.QueuedMessage, len(items))
+	for i, item := range items {
+		var data []byte
+		switch v := item.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			return nil, fmt.Errorf("redis: unexpected queued message %T", item)
+		}
+		if err := json.Unmarshal(data, &messages[i]); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// Remove deletes messages by ID, releasing their leases
+func (q *Queue) Remove(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	_, err := q.client.Eval(ctx, removeScript, q.keys, args...)
+	return err
+}
+
+// Len returns the number of queued messages, leased ones included
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	result, err := q.client.Eval(ctx, lenScript, q.keys)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := result.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected queue length %T", result)
+	}
+	return int(n), nil
+}
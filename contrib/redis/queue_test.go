@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func testQueued(ids ...string) []expo.QueuedMessage {
+	messages := make([]expo.QueuedMessage, len(ids))
+	for i, id := range ids {
+		messages[i] = expo.QueuedMessage{ID: id, Message: expo.PushMessage{
+			To: []expo.ExponentPushToken{"ExponentPushToken[" + expo.ExponentPushToken(id) + "]"}, Body: id, CorrelationID: "corr-" + id}}
+	}
+	return messages
+}
+
+func peekIDs(t *testing.T, q *Queue, n int) []string {
+	t.Helper()
+	messages, err := q.Peek(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+	return ids
+}
+
+func TestQueueLeases(t *testing.T) {
+	m, client := newTestRedis(t)
+	ctx := context.Background()
+	first := NewQueue(client, "sender", time.Minute)
+	second := NewQueue(client, "sender", time.Minute)
+	if _, err := first.Push(ctx, testQueued("c", "a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	head, err := first.Peek(ctx, 2)
+	if err != nil || len(head) != 2 || head[0].ID != "c" || head[1].Message.CorrelationID != "corr-a" {
+		t.Fatalf("Expected c then a, got %+v, %v", head, err)
+	}
+	// The other process skips the leased messages
+	if ids := peekIDs(t, second, 10); len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("Expected only b unleased, got %v", ids)
+	}
+	if n, err := second.Len(ctx); n != 3 || err != nil {
+		t.Errorf("Expected 3 messages, leased ones included, got %d, %v", n, err)
+	}
+
+	// c is sent; a's sender dies and its lease expires
+	if err := first.Remove(ctx, []string{"c", "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetTime(testNow.Add(2 * time.Minute))
+	if ids := peekIDs(t, second, 10); len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("Expected a and b once their leases expired, got %v", ids)
+	}
+	if n, _ := second.Len(ctx); n != 2 {
+		t.Errorf("Expected 2 messages after Remove, got %d", n)
+	}
+}
+
+func TestQueueIdempotencyKey(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	q := NewQueue(client, "sender", time.Minute)
+	messages := testQueued("a", "b", "c")
+	messages[0].Message.IdempotencyKey = "k"
+	messages[1].Message.IdempotencyKey = "k"
+	if duplicates, err := q.Push(ctx, messages); err != nil || len(duplicates) != 1 || duplicates[0] != "b" {
+		t.Fatalf("Expected b dropped, got %v, %v", duplicates, err)
+	}
+	// Another process retrying the same notification
+	if duplicates, err := q.Push(ctx, testQueued("d")); err != nil || len(duplicates) != 0 {
+		t.Fatalf("Expected nothing dropped, got %v, %v", duplicates, err)
+	}
+	retry := testQueued("e")
+	retry[0].Message.IdempotencyKey = "k"
+	q.Push(ctx, retry)
+	if ids := peekIDs(t, q, 10); len(ids) != 3 || ids[0] != "a" || ids[1] != "c" || ids[2] != "d" {
+		t.Fatalf("Expected a, c and d, got %v", ids)
+	}
+	if err := q.Remove(ctx, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	q.Push(ctx, retry)
+	if n, _ := q.Len(ctx); n != 3 {
+		t.Errorf("Expected the key queued again once removed, got %d messages", n)
+	}
+}
@@ -0,0 +1,103 @@
+// Package redis provides Redis-backed implementations of the pluggable
+// expo interfaces, so several replicas of a sender can share state.
+//
+// The package does not depend on a Redis client library. Wrap your client
+// in an EvalFunc, e.g. with go-redis:
+//
+//	eval := redis.EvalFunc(func(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return rdb.Eval(ctx, script, keys, args...).Result()
+//	})
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Evaler runs a Lua script on a Redis server
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// EvalFunc adapts a function to the Evaler interface
+type EvalFunc func(ctx context.Context, script string, keys []string, args ...any) (any, error)
+
+// Eval calls f
+func (f EvalFunc) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return f(ctx, script, keys, args...)
+}
+
+// tokenBucketScript reserves ARGV[3] tokens from a bucket refilled at ARGV[1]
+// tokens per second up to ARGV[2], and returns how many milliseconds the
+// caller must wait before using them. The server clock is used so replicas
+// with skewed clocks still agree.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate) - n
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+if tokens >= 0 then
+	return 0
+end
+return math.ceil(-tokens / rate * 1000)
+`
+
+// refundScript gives ARGV[1] reserved tokens back to the bucket, up to
+// ARGV[2]
+const refundScript = `
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[2]), tokens + tonumber(ARGV[1]))))
+end
+return 0
+`
+
+// RateLimiter is an expo.RateLimiter whose budget is shared by every
+// process using the same key
+type RateLimiter struct {
+	client Evaler
+	key    string
+	rate   float64
+	burst  int
+}
+
+// NewRateLimiter creates a limiter allowing rate notifications per second,
+// with bursts of up to burst, across all processes using key
+func NewRateLimiter(client Evaler, key string, rate float64, burst int) *RateLimiter {
+	return &RateLimiter{client: client, key: key, rate: rate, burst: burst}
+}
+
+// Wait blocks until n notifications may be sent or ctx is done. When ctx
+// is done first, the n notifications reserved are given back.
+func (l *RateLimiter) Wait(ctx context.Context, n int) error {
+	result, err := l.client.Eval(ctx, tokenBucketScript, []string{l.key}, l.rate, l.burst, n)
+	if err != nil {
+		return err
+	}
+	wait, ok := result.(int64)
+	if !ok {
+		return fmt.Errorf("redis: unexpected rate limit result %T", result)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(wait) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// Give back the reservation. Should that fail, the bucket refills
+		// once the wait is over anyway.
+		l.client.Eval(context.WithoutCancel(ctx), refundScript, []string{l.key}, n, l.burst)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
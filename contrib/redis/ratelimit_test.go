@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	limiter := NewRateLimiter(client, "rate", 1, 2)
+	other := NewRateLimiter(client, "rate", 1, 2)
+	if err := limiter.Wait(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	// The burst is spent for every process sharing the key
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := other.Wait(ctx, 1); err == nil {
+		t.Error("Expected the other process to wait for the bucket to refill")
+	}
+}
+
+func TestRateLimiterReleasesOnCancel(t *testing.T) {
+	m, client := newTestRedis(t)
+	limiter := NewRateLimiter(client, "rate", 1, 2)
+	if err := limiter.Wait(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, 2); err == nil {
+		t.Fatal("Expected the wait to be canceled")
+	}
+	// Without the release, the canceled wait would leave the bucket 2 tokens
+	// in debt
+	tokens, err := strconv.ParseFloat(m.HGet("rate", "tokens"), 64)
+	if err != nil || tokens < -0.5 {
+		t.Errorf("Expected the reservation given back, got %v tokens, %v", tokens, err)
+	}
+}
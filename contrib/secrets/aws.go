@@ -0,0 +1,138 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// ErrNoCredentials is returned when no AWS credentials are configured
+var ErrNoCredentials = errors.New("no AWS credentials")
+
+// AWSCredentials sign requests to AWS
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSSecretsManager reads a secret from AWS Secrets Manager
+type AWSSecretsManager struct {
+	// Region defaults to $AWS_REGION
+	Region string
+	// SecretID is the name or ARN of the secret
+	SecretID string
+	// Key is the field holding the value when the secret is a JSON object
+	Key string
+	// Credentials returns the credentials to sign requests with. Defaults to
+	// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	// Endpoint overrides the regional endpoint, e.g. for VPC endpoints
+	Endpoint string
+	Client   *http.Client
+}
+
+var _ expo.SecretSource = (*AWSSecretsManager)(nil)
+
+// Secret reads the current version of the secret
+func (a *AWSSecretsManager) Secret(ctx context.Context) (string, error) {
+	credsFunc := a.Credentials
+	if credsFunc == nil {
+		credsFunc = envCredentials
+	}
+	creds, err := credsFunc(ctx)
+	if err != nil {
+		return "", err
+	}
+	region := a.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com/"
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": a.SecretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, creds, region, "secretsmanager", time.Now())
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doJSON(a.Client, req, &out); err != nil {
+		return "", err
+	}
+	return jsonField(out.SecretString, a.Key)
+}
+
+func envCredentials(context.Context) (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, ErrNoCredentials
+	}
+	return creds, nil
+}
+
+// signV4 signs req with AWS Signature Version 4. The request must have no
+// query string, which holds for the JSON APIs used here.
+func signV4(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	signed := "content-type;host;x-amz-date;x-amz-target"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		signed = "content-type;host;x-amz-date;x-amz-security-token;x-amz-target"
+		canonicalHeaders += "x-amz-security-token:" + creds.SessionToken + "\n"
+	}
+	canonicalHeaders += "x-amz-target:" + req.Header.Get("X-Amz-Target") + "\n"
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := req.Method + "\n" + path + "\n\n" + canonicalHeaders + "\n" + signed + "\n" + sha256Hex(body)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+signature)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
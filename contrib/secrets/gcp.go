@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+const gcpMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCPSecretManager reads a secret version from Google Cloud Secret Manager
+type GCPSecretManager struct {
+	// Name is the resource name of the version, e.g.
+	// "projects/my-project/secrets/expo-token/versions/latest"
+	Name string
+	// Key is the field holding the value when the secret is a JSON object
+	Key string
+	// Token returns an OAuth2 access token, e.g. from an oauth2.TokenSource.
+	// Defaults to the token of the instance's service account, from the
+	// metadata server.
+	Token  func(ctx context.Context) (string, error)
+	Client *http.Client
+}
+
+var _ expo.SecretSource = (*GCPSecretManager)(nil)
+
+// Secret accesses the secret version
+func (g *GCPSecretManager) Secret(ctx context.Context) (string, error) {
+	tokenFunc := g.Token
+	if tokenFunc == nil {
+		tokenFunc = g.metadataToken
+	}
+	token, err := tokenFunc(ctx)
+	if err != nil {
+		return "", err
+	}
+	url := "https://secretmanager.googleapis.com/v1/" + strings.TrimPrefix(g.Name, "/") + ":access"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var out struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doJSON(g.Client, req, &out); err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(out.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return jsonField(string(data), g.Key)
+}
+
+func (g *GCPSecretManager) metadataToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataToken, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(g.Client, req, &out); err != nil {
+		return "", err
+	}
+	return out.AccessToken, nil
+}
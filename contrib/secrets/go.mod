@@ -0,0 +1,7 @@
+module github.com/montovaneli/go-expo-notification/contrib/secrets
+
+go 1.22.1
+
+require github.com/montovaneli/go-expo-notification v0.0.0-00010101000000-000000000000
+
+replace github.com/montovaneli/go-expo-notification => ../..
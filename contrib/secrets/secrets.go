@@ -0,0 +1,63 @@
+// Package secrets provides expo.SecretSource implementations backed by
+// secret managers, to load the Expo access token without keeping it in
+// process arguments or environment variables:
+//
+//	client := expo.NewPushClient(&expo.ClientConfig{
+//		AccessTokenSource: &secrets.AWSSecretsManager{Region: "us-east-1", SecretID: "expo/access-token"},
+//	})
+//
+// The sources call the managers' HTTP APIs directly, so the package has no
+// dependencies beyond the standard library.
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrKeyNotFound is returned when a JSON secret has no value for the
+// configured key
+var ErrKeyNotFound = errors.New("key not found in secret")
+
+// maxSecretResponse bounds the size of responses read from secret managers
+const maxSecretResponse = 1 << 20
+
+// doJSON sends req and decodes the JSON response into v
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: unexpected status %s", req.Method, req.URL.Host, resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// jsonField returns the string value of key in a JSON object secret, or
+// the secret itself if key is empty
+func jsonField(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", err
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/expo" || r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"accessToken":"expo-token"}}}`))
+	}))
+	defer srv.Close()
+	v := &Vault{Addr: srv.URL, Token: "root", Path: "expo", Key: "accessToken"}
+	if token, err := v.Secret(context.Background()); err != nil || token != "expo-token" {
+		t.Errorf("Unexpected secret %q, %v", token, err)
+	}
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") {
+			t.Errorf("Unexpected authorization %q", auth)
+		}
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		if in["SecretId"] != "expo" || r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("Unexpected request %v", in)
+		}
+		w.Write([]byte(`{"SecretString":"{\"token\":\"expo-token\"}"}`))
+	}))
+	defer srv.Close()
+	a := &AWSSecretsManager{
+		Region:   "eu-west-1",
+		SecretID: "expo",
+		Key:      "token",
+		Endpoint: srv.URL,
+		Credentials: func(context.Context) (AWSCredentials, error) {
+			return AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		},
+	}
+	if token, err := a.Secret(context.Background()); err != nil || token != "expo-token" {
+		t.Errorf("Unexpected secret %q, %v", token, err)
+	}
+}
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// Vault reads a secret from a HashiCorp Vault KV version 2 engine
+type Vault struct {
+	// Addr is the Vault address. Defaults to $VAULT_ADDR.
+	Addr string
+	// Token authenticates to Vault. Defaults to $VAULT_TOKEN.
+	Token string
+	// Mount is the mount path of the KV engine. Defaults to "secret".
+	Mount string
+	// Path is the path of the secret within the engine, e.g. "expo"
+	Path string
+	// Key is the field of the secret holding the value, e.g. "accessToken"
+	Key    string
+	Client *http.Client
+}
+
+var _ expo.SecretSource = (*Vault)(nil)
+
+// Secret reads the latest version of the secret
+func (v *Vault) Secret(ctx context.Context) (string, error) {
+	addr := v.Addr
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	token := v.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	mount := v.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(addr, "/"), mount, strings.TrimPrefix(v.Path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	var out struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := doJSON(v.Client, req, &out); err != nil {
+		return "", err
+	}
+	value, ok := out.Data.Data[v.Key].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, v.Key)
+	}
+	return value, nil
+}
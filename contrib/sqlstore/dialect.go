@@ -0,0 +1,53 @@
+// Package sqlstore provides database/sql implementations of the expo
+// stores: tokens, the event outbox, scheduled messages and a delivery event
+// log. Their tables are created and upgraded by versioned migrations:
+//
+//	db, _ := sql.Open("pgx", dsn)
+//	m := sqlstore.NewMigrator(db, sqlstore.Postgres)
+//	planned, _ := m.Plan(ctx) // dry run
+//	applied, err := m.Migrate(ctx)
+//
+// The package doesn't import a driver; register one in your program.
+package sqlstore
+
+import "strconv"
+
+// Dialect holds the differences between the supported databases
+type Dialect struct {
+	Name string
+	// serial is the column definition of auto-incremented keys
+	serial string
+	// numbered placeholders ($1) instead of question marks
+	numbered bool
+	// tableQuery counts the tables named by its argument
+	tableQuery string
+}
+
+// Supported dialects
+var (
+	Postgres = Dialect{Name: "postgres", serial: "BIGSERIAL PRIMARY KEY", numbered: true,
+		tableQuery: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"}
+	MySQL = Dialect{Name: "mysql", serial: "BIGINT AUTO_INCREMENT PRIMARY KEY",
+		tableQuery: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"}
+	SQLite = Dialect{Name: "sqlite", serial: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		tableQuery: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"}
+)
+
+// rebind replaces the question mark placeholders of query for the dialect
+func (d Dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	out := make([]byte, 0, len(query)+8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			out = append(out, '$')
+			out = strconv.AppendInt(out, int64(n), 10)
+			continue
+		}
+		out = append(out, query[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,87 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// EventLog is an expo.EventSink recording delivery events in the
+// expo_delivery_events table, for audits and reports beyond the in-memory
+// Tracker
+type EventLog struct {
+	DB      *sql.DB
+	Dialect Dialect
+	// Timeout bounds each insert, DefaultTimeout if zero
+	Timeout time.Duration
+	// ErrorReporter receives insert failures
+	ErrorReporter expo.ErrorReporter
+}
+
+// NewEventLog creates an EventLog on db, migrated with a Migrator
+func NewEventLog(db *sql.DB, dialect Dialect) *EventLog {
+	return &EventLog{DB: db, Dialect: dialect}
+}
+
+var _ expo.EventSink = (*EventLog)(nil)
+
+// HandleEvent records event
+func (l *EventLog) HandleEvent(event expo.DeliveryEvent) {
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := l.Record(ctx, event); err != nil && l.ErrorReporter != nil {
+		l.ErrorReporter(err)
+	}
+}
+
+// Record inserts event
+func (l *EventLog) Record(ctx context.Context, event expo.DeliveryEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = l.DB.ExecContext(ctx, l.Dialect.rebind(
+		"INSERT INTO expo_delivery_events (type, time, token, ticket_id, error_code, correlation_id, event) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+		string(event.Type), unixNano(event.Time), string(event.Token), event.TicketID, event.ErrorCode, event.CorrelationID, string(data))
+	return err
+}
+
+// Events returns the events recorded in [start, end), oldest first
+func (l *EventLog) Events(ctx context.Context, start, end time.Time) ([]expo.DeliveryEvent, error) {
+	return l.query(ctx, "time >= ? AND time < ?", unixNano(start), unixNano(end))
+}
+
+// Trace returns the events of the message with the given correlation ID,
+// oldest first
+func (l *EventLog) Trace(ctx context.Context, correlationID string) ([]expo.DeliveryEvent, error) {
+	return l.query(ctx, "correlation_id = ?", correlationID)
+}
+
+func (l *EventLog) query(ctx context.Context, where string, args ...any) ([]expo.DeliveryEvent, error) {
+	rows, err := l.DB.QueryContext(ctx, l.Dialect.rebind(
+		"SELECT event FROM expo_delivery_events WHERE "+where+" ORDER BY time, id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []expo.DeliveryEvent
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var event expo.DeliveryEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
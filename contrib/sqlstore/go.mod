@@ -0,0 +1,10 @@
+module github.com/montovaneli/go-expo-notification/contrib/sqlstore
+
+go 1.22.1
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/montovaneli/go-expo-notification v0.0.0-00010101000000-000000000000
+)
+
+replace github.com/montovaneli/go-expo-notification => ../..
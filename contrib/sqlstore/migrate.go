@@ -0,0 +1,198 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// migrations are applied in order. Never edit a released migration; add a
+// new one instead. {{serial}} is replaced by the dialect's auto-incremented
+// key definition. MySQL commits DDL statements implicitly, so new
+// migrations should hold a single DDL statement each.
+var migrations = []Migration{
+	{1, "create tokens", []string{
+		`CREATE TABLE expo_tokens (
+			token VARCHAR(255) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL DEFAULT '',
+			state VARCHAR(32) NOT NULL,
+			metadata TEXT NOT NULL,
+			verification_ticket VARCHAR(255) NOT NULL DEFAULT '',
+			created_at BIGINT NOT NULL DEFAULT 0,
+			updated_at BIGINT NOT NULL DEFAULT 0,
+			deleted_at BIGINT NOT NULL DEFAULT 0,
+			restore_state VARCHAR(32) NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX expo_tokens_state ON expo_tokens (state)`,
+		`CREATE INDEX expo_tokens_user_id ON expo_tokens (user_id)`,
+	}},
+	{2, "create event outbox", []string{
+		`CREATE TABLE expo_event_outbox (
+			seq {{serial}},
+			event TEXT NOT NULL
+		)`,
+	}},
+	{3, "create delivery events", []string{
+		`CREATE TABLE expo_delivery_events (
+			id {{serial}},
+			type VARCHAR(32) NOT NULL,
+			time BIGINT NOT NULL,
+			token VARCHAR(255) NOT NULL,
+			ticket_id VARCHAR(255) NOT NULL DEFAULT '',
+			error_code VARCHAR(255) NOT NULL DEFAULT '',
+			event TEXT NOT NULL
+		)`,
+		`CREATE INDEX expo_delivery_events_time ON expo_delivery_events (time)`,
+	}},
+	{4, "add correlation IDs to delivery events", []string{
+		`ALTER TABLE expo_delivery_events ADD COLUMN correlation_id VARCHAR(64) NOT NULL DEFAULT ''`,
+		`CREATE INDEX expo_delivery_events_correlation_id ON expo_delivery_events (correlation_id)`,
+	}},
+	{5, "add segments and last success to tokens", []string{
+		`ALTER TABLE expo_tokens ADD COLUMN segments VARCHAR(1024) NOT NULL DEFAULT ''`,
+		`ALTER TABLE expo_tokens ADD COLUMN last_success_at BIGINT NOT NULL DEFAULT 0`,
+	}},
+	{6, "create scheduled messages", []string{
+		`CREATE TABLE expo_scheduled_messages (
+			id VARCHAR(64) PRIMARY KEY,
+			at BIGINT NOT NULL,
+			message TEXT NOT NULL
+		)`,
+	}},
+	{7, "create event outbox sequence", []string{
+		`CREATE TABLE expo_event_outbox_seq (
+			id INTEGER PRIMARY KEY,
+			seq BIGINT NOT NULL
+		)`,
+		`INSERT INTO expo_event_outbox_seq (id, seq) SELECT 1, COALESCE(MAX(seq), 0) FROM expo_event_outbox`,
+	}},
+}
+
+// migrationsTable records the applied migrations
+const migrationsTable = "expo_schema_migrations"
+
+// Migrations returns the migrations shipped with the package, in order
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// Migrator creates and upgrades the tables of the stores
+type Migrator struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// NewMigrator creates a Migrator for db
+func NewMigrator(db *sql.DB, dialect Dialect) *Migrator {
+	return &Migrator{DB: db, Dialect: dialect}
+}
+
+// Plan returns the migrations Migrate would apply, rendered for the
+// dialect, without changing the database
+func (m *Migrator) Plan(ctx context.Context) ([]Migration, error) {
+	// The migrations table doesn't exist before the first Migrate
+	var applied map[int]bool
+	exists, err := m.hasTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if applied, err = m.applied(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var pending []Migration
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		rendered := migration
+		rendered.Statements = make([]string, len(migration.Statements))
+		for i, stmt := range migration.Statements {
+			rendered.Statements[i] = strings.ReplaceAll(stmt, "{{serial}}", m.Dialect.serial)
+		}
+		pending = append(pending, rendered)
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order, each in its own
+// transaction, and returns those it applied. On MySQL, where DDL statements
+// commit implicitly, a migration that fails keeps the statements that ran
+// before the failure: revert them by hand before migrating again.
+func (m *Migrator) Migrate(ctx context.Context) ([]Migration, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+	pending, err := m.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, migration := range pending {
+		if err := m.apply(ctx, migration); err != nil {
+			return pending[:i], fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range migration.Statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(
+		"INSERT INTO "+migrationsTable+" (version, name, applied_at) VALUES (?, ?, ?)"),
+		migration.Version, migration.Name, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`)
+	return err
+}
+
+func (m *Migrator) hasTable(ctx context.Context) (bool, error) {
+	var n int
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(m.Dialect.tableQuery), migrationsTable).Scan(&n)
+	return n > 0, err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]bool, error) {
+	rows, err := m.DB.QueryContext(ctx, "SELECT version FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
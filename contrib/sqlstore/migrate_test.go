@@ -0,0 +1,41 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "expo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m := NewMigrator(db, SQLite)
+	planned, err := m.Plan(ctx)
+	if err != nil || len(planned) != len(migrations) {
+		t.Fatalf("Expected every migration planned, got %d, %v", len(planned), err)
+	}
+	if planned[1].Statements[0] == migrations[1].Statements[0] {
+		t.Error("Expected the statements rendered for the dialect")
+	}
+	applied, err := m.Migrate(ctx)
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("Expected every migration applied, got %d, %v", len(applied), err)
+	}
+	if planned, err := m.Plan(ctx); len(planned) != 0 || err != nil {
+		t.Errorf("Expected nothing left to plan, got %d, %v", len(planned), err)
+	}
+	if applied, err := m.Migrate(ctx); len(applied) != 0 || err != nil {
+		t.Errorf("Expected nothing left to apply, got %d, %v", len(applied), err)
+	}
+
+	// A failing database isn't mistaken for a new one
+	db.Close()
+	if planned, err := m.Plan(ctx); err == nil {
+		t.Errorf("Expected an error, got %d migrations planned", len(planned))
+	}
+}
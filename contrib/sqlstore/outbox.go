@@ -0,0 +1,106 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// OutboxStore is an expo.OutboxStore in the expo_event_outbox table, so
+// unacknowledged events survive restarts
+type OutboxStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+	// Timeout bounds each query, DefaultTimeout if zero. The OutboxStore
+	// interface carries no context.
+	Timeout time.Duration
+}
+
+// DefaultTimeout bounds the queries of stores whose interface carries no
+// context
+const DefaultTimeout = 5 * time.Second
+
+// NewOutboxStore creates an OutboxStore on db, migrated with a Migrator
+func NewOutboxStore(db *sql.DB, dialect Dialect) *OutboxStore {
+	return &OutboxStore{DB: db, Dialect: dialect}
+}
+
+var _ expo.OutboxStore = (*OutboxStore)(nil)
+
+func (s *OutboxStore) context() (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// Append stores event and returns its sequence number. The number comes
+// from the counter in expo_event_outbox_seq, incremented in the same
+// transaction: its row lock serializes appends, so events commit in order
+// and a rolled back append leaves no hole.
+func (s *OutboxStore) Append(event expo.DeliveryEvent) (uint64, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := s.context()
+	defer cancel()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// Updating first takes the lock before the counter is read
+	if _, err := tx.ExecContext(ctx, "UPDATE expo_event_outbox_seq SET seq = seq + 1 WHERE id = 1"); err != nil {
+		return 0, err
+	}
+	var seq uint64
+	if err := tx.QueryRowContext(ctx, "SELECT seq FROM expo_event_outbox_seq WHERE id = 1").Scan(&seq); err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, s.Dialect.rebind(
+		"INSERT INTO expo_event_outbox (seq, event) VALUES (?, ?)"), seq, string(data))
+	if err != nil {
+		return 0, err
+	}
+	return seq, tx.Commit()
+}
+
+// Pending returns up to limit unacknowledged events, oldest first
+func (s *OutboxStore) Pending(limit int) ([]expo.SequencedEvent, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind(
+		"SELECT seq, event FROM expo_event_outbox ORDER BY seq LIMIT ?"), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []expo.SequencedEvent
+	for rows.Next() {
+		var (
+			event expo.SequencedEvent
+			data  string
+		)
+		if err := rows.Scan(&event.Seq, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &event.DeliveryEvent); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Ack removes the events up to and including seq
+func (s *OutboxStore) Ack(seq uint64) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	_, err := s.DB.ExecContext(ctx, s.Dialect.rebind("DELETE FROM expo_event_outbox WHERE seq <= ?"), seq)
+	return err
+}
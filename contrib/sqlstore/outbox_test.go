@@ -0,0 +1,59 @@
+package sqlstore
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func TestOutboxStore(t *testing.T) {
+	db := newTestDB(t)
+	store := NewOutboxStore(db, SQLite)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Append(expo.DeliveryEvent{Type: expo.EventSent, Token: "ExponentPushToken[a]"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	events, err := store.Pending(100)
+	if err != nil || len(events) != 20 {
+		t.Fatalf("Expected 20 events, got %d, %v", len(events), err)
+	}
+	// Concurrent appends still number the events without holes
+	for i, event := range events {
+		if event.Seq != uint64(i+1) || event.Token != "ExponentPushToken[a]" {
+			t.Fatalf("Expected event %d, got %+v", i+1, event)
+		}
+	}
+	if err := store.Ack(15); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := store.Pending(100); len(events) != 5 || events[0].Seq != 16 {
+		t.Errorf("Expected events 16 to 20, got %+v", events)
+	}
+	// Numbering goes on after the acknowledged events
+	if seq, err := store.Append(expo.DeliveryEvent{Type: expo.EventSent}); seq != 21 || err != nil {
+		t.Errorf("Expected sequence number 21, got %d, %v", seq, err)
+	}
+}
+
+func TestOutboxSequenceMigration(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	// Events appended before the counter existed
+	db.ExecContext(ctx, "DELETE FROM expo_event_outbox_seq")
+	db.ExecContext(ctx, "INSERT INTO expo_event_outbox (seq, event) VALUES (7, '{}')")
+	if _, err := db.ExecContext(ctx, migrations[6].Statements[1]); err != nil {
+		t.Fatal(err)
+	}
+	if seq, err := NewOutboxStore(db, SQLite).Append(expo.DeliveryEvent{Type: expo.EventSent}); seq != 8 || err != nil {
+		t.Errorf("Expected numbering to go on after 7, got %d, %v", seq, err)
+	}
+}
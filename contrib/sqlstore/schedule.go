@@ -0,0 +1,76 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// ScheduleStore is an expo.ScheduleStore in the expo_scheduled_messages
+// table, so the messages of a Scheduler survive restarts
+type ScheduleStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// NewScheduleStore creates a ScheduleStore on db, migrated with a Migrator
+func NewScheduleStore(db *sql.DB, dialect Dialect) *ScheduleStore {
+	return &ScheduleStore{DB: db, Dialect: dialect}
+}
+
+var _ expo.ScheduleStore = (*ScheduleStore)(nil)
+
+// Save creates or replaces message
+func (s *ScheduleStore) Save(ctx context.Context, message expo.ScheduledMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	// Delete and insert rather than an upsert, whose syntax differs
+	// between the dialects
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.Dialect.rebind("DELETE FROM expo_scheduled_messages WHERE id = ?"), message.ID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.Dialect.rebind(
+		"INSERT INTO expo_scheduled_messages (id, at, message) VALUES (?, ?, ?)"),
+		message.ID, unixNano(message.At), string(data))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes the message with id
+func (s *ScheduleStore) Delete(ctx context.Context, id string) error {
+	_, err := s.DB.ExecContext(ctx, s.Dialect.rebind("DELETE FROM expo_scheduled_messages WHERE id = ?"), id)
+	return err
+}
+
+// Load returns every stored message, soonest first
+func (s *ScheduleStore) Load(ctx context.Context) ([]expo.ScheduledMessage, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT message FROM expo_scheduled_messages ORDER BY at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []expo.ScheduledMessage
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var message expo.ScheduledMessage
+		if err := json.Unmarshal([]byte(data), &message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
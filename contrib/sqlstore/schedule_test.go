@@ -0,0 +1,39 @@
+package sqlstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func TestScheduleStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewScheduleStore(newTestDB(t), SQLite)
+	at := time.Unix(1_700_000_000, 0)
+	message := expo.PushMessage{To: []expo.ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi", CorrelationID: "corr"}
+	for _, m := range []expo.ScheduledMessage{
+		{ID: "later", Message: message, At: at.Add(time.Hour)},
+		{ID: "soon", Message: message, At: at.Add(2 * time.Hour)},
+		{ID: "gone", Message: message, At: at},
+	} {
+		if err := store.Save(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Save replaces a message
+	if err := store.Save(ctx, expo.ScheduledMessage{ID: "soon", Message: message, At: at}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "gone"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load(ctx)
+	if err != nil || len(loaded) != 2 {
+		t.Fatalf("Expected 2 messages, got %+v, %v", loaded, err)
+	}
+	if loaded[0].ID != "soon" || !loaded[0].At.Equal(at) || loaded[0].Message.CorrelationID != "corr" || loaded[1].ID != "later" {
+		t.Errorf("Expected soon then later, got %+v", loaded)
+	}
+}
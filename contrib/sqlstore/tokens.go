@@ -0,0 +1,160 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+const tokenColumns = "token, user_id, state, metadata, verification_ticket, created_at, updated_at, deleted_at, restore_state, segments, last_success_at"
+
+// TokenStore is an expo.TokenStore in the expo_tokens table
+type TokenStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// NewTokenStore creates a TokenStore on db, migrated with a Migrator
+func NewTokenStore(db *sql.DB, dialect Dialect) *TokenStore {
+	return &TokenStore{DB: db, Dialect: dialect}
+}
+
+var _ expo.TokenStore = (*TokenStore)(nil)
+
+// Get returns the record of token
+func (s *TokenStore) Get(ctx context.Context, token expo.ExponentPushToken) (*expo.TokenRecord, error) {
+	row := s.DB.QueryRowContext(ctx, s.Dialect.rebind(
+		"SELECT "+tokenColumns+" FROM expo_tokens WHERE token = ?"), string(token))
+	record, err := scanToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, expo.ErrTokenNotFound
+	}
+	return record, err
+}
+
+// Put creates or replaces record
+func (s *TokenStore) Put(ctx context.Context, record *expo.TokenRecord) error {
+	metadata, err := json.Marshal(record.Metadata)
+	if err != nil {
+		return err
+	}
+	// Delete and insert rather than an upsert, whose syntax differs
+	// between the dialects
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.Dialect.rebind("DELETE FROM expo_tokens WHERE token = ?"), string(record.Token)); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.Dialect.rebind(
+		"INSERT INTO expo_tokens ("+tokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		string(record.Token), record.UserID, string(record.State), string(metadata), record.VerificationTicket,
+		unixNano(record.CreatedAt), unixNano(record.UpdatedAt), unixNano(record.DeletedAt), string(record.RestoreState),
+		joinSegments(record.Segments), unixNano(record.LastSuccessAt))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List returns the records matching filter, ordered by token
+func (s *TokenStore) List(ctx context.Context, filter expo.TokenFilter) ([]expo.TokenRecord, error) {
+	query := "SELECT " + tokenColumns + " FROM expo_tokens WHERE 1 = 1"
+	var args []any
+	if filter.State != "" {
+		query += " AND state = ?"
+		args = append(args, string(filter.State))
+	}
+	if filter.UserID != "" {
+		query += " AND user_id = ?"
+		args = append(args, filter.UserID)
+	}
+	if filter.Segment != "" {
+		query += " AND segments LIKE ?"
+		args = append(args, "%,"+filter.Segment+",%")
+	}
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind(query+" ORDER BY token"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []expo.TokenRecord
+	for rows.Next() {
+		record, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+	return records, rows.Err()
+}
+
+// Delete removes the record of token
+func (s *TokenStore) Delete(ctx context.Context, token expo.ExponentPushToken) error {
+	_, err := s.DB.ExecContext(ctx, s.Dialect.rebind("DELETE FROM expo_tokens WHERE token = ?"), string(token))
+	return err
+}
+
+func scanToken(row interface{ Scan(...any) error }) (*expo.TokenRecord, error) {
+	var (
+		record                                expo.TokenRecord
+		token, state, restore, meta, segments string
+		created, updated, deleted, success    int64
+	)
+	err := row.Scan(&token, &record.UserID, &state, &meta, &record.VerificationTicket,
+		&created, &updated, &deleted, &restore, &segments, &success)
+	if err != nil {
+		return nil, err
+	}
+	record.Token = expo.ExponentPushToken(token)
+	record.State = expo.TokenState(state)
+	record.RestoreState = expo.TokenState(restore)
+	if err := json.Unmarshal([]byte(meta), &record.Metadata); err != nil {
+		return nil, err
+	}
+	record.CreatedAt = fromUnixNano(created)
+	record.UpdatedAt = fromUnixNano(updated)
+	record.DeletedAt = fromUnixNano(deleted)
+	record.Segments = splitSegments(segments)
+	record.LastSuccessAt = fromUnixNano(success)
+	return &record, nil
+}
+
+// joinSegments stores segments as ",a,b,", so a segment is matched with
+// LIKE '%,a,%' in every dialect
+func joinSegments(segments []string) string {
+	if len(segments) == 0 {
+		return ""
+	}
+	return "," + strings.Join(segments, ",") + ","
+}
+
+func splitSegments(s string) []string {
+	s = strings.Trim(s, ",")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// unixNano stores times as nanoseconds since the epoch, 0 for the zero time
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,68 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func TestTokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewTokenStore(newTestDB(t), SQLite)
+	now := time.Unix(1_700_000_000, 0)
+	records := []expo.TokenRecord{
+		{Token: "ExponentPushToken[a]", UserID: "u1", State: expo.TokenStateActive, Segments: []string{"beta", "pro"},
+			Metadata: map[string]string{"platform": "ios"}, CreatedAt: now, LastSuccessAt: now},
+		{Token: "ExponentPushToken[b]", UserID: "u1", State: expo.TokenStateInvalid},
+		{Token: "ExponentPushToken[c]", UserID: "u2", State: expo.TokenStateActive, Segments: []string{"pro"}},
+	}
+	for i := range records {
+		if err := store.Put(ctx, &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := store.Get(ctx, "ExponentPushToken[a]")
+	if err != nil || got.UserID != "u1" || got.Metadata["platform"] != "ios" || len(got.Segments) != 2 ||
+		!got.CreatedAt.Equal(now) || !got.LastSuccessAt.Equal(now) {
+		t.Errorf("Incorrect record %+v, %v", got, err)
+	}
+	if _, err := store.Get(ctx, "ExponentPushToken[x]"); !errors.Is(err, expo.ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound, got %v", err)
+	}
+
+	list := func(filter expo.TokenFilter) []expo.ExponentPushToken {
+		t.Helper()
+		records, err := store.List(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens := make([]expo.ExponentPushToken, len(records))
+		for i := range records {
+			tokens[i] = records[i].Token
+		}
+		return tokens
+	}
+	if tokens := list(expo.TokenFilter{State: expo.TokenStateActive, Segment: "pro"}); len(tokens) != 2 {
+		t.Errorf("Expected a and c, got %v", tokens)
+	}
+	// Segments match whole names only
+	if tokens := list(expo.TokenFilter{Segment: "bet"}); len(tokens) != 0 {
+		t.Errorf("Expected no token, got %v", tokens)
+	}
+	if tokens := list(expo.TokenFilter{UserID: "u1"}); len(tokens) != 2 || tokens[0] != "ExponentPushToken[a]" {
+		t.Errorf("Expected a then b, got %v", tokens)
+	}
+
+	// Put replaces the record
+	records[1].State = expo.TokenStateActive
+	store.Put(ctx, &records[1])
+	if err := store.Delete(ctx, "ExponentPushToken[c]"); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := list(expo.TokenFilter{State: expo.TokenStateActive}); len(tokens) != 2 || tokens[1] != "ExponentPushToken[b]" {
+		t.Errorf("Expected a and b, got %v", tokens)
+	}
+}
@@ -0,0 +1,72 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorrelationIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/--/api/v2/push/getReceipts" {
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+				"ExponentPushToken[150]": map[string]any{"status": "ok"},
+			}})
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		tickets := okTickets(messages)
+		for i := range tickets.Data {
+			tickets.Data[i].ID = string(messages[i].To[0])
+		}
+		json.NewEncoder(w).Encode(tickets)
+	}))
+	defer srv.Close()
+	tracker := NewTracker()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, EventSink: tracker})
+	messages := testMessages(200)
+	messages[0].CorrelationID = "mine"
+	responses, err := client.PublishMultiple(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[1].CorrelationID != "" {
+		t.Error("Expected the caller's messages to be left unchanged")
+	}
+	if responses[0].PushMessage.CorrelationID != "mine" {
+		t.Errorf("Expected a given correlation ID to be kept, got %q", responses[0].PushMessage.CorrelationID)
+	}
+	id := responses[150].PushMessage.CorrelationID
+	if id == "" || id == responses[149].PushMessage.CorrelationID {
+		t.Fatalf("Expected a distinct correlation ID per message, got %q", id)
+	}
+	trace := tracker.Trace(id)
+	if len(trace) != 1 || trace[0].TicketID != "ExponentPushToken[150]" {
+		t.Errorf("Expected the sent event of the message, got %v", trace)
+	}
+	receipts, err := client.GetTicketReceipts(context.Background(), responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := receipts["ExponentPushToken[150]"]; r.CorrelationID != id {
+		t.Errorf("Expected the receipt to carry the correlation ID, got %q", r.CorrelationID)
+	}
+}
+
+func TestCorrelationIDQueued(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	tracker := NewTracker()
+	p := &AsyncPublisher{Client: NewPushClient(&ClientConfig{Host: srv.URL, EventSink: tracker})}
+	id := p.Enqueue(testMessages(1)[0])
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if trace := tracker.Trace(id); len(trace) != 1 || trace[0].Type != EventSent {
+		t.Errorf("Expected the queued message's ID in its events, got %v", trace)
+	}
+}
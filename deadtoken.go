@@ -0,0 +1,43 @@
+package expo
+
+// DetailExpoPushToken is the key of the Details of tickets and receipts
+// holding the token of a DeviceNotRegistered error
+const DetailExpoPushToken = "expoPushToken"
+
+// notifyDeadTickets calls the OnDeviceNotRegistered hook with the tokens of
+// the tickets reporting DeviceNotRegistered
+func (c *PushClient) notifyDeadTickets(tickets []PushResponse) {
+	if c.onDeviceNotRegistered == nil {
+		return
+	}
+	for i := range tickets {
+		if tickets[i].ErrorCode() == ErrorCodeDeviceNotRegistered {
+			c.notifyDead(tickets[i].Details, tickets[i].PushMessage.To)
+		}
+	}
+}
+
+// notifyDeadReceipts calls the OnDeviceNotRegistered hook with the tokens
+// of the receipts reporting DeviceNotRegistered. tokens holds the
+// recipients of the tickets by ID, when known.
+func (c *PushClient) notifyDeadReceipts(receipts map[string]PushReceipt, tokens map[string][]ExponentPushToken) {
+	if c.onDeviceNotRegistered == nil {
+		return
+	}
+	for id, receipt := range receipts {
+		if receipt.ErrorCode() == ErrorCodeDeviceNotRegistered {
+			c.notifyDead(receipt.Details, tokens[id])
+		}
+	}
+}
+
+// notifyDead reports the token named in details or, failing that, the
+// single recipient of the message. A message to several recipients
+// doesn't tell which one is dead, so none is reported.
+func (c *PushClient) notifyDead(details map[string]string, recipients []ExponentPushToken) {
+	if token := details[DetailExpoPushToken]; token != "" {
+		c.onDeviceNotRegistered(ExponentPushToken(token))
+	} else if len(recipients) == 1 {
+		c.onDeviceNotRegistered(recipients[0])
+	}
+}
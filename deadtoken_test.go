@@ -0,0 +1,54 @@
+package expo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOnDeviceNotRegistered(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getReceipts") {
+			w.Write([]byte(`{"data":{
+				"r1":{"status":"error","details":{"error":"DeviceNotRegistered","expoPushToken":"ExponentPushToken[r1]"}},
+				"r2":{"status":"error","details":{"error":"DeviceNotRegistered"}},
+				"r3":{"status":"ok"}}}`))
+			return
+		}
+		r.Body.Close()
+		json := `{"data":[{"status":"ok","id":"r3"},{"status":"error","details":{"error":"DeviceNotRegistered"}},` +
+			`{"status":"error","details":{"error":"MessageRateExceeded"}},{"status":"error","details":{"error":"DeviceNotRegistered"}}]}`
+		w.Write([]byte(json))
+	}))
+	defer srv.Close()
+	var tokens []ExponentPushToken
+	client := New(WithHost(srv.URL), WithDeviceNotRegistered(func(token ExponentPushToken) {
+		tokens = append(tokens, token)
+	}))
+
+	messages := testMessages(3)
+	// A ticket of a message to several recipients doesn't tell which one is dead
+	messages = append(messages, PushMessage{To: []ExponentPushToken{"ExponentPushToken[x]", "ExponentPushToken[y]"}, Body: "hi"})
+	if _, err := client.PublishMultiple(messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0] != messages[1].To[0] {
+		t.Errorf("Expected %s from the tickets, got %v", messages[1].To[0], tokens)
+	}
+
+	// Receipts name the token, or it comes from the ticket
+	tokens = nil
+	tickets := []PushResponse{
+		{ID: "r1", Status: SuccessStatus},
+		{ID: "r2", Status: SuccessStatus, PushMessage: PushMessage{To: []ExponentPushToken{"ExponentPushToken[r2]"}}},
+	}
+	if _, err := client.GetTicketReceipts(context.Background(), tickets); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || !slices.Contains(tokens, "ExponentPushToken[r1]") || !slices.Contains(tokens, "ExponentPushToken[r2]") {
+		t.Errorf("Unexpected tokens from receipts %v", tokens)
+	}
+}
@@ -0,0 +1,41 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// decodeTickets parses the body of a push/send response that must hold count
+// tickets. It never panics on malformed or truncated input: every failure is
+// a *NonJSONResponseError or a *PushServerError. resp may be nil.
+func decodeTickets(resp *http.Response, body []byte, count int) ([]PushResponse, error) {
+	// Validate the response format first
+	var r *Response
+	err := json.Unmarshal(body, &r)
+	if err != nil || r == nil {
+		// The response isn't json, e.g. a proxy or maintenance page
+		return nil, newNonJSONResponseError(resp, body, err)
+	}
+	// If there are errors with the entire request, raise an error now.
+	if r.Errors != nil {
+		return nil, NewPushServerError("Invalid server response", resp, r, r.Errors)
+	}
+	// We expect the response to have a 'data' field with the responses.
+	if r.Data == nil {
+		return nil, NewPushServerError("Invalid server response", resp, r, nil)
+	}
+	// Sanity check the response
+	if count != len(r.Data) {
+		message := "Mismatched response length. Expected %d receipts but only received %d"
+		errorMessage := fmt.Sprintf(message, count, len(r.Data))
+		return nil, NewPushServerError(errorMessage, resp, r, nil)
+	}
+	for i := range r.Data {
+		if status := r.Data[i].Status; status != SuccessStatus && status != errorStatus {
+			errorMessage := fmt.Sprintf("Invalid ticket status %q at index %d", status, i)
+			return nil, NewPushServerError(errorMessage, resp, r, nil)
+		}
+	}
+	return r.Data, nil
+}
@@ -0,0 +1,32 @@
+package expo
+
+import (
+	"errors"
+	"testing"
+)
+
+func FuzzDecodeTickets(f *testing.F) {
+	f.Add([]byte(`{"data":[{"status":"ok","id":"a"}]}`), 1)
+	f.Add([]byte(`{"data":[{"status":"error","message":"x","details":{"error":"DeviceNotRegistered"}}]}`), 1)
+	f.Add([]byte(`{"errors":[{"code":"E","message":"m","isTransient":true}]}`), 1)
+	f.Add([]byte(`{"data":[{"status":"ok"`), 1)
+	f.Add([]byte(`null`), 0)
+	f.Add([]byte(`<html></html>`), 2)
+	f.Fuzz(func(t *testing.T, body []byte, count int) {
+		tickets, err := decodeTickets(nil, body, count)
+		if err != nil {
+			var nonJSON *NonJSONResponseError
+			var server *PushServerError
+			if !errors.As(err, &nonJSON) && !errors.As(err, &server) {
+				t.Fatalf("Untyped error %T: %v", err, err)
+			}
+			return
+		}
+		if len(tickets) != count {
+			t.Fatalf("Expected %d tickets, got %d", count, len(tickets))
+		}
+		for i := range tickets {
+			tickets[i].ValidateResponse()
+		}
+	})
+}
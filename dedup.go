@@ -0,0 +1,100 @@
+package expo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DedupCache stores message fingerprints for duplicate-send detection.
+// Implementations must be safe for concurrent use. A cache shared between
+// processes suppresses replays across all of them.
+type DedupCache interface {
+	// Add records key for ttl and reports whether it was not already present
+	Add(key string, ttl time.Duration) bool
+	// Remove forgets key, e.g. after the send it guarded failed
+	Remove(key string)
+}
+
+// Fingerprint returns a stable hash of the message content, ignoring recipients.
+// Two messages with the same fingerprint render identically on the device.
+func Fingerprint(message *PushMessage) string {
+	content := *message
+	content.To = nil
+	// Marshalling a struct is deterministic and map keys are sorted
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// dedup removes the recipients that already received an identical message,
+// or one with the same idempotency key, within the window. It returns the
+// keys it recorded for the remaining recipients and the recipients it
+// removed.
+func (c *PushClient) dedup(message PushMessage) (PushMessage, []string, []ExponentPushToken) {
+	var fingerprint string
+	if message.IdempotencyKey != "" {
+		// The prefix keeps keys apart from fingerprints
+		fingerprint = "idempotency:" + message.IdempotencyKey
+	} else {
+		fingerprint = Fingerprint(&message)
+	}
+	to := make([]ExponentPushToken, 0, len(message.To))
+	keys := make([]string, 0, len(message.To))
+	var dropped []ExponentPushToken
+	for _, token := range message.To {
+		key := fingerprint + ":" + string(token)
+		if c.dedupCache.Add(key, c.dedupWindow) {
+			to = append(to, token)
+			keys = append(keys, key)
+		} else {
+			dropped = append(dropped, token)
+		}
+	}
+	message.To = to
+	return message, keys, dropped
+}
+
+// MemoryDedupCache is an in-process DedupCache
+type MemoryDedupCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+// NewMemoryDedupCache creates an empty in-memory DedupCache
+func NewMemoryDedupCache() *MemoryDedupCache {
+	return &MemoryDedupCache{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Add records key for ttl and reports whether it was not already present
+func (m *MemoryDedupCache) Add(key string, ttl time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if expires, ok := m.entries[key]; ok && now.Before(expires) {
+		return false
+	}
+	// Sweep expired entries opportunistically to bound memory
+	if len(m.entries) > 0 && len(m.entries)%1024 == 0 {
+		for k, expires := range m.entries {
+			if !now.Before(expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	m.entries[key] = now.Add(ttl)
+	return true
+}
+
+// Remove forgets key
+func (m *MemoryDedupCache) Remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+}
@@ -0,0 +1,89 @@
+package expo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryDedupCacheExpires(t *testing.T) {
+	cache := NewMemoryDedupCache()
+	now := time.Unix(1000, 0)
+	cache.now = func() time.Time { return now }
+	if !cache.Add("a", time.Minute) {
+		t.Error("First add reported duplicate")
+	}
+	if cache.Add("a", time.Minute) {
+		t.Error("Second add within window not reported as duplicate")
+	}
+	now = now.Add(2 * time.Minute)
+	if !cache.Add("a", time.Minute) {
+		t.Error("Add after window reported duplicate")
+	}
+}
+
+func TestFingerprintIgnoresRecipients(t *testing.T) {
+	a := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi"}
+	b := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[b]"}, Body: "hi"}
+	c := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "bye"}
+	if Fingerprint(a) != Fingerprint(b) {
+		t.Error("Fingerprint depends on recipients")
+	}
+	if Fingerprint(a) == Fingerprint(c) {
+		t.Error("Fingerprint ignores body")
+	}
+}
+
+func TestPublishSuppressesDuplicates(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	client := NewPushClient(&ClientConfig{Host: srv.URL, DedupWindow: time.Minute})
+	message := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi"}
+
+	first, err := client.Publish(message)
+	if err != nil || first.ValidateResponse() != nil {
+		t.Fatalf("First publish failed: %v", err)
+	}
+	second, err := client.Publish(message)
+	if err != nil {
+		t.Fatalf("Second publish failed: %v", err)
+	}
+	if _, ok := second.ValidateResponse().(*SuppressedError); !ok {
+		t.Error("Duplicate was not suppressed")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 request, got %d", calls.Load())
+	}
+}
+
+func TestPublishIdempotencyKey(t *testing.T) {
+	fail := true
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		if fail {
+			return Response{Errors: []APIError{{Code: "INTERNAL_SERVER_ERROR", Message: "down"}}}
+		}
+		return okTickets(messages)
+	})
+	cache := NewMemoryDedupCache()
+	message := PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "Shipped", IdempotencyKey: "order-42"}
+
+	// A failed send doesn't count, so the retry goes out
+	client := NewPushClient(&ClientConfig{Host: srv.URL, DedupWindow: time.Hour, DedupCache: cache})
+	if _, err := client.Publish(&message); err == nil {
+		t.Fatal("Expected the send to fail")
+	}
+	fail = false
+	if _, err := client.Publish(&message); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restarted process sharing the cache doesn't send it again, even
+	// with different content
+	restarted := NewPushClient(&ClientConfig{Host: srv.URL, DedupWindow: time.Hour, DedupCache: cache})
+	message.Body = "Your order shipped"
+	response, err := restarted.Publish(&message)
+	if err != nil || response.Status != SuppressedStatus {
+		t.Errorf("Expected a suppressed response, got %+v (%v)", response, err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Server called %d times, want 2", calls.Load())
+	}
+}
@@ -0,0 +1,69 @@
+package expo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DialOptions tune how the default transport connects to the host, e.g. for
+// environments with broken IPv6 routes to exp.host, which show up as
+// intermittent timeouts
+type DialOptions struct {
+	// Timeout bounds establishing a connection. Defaults to 30 seconds.
+	Timeout time.Duration
+	// FallbackDelay is how long an IPv6 connection attempt gets before an
+	// IPv4 one is raced against it ("Happy Eyeballs"). Zero uses the net
+	// package default of 300ms; a negative delay disables the fallback.
+	FallbackDelay time.Duration
+	// ForceIPv4 only connects over IPv4
+	ForceIPv4 bool
+	// Resolver caches host lookups. Addresses are then tried one after
+	// the other, without the IPv6/IPv4 race.
+	Resolver *CachingResolver
+	// DialContext replaces the dialer, e.g. to route traffic through a
+	// local sidecar proxy. The other options are then ignored.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	// UnixSocket connects every request to a unix socket instead of the
+	// host, e.g. a service mesh or egress proxy listening locally. Requests
+	// keep the host's name, so use an http host if the proxy terminates TLS.
+	UnixSocket string
+}
+
+// transport returns a copy of http.DefaultTransport dialing with these options
+func (o *DialOptions) transport() http.RoundTripper {
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{
+		Timeout:       timeout,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: o.FallbackDelay,
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	switch {
+	case o.DialContext != nil:
+		t.DialContext = o.DialContext
+		return t
+	case o.UnixSocket != "":
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", o.UnixSocket)
+		}
+		return t
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if o.ForceIPv4 && network == "tcp" {
+			network = "tcp4"
+		}
+		if o.Resolver != nil {
+			return o.Resolver.dial(ctx, dialer, network, addr, o.ForceIPv4)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	if o.Resolver != nil {
+		return &resolverTransport{Transport: t, resolver: o.Resolver}
+	}
+	return t
+}
@@ -0,0 +1,52 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestDialOptionsForceIPv4(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	transport := (&DialOptions{ForceIPv4: true}).transport().(*http.Transport)
+	conn, err := transport.DialContext(context.Background(), "tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if addr := conn.RemoteAddr().(*net.TCPAddr); addr.IP.To4() == nil {
+		t.Errorf("Expected an IPv4 connection, got %v", addr)
+	}
+	if _, err := transport.DialContext(context.Background(), "tcp", "[::1]:1"); err == nil {
+		t.Error("Expected dialing an IPv6 address to fail")
+	}
+}
+
+func TestDialOptionsUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "proxy.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip("unix sockets unavailable:", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	srv.Listener = listener
+	srv.Start()
+	defer srv.Close()
+
+	client := NewPushClient(&ClientConfig{
+		Host: "http://exp.host",
+		Dial: &DialOptions{UnixSocket: socket},
+	})
+	if _, err := client.Publish(&testMessages(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+}
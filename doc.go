@@ -0,0 +1,16 @@
+// Package expo sends push notifications through the Expo push service.
+//
+// The core of the package is PushClient. Optional subsystems built on top of
+// it, such as campaigns, the scheduler and health probes, can be left out of
+// binaries that only publish notifications by building with the expominimal
+// tag:
+//
+//	go build -tags expominimal
+//
+// The core client has no platform-specific code and builds for js/wasm and
+// wasip1. On runtimes without sockets, set ClientConfig.Transport to an
+// http.RoundTripper provided by the host.
+//
+// Adapters for external systems such as Redis live in nested modules under
+// contrib, so their dependencies stay out of the core module.
+package expo
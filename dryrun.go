@@ -0,0 +1,38 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// DryRunTicketPrefix starts the IDs of the tickets returned in dry-run mode,
+// see ClientConfig.DryRun
+const DryRunTicketPrefix = "dryrun-"
+
+// sendDryRun answers a send without calling the host, with a successful ticket
+// per message
+func (c *PushClient) sendDryRun(ctx context.Context, messages []PushMessage, data []byte, sent []json.RawMessage, stats *BatchStats) []PushResponse {
+	stats.PayloadBytes = len(data)
+	stats.WireBytes = len(data)
+	tickets := make([]PushResponse, len(messages))
+	for i := range messages {
+		tickets[i] = PushResponse{
+			ID:          DryRunTicketPrefix + c.NewID(),
+			Status:      SuccessStatus,
+			PushMessage: messages[i],
+			Sent:        sent[i],
+		}
+	}
+	c.log(ctx, slog.LevelDebug, "expo dry run",
+		slog.Int("messages", len(messages)),
+		slog.Int("bytes", stats.PayloadBytes))
+	return tickets
+}
+
+// dryRunReceipts reports every ticket delivered without calling the host
+func dryRunReceipts(ids []string, receipts map[string]PushReceipt) {
+	for _, id := range ids {
+		receipts[id] = PushReceipt{ID: id, Status: SuccessStatus}
+	}
+}
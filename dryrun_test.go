@@ -0,0 +1,45 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+	}))
+	defer srv.Close()
+	report := &BatchReport{}
+	client := NewPushClient(&ClientConfig{Host: srv.URL, DryRun: true, ValidateTokens: true, BatchObserver: report})
+
+	tickets, err := client.PublishMultiple(testMessages(150))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(tickets))
+	for i, ticket := range tickets {
+		if !ticket.isSuccess() || !strings.HasPrefix(ticket.ID, DryRunTicketPrefix) || len(ticket.Sent) == 0 {
+			t.Fatalf("Unexpected ticket %+v", ticket)
+		}
+		ids[i] = ticket.ID
+	}
+	if s := report.Summary(); s.Requests != 2 || s.Messages != 150 || s.PayloadBytes == 0 {
+		t.Errorf("Expected the messages chunked in 2 requests, got %+v", s)
+	}
+
+	receipts, err := client.GetReceiptsContext(context.Background(), ids)
+	if err != nil || len(receipts) != len(ids) || receipts[ids[0]].Status != SuccessStatus {
+		t.Errorf("Unexpected receipts %v (%v)", receipts, err)
+	}
+
+	// Messages are still validated
+	var invalid *InvalidTokensError
+	if _, err := client.Publish(&PushMessage{To: []ExponentPushToken{"nope"}}); !errors.As(err, &invalid) {
+		t.Errorf("Expected an InvalidTokensError, got %v", err)
+	}
+}
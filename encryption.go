@@ -0,0 +1,207 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Data keys set by PayloadKeyring.EncryptMessage
+const (
+	DataEncryptedPayload = "encryptedPayload"
+	DataKeyVersion       = "keyVersion"
+)
+
+var (
+	// ErrUnknownKeyVersion is returned when opening a payload sealed with a
+	// key the keyring doesn't hold, e.g. one that was retired
+	ErrUnknownKeyVersion = newCodedError(ErrorCodeDecryptionFailed, "unknown payload key version")
+	// ErrInvalidPayload is returned when opening a payload that is
+	// malformed or was tampered with
+	ErrInvalidPayload = newCodedError(ErrorCodeDecryptionFailed, "invalid encrypted payload")
+	// ErrKeyVersionExists is returned when rotating to a key version the
+	// keyring already holds
+	ErrKeyVersionExists = newCodedError(ErrorCodeConflict, "payload key version already exists")
+	// ErrRetireCurrentKey is returned when retiring the current key
+	ErrRetireCurrentKey = newCodedError(ErrorCodeConflict, "cannot retire the current payload key")
+)
+
+// EncryptedContent is the content sealed by PayloadKeyring.EncryptMessage
+type EncryptedContent struct {
+	Title    string            `json:"title,omitempty"`
+	Subtitle string            `json:"subtitle,omitempty"`
+	Body     string            `json:"body"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// PayloadKeyring encrypts notification content with AES-GCM, so Expo and
+// the push services only see ciphertext; the app decrypts it on the
+// device, e.g. in a notification service extension. Keys are versioned and
+// every payload carries the version it was sealed with: Rotate makes a new
+// key current while older ones still open the notifications already
+// delivered, until they are retired.
+type PayloadKeyring struct {
+	// Placeholder is the body shown by devices that can't decrypt the
+	// content
+	Placeholder string
+
+	mu      sync.RWMutex
+	keys    map[uint32]cipher.AEAD
+	current uint32
+}
+
+// NewPayloadKeyring creates a keyring sealing with key, of 16, 24 or 32
+// bytes, as version
+func NewPayloadKeyring(version uint32, key []byte) (*PayloadKeyring, error) {
+	k := &PayloadKeyring{keys: make(map[uint32]cipher.AEAD)}
+	if err := k.Rotate(version, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate adds key as version and seals new payloads with it. Versions must
+// be new, so a payload never opens with another key than its own.
+func (k *PayloadKeyring) Rotate(version uint32, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[version]; ok {
+		return fmt.Errorf("%w: %d", ErrKeyVersionExists, version)
+	}
+	k.keys[version] = aead
+	k.current = version
+	return nil
+}
+
+// Retire removes an old key once the notifications it sealed no longer
+// need decrypting. The current key can't be retired.
+func (k *PayloadKeyring) Retire(version uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if version == k.current {
+		return ErrRetireCurrentKey
+	}
+	if _, ok := k.keys[version]; !ok {
+		return ErrUnknownKeyVersion
+	}
+	delete(k.keys, version)
+	return nil
+}
+
+// Current returns the version new payloads are sealed with
+func (k *PayloadKeyring) Current() uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Versions returns the versions of the keys held, in ascending order
+func (k *PayloadKeyring) Versions() []uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	versions := make([]uint32, 0, len(k.keys))
+	for version := range k.keys {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+// Seal encrypts plaintext with the current key. The result is the base64
+// of the big-endian key version, the nonce and the ciphertext.
+func (k *PayloadKeyring) Seal(plaintext []byte) (string, error) {
+	sealed, _, err := k.seal(plaintext)
+	return sealed, err
+}
+
+func (k *PayloadKeyring) seal(plaintext []byte) (string, uint32, error) {
+	k.mu.RLock()
+	version, aead := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+	out := make([]byte, 4+aead.NonceSize(), 4+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint32(out, version)
+	nonce := out[4:]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", 0, err
+	}
+	// The version is authenticated, so it can't be swapped
+	out = aead.Seal(out, nonce, plaintext, out[:4])
+	return base64.StdEncoding.EncodeToString(out), version, nil
+}
+
+// Open decrypts a payload sealed by Seal with any key the keyring holds
+func (k *PayloadKeyring) Open(sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < 4 {
+		return nil, ErrInvalidPayload
+	}
+	k.mu.RLock()
+	aead, ok := k.keys[binary.BigEndian.Uint32(data)]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKeyVersion
+	}
+	if len(data) < 4+aead.NonceSize() {
+		return nil, ErrInvalidPayload
+	}
+	plaintext, err := aead.Open(nil, data[4:4+aead.NonceSize()], data[4+aead.NonceSize():], data[:4])
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return plaintext, nil
+}
+
+// EncryptMessage returns a copy of message whose title, subtitle, body and
+// data are sealed in Data[DataEncryptedPayload], with the key version in
+// Data[DataKeyVersion]. The body is replaced with Placeholder.
+func (k *PayloadKeyring) EncryptMessage(message *PushMessage) (PushMessage, error) {
+	content, err := json.Marshal(EncryptedContent{
+		Title: message.Title, Subtitle: message.Subtitle, Body: message.Body, Data: message.Data})
+	if err != nil {
+		return PushMessage{}, err
+	}
+	sealed, version, err := k.seal(content)
+	if err != nil {
+		return PushMessage{}, err
+	}
+	encrypted := *message
+	encrypted.Title = ""
+	encrypted.Subtitle = ""
+	encrypted.Body = k.Placeholder
+	encrypted.Data = map[string]string{
+		DataEncryptedPayload: sealed,
+		DataKeyVersion:       strconv.FormatUint(uint64(version), 10),
+	}
+	return encrypted, nil
+}
+
+// DecryptMessage opens the content of a message encrypted by EncryptMessage,
+// as the app would on the device
+func (k *PayloadKeyring) DecryptMessage(message *PushMessage) (EncryptedContent, error) {
+	var content EncryptedContent
+	plaintext, err := k.Open(message.Data[DataEncryptedPayload])
+	if err != nil {
+		return content, err
+	}
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return content, ErrInvalidPayload
+	}
+	return content, nil
+}
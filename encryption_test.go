@@ -0,0 +1,73 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadKeyringRotation(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	keyring, err := NewPayloadKeyring(1, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring.Placeholder = "New message"
+	message := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Title: "Hi", Subtitle: "From Ann", Body: "Secret", Data: map[string]string{"id": "7"}}
+	before, err := keyring.EncryptMessage(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Body != "New message" || before.Title != "" || before.Subtitle != "" || before.Data[DataKeyVersion] != "1" {
+		t.Errorf("Incorrect encrypted message: %+v", before)
+	}
+
+	if err := keyring.Rotate(1, newKey); !errors.Is(err, ErrKeyVersionExists) || ErrorCodeOf(err) != ErrorCodeConflict {
+		t.Errorf("Expected reusing a version to fail with a conflict, got %v", err)
+	}
+	if err := keyring.Rotate(2, newKey); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := keyring.EncryptMessage(message)
+	if after.Data[DataKeyVersion] != "2" {
+		t.Errorf("Expected new sends sealed with version 2, got %s", after.Data[DataKeyVersion])
+	}
+	want := EncryptedContent{Title: "Hi", Subtitle: "From Ann", Body: "Secret", Data: map[string]string{"id": "7"}}
+	for _, m := range []*PushMessage{&before, &after} {
+		if content, err := keyring.DecryptMessage(m); err != nil || !reflect.DeepEqual(content, want) {
+			t.Errorf("Expected %+v, got %+v, %v", want, content, err)
+		}
+	}
+
+	if err := keyring.Retire(2); !errors.Is(err, ErrRetireCurrentKey) {
+		t.Errorf("Expected retiring the current key to fail, got %v", err)
+	}
+	if err := keyring.Retire(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.DecryptMessage(&before); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Expected ErrUnknownKeyVersion, got %v", err)
+	}
+	if versions := keyring.Versions(); !reflect.DeepEqual(versions, []uint32{2}) {
+		t.Errorf("Incorrect versions: %v", versions)
+	}
+}
+
+func TestPayloadKeyringTampering(t *testing.T) {
+	keyring, _ := NewPayloadKeyring(1, bytes.Repeat([]byte{1}, 16))
+	keyring.Rotate(2, bytes.Repeat([]byte{2}, 16))
+	sealed, _ := keyring.Seal([]byte("secret"))
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	// Claim the payload was sealed with the old key
+	raw[3] = 1
+	if _, err := keyring.Open(base64.StdEncoding.EncodeToString(raw)); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Expected ErrInvalidPayload for a swapped version, got %v", err)
+	}
+	if _, err := keyring.Open(sealed[:8]); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Expected ErrInvalidPayload for a truncated payload, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ErrorCode classifies the errors returned by this package, and the
+// details.error of failed tickets and receipts. Get the code of any error
+// with ErrorCodeOf, and switch on it rather than on messages.
+//
+// Codes are stable: once released, a code is never renamed, removed or
+// given another meaning, and its string value, safe to store and to use as
+// a metric label, never changes. New codes may be added in minor releases,
+// so code that switches on them should handle codes it doesn't know, e.g.
+// like ErrorCodeUnknown. Ticket and receipt codes Expo adds later are
+// passed through as is.
+type ErrorCode string
+
+// Error codes of tickets and receipts, as sent by Expo
+const (
+	ErrorCodeDeviceNotRegistered ErrorCode = ErrorDeviceNotRegistered
+	ErrorCodeMessageTooBig       ErrorCode = ErrorMessageTooBig
+	ErrorCodeMessageRateExceeded ErrorCode = ErrorMessageRateExceeded
+	ErrorCodeMismatchSenderID    ErrorCode = ErrorMismatchSenderID
+	ErrorCodeInvalidCredentials  ErrorCode = ErrorInvalidCredentials
+)
+
+// Error codes of validation, before anything is sent
+const (
+	// ErrorCodeInvalidToken is a malformed or empty push token
+	ErrorCodeInvalidToken ErrorCode = "InvalidToken"
+	// ErrorCodeNoRecipients is a message without recipients
+	ErrorCodeNoRecipients ErrorCode = "NoRecipients"
+	// ErrorCodeInvalidData is message data breaking the schema of its category
+	ErrorCodeInvalidData ErrorCode = "InvalidData"
+	// ErrorCodeInvalidArgument is an argument the call can't work with, e.g.
+	// an invalid recurrence rule or chunk
+	ErrorCodeInvalidArgument ErrorCode = "InvalidArgument"
+	// ErrorCodeUnsupportedAPIVersion is an API version the client doesn't know
+	ErrorCodeUnsupportedAPIVersion ErrorCode = "UnsupportedAPIVersion"
+)
+
+// Error codes of requests to Expo
+const (
+	// ErrorCodeUnauthorized is a 401 or 403 response
+	ErrorCodeUnauthorized ErrorCode = "Unauthorized"
+	// ErrorCodeRateLimited is a 429 response
+	ErrorCodeRateLimited ErrorCode = "RateLimited"
+	// ErrorCodeServerError is a 5xx response
+	ErrorCodeServerError ErrorCode = "ServerError"
+	// ErrorCodeBadRequest is any other non-2xx response
+	ErrorCodeBadRequest ErrorCode = "BadRequest"
+	// ErrorCodeRequestTooLarge is a 413 response
+	ErrorCodeRequestTooLarge ErrorCode = "RequestTooLarge"
+	// ErrorCodeAPIError is a response holding Expo's error objects
+	ErrorCodeAPIError ErrorCode = "APIError"
+	// ErrorCodeInvalidResponse is a response that isn't a valid push response
+	ErrorCodeInvalidResponse ErrorCode = "InvalidResponse"
+	// ErrorCodeResponseTooLarge is a response over the size limit
+	ErrorCodeResponseTooLarge ErrorCode = "ResponseTooLarge"
+	// ErrorCodeNetwork is a failure to reach Expo, e.g. a refused
+	// connection or a DNS error
+	ErrorCodeNetwork ErrorCode = "Network"
+	// ErrorCodeTimeout is a deadline that passed
+	ErrorCodeTimeout ErrorCode = "Timeout"
+	// ErrorCodeCanceled is a canceled context
+	ErrorCodeCanceled ErrorCode = "Canceled"
+	// ErrorCodeCircuitOpen is a call not sent because the circuit is open,
+	// see CircuitBreaker
+	ErrorCodeCircuitOpen ErrorCode = "CircuitOpen"
+)
+
+// Error codes of the other features of the package
+const (
+	// ErrorCodeSuppressed is a message suppressed before sending, e.g. by
+	// dedup or consent
+	ErrorCodeSuppressed ErrorCode = "Suppressed"
+	// ErrorCodeNotFound is a token, secret, tenant or scheduled message
+	// that doesn't exist
+	ErrorCodeNotFound ErrorCode = "NotFound"
+	// ErrorCodeConflict is an operation the current state forbids, e.g.
+	// starting a campaign that is already running
+	ErrorCodeConflict ErrorCode = "Conflict"
+	// ErrorCodeInvalidSignature is a signed request that doesn't verify
+	ErrorCodeInvalidSignature ErrorCode = "InvalidSignature"
+	// ErrorCodeCampaignPaused is a campaign that was paused
+	ErrorCodeCampaignPaused ErrorCode = "CampaignPaused"
+	// ErrorCodeQuotaExceeded is a send over its quota
+	ErrorCodeQuotaExceeded ErrorCode = "QuotaExceeded"
+	// ErrorCodeApprovalRequired is a campaign waiting for, or refused, an
+	// approval
+	ErrorCodeApprovalRequired ErrorCode = "ApprovalRequired"
+	// ErrorCodeDecryptionFailed is an encrypted payload that can't be opened
+	ErrorCodeDecryptionFailed ErrorCode = "DecryptionFailed"
+	// ErrorCodeReceiptExpired is a receipt that never became available
+	ErrorCodeReceiptExpired ErrorCode = "ReceiptExpired"
+	// ErrorCodePanic is a panic recovered in a background goroutine
+	ErrorCodePanic ErrorCode = "Panic"
+	// ErrorCodeUnknown is any other error, e.g. one returned by a
+	// user-provided store
+	ErrorCodeUnknown ErrorCode = "Unknown"
+)
+
+// ErrorCodeOf returns the code of err, found anywhere in its chain. It
+// returns "" for a nil error and ErrorCodeUnknown for errors without a code.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var coded interface{ ErrorCode() ErrorCode }
+	if errors.As(err, &coded) {
+		if code := coded.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCodeCanceled
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorCodeTimeout
+		}
+		return ErrorCodeNetwork
+	}
+	return ErrorCodeUnknown
+}
+
+// codedError is a sentinel error carrying its code
+type codedError struct {
+	code    ErrorCode
+	message string
+}
+
+func newCodedError(code ErrorCode, message string) error {
+	return &codedError{code: code, message: message}
+}
+
+func (e *codedError) Error() string {
+	return e.message
+}
+
+func (e *codedError) ErrorCode() ErrorCode {
+	return e.code
+}
+
+// ErrorCode classifies the response by its status
+func (e *HTTPError) ErrorCode() ErrorCode {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrorCodeUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case e.StatusCode == http.StatusRequestEntityTooLarge:
+		return ErrorCodeRequestTooLarge
+	case e.StatusCode >= 500:
+		return ErrorCodeServerError
+	}
+	return ErrorCodeBadRequest
+}
+
+// ErrorCode returns ErrorCodeInvalidToken
+func (e *InvalidTokensError) ErrorCode() ErrorCode {
+	return ErrorCodeInvalidToken
+}
+
+// ErrorCode returns the details.error of the ticket or receipt
+func (e *PushResponseError) ErrorCode() ErrorCode {
+	if e.Response != nil {
+		if code := e.Response.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	return ErrorCodeUnknown
+}
+
+// ErrorCode returns ErrorCodeSuppressed
+func (e *SuppressedError) ErrorCode() ErrorCode {
+	return ErrorCodeSuppressed
+}
+
+// ErrorCode returns ErrorCodeCircuitOpen
+func (e *DivertedError) ErrorCode() ErrorCode {
+	return ErrorCodeCircuitOpen
+}
+
+// ErrorCode returns ErrorCodeAPIError
+func (e *PushServerError) ErrorCode() ErrorCode {
+	return ErrorCodeAPIError
+}
+
+// ErrorCode returns ErrorCodeInvalidResponse
+func (e *NonJSONResponseError) ErrorCode() ErrorCode {
+	return ErrorCodeInvalidResponse
+}
+
+// ErrorCode returns ErrorCodePanic
+func (e *PanicError) ErrorCode() ErrorCode {
+	return ErrorCodePanic
+}
@@ -0,0 +1,100 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errorRateBuckets is the number of buckets the guard window is split into
+const errorRateBuckets = 10
+
+// ErrorRateGuard is an EventSink that raises an AlertErrorRate alert when
+// the share of failed deliveries over Window reaches Threshold. It alerts at
+// most once per Window.
+type ErrorRateGuard struct {
+	// Threshold is the failure ratio that trips the guard, e.g. 0.2
+	Threshold float64
+	// Window is the period the ratio is computed over. Defaults to 5 minutes.
+	Window time.Duration
+	// MinEvents is the number of deliveries required in the window before
+	// the guard can trip, so a single failure doesn't page anyone
+	MinEvents int
+	Sink      AlertSink
+	// ErrorReporter receives errors delivering alerts
+	ErrorReporter ErrorReporter
+
+	mu      sync.Mutex
+	buckets [errorRateBuckets]errorRateBucket
+	alerted time.Time
+}
+
+type errorRateBucket struct {
+	start  time.Time
+	sent   int
+	failed int
+}
+
+// HandleEvent counts sent and failed events. The alert is sent from a
+// separate goroutine, so HandleEvent doesn't block.
+func (g *ErrorRateGuard) HandleEvent(event DeliveryEvent) {
+	if event.Type != EventSent && event.Type != EventFailed {
+		return
+	}
+	now := event.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	window := g.window()
+
+	g.mu.Lock()
+	span := window / errorRateBuckets
+	start := now.Truncate(span)
+	b := &g.buckets[int(start.UnixNano()/int64(span))%errorRateBuckets]
+	if !b.start.Equal(start) {
+		*b = errorRateBucket{start: start}
+	}
+	if event.Type == EventFailed {
+		b.failed++
+	} else {
+		b.sent++
+	}
+	var sent, failed int
+	for _, b := range g.buckets {
+		if now.Sub(b.start) < window {
+			sent += b.sent
+			failed += b.failed
+		}
+	}
+	total := sent + failed
+	ratio := float64(failed) / float64(total)
+	trip := total >= g.MinEvents && ratio >= g.Threshold && now.Sub(g.alerted) >= window
+	if trip {
+		g.alerted = now
+	}
+	g.mu.Unlock()
+
+	if trip && g.Sink != nil {
+		alert := Alert{
+			Kind:    AlertErrorRate,
+			Time:    now,
+			Message: fmt.Sprintf("%d of %d deliveries (%.0f%%) failed in the last %s", failed, total, ratio*100, window),
+			Labels:  event.Labels,
+		}
+		go func() {
+			if err := g.Sink.SendAlert(context.Background(), alert); err != nil && g.ErrorReporter != nil {
+				g.ErrorReporter(err)
+			}
+		}()
+	}
+}
+
+func (g *ErrorRateGuard) window() time.Duration {
+	if g.Window <= 0 {
+		return 5 * time.Minute
+	}
+	return g.Window
+}
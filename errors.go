@@ -0,0 +1,137 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultErrorBodyLimit is how much of a non-2xx response body is read and
+// attached to the returned error unless configured otherwise
+const DefaultErrorBodyLimit = 64 << 10
+
+// HTTPError is the base of the errors returned for non-2xx responses.
+// When the body holds Expo's error objects, the embedded PushServerError
+// carries them and its Message is Expo's explanation.
+type HTTPError struct {
+	PushServerError
+	StatusCode int
+	Status     string
+	// Body is the start of the response body, up to the configured limit
+	Body []byte
+	// RetryAfter is how long the server asked to wait before retrying,
+	// from the Retry-After header of 429 and 503 responses
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	message := fmt.Sprintf("invalid response (%d %s)", e.StatusCode, e.Status)
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+func (e *HTTPError) statusCode() int {
+	return e.StatusCode
+}
+
+func (e *HTTPError) retryAfter() time.Duration {
+	return e.RetryAfter
+}
+
+// AuthError is returned for 401 and 403 responses, e.g. when the access
+// token is missing, invalid or revoked
+type AuthError struct {
+	HTTPError
+}
+
+// RateLimitError is returned for 429 responses, when requests are sent too
+// frequently. Slow down and retry later, after RetryAfter if set. The
+// client already retries them, see RetryPolicy.
+type RateLimitError struct {
+	HTTPError
+}
+
+func (e *RateLimitError) Error() string {
+	message := fmt.Sprintf("rate limited (%d %s)", e.StatusCode, e.Status)
+	if e.RetryAfter > 0 {
+		message += fmt.Sprintf(", retry after %v", e.RetryAfter)
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+// ServerError is returned for 5xx responses. These are usually transient.
+type ServerError struct {
+	HTTPError
+}
+
+// ClientError is returned for other 4xx responses, when the request itself
+// is invalid
+type ClientError struct {
+	HTTPError
+}
+
+// newHTTPError classifies a non-2xx response by its status code
+func newHTTPError(resp *http.Response, body []byte) error {
+	statusCode := resp.StatusCode
+	base := HTTPError{StatusCode: statusCode, Status: resp.Status, Body: body}
+	base.Response = resp
+	base.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	var data Response
+	if json.Unmarshal(body, &data) == nil {
+		base.ResponseData = &data
+		base.Errors = data.Errors
+		if len(data.Errors) > 0 {
+			base.Message = data.Errors[0].Message
+		}
+	}
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return &AuthError{base}
+	case statusCode == http.StatusTooManyRequests:
+		return &RateLimitError{base}
+	case statusCode >= 500:
+		return &ServerError{base}
+	case statusCode >= 400:
+		return &ClientError{base}
+	}
+	return &base
+}
+
+// checkStatus returns an error for non-2xx responses. The body is only used
+// for the error, so it is read up to limit, attached, and closed here. With
+// a negative limit the body is drained without being attached.
+func checkStatus(resp *http.Response, limit int64) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	var data []byte
+	if limit > 0 {
+		// A truncated body usually still explains the error
+		data, _ = io.ReadAll(io.LimitReader(resp.Body, limit))
+	}
+	closeBody(resp.Body)
+	return newHTTPError(resp, data)
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP
+// date. It returns zero if the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckStatusTaxonomy(t *testing.T) {
+	for code, check := range map[int]func(error) bool{
+		http.StatusUnauthorized:          func(err error) bool { var e *AuthError; return errors.As(err, &e) },
+		http.StatusForbidden:             func(err error) bool { var e *AuthError; return errors.As(err, &e) },
+		http.StatusTooManyRequests:       func(err error) bool { var e *RateLimitError; return errors.As(err, &e) },
+		http.StatusBadGateway:            func(err error) bool { var e *ServerError; return errors.As(err, &e) },
+		http.StatusRequestEntityTooLarge: func(err error) bool { var e *ClientError; return errors.As(err, &e) },
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+			w.Write([]byte(`{"errors":[{"code":"E","message":"explanation"}]}`))
+		}))
+		client := NewPushClient(&ClientConfig{Host: srv.URL})
+		_, err := client.Publish(&PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}})
+		srv.Close()
+		if !check(err) {
+			t.Errorf("%d: incorrect error type %T", code, err)
+		}
+	}
+}
+
+func TestHTTPErrorAttachesExpoExplanation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"code":"VALIDATION_ERROR","message":"\"to\" must be a string"}]}`))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	_, err := client.Publish(&PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}})
+	var typed *ClientError
+	if !errors.As(err, &typed) {
+		t.Fatalf("Expected ClientError, got %v", err)
+	}
+	if typed.Message != `"to" must be a string` || len(typed.Errors) != 1 {
+		t.Errorf("Expo explanation not attached: %+v", typed.PushServerError)
+	}
+
+	client = NewPushClient(&ClientConfig{Host: srv.URL, ErrorBodyLimit: -1})
+	_, err = client.Publish(&PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}})
+	if !errors.As(err, &typed) || typed.Body != nil {
+		t.Errorf("Body attached despite negative limit: %v", err)
+	}
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	for err, want := range map[error]ErrorCode{
+		nil:               "",
+		ErrMalformedToken: ErrorCodeInvalidToken,
+		fmt.Errorf("wrapped: %w", ErrResponseTooLarge):                        ErrorCodeResponseTooLarge,
+		&InvalidTokensError{}:                                                 ErrorCodeInvalidToken,
+		&RateLimitError{HTTPError{StatusCode: http.StatusTooManyRequests}}:    ErrorCodeRateLimited,
+		&ClientError{HTTPError{StatusCode: http.StatusRequestEntityTooLarge}}: ErrorCodeRequestTooLarge,
+		&DeviceNotRegisteredError{PushResponseError{&PushResponse{Details: map[string]string{"error": ErrorDeviceNotRegistered}}}}: ErrorCodeDeviceNotRegistered,
+		&SuppressedError{}:                               ErrorCodeSuppressed,
+		&NonJSONResponseError{}:                          ErrorCodeInvalidResponse,
+		fmt.Errorf("send: %w", context.DeadlineExceeded): ErrorCodeTimeout,
+		errors.New("store down"):                         ErrorCodeUnknown,
+	} {
+		if code := ErrorCodeOf(err); code != want {
+			t.Errorf("ErrorCodeOf(%v) = %q, want %q", err, code, want)
+		}
+	}
+
+	// Errors of a failed publish
+	client := NewPushClient(nil)
+	if _, err := client.Publish(&PushMessage{}); ErrorCodeOf(err) != ErrorCodeNoRecipients {
+		t.Errorf("Incorrect code of %v: %q", err, ErrorCodeOf(err))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	client = NewPushClient(&ClientConfig{Host: srv.URL})
+	_, err := client.Publish(&PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}})
+	if ErrorCodeOf(err) != ErrorCodeUnauthorized {
+		t.Errorf("Incorrect code of %v: %q", err, ErrorCodeOf(err))
+	}
+}
@@ -0,0 +1,82 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultRequestLatency is the duration of a push request assumed by
+// EstimateDuration unless the model says otherwise
+const DefaultRequestLatency = 500 * time.Millisecond
+
+// ThroughputModel describes the sending conditions of a campaign for
+// EstimateDuration
+type ThroughputModel struct {
+	// Rate is the rate limit in notifications per second.
+	// Defaults to DefaultRateLimit.
+	Rate float64
+	// Latency is the expected duration of one request.
+	// Defaults to DefaultRequestLatency.
+	Latency time.Duration
+	// Concurrency is the number of requests in flight at once. Defaults to 1,
+	// as a Campaign sends its chunks one after the other.
+	Concurrency int
+}
+
+// EstimateDuration returns how long sending the whole campaign should take,
+// bounded either by request latency or by the rate limit, whichever is
+// slower. Blackout windows are not accounted for.
+func EstimateDuration(campaign *Campaign, model ThroughputModel) time.Duration {
+	if model.Rate <= 0 {
+		model.Rate = DefaultRateLimit
+	}
+	if model.Latency <= 0 {
+		model.Latency = DefaultRequestLatency
+	}
+	if model.Concurrency <= 0 {
+		model.Concurrency = 1
+	}
+	chunks := len(campaign.chunks())
+	rounds := (chunks + model.Concurrency - 1) / model.Concurrency
+	byLatency := time.Duration(rounds) * model.Latency
+	byRate := time.Duration(math.Ceil(float64(countRecipients(campaign.Messages)) / model.Rate * float64(time.Second)))
+	return max(byLatency, byRate)
+}
+
+// CampaignProgress is a snapshot of a running or finished campaign
+type CampaignProgress struct {
+	Chunks     int
+	ChunksSent int
+	// Started is when the current run started
+	Started time.Time
+	// ETA is the estimated time left, based on the pace of the current run.
+	// It is zero until the first chunk of the run was sent.
+	ETA time.Duration
+}
+
+// Progress returns the progress of the campaign and its live ETA
+func (c *Campaign) Progress() CampaignProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.progress
+	if sentThisRun := p.ChunksSent - c.resumedAt; sentThisRun > 0 && c.state == CampaignRunning {
+		perChunk := time.Since(p.Started) / time.Duration(sentThisRun)
+		p.ETA = perChunk * time.Duration(p.Chunks-p.ChunksSent)
+	}
+	return p
+}
+
+func (c *Campaign) startProgress(chunks, sent int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.progress = CampaignProgress{Chunks: chunks, ChunksSent: sent, Started: time.Now()}
+	c.resumedAt = sent
+}
+
+func (c *Campaign) advanceProgress() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.progress.ChunksSent++
+}
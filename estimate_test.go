@@ -0,0 +1,38 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEstimateDuration(t *testing.T) {
+	campaign := &Campaign{Messages: testMessages(1200)}
+	// 12 chunks of 100 at 1s each, or 1200 notifications at 600/s
+	if d := EstimateDuration(campaign, ThroughputModel{Latency: time.Second}); d != 12*time.Second {
+		t.Errorf("Expected latency-bound 12s, got %v", d)
+	}
+	if d := EstimateDuration(campaign, ThroughputModel{Latency: time.Millisecond, Concurrency: 4}); d != 2*time.Second {
+		t.Errorf("Expected rate-bound 2s, got %v", d)
+	}
+}
+
+func TestCampaignProgress(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	campaign := &Campaign{
+		ID:        "c",
+		Messages:  testMessages(30),
+		ChunkSize: 10,
+		Client:    NewPushClient(&ClientConfig{Host: srv.URL}),
+	}
+	campaign.OnChunk = func(chunk int, _ []PushResponse) {
+		if p := campaign.Progress(); p.ChunksSent != chunk+1 || p.Chunks != 3 || (chunk < 2 && p.ETA <= 0) {
+			t.Errorf("Incorrect progress after chunk %d: %+v", chunk, p)
+		}
+	}
+	if err := campaign.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,156 @@
+package expo
+
+import (
+	"context"
+	"time"
+)
+
+// EventType is the kind of a DeliveryEvent
+type EventType string
+
+const (
+	// EventSent is emitted when Expo accepted a message for a token
+	EventSent EventType = "sent"
+	// EventFailed is emitted when a ticket reported an error for a token,
+	// or the request carrying the message failed
+	EventFailed EventType = "failed"
+	// EventSuppressed is emitted when the client dropped a token, e.g. as a
+	// duplicate. ErrorCode holds the suppression reason.
+	EventSuppressed EventType = "suppressed"
+	// EventPruned records that an application removed a dead token.
+	// The client doesn't emit it; token stores and applications do.
+	EventPruned EventType = "pruned"
+	// EventOpened records that the user opened the notification, as
+	// reported by the app. See Tracker.RecordOpen.
+	EventOpened EventType = "opened"
+	// EventHeldOut records that a recipient was held out of a campaign as
+	// part of its control group, see Campaign.Holdout
+	EventHeldOut EventType = "heldout"
+)
+
+// Labels with a conventional meaning in events and reports
+const (
+	// LabelApp names the app or Expo project a call belongs to
+	LabelApp = "app"
+	// LabelCampaign is the ID of the campaign that made the call
+	LabelCampaign = "campaign"
+	// LabelTenant is the ID of the Registry tenant whose client made the call
+	LabelTenant = "tenant"
+)
+
+// ErrorRequestFailed is the ErrorCode of EventFailed events emitted when the
+// whole request failed, rather than a single ticket
+const ErrorRequestFailed = "RequestFailed"
+
+// DeliveryEvent describes what happened to a message for one token
+type DeliveryEvent struct {
+	Type     EventType         `json:"type"`
+	Time     time.Time         `json:"time"`
+	Token    ExponentPushToken `json:"token"`
+	TicketID string            `json:"ticketId,omitempty"`
+	// ErrorCode is the details.error of a failed ticket, ErrorRequestFailed,
+	// or the reason of a suppression
+	ErrorCode string `json:"errorCode,omitempty"`
+	Message   string `json:"message,omitempty"`
+	// Locale is the locale of the content delivered, see Localizer
+	Locale string `json:"locale,omitempty"`
+	// Labels are the labels of the call that sent the message
+	Labels map[string]string `json:"labels,omitempty"`
+	// CorrelationID is the PushMessage.CorrelationID of the message
+	CorrelationID string `json:"correlationId,omitempty"`
+}
+
+// EventSink receives delivery events. HandleEvent is called synchronously
+// from publish calls, so it must not block for long.
+type EventSink interface {
+	HandleEvent(event DeliveryEvent)
+}
+
+// EventSinkFunc adapts a function to the EventSink interface
+type EventSinkFunc func(event DeliveryEvent)
+
+// HandleEvent calls f
+func (f EventSinkFunc) HandleEvent(event DeliveryEvent) {
+	f(event)
+}
+
+// MultiEventSink sends every event to all the given sinks
+func MultiEventSink(sinks ...EventSink) EventSink {
+	return EventSinkFunc(func(event DeliveryEvent) {
+		for _, sink := range sinks {
+			sink.HandleEvent(event)
+		}
+	})
+}
+
+// emitResponses emits the events of a publish call that got responses.
+// dropped holds, per message, the recipients the client filtered out.
+func (c *PushClient) emitResponses(ctx context.Context, responses []PushResponse, dropped []filterResult) {
+	if c.eventSink == nil {
+		return
+	}
+	now := time.Now()
+	labels := CallLabels(ctx)
+	for i := range responses {
+		r := &responses[i]
+		for _, d := range dropped[i].dropped {
+			c.emitSuppressed(now, d.token, d.reason, &dropped[i].message, labels)
+		}
+		// Messages of failed chunks have no response and got a failure
+		// event; diverted ones get their events once published again
+		if r.Status == SuppressedStatus || r.Status == DivertedStatus || r.Status == "" {
+			continue
+		}
+		event := DeliveryEvent{
+			Type:          EventSent,
+			Time:          now,
+			TicketID:      r.ID,
+			Locale:        r.PushMessage.Locale,
+			Labels:        labels,
+			CorrelationID: r.PushMessage.CorrelationID,
+		}
+		if !r.isSuccess() {
+			event.Type = EventFailed
+			event.ErrorCode = string(r.ErrorCode())
+			event.Message = r.Message
+		}
+		for _, token := range r.PushMessage.To {
+			event.Token = token
+			c.eventSink.HandleEvent(event)
+		}
+	}
+}
+
+func (c *PushClient) emitSuppressed(now time.Time, token ExponentPushToken, reason string, message *PushMessage, labels map[string]string) {
+	c.eventSink.HandleEvent(DeliveryEvent{
+		Type:          EventSuppressed,
+		Time:          now,
+		Token:         token,
+		ErrorCode:     reason,
+		Locale:        message.Locale,
+		Labels:        labels,
+		CorrelationID: message.CorrelationID,
+	})
+}
+
+// emitFailure emits the events of a request that failed as a whole
+func (c *PushClient) emitFailure(ctx context.Context, messages []PushMessage, err error) {
+	if c.eventSink == nil {
+		return
+	}
+	event := DeliveryEvent{
+		Type:      EventFailed,
+		Time:      time.Now(),
+		ErrorCode: ErrorRequestFailed,
+		Message:   err.Error(),
+		Labels:    CallLabels(ctx),
+	}
+	for i := range messages {
+		event.Locale = messages[i].Locale
+		event.CorrelationID = messages[i].CorrelationID
+		for _, token := range messages[i].To {
+			event.Token = token
+			c.eventSink.HandleEvent(event)
+		}
+	}
+}
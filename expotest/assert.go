@@ -0,0 +1,146 @@
+package expotest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// Recorder is anything that records sent messages, such as Server
+type Recorder interface {
+	Messages() []expo.PushMessage
+}
+
+// Matcher selects messages in assertions
+type Matcher interface {
+	Match(message expo.PushMessage) bool
+	// String describes the matcher in assertion failures
+	String() string
+}
+
+type matcher struct {
+	match       func(expo.PushMessage) bool
+	description string
+}
+
+func (m matcher) Match(message expo.PushMessage) bool { return m.match(message) }
+func (m matcher) String() string                      { return m.description }
+
+// To matches messages sent to token
+func To(token expo.ExponentPushToken) Matcher {
+	return matcher{func(m expo.PushMessage) bool {
+		for _, t := range m.To {
+			if t == token {
+				return true
+			}
+		}
+		return false
+	}, fmt.Sprintf("to %s", token)}
+}
+
+// TitleContains matches messages whose title contains s
+func TitleContains(s string) Matcher {
+	return matcher{func(m expo.PushMessage) bool {
+		return strings.Contains(m.Title, s)
+	}, fmt.Sprintf("title containing %q", s)}
+}
+
+// BodyContains matches messages whose body contains s
+func BodyContains(s string) Matcher {
+	return matcher{func(m expo.PushMessage) bool {
+		return strings.Contains(m.Body, s)
+	}, fmt.Sprintf("body containing %q", s)}
+}
+
+// HasData matches messages holding all the given data keys
+func HasData(keys ...string) Matcher {
+	return matcher{func(m expo.PushMessage) bool {
+		for _, k := range keys {
+			if _, ok := m.Data[k]; !ok {
+				return false
+			}
+		}
+		return true
+	}, fmt.Sprintf("data keys %v", keys)}
+}
+
+// DataEquals matches messages whose data holds key with the given value
+func DataEquals(key, value string) Matcher {
+	return matcher{func(m expo.PushMessage) bool {
+		v, ok := m.Data[key]
+		return ok && v == value
+	}, fmt.Sprintf("data %s=%q", key, value)}
+}
+
+// Priority matches messages with the given priority
+func Priority(priority string) Matcher {
+	return matcher{func(m expo.PushMessage) bool {
+		return m.Priority == priority
+	}, fmt.Sprintf("priority %q", priority)}
+}
+
+// AssertSent fails the test unless at least one recorded message matches all matchers
+func AssertSent(t testing.TB, recorder Recorder, matchers ...Matcher) {
+	t.Helper()
+	messages := recorder.Messages()
+	if len(matching(messages, matchers)) == 0 {
+		t.Errorf("expected a message matching %s, got %d messages:\n%s",
+			describe(matchers), len(messages), dump(messages))
+	}
+}
+
+// AssertSentCount fails the test unless exactly n recorded messages match all matchers
+func AssertSentCount(t testing.TB, recorder Recorder, n int, matchers ...Matcher) {
+	t.Helper()
+	messages := recorder.Messages()
+	if got := len(matching(messages, matchers)); got != n {
+		t.Errorf("expected %d messages matching %s, got %d of %d messages:\n%s",
+			n, describe(matchers), got, len(messages), dump(messages))
+	}
+}
+
+// AssertNotSent fails the test if any recorded message matches all matchers
+func AssertNotSent(t testing.TB, recorder Recorder, matchers ...Matcher) {
+	t.Helper()
+	if found := matching(recorder.Messages(), matchers); len(found) > 0 {
+		t.Errorf("expected no message matching %s, got:\n%s", describe(matchers), dump(found))
+	}
+}
+
+func matching(messages []expo.PushMessage, matchers []Matcher) []expo.PushMessage {
+	var found []expo.PushMessage
+	for _, message := range messages {
+		ok := true
+		for _, m := range matchers {
+			if !m.Match(message) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			found = append(found, message)
+		}
+	}
+	return found
+}
+
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return "anything"
+	}
+	parts := make([]string, len(matchers))
+	for i, m := range matchers {
+		parts[i] = m.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func dump(messages []expo.PushMessage) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "\tto %v: title %q body %q data %v priority %q\n", m.To, m.Title, m.Body, m.Data, m.Priority)
+	}
+	return b.String()
+}
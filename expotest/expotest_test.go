@@ -0,0 +1,135 @@
+package expotest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func TestAssertSent(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	message := &expo.PushMessage{
+		To:       []expo.ExponentPushToken{"ExponentPushToken[a]"},
+		Title:    "Your order shipped",
+		Data:     map[string]string{"orderId": "42"},
+		Priority: expo.HighPriority,
+	}
+	response, err := srv.Client().Publish(message)
+	if err != nil || response.ValidateResponse() != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	AssertSent(t, srv, To("ExponentPushToken[a]"), TitleContains("shipped"), HasData("orderId"), Priority(expo.HighPriority))
+	AssertSentCount(t, srv, 1, DataEquals("orderId", "42"))
+	AssertNotSent(t, srv, To("ExponentPushToken[b]"))
+
+	failing := &testing.T{}
+	AssertSent(failing, srv, TitleContains("cancelled"))
+	if !failing.Failed() {
+		t.Error("AssertSent passed without a matching message")
+	}
+}
+
+func TestServerTicketsAndReceipts(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetTickets(func(message expo.PushMessage) expo.PushResponse {
+		if message.To[0] == "ExponentPushToken[gone]" {
+			return expo.PushResponse{Status: "error", Details: map[string]string{"error": expo.ErrorDeviceNotRegistered}}
+		}
+		return expo.PushResponse{Status: expo.SuccessStatus}
+	})
+	srv.SetReceipts(func(id string) (expo.PushReceipt, bool) {
+		return expo.PushReceipt{}, false
+	})
+	client := srv.Client()
+	responses, err := client.PublishMultiple([]expo.PushMessage{
+		{To: []expo.ExponentPushToken{"ExponentPushToken[a]"}},
+		{To: []expo.ExponentPushToken{"ExponentPushToken[gone]"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responses[0].ID == "" || responses[1].ErrorCode() != expo.ErrorCodeDeviceNotRegistered {
+		t.Errorf("Incorrect tickets %+v", responses)
+	}
+	receipts, err := client.GetReceipts([]string{responses[0].ID})
+	if err != nil || len(receipts) != 0 {
+		t.Errorf("Expected no receipt ready, got %v, %v", receipts, err)
+	}
+	requests := srv.Requests()
+	if len(requests) != 2 || len(requests[0].Messages) != 2 || requests[1].IDs[0] != responses[0].ID {
+		t.Errorf("Incorrect requests %+v", requests)
+	}
+
+	// Larger sets are split like Expo requires
+	srv.Reset()
+	srv.SetReceipts(nil)
+	ids := make([]string, 2500)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	receipts, err = client.GetReceipts(ids)
+	if err != nil || len(receipts) != len(ids) || len(srv.Requests()) != 3 {
+		t.Errorf("Expected %d receipts in 3 requests, got %d in %d, %v", len(ids), len(receipts), len(srv.Requests()), err)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.InjectFaults(RateLimited(time.Second), Malformed("<html>maintenance</html>"))
+	client := srv.Client()
+	message := &expo.PushMessage{To: []expo.ExponentPushToken{"ExponentPushToken[a]"}}
+
+	_, err := client.PublishContext(context.Background(), message, expo.WithNoRetry())
+	var rateLimited *expo.RateLimitError
+	if !errors.As(err, &rateLimited) || rateLimited.RetryAfter != time.Second {
+		t.Errorf("Expected a 429 with Retry-After, got %v", err)
+	}
+	_, err = client.Publish(message)
+	var malformed *expo.NonJSONResponseError
+	if !errors.As(err, &malformed) {
+		t.Errorf("Expected a malformed response, got %v", err)
+	}
+	if _, err := client.Publish(message); err != nil {
+		t.Errorf("Faults not consumed: %v", err)
+	}
+	if len(srv.Requests()) != 3 || len(srv.Messages()) != 1 {
+		t.Errorf("%d requests and %d messages recorded, want 3 and 1", len(srv.Requests()), len(srv.Messages()))
+	}
+}
+
+func TestServerMessageLimit(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	messages := make([]expo.PushMessage, expo.MaxMessagesPerRequest+1)
+	for i := range messages {
+		messages[i].To = []expo.ExponentPushToken{expo.ExponentPushToken(fmt.Sprintf("ExponentPushToken[%d]", i))}
+	}
+	body, _ := json.Marshal(messages)
+	resp, err := http.Post(srv.URL+"/--/api/v2/push/send", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || len(srv.Messages()) != 0 {
+		t.Errorf("Expected a request over the limit refused, got %d with %d messages accepted", resp.StatusCode, len(srv.Messages()))
+	}
+
+	// The client chunks them like Expo requires
+	if _, err := srv.Client().PublishMultiple(messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Messages()) != len(messages) || len(srv.Requests()) != 3 {
+		t.Errorf("Expected %d messages in 2 more requests, got %d in %d", len(messages), len(srv.Messages()), len(srv.Requests())-1)
+	}
+}
@@ -0,0 +1,99 @@
+package expotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// UpdateGoldenEnv is the environment variable that, when set to 1, makes
+// GoldenTransport rewrite golden files instead of comparing against them
+const UpdateGoldenEnv = "EXPOTEST_UPDATE_GOLDEN"
+
+// GoldenTransport is an http.RoundTripper that records the canonicalized
+// payload of every request and, when the test ends, compares them with a
+// golden file. It catches accidental payload changes such as dropped fields
+// or renamed keys. Run the tests with EXPOTEST_UPDATE_GOLDEN=1 to accept
+// the current payloads.
+type GoldenTransport struct {
+	t    testing.TB
+	path string
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	payloads bytes.Buffer
+}
+
+// NewGoldenTransport creates a transport comparing payloads with the golden
+// file at path. Requests are forwarded to next, or http.DefaultTransport if nil.
+func NewGoldenTransport(t testing.TB, path string, next http.RoundTripper) *GoldenTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	g := &GoldenTransport{t: t, path: path, next: next}
+	t.Cleanup(g.verify)
+	return g
+}
+
+// RoundTrip records the request payload and forwards the request
+func (g *GoldenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	g.mu.Lock()
+	fmt.Fprintf(&g.payloads, "%s %s\n%s\n\n", req.Method, req.URL.Path, canonicalize(body))
+	g.mu.Unlock()
+	return g.next.RoundTrip(req)
+}
+
+// canonicalize indents JSON with sorted keys so golden files diff well.
+// Bodies that aren't JSON are kept as they are.
+func canonicalize(body []byte) []byte {
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	canonical, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+func (g *GoldenTransport) verify() {
+	g.mu.Lock()
+	got := g.payloads.Bytes()
+	g.mu.Unlock()
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(g.path), 0o755); err != nil {
+			g.t.Fatal(err)
+		}
+		if err := os.WriteFile(g.path, got, 0o644); err != nil {
+			g.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(g.path)
+	if err != nil {
+		g.t.Errorf("reading golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		g.t.Errorf("payloads differ from golden file %s (run with %s=1 to update)\ngot:\n%s\nwant:\n%s",
+			g.path, UpdateGoldenEnv, got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package expotest
+
+import (
+	"testing"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+func TestGoldenTransport(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.Transport = NewGoldenTransport(t, "testdata/publish.golden", nil)
+	client := expo.NewPushClient(config)
+	_, err := client.Publish(&expo.PushMessage{
+		To:       []expo.ExponentPushToken{"ExponentPushToken[a]"},
+		Title:    "Hello",
+		Body:     "World",
+		Data:     map[string]string{"b": "2", "a": "1"},
+		Priority: expo.HighPriority,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
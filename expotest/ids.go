@@ -0,0 +1,13 @@
+package expotest
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// ticketID returns a random ID formatted like Expo's ticket IDs
+func ticketID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,264 @@
+// Package expotest provides helpers for testing code that sends push
+// notifications with the expo package.
+package expotest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// Request is a request received by the Server
+type Request struct {
+	Path   string
+	Header http.Header
+	// Body is the decompressed request body
+	Body []byte
+	// Messages are the messages of a send request
+	Messages []expo.PushMessage
+	// IDs are the ticket IDs of a receipts request
+	IDs []string
+}
+
+// Fault is an error response the Server answers a request with instead of
+// handling it, see InjectFaults
+type Fault struct {
+	Status int
+	// Body defaults to an empty body
+	Body string
+	// ContentType defaults to application/json
+	ContentType string
+	// RetryAfter sets the Retry-After header, in seconds
+	RetryAfter time.Duration
+}
+
+// RateLimited is a 429 answered when requests are sent too frequently
+func RateLimited(retryAfter time.Duration) Fault {
+	return Fault{
+		Status:     http.StatusTooManyRequests,
+		Body:       `{"errors":[{"code":"TOO_MANY_REQUESTS","message":"rate limit exceeded","isTransient":true}]}`,
+		RetryAfter: retryAfter,
+	}
+}
+
+// Malformed is a 200 whose body isn't a push response, e.g. the page of a
+// captive portal or a proxy
+func Malformed(body string) Fault {
+	return Fault{Status: http.StatusOK, Body: body, ContentType: "text/html"}
+}
+
+// Server is a fake Expo push server. It records every request, answers
+// with the tickets and receipts it is configured with, accepting every
+// message and reporting every ticket delivered by default, and can inject
+// faults such as 429s and malformed bodies. Like Expo, it refuses requests
+// with more than 100 messages or 1000 receipt IDs.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+	messages []expo.PushMessage
+	faults   []Fault
+	tickets  func(message expo.PushMessage) expo.PushResponse
+	receipts func(id string) (expo.PushReceipt, bool)
+}
+
+// NewServer starts a fake Expo push server. Callers must Close it.
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/--/api/v2/push/send", s.handleSend)
+	mux.HandleFunc("/--/api/v2/push/getReceipts", s.handleGetReceipts)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns a client configuration pointing at the server
+func (s *Server) Config() *expo.ClientConfig {
+	return &expo.ClientConfig{Host: s.URL}
+}
+
+// Client returns a push client sending to the server
+func (s *Server) Client() *expo.PushClient {
+	return expo.NewPushClient(s.Config())
+}
+
+// Messages returns the messages accepted so far, in order
+func (s *Server) Messages() []expo.PushMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]expo.PushMessage(nil), s.messages...)
+}
+
+// Requests returns the requests received so far, in order, including the
+// ones answered with a fault
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset forgets the requests and messages received so far, and the faults
+// not injected yet
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.messages = nil
+	s.faults = nil
+}
+
+// SetTickets answers every message sent with the ticket fn returns. The
+// server assigns an ID to successful tickets without one.
+func (s *Server) SetTickets(fn func(message expo.PushMessage) expo.PushResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickets = fn
+}
+
+// SetReceipts answers every receipt requested with the receipt fn returns.
+// When fn returns false the receipt is left out, as if it wasn't ready.
+func (s *Server) SetReceipts(fn func(id string) (expo.PushReceipt, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receipts = fn
+}
+
+// InjectFaults answers the next requests, one fault each, with faults
+// instead of handling them
+func (s *Server) InjectFaults(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// receive records a request and returns the fault to answer it with, if any
+func (s *Server) receive(request Request) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+	if len(s.faults) == 0 {
+		return Fault{}, false
+	}
+	fault := s.faults[0]
+	s.faults = s.faults[1:]
+	return fault, true
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var messages []expo.PushMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if fault, ok := s.receive(Request{Path: r.URL.Path, Header: r.Header, Body: body, Messages: messages}); ok {
+		writeFault(w, fault)
+		return
+	}
+	// Like Expo, refuse requests over the limit
+	if len(messages) > expo.MaxMessagesPerRequest {
+		writeFault(w, Fault{
+			Status: http.StatusBadRequest,
+			Body:   `{"errors":[{"code":"PUSH_TOO_MANY_NOTIFICATIONS","message":"too many push notifications in one request"}]}`,
+		})
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, messages...)
+	tickets := s.tickets
+	s.mu.Unlock()
+	response := expo.Response{Data: make([]expo.PushResponse, len(messages))}
+	for i := range messages {
+		ticket := expo.PushResponse{Status: expo.SuccessStatus}
+		if tickets != nil {
+			ticket = tickets(messages[i])
+		}
+		if ticket.Status == expo.SuccessStatus && ticket.ID == "" {
+			ticket.ID = ticketID()
+		}
+		response.Data[i] = ticket
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleGetReceipts(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if fault, ok := s.receive(Request{Path: r.URL.Path, Header: r.Header, Body: body, IDs: request.IDs}); ok {
+		writeFault(w, fault)
+		return
+	}
+	// Like Expo, refuse requests over the limit
+	if len(request.IDs) > expo.MaxReceiptIDsPerRequest {
+		writeFault(w, Fault{
+			Status: http.StatusBadRequest,
+			Body:   `{"errors":[{"code":"VALIDATION_ERROR","message":"too many receipt IDs"}]}`,
+		})
+		return
+	}
+
+	s.mu.Lock()
+	fn := s.receipts
+	s.mu.Unlock()
+	receipts := make(map[string]expo.PushReceipt, len(request.IDs))
+	for _, id := range request.IDs {
+		if fn == nil {
+			receipts[id] = expo.PushReceipt{Status: expo.SuccessStatus}
+		} else if receipt, ok := fn(id); ok {
+			receipts[id] = receipt
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": receipts})
+}
+
+// readBody reads the request body, decompressing it if the client gzipped it
+func readBody(r *http.Request) ([]byte, error) {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	return io.ReadAll(body)
+}
+
+func writeFault(w http.ResponseWriter, fault Fault) {
+	contentType := fault.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Seconds())))
+	}
+	w.WriteHeader(fault.Status)
+	io.WriteString(w, fault.Body)
+}
@@ -0,0 +1,182 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults of FailoverTransport
+const (
+	DefaultFailoverThreshold    = 0.5
+	DefaultFailoverMinRequests  = 10
+	DefaultFailoverWindow       = time.Minute
+	DefaultFailoverCooldown     = 30 * time.Second
+	DefaultFailoverWarmInterval = 30 * time.Second
+)
+
+// FailoverTransport sends requests through Primary, and through Standby,
+// e.g. a proxy in another region, while the primary's error rate is high.
+// Transport errors and 5xx responses count as failures. After Cooldown
+// the primary gets requests again. Run keeps the standby's connections
+// warm so switching costs no handshake. Use it as ClientConfig.Transport
+// for latency-critical transactional pushes.
+type FailoverTransport struct {
+	// Primary defaults to http.DefaultTransport
+	Primary http.RoundTripper
+	Standby http.RoundTripper
+	// Threshold is the failure ratio of the primary, over Window, that
+	// switches to the standby. Defaults to DefaultFailoverThreshold.
+	Threshold float64
+	// MinRequests is the number of requests in the window required to
+	// switch. Defaults to DefaultFailoverMinRequests.
+	MinRequests int
+	// Window defaults to DefaultFailoverWindow
+	Window time.Duration
+	// Cooldown is how long the standby is used. Defaults to DefaultFailoverCooldown.
+	Cooldown time.Duration
+	// WarmURL is requested through the standby every WarmInterval by Run,
+	// e.g. the Expo API URL
+	WarmURL string
+	// WarmInterval defaults to DefaultFailoverWarmInterval
+	WarmInterval time.Duration
+	// OnSwitch is called when requests move to the standby, and back
+	OnSwitch func(standby bool)
+	// ErrorReporter receives the errors of warm-up requests
+	ErrorReporter ErrorReporter
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	failures    int
+	// standbyUntil is when the primary gets requests again
+	standbyUntil time.Time
+	now          func() time.Time
+}
+
+// NewFailoverTransport creates a FailoverTransport with the default settings
+func NewFailoverTransport(primary, standby http.RoundTripper) *FailoverTransport {
+	return &FailoverTransport{Primary: primary, Standby: standby}
+}
+
+// RoundTrip sends req through the active path
+func (t *FailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.UsingStandby() {
+		return t.Standby.RoundTrip(req)
+	}
+	primary := t.Primary
+	if primary == nil {
+		primary = http.DefaultTransport
+	}
+	resp, err := primary.RoundTrip(req)
+	t.record(err != nil || resp.StatusCode >= 500)
+	return resp, err
+}
+
+// UsingStandby reports whether requests currently go through the standby
+func (t *FailoverTransport) UsingStandby() bool {
+	now := t.clock()
+	t.mu.Lock()
+	standby := now.Before(t.standbyUntil)
+	recovered := !standby && !t.standbyUntil.IsZero()
+	if recovered {
+		t.standbyUntil = time.Time{}
+	}
+	t.mu.Unlock()
+	if recovered && t.OnSwitch != nil {
+		t.OnSwitch(false)
+	}
+	return standby
+}
+
+// record counts a request of the primary and switches to the standby if
+// the error rate tripped
+func (t *FailoverTransport) record(failed bool) {
+	window := t.Window
+	if window <= 0 {
+		window = DefaultFailoverWindow
+	}
+	threshold := t.Threshold
+	if threshold <= 0 {
+		threshold = DefaultFailoverThreshold
+	}
+	minRequests := t.MinRequests
+	if minRequests <= 0 {
+		minRequests = DefaultFailoverMinRequests
+	}
+	cooldown := t.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultFailoverCooldown
+	}
+	now := t.clock()
+	t.mu.Lock()
+	if now.Sub(t.windowStart) >= window {
+		t.windowStart, t.requests, t.failures = now, 0, 0
+	}
+	t.requests++
+	if failed {
+		t.failures++
+	}
+	trip := t.Standby != nil && t.requests >= minRequests &&
+		float64(t.failures)/float64(t.requests) >= threshold && !now.Before(t.standbyUntil)
+	if trip {
+		t.standbyUntil = now.Add(cooldown)
+		// The primary starts over when it gets requests again
+		t.windowStart, t.requests, t.failures = time.Time{}, 0, 0
+	}
+	t.mu.Unlock()
+	if trip && t.OnSwitch != nil {
+		t.OnSwitch(true)
+	}
+}
+
+// Run requests WarmURL through the standby every WarmInterval until ctx is
+// done, so its connections stay open
+func (t *FailoverTransport) Run(ctx context.Context) error {
+	return supervise(ctx, "failover warm-up", t.ErrorReporter, func(ctx context.Context) error {
+		interval := t.WarmInterval
+		if interval <= 0 {
+			interval = DefaultFailoverWarmInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := t.warm(ctx); err != nil && ctx.Err() == nil && t.ErrorReporter != nil {
+				t.ErrorReporter(err)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// warm sends a HEAD request to WarmURL through the standby. Any HTTP
+// response counts as success.
+func (t *FailoverTransport) warm(ctx context.Context) error {
+	if t.Standby == nil || t.WarmURL == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, t.WarmURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.Standby.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	closeBody(resp.Body)
+	return nil
+}
+
+func (t *FailoverTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,55 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestFailoverTransport(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	defer srv.Close()
+	primaryCalls := 0
+	primary := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		primaryCalls++
+		return nil, errors.New("connection reset")
+	})
+	now := time.Now()
+	var switches []bool
+	transport := NewFailoverTransport(primary, http.DefaultTransport)
+	transport.MinRequests = 2
+	transport.OnSwitch = func(standby bool) { switches = append(switches, standby) }
+	transport.now = func() time.Time { return now }
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Transport: transport})
+
+	message := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}}
+	for range 2 {
+		if _, err := client.Publish(message); err == nil {
+			t.Fatal("Expected the primary to fail")
+		}
+	}
+	if !transport.UsingStandby() {
+		t.Fatal("Expected a switch to the standby")
+	}
+	if _, err := client.Publish(message); err != nil || calls.Load() != 1 {
+		t.Errorf("Expected the standby to send, got %d requests, %v", calls.Load(), err)
+	}
+
+	now = now.Add(DefaultFailoverCooldown)
+	client.Publish(message)
+	if primaryCalls != 3 {
+		t.Errorf("Expected the primary to get requests after the cooldown, got %d", primaryCalls)
+	}
+	if len(switches) != 2 || !switches[0] || switches[1] {
+		t.Errorf("Incorrect switches: %v", switches)
+	}
+}
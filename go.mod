@@ -1,5 +1,3 @@
 module github.com/montovaneli/go-expo-notification
 
 go 1.22.1
-
-require github.com/opus-domini/fast-shot v0.10.0
@@ -0,0 +1,47 @@
+package expo
+
+// Grouping keys stored in PushMessage.Data. Expo does not group notifications
+// itself; the app reads these keys to thread and stack related notifications,
+// so server and client must agree on their names.
+const (
+	// DataKeyThreadID identifies the conversation a notification belongs to,
+	// e.g. a chat room. Notifications with the same thread are shown together.
+	DataKeyThreadID = "threadId"
+	// DataKeyGroupID identifies a broader group of notifications, e.g. all
+	// order updates, that the app may collapse under a summary
+	DataKeyGroupID = "groupId"
+	// DataKeyGroupSummary holds the summary text shown for a collapsed group
+	DataKeyGroupSummary = "groupSummary"
+)
+
+// SetThreadID sets the thread identifier consumed by the app
+func (m *PushMessage) SetThreadID(id string) *PushMessage {
+	return m.setData(DataKeyThreadID, id)
+}
+
+// ThreadID returns the thread identifier, if any
+func (m *PushMessage) ThreadID() string {
+	return m.Data[DataKeyThreadID]
+}
+
+// SetGroup sets the group identifier and, if not empty, the group summary
+func (m *PushMessage) SetGroup(id, summary string) *PushMessage {
+	m.setData(DataKeyGroupID, id)
+	if summary != "" {
+		m.setData(DataKeyGroupSummary, summary)
+	}
+	return m
+}
+
+// GroupID returns the group identifier, if any
+func (m *PushMessage) GroupID() string {
+	return m.Data[DataKeyGroupID]
+}
+
+func (m *PushMessage) setData(key, value string) *PushMessage {
+	if m.Data == nil {
+		m.Data = make(map[string]string)
+	}
+	m.Data[key] = value
+	return m
+}
@@ -0,0 +1,132 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HealthCheck reports an error when a dependency of the sender is unhealthy
+type HealthCheck func(ctx context.Context) error
+
+// Health serves Kubernetes-style probes: /healthz reports the liveness checks,
+// so orchestrators can restart a stuck sender, and /readyz reports the
+// readiness checks, so they can stop routing to an unhealthy one. Add
+// CircuitCheck to the readiness checks to report an open circuit.
+type Health struct {
+	Liveness  map[string]HealthCheck
+	Readiness map[string]HealthCheck
+	// Timeout bounds the time taken by all the checks of a probe.
+	// Defaults to five seconds.
+	Timeout time.Duration
+}
+
+// healthStatus is the JSON body of a probe response
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// ServeHTTP serves /healthz and /readyz, or any path ending with them
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var checks map[string]HealthCheck
+	switch path := r.URL.Path; {
+	case strings.HasSuffix(path, "/healthz"):
+		checks = h.Liveness
+	case strings.HasSuffix(path, "/readyz"):
+		checks = h.Readiness
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	status, ok := runChecks(ctx, checks)
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// runChecks runs checks concurrently and reports whether all of them passed.
+// Checks still running once ctx is done, e.g. ones ignoring it, fail.
+func runChecks(ctx context.Context, checks map[string]HealthCheck) (healthStatus, bool) {
+	status := healthStatus{Status: "ok", Checks: make(map[string]string, len(checks))}
+	type result struct {
+		name string
+		err  error
+	}
+	// Buffered so checks finishing after the probe returned don't block
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check HealthCheck) {
+			results <- result{name, check(ctx)}
+		}(name, check)
+	}
+	ok := true
+collect:
+	for range checks {
+		select {
+		case r := <-results:
+			status.Checks[r.name] = "ok"
+			if r.err != nil {
+				ok = false
+				status.Checks[r.name] = "failed: " + r.err.Error()
+			}
+		case <-ctx.Done():
+			for name := range checks {
+				if _, done := status.Checks[name]; !done {
+					status.Checks[name] = "failed: " + ctx.Err().Error()
+				}
+			}
+			ok = false
+			break collect
+		}
+	}
+	if !ok {
+		status.Status = "unavailable"
+	}
+	return status, ok
+}
+
+// Pinger is implemented by stores and clients that can check their connectivity,
+// such as *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingCheck reports whether a store is reachable
+func PingCheck(p Pinger) HealthCheck {
+	return p.PingContext
+}
+
+// BacklogCheck fails when backlog returns more than max pending items
+func BacklogCheck(backlog func() int, max int) HealthCheck {
+	return func(context.Context) error {
+		if n := backlog(); n > max {
+			return fmt.Errorf("backlog of %d exceeds %d", n, max)
+		}
+		return nil
+	}
+}
+
+// CircuitCheck fails with ErrCircuitOpen while the circuit of breaker is open
+func CircuitCheck(breaker *CircuitBreaker) HealthCheck {
+	return func(context.Context) error {
+		if breaker.Open() {
+			return ErrCircuitOpen
+		}
+		return nil
+	}
+}
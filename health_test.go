@@ -0,0 +1,77 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthProbes(t *testing.T) {
+	health := &Health{
+		Liveness: map[string]HealthCheck{
+			"scheduler": func(context.Context) error { return nil },
+		},
+		Readiness: map[string]HealthCheck{
+			"store":   func(context.Context) error { return errors.New("connection refused") },
+			"backlog": BacklogCheck(func() int { return 10 }, 100),
+		},
+	}
+	for path, code := range map[string]int{
+		"/healthz": http.StatusOK,
+		"/readyz":  http.StatusServiceUnavailable,
+		"/other":   http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		health.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestCircuitCheck(t *testing.T) {
+	breaker := &CircuitBreaker{Failures: 1}
+	check := CircuitCheck(breaker)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected a closed circuit to pass, got %v", err)
+	}
+	breaker.record(newCodedError(ErrorCodeNetwork, "connection refused"))
+	if err := check(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected an open circuit to fail, got %v", err)
+	}
+	breaker.Reset()
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected a reset circuit to pass, got %v", err)
+	}
+}
+
+func TestHealthProbeTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	health := &Health{
+		Readiness: map[string]HealthCheck{
+			// A check ignoring ctx doesn't hold up the probe
+			"stuck": func(context.Context) error { <-block; return nil },
+			// An error reading "ok" is still a failure
+			"store": func(context.Context) error { return errors.New("ok") },
+		},
+		Timeout: 20 * time.Millisecond,
+	}
+	start := time.Now()
+	w := httptest.NewRecorder()
+	health.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || time.Since(start) > time.Second {
+		t.Errorf("Expected the probe to fail once timed out, got %d after %v", w.Code, time.Since(start))
+	}
+	var status healthStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if status.Checks["stuck"] == "ok" || status.Checks["store"] == "ok" {
+		t.Errorf("Expected both checks failed, got %v", status.Checks)
+	}
+}
@@ -0,0 +1,44 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestServer serves handler, counting the requests. Requests may come
+// concurrently, e.g. from several AsyncPublisher workers.
+func newTestServer(t *testing.T, handler func(messages []PushMessage) Response) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	calls := new(atomic.Int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var messages []PushMessage
+		if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		json.NewEncoder(w).Encode(handler(messages))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func okTickets(messages []PushMessage) Response {
+	r := Response{}
+	for range messages {
+		r.Data = append(r.Data, PushResponse{Status: SuccessStatus, ID: "ticket"})
+	}
+	return r
+}
+
+func testMessages(n int) []PushMessage {
+	messages := make([]PushMessage, n)
+	for i := range messages {
+		token := ExponentPushToken(fmt.Sprintf("ExponentPushToken[%d]", i))
+		messages[i] = PushMessage{To: []ExponentPushToken{token}, Body: "hi"}
+	}
+	return messages
+}
@@ -0,0 +1,78 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// holdsOut reports whether token belongs to the control group of a
+// campaign holding out share of its recipients. The choice is random
+// across tokens but fixed for a campaign and token, so it survives
+// restarts and differs between campaigns.
+func holdsOut(campaignID string, token ExponentPushToken, share float64) bool {
+	h := sha256.New()
+	h.Write([]byte(campaignID))
+	h.Write([]byte{0})
+	h.Write([]byte(token))
+	return float64(binary.BigEndian.Uint64(h.Sum(nil)))/math.MaxUint64 < share
+}
+
+// holdOut returns messages without the recipients held out, and those
+// recipients. Messages left without recipients are removed.
+func holdOut(campaignID string, messages []PushMessage, share float64) ([]PushMessage, []ExponentPushToken) {
+	var heldOut []ExponentPushToken
+	kept := make([]PushMessage, 0, len(messages))
+	for _, message := range messages {
+		to := make([]ExponentPushToken, 0, len(message.To))
+		for _, token := range message.To {
+			if holdsOut(campaignID, token, share) {
+				heldOut = append(heldOut, token)
+			} else {
+				to = append(to, token)
+			}
+		}
+		if len(to) > 0 {
+			message.To = to
+			kept = append(kept, message)
+		}
+	}
+	return kept, heldOut
+}
+
+// HeldOut returns the recipients held out of the campaign so far
+func (c *Campaign) HeldOut() []ExponentPushToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ExponentPushToken(nil), c.heldOut...)
+}
+
+// emitHeldOut records the held out recipients with the client's EventSink,
+// e.g. a Tracker
+func (c *Campaign) emitHeldOut(tokens []ExponentPushToken) {
+	if c.Client == nil || c.Client.eventSink == nil {
+		return
+	}
+	now := time.Now()
+	labels := map[string]string{LabelCampaign: c.ID}
+	for _, token := range tokens {
+		c.Client.eventSink.HandleEvent(DeliveryEvent{Type: EventHeldOut, Time: now, Token: token, Labels: labels})
+	}
+}
+
+// HeldOut returns the recipients held out of a campaign, see
+// Campaign.Holdout, to compare their engagement with the recipients'
+func (t *Tracker) HeldOut(campaignID string) []ExponentPushToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var tokens []ExponentPushToken
+	for _, event := range t.events {
+		if event.Type == EventHeldOut && event.Labels[LabelCampaign] == campaignID {
+			tokens = append(tokens, event.Token)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,38 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCampaignHoldout(t *testing.T) {
+	var sent int
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		sent += len(messages)
+		return okTickets(messages)
+	})
+	tracker := NewTracker()
+	campaign := &Campaign{
+		ID:       "spring-sale",
+		Messages: testMessages(1000),
+		Client:   NewPushClient(&ClientConfig{Host: srv.URL, EventSink: tracker}),
+		Holdout:  0.1,
+	}
+	if err := campaign.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	heldOut := campaign.HeldOut()
+	if len(heldOut) < 70 || len(heldOut) > 130 || sent+len(heldOut) != 1000 {
+		t.Errorf("%d held out and %d sent of 1000, want about 100", len(heldOut), sent)
+	}
+	if recorded := tracker.HeldOut("spring-sale"); len(recorded) != len(heldOut) {
+		t.Errorf("%d held out recorded, want %d", len(recorded), len(heldOut))
+	}
+	for _, token := range heldOut {
+		if !holdsOut("spring-sale", token, 0.1) || holdsOut("spring-sale", token, 0) {
+			t.Fatalf("Holdout of %s not stable", token)
+		}
+	}
+}
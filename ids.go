@@ -0,0 +1,161 @@
+package expo
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// IDGenerator creates the identifiers of the package: correlation IDs and
+// the IDs of scheduled, recurring and queued messages. Implementations must
+// be safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to the IDGenerator interface
+type IDGeneratorFunc func() string
+
+// NewID calls f
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// NewID returns an identifier from the client's IDGenerator, e.g. for an
+// IdempotencyKey that sorts like the other IDs
+func (c *PushClient) NewID() string {
+	if c == nil || c.ids == nil {
+		return NewUUIDv7()
+	}
+	return c.ids.NewID()
+}
+
+// NewUUIDv7 returns a random UUIDv7 (RFC 9562), whose first 48 bits are the
+// time in milliseconds, so IDs sort by creation time in indexes and logs.
+// It is the default IDGenerator.
+func NewUUIDv7() string {
+	var b [16]byte
+	randomBytes(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:], uint32(ms))
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	var out [36]byte
+	hex.Encode(out[0:], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
+
+// crockford is the alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a random ULID: 26 characters of Crockford's base32, the
+// first 10 encoding the time in milliseconds, so IDs sort by creation time
+func NewULID() string {
+	var b [16]byte
+	randomBytes(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	hi := ms<<16 | uint64(binary.BigEndian.Uint16(b[6:]))
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// SnowflakeEpoch is the default epoch of Snowflake IDs
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates 64-bit IDs: 41 bits of milliseconds since Epoch, 10
+// bits of Node and 12 bits of sequence within the millisecond. IDs of
+// distinct nodes never collide, and they are zero-padded to 19 digits, so
+// they sort by creation time as numbers and as strings.
+type Snowflake struct {
+	// Node tells apart the processes generating IDs, from 0 to 1023
+	Node int64
+	// Epoch defaults to SnowflakeEpoch
+	Epoch time.Time
+
+	mu   sync.Mutex
+	last int64
+	seq  int64
+}
+
+// NewID returns the next ID. Past 4096 IDs in a millisecond, or when the
+// clock goes back, IDs borrow from the following milliseconds.
+func (s *Snowflake) NewID() string {
+	epoch := s.Epoch
+	if epoch.IsZero() {
+		epoch = SnowflakeEpoch
+	}
+	ms := time.Since(epoch).Milliseconds()
+	s.mu.Lock()
+	if ms <= s.last {
+		s.seq = (s.seq + 1) & 0xfff
+		ms = s.last
+		if s.seq == 0 {
+			ms++
+		}
+	} else {
+		s.seq = 0
+	}
+	s.last = ms
+	id := ms<<22 | (s.Node&0x3ff)<<12 | s.seq
+	s.mu.Unlock()
+	return fmt.Sprintf("%019d", id)
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}
+
+// DataKeyCorrelationID holds the correlation ID of a message in its data,
+// with ClientConfig.SendCorrelationID, so the app can report it back
+const DataKeyCorrelationID = "correlationId"
+
+// withCorrelationData returns message with its correlation ID in Data. The
+// caller's map is copied rather than modified.
+func withCorrelationData(message PushMessage) PushMessage {
+	data := make(map[string]string, len(message.Data)+1)
+	for k, v := range message.Data {
+		data[k] = v
+	}
+	data[DataKeyCorrelationID] = message.CorrelationID
+	message.Data = data
+	return message
+}
+
+// withCorrelationIDs returns messages with a correlation ID each. The
+// caller's slice is copied rather than modified.
+func (c *PushClient) withCorrelationIDs(messages []PushMessage) []PushMessage {
+	for i := range messages {
+		if messages[i].CorrelationID != "" {
+			continue
+		}
+		messages = slices.Clone(messages)
+		for j := i; j < len(messages); j++ {
+			if messages[j].CorrelationID == "" {
+				messages[j].CorrelationID = c.NewID()
+			}
+		}
+		break
+	}
+	return messages
+}
@@ -0,0 +1,71 @@
+package expo
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIDGenerators(t *testing.T) {
+	for name, tc := range map[string]struct {
+		gen     IDGenerator
+		pattern string
+	}{
+		"uuidv7":    {IDGeneratorFunc(NewUUIDv7), `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		"ulid":      {IDGeneratorFunc(NewULID), `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+		"snowflake": {&Snowflake{Node: 7}, `^[0-9]{19}$`},
+	} {
+		re := regexp.MustCompile(tc.pattern)
+		var ids []string
+		for i := range 3000 {
+			if i%1000 == 0 {
+				// Time-ordered IDs only sort across milliseconds
+				time.Sleep(2 * time.Millisecond)
+			}
+			ids = append(ids, tc.gen.NewID())
+		}
+		for _, id := range ids {
+			if !re.MatchString(id) {
+				t.Errorf("%s: malformed ID %q", name, id)
+				break
+			}
+		}
+		sorted := slices.Clone(ids)
+		slices.Sort(sorted)
+		if len(slices.Compact(sorted)) != len(ids) {
+			t.Errorf("%s: duplicate IDs", name)
+		}
+		for _, i := range []int{999, 1999} {
+			if ids[i] >= ids[i+1] {
+				t.Errorf("%s: %q sorts after the later %q", name, ids[i], ids[i+1])
+			}
+		}
+	}
+	// Snowflake IDs of a node are strictly increasing
+	s := &Snowflake{}
+	last := s.NewID()
+	for range 10000 {
+		id := s.NewID()
+		if id <= last {
+			t.Fatalf("Snowflake %q after %q", id, last)
+		}
+		last = id
+	}
+}
+
+func TestClientIDGenerator(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	n := 0
+	client := New(WithHost(srv.URL), WithIDGenerator(IDGeneratorFunc(func() string {
+		n++
+		return "id-" + string(rune('0'+n))
+	})))
+	responses, err := client.PublishMultiple(testMessages(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responses[0].PushMessage.CorrelationID != "id-1" || responses[1].PushMessage.CorrelationID != "id-2" {
+		t.Errorf("Expected the generator's correlation IDs, got %+v", responses)
+	}
+}
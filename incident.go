@@ -0,0 +1,167 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIncidentTag tags the messages an IncidentQueue diverts
+	DefaultIncidentTag = "incident"
+	// DefaultReplayRate is the number of notifications per second an
+	// IncidentQueue replays, well below DefaultRateLimit so a recovering
+	// Expo isn't flooded
+	DefaultReplayRate = 100
+	// DefaultReplayInterval is how often IncidentQueue.Run checks whether
+	// Expo recovered
+	DefaultReplayInterval = 10 * time.Second
+)
+
+// IncidentQueue is a Diverter keeping the messages of the publish calls
+// made while the circuit of its Client is open in a MessageQueue, so
+// callers don't fail during an Expo incident. Run replays them, paced, once
+// Expo recovers. Set it as ClientConfig.Diverter of the same client:
+//
+//	incidents := &expo.IncidentQueue{Queue: queue}
+//	client := expo.NewPushClient(&expo.ClientConfig{
+//		CircuitBreaker: &expo.CircuitBreaker{},
+//		Diverter:       incidents,
+//	})
+//	incidents.Client = client
+//	go incidents.Run(ctx)
+type IncidentQueue struct {
+	Client *PushClient
+	// Queue holds the diverted messages. Defaults to an in-memory queue; use
+	// a persistent one so they survive a restart.
+	Queue MessageQueue
+	// Tag is attached to the diverted messages, e.g. the ID of the incident.
+	// Defaults to DefaultIncidentTag.
+	Tag string
+	// HealthCheck is polled while the circuit is open. Once it passes the
+	// circuit is closed and the queue replayed. Without it the queue is
+	// replayed once the circuit's cooldown passed.
+	HealthCheck HealthCheck
+	// RateLimiter paces the replay. Defaults to DefaultReplayRate
+	// notifications per second.
+	RateLimiter RateLimiter
+	// Interval is how often Run checks for recovery. Defaults to
+	// DefaultReplayInterval.
+	Interval time.Duration
+	// ErrorReporter receives the errors of replays
+	ErrorReporter ErrorReporter
+	// OnDropped is called for every message removed from the queue
+	// without a ticket because its replay failed for good
+	OnDropped func(message QueuedMessage, err error)
+
+	once    sync.Once
+	queue   MessageQueue
+	limiter RateLimiter
+	now     func() time.Time
+}
+
+func (q *IncidentQueue) init() {
+	q.once.Do(func() {
+		q.queue = q.Queue
+		if q.queue == nil {
+			q.queue = NewMemoryMessageQueue()
+		}
+		q.limiter = q.RateLimiter
+		if q.limiter == nil {
+			q.limiter = NewTokenBucketLimiter(DefaultReplayRate, DefaultReplayRate)
+		}
+	})
+}
+
+// Divert queues messages, tagged with Tag, and returns a response with
+// DivertedStatus for each of them
+func (q *IncidentQueue) Divert(ctx context.Context, messages []PushMessage) ([]PushResponse, error) {
+	q.init()
+	tag := q.Tag
+	if tag == "" {
+		tag = DefaultIncidentTag
+	}
+	now := q.clock()
+	queued := make([]QueuedMessage, len(messages))
+	responses := make([]PushResponse, len(messages))
+	for i, message := range messages {
+		queued[i] = QueuedMessage{ID: q.Client.NewID(), Message: message, Tag: tag, EnqueuedAt: now}
+		responses[i] = PushResponse{
+			PushMessage: message,
+			Status:      DivertedStatus,
+			Message:     "circuit open: queued for replay once expo recovers",
+			Details:     map[string]string{"tag": tag},
+		}
+	}
+	if _, err := q.queue.Push(ctx, queued); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// Len returns the number of messages waiting for replay
+func (q *IncidentQueue) Len(ctx context.Context) (int, error) {
+	q.init()
+	return q.queue.Len(ctx)
+}
+
+// Replay publishes the queued messages, oldest first and paced by
+// RateLimiter, until the queue is empty. It stops with ErrCircuitOpen if
+// the circuit opens again, keeping the messages not sent yet, and drops the
+// messages of requests that failed for good, see QueuePublisher.Flush. It
+// returns the number of messages replayed.
+func (q *IncidentQueue) Replay(ctx context.Context) (int, error) {
+	q.init()
+	// Replayed messages fail rather than being diverted again
+	return drainQueue(withoutDivert(ctx), q.Client, q.queue, q.limiter, q.OnDropped)
+}
+
+// Run replays the queue whenever Expo is healthy, checking every Interval,
+// until ctx is done
+func (q *IncidentQueue) Run(ctx context.Context) error {
+	return supervise(ctx, "incident queue", q.ErrorReporter, func(ctx context.Context) error {
+		interval := q.Interval
+		if interval <= 0 {
+			interval = DefaultReplayInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				if err := q.replayIfHealthy(ctx); err != nil && ctx.Err() == nil && q.ErrorReporter != nil {
+					q.ErrorReporter(err)
+				}
+			}
+		}
+	})
+}
+
+// replayIfHealthy closes the circuit if the health check passes, and
+// replays the queue once the circuit is closed
+func (q *IncidentQueue) replayIfHealthy(ctx context.Context) error {
+	if breaker := q.Client.breaker; breaker != nil && breaker.Open() {
+		// A failing check is the incident going on, not worth reporting
+		if q.HealthCheck == nil || q.HealthCheck(ctx) != nil {
+			return nil
+		}
+		breaker.Reset()
+	}
+	_, err := q.Replay(ctx)
+	if errors.Is(err, ErrCircuitOpen) {
+		return nil
+	}
+	return err
+}
+
+func (q *IncidentQueue) clock() time.Time {
+	if q.now != nil {
+		return q.now()
+	}
+	return time.Now()
+}
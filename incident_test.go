@@ -0,0 +1,104 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIncidentQueue(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var sent atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		sent.Add(1)
+		w.Write([]byte(`{"data":[{"status":"ok","id":"t"}]}`))
+	}))
+	defer srv.Close()
+	var healthy atomic.Bool
+	incidents := &IncidentQueue{
+		Tag:      "INC-1",
+		Interval: 10 * time.Millisecond,
+		HealthCheck: func(context.Context) error {
+			if !healthy.Load() {
+				return errors.New("expo down")
+			}
+			return nil
+		},
+	}
+	breaker := &CircuitBreaker{Failures: 1, Cooldown: time.Hour}
+	client := New(WithHost(srv.URL), WithCircuitBreaker(breaker, incidents), WithDedup(time.Hour, nil))
+	incidents.Client = client
+
+	message := testMessages(1)[0]
+	if _, err := client.Publish(&message); ErrorCodeOf(err) != ErrorCodeServerError {
+		t.Fatalf("Expected the first failure to be returned, got %v", err)
+	}
+	response, err := client.Publish(&message)
+	if err != nil || response.Status != DivertedStatus {
+		t.Fatalf("Expected the message to be diverted, got %+v, %v", response, err)
+	}
+	var diverted *DivertedError
+	if err := response.ValidateResponse(); !errors.As(err, &diverted) || ErrorCodeOf(err) != ErrorCodeCircuitOpen || err.Error() == "" {
+		t.Errorf("Expected a DivertedError, got %#v", err)
+	}
+	head, _ := incidents.queue.Peek(context.Background(), 10)
+	if len(head) != 1 || head[0].Tag != "INC-1" || head[0].Message.To[0] != message.To[0] {
+		t.Fatalf("Unexpected queue %+v", head)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go incidents.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if n, _ := incidents.Len(ctx); n != 1 {
+		t.Fatalf("Expected the message to wait for recovery, got %d queued", n)
+	}
+	failing.Store(false)
+	healthy.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if n, _ := incidents.Len(ctx); n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The diverted message wasn't recorded by dedup, so the replay sends it
+	if n, _ := incidents.Len(ctx); n != 0 || sent.Load() != 1 || breaker.Open() {
+		t.Errorf("Expected the message to be replayed once, got %d queued and %d sent", n, sent.Load())
+	}
+}
+
+func TestIncidentQueueReplayKeepsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	incidents := &IncidentQueue{Queue: NewMemoryMessageQueue()}
+	breaker := &CircuitBreaker{Failures: 1}
+	incidents.Client = New(WithHost(srv.URL), WithCircuitBreaker(breaker, incidents))
+	ctx := context.Background()
+	if _, err := incidents.Divert(ctx, testMessages(3)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := incidents.Replay(ctx); n != 0 || ErrorCodeOf(err) != ErrorCodeServerError {
+		t.Errorf("Expected the replay to fail, got %d, %v", n, err)
+	}
+	// The circuit is open now, so the next replay fails without diverting
+	if n, err := incidents.Replay(ctx); n != 0 || !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %d, %v", n, err)
+	}
+	if n, _ := incidents.Len(ctx); n != 3 {
+		t.Errorf("Expected the messages to stay queued, got %d", n)
+	}
+}
@@ -0,0 +1,184 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// LedgerReport summarizes the delivery events of a period per app
+type LedgerReport struct {
+	Start time.Time   `json:"start"`
+	End   time.Time   `json:"end"`
+	Apps  []AppLedger `json:"apps"`
+}
+
+// AppLedger summarizes the delivery events of one app. Events without an
+// app label are reported under the empty app name.
+type AppLedger struct {
+	App        string       `json:"app"`
+	Sent       int          `json:"sent"`
+	Failed     int          `json:"failed"`
+	Suppressed int          `json:"suppressed"`
+	Pruned     int          `json:"pruned"`
+	TopErrors  []ErrorCount `json:"topErrors,omitempty"`
+}
+
+// ErrorCount is the number of failures with a given error code
+type ErrorCount struct {
+	Code  string `json:"code"`
+	Count int    `json:"count"`
+}
+
+// Ledger summarizes the events recorded in [start, end), listing up to
+// topErrors error codes per app
+func (t *Tracker) Ledger(start, end time.Time, topErrors int) *LedgerReport {
+	apps := make(map[string]*AppLedger)
+	codes := make(map[string]map[string]int)
+	for _, event := range t.Events(start, end) {
+		app := event.Labels[LabelApp]
+		ledger, ok := apps[app]
+		if !ok {
+			ledger = &AppLedger{App: app}
+			apps[app] = ledger
+			codes[app] = make(map[string]int)
+		}
+		switch event.Type {
+		case EventSent:
+			ledger.Sent++
+		case EventFailed:
+			ledger.Failed++
+			codes[app][event.ErrorCode]++
+		case EventSuppressed:
+			ledger.Suppressed++
+		case EventPruned:
+			ledger.Pruned++
+		}
+	}
+	report := &LedgerReport{Start: start, End: end, Apps: make([]AppLedger, 0, len(apps))}
+	for app, ledger := range apps {
+		ledger.TopErrors = topCounts(codes[app], topErrors)
+		report.Apps = append(report.Apps, *ledger)
+	}
+	sort.Slice(report.Apps, func(i, j int) bool { return report.Apps[i].App < report.Apps[j].App })
+	return report
+}
+
+func topCounts(counts map[string]int, n int) []ErrorCount {
+	top := make([]ErrorCount, 0, len(counts))
+	for code, count := range counts {
+		top = append(top, ErrorCount{Code: code, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Code < top[j].Code
+	})
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// ReportSink receives periodical ledger reports, e.g. to post them to a
+// webhook, send them by email or store them for BI ingestion
+type ReportSink interface {
+	EmitReport(ctx context.Context, report *LedgerReport) error
+}
+
+// ReportSinkFunc adapts a function to the ReportSink interface
+type ReportSinkFunc func(ctx context.Context, report *LedgerReport) error
+
+// EmitReport calls f
+func (f ReportSinkFunc) EmitReport(ctx context.Context, report *LedgerReport) error {
+	return f(ctx, report)
+}
+
+// WebhookReportSink posts reports as JSON to a URL
+type WebhookReportSink struct {
+	URL string
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// EmitReport posts the report
+func (s *WebhookReportSink) EmitReport(ctx context.Context, report *LedgerReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	closeBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("report webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// JSONReportSink writes each report as a line of JSON, e.g. to a file
+// picked up by a BI pipeline
+type JSONReportSink struct {
+	W io.Writer
+}
+
+// EmitReport writes the report
+func (s *JSONReportSink) EmitReport(_ context.Context, report *LedgerReport) error {
+	return json.NewEncoder(s.W).Encode(report)
+}
+
+// LedgerReporter emits a ledger report for every elapsed period, e.g. daily
+// or weekly
+type LedgerReporter struct {
+	Tracker *Tracker
+	// Period is the length of each report. Defaults to one day.
+	Period time.Duration
+	// TopErrors is the number of error codes listed per app
+	TopErrors int
+	Sinks     []ReportSink
+	// ErrorReporter receives sink errors and recovered panics
+	ErrorReporter ErrorReporter
+}
+
+// Run emits a report at the end of every period until ctx is done
+func (r *LedgerReporter) Run(ctx context.Context) error {
+	period := r.Period
+	if period <= 0 {
+		period = 24 * time.Hour
+	}
+	return supervise(ctx, "ledger reporter", r.ErrorReporter, func(ctx context.Context) error {
+		start := time.Now().Truncate(period)
+		for {
+			end := start.Add(period)
+			if err := sleepUntil(ctx, end); err != nil {
+				return err
+			}
+			report := r.Tracker.Ledger(start, end, r.TopErrors)
+			for _, sink := range r.Sinks {
+				if err := sink.EmitReport(ctx, report); err != nil && r.ErrorReporter != nil {
+					r.ErrorReporter(err)
+				}
+			}
+			start = end
+		}
+	})
+}
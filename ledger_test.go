@@ -0,0 +1,72 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTrackerLedger(t *testing.T) {
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		r := okTickets(messages)
+		r.Data[1] = PushResponse{Status: "error", Details: map[string]string{"error": ErrorDeviceNotRegistered}}
+		return r
+	})
+	tracker := NewTracker()
+	client := NewPushClient(&ClientConfig{
+		Host:        srv.URL,
+		EventSink:   tracker,
+		Labels:      map[string]string{LabelApp: "shop"},
+		DedupWindow: time.Minute,
+	})
+	messages := testMessages(3)
+	messages[2] = messages[0]
+	if _, err := client.PublishMultiple(messages[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PublishMultiple(messages[2:]); err != nil {
+		t.Fatal(err)
+	}
+	tracker.HandleEvent(DeliveryEvent{Type: EventPruned, Time: time.Now(), Labels: map[string]string{LabelApp: "shop"}})
+
+	report := tracker.Ledger(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 5)
+	if len(report.Apps) != 1 {
+		t.Fatalf("Expected 1 app, got %+v", report.Apps)
+	}
+	got := report.Apps[0]
+	if got.App != "shop" || got.Sent != 1 || got.Failed != 1 || got.Suppressed != 1 || got.Pruned != 1 {
+		t.Errorf("Incorrect ledger: %+v", got)
+	}
+	if len(got.TopErrors) != 1 || got.TopErrors[0].Code != ErrorDeviceNotRegistered {
+		t.Errorf("Incorrect top errors: %+v", got.TopErrors)
+	}
+
+	var buf bytes.Buffer
+	if err := (&JSONReportSink{W: &buf}).EmitReport(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+	var decoded LedgerReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Apps[0].Sent != 1 {
+		t.Errorf("Report not written as JSON: %s", buf.String())
+	}
+}
+
+func TestTrackerReplayEvents(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+	for i := 3; i > 0; i-- {
+		tracker.HandleEvent(DeliveryEvent{Type: EventSent, Time: now.Add(-time.Duration(i) * time.Hour), TicketID: fmt.Sprint(i)})
+	}
+	var replayed []string
+	n := tracker.ReplayEvents(now.Add(-150*time.Minute), EventSinkFunc(func(event DeliveryEvent) {
+		replayed = append(replayed, event.TicketID)
+	}))
+	if n != 2 || len(replayed) != 2 || replayed[0] != "2" || replayed[1] != "1" {
+		t.Errorf("Expected events 2 and 1 in order, got %v", replayed)
+	}
+}
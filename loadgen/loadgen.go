@@ -0,0 +1,185 @@
+// Package loadgen soak-tests a push pipeline for capacity planning. It
+// sends synthetic batches at a fixed rate through an expo.PushClient, to the
+// fake server of expotest or to a staging relay, and reports throughput and
+// latency percentiles. The expo command wraps it:
+//
+//	go run ./cmd/expo loadgen -qps 50 -batch 100 -duration 1m
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	expo "github.com/montovaneli/go-expo-notification"
+)
+
+// Defaults of Config
+const (
+	DefaultQPS         = 10
+	DefaultDuration    = 10 * time.Second
+	DefaultConcurrency = 16
+)
+
+// Config describes a load test
+type Config struct {
+	// Client sends the requests. Point it at expotest.NewServer or a
+	// staging relay, never at production.
+	Client *expo.PushClient
+	// BatchSize is the number of messages per request, at most
+	// expo.MaxMessagesPerRequest. Defaults to the maximum.
+	BatchSize int
+	// QPS is the number of requests started per second. Defaults to DefaultQPS.
+	QPS float64
+	// Duration is how long requests are started for. Defaults to DefaultDuration.
+	Duration time.Duration
+	// Concurrency bounds the requests in flight. Requests due while the
+	// bound is reached are skipped and counted in Report.Dropped.
+	// Defaults to DefaultConcurrency.
+	Concurrency int
+	// Message returns the i-th synthetic message. It is called from
+	// concurrent requests. Defaults to a short notification to a token
+	// unique to i.
+	Message func(i int) expo.PushMessage
+}
+
+// Report is the outcome of a load test
+type Report struct {
+	Requests int
+	Messages int
+	// Errors is the number of failed requests
+	Errors int
+	// Dropped is the number of requests not started because Concurrency
+	// requests were in flight, a sign the target can't keep up
+	Dropped  int
+	Duration time.Duration
+	// Throughput is the number of messages accepted per second
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%d requests (%d failed, %d dropped), %d messages in %v: %.1f msg/s, latency p50 %v p90 %v p99 %v max %v",
+		r.Requests, r.Errors, r.Dropped, r.Messages, r.Duration.Round(time.Millisecond), r.Throughput,
+		r.P50.Round(time.Microsecond), r.P90.Round(time.Microsecond), r.P99.Round(time.Microsecond), r.Max.Round(time.Microsecond))
+}
+
+// Run starts QPS requests per second for Duration, or until ctx is done,
+// waits for those in flight and reports on them
+func Run(ctx context.Context, config Config) (*Report, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("loadgen: no client")
+	}
+	batch := config.BatchSize
+	if batch <= 0 || batch > expo.MaxMessagesPerRequest {
+		batch = expo.MaxMessagesPerRequest
+	}
+	qps := config.QPS
+	if qps <= 0 {
+		qps = DefaultQPS
+	}
+	duration := config.Duration
+	if duration <= 0 {
+		duration = DefaultDuration
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	message := config.Message
+	if message == nil {
+		message = syntheticMessage
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		report    Report
+		wg        sync.WaitGroup
+		dropped   int
+	)
+	slots := make(chan struct{}, concurrency)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / qps))
+	defer ticker.Stop()
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	start := time.Now()
+	for n := 0; ; n++ {
+		select {
+		case slots <- struct{}{}:
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				messages := make([]expo.PushMessage, batch)
+				for i := range messages {
+					messages[i] = message(n*batch + i)
+				}
+				// In-flight requests finish after the test ends
+				sent := time.Now()
+				_, err := config.Client.PublishMultipleContext(context.WithoutCancel(ctx), messages, expo.WithNoRetry())
+				latency := time.Since(sent)
+				mu.Lock()
+				defer mu.Unlock()
+				report.Requests++
+				if err != nil {
+					report.Errors++
+					return
+				}
+				report.Messages += len(messages)
+				latencies = append(latencies, latency)
+			}(n)
+		default:
+			dropped++
+		}
+		if !wait(ctx, ticker) {
+			break
+		}
+	}
+	wg.Wait()
+
+	report.Dropped = dropped
+	report.Duration = time.Since(start)
+	report.Throughput = float64(report.Messages) / report.Duration.Seconds()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.P50 = percentile(latencies, 0.50)
+	report.P90 = percentile(latencies, 0.90)
+	report.P99 = percentile(latencies, 0.99)
+	if len(latencies) > 0 {
+		report.Max = latencies[len(latencies)-1]
+	}
+	return &report, nil
+}
+
+// wait waits for the next tick and reports whether the test goes on
+func wait(ctx context.Context, ticker *time.Ticker) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ticker.C:
+		return true
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies, nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func syntheticMessage(i int) expo.PushMessage {
+	return expo.PushMessage{
+		To:    []expo.ExponentPushToken{expo.ExponentPushToken(fmt.Sprintf("ExponentPushToken[loadgen-%d]", i))},
+		Title: "Load test",
+		Body:  fmt.Sprintf("Synthetic notification %d", i),
+	}
+}
@@ -0,0 +1,44 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/montovaneli/go-expo-notification/expotest"
+)
+
+func TestRun(t *testing.T) {
+	srv := expotest.NewServer()
+	defer srv.Close()
+	report, err := Run(context.Background(), Config{
+		Client:    srv.Client(),
+		BatchSize: 10,
+		QPS:       100,
+		Duration:  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.Errors != 0 || report.Messages != 10*report.Requests {
+		t.Errorf("Unexpected report: %v", report)
+	}
+	if got := len(srv.Messages()); got != report.Messages {
+		t.Errorf("Expected the server to receive %d messages, got %d", report.Messages, got)
+	}
+	if report.P50 <= 0 || report.P50 > report.P99 || report.P99 > report.Max {
+		t.Errorf("Inconsistent percentiles: %v", report)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{0.5: 50 * time.Millisecond, 0.99: 99 * time.Millisecond, 1: 100 * time.Millisecond} {
+		if got := percentile(latencies, p); got != want {
+			t.Errorf("p%v: expected %v, got %v", p*100, want, got)
+		}
+	}
+}
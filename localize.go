@@ -0,0 +1,83 @@
+package expo
+
+import "strings"
+
+// ErrNoLocalizedContent is returned when neither a locale nor any of its
+// fallbacks has content
+var ErrNoLocalizedContent = newCodedError(ErrorCodeNotFound, "no localized content")
+
+// LocalizedContent is the text of a notification in one locale
+type LocalizedContent struct {
+	Title string
+	Body  string
+}
+
+// Localizer picks the content of a notification for a recipient's locale.
+// When a locale has no content, it walks the fallback chain: the locale's
+// entry in Fallbacks if any, else its parents (pt-BR, then pt), then Default.
+type Localizer struct {
+	// Content holds the content per locale tag, e.g. "pt-BR"
+	Content map[string]LocalizedContent
+	// Fallbacks overrides the chain of a locale, e.g. {"es-MX": {"es-419", "es"}}
+	Fallbacks map[string][]string
+	// Default is the last locale tried, e.g. "en"
+	Default string
+}
+
+// Resolve returns the content for locale and the locale it was found in
+func (l *Localizer) Resolve(locale string) (LocalizedContent, string, error) {
+	for _, tag := range l.chain(locale) {
+		if content, key, ok := l.lookup(tag); ok {
+			return content, key, nil
+		}
+	}
+	return LocalizedContent{}, "", ErrNoLocalizedContent
+}
+
+// Localize sets the title and body of message for locale. Message.Locale
+// records the locale used, which delivery events report.
+func (l *Localizer) Localize(message *PushMessage, locale string) error {
+	content, used, err := l.Resolve(locale)
+	if err != nil {
+		return err
+	}
+	message.Title = content.Title
+	message.Body = content.Body
+	message.Locale = used
+	return nil
+}
+
+func (l *Localizer) chain(locale string) []string {
+	locale = normalizeLocale(locale)
+	chain := []string{locale}
+	if fallbacks, ok := l.Fallbacks[locale]; ok {
+		chain = append(chain, fallbacks...)
+	} else {
+		for i := strings.LastIndexByte(locale, '-'); i > 0; i = strings.LastIndexByte(locale, '-') {
+			locale = locale[:i]
+			chain = append(chain, locale)
+		}
+	}
+	if l.Default != "" {
+		chain = append(chain, l.Default)
+	}
+	return chain
+}
+
+// lookup finds content for tag, ignoring case, and returns its key
+func (l *Localizer) lookup(tag string) (LocalizedContent, string, bool) {
+	if content, ok := l.Content[tag]; ok {
+		return content, tag, true
+	}
+	for key, content := range l.Content {
+		if strings.EqualFold(normalizeLocale(key), tag) {
+			return content, key, true
+		}
+	}
+	return LocalizedContent{}, "", false
+}
+
+// normalizeLocale turns POSIX style tags like pt_BR into pt-BR
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+}
@@ -0,0 +1,56 @@
+package expo
+
+import "testing"
+
+func TestLocalizerFallbackChain(t *testing.T) {
+	l := &Localizer{
+		Content: map[string]LocalizedContent{
+			"en":     {Body: "Your order shipped"},
+			"pt":     {Body: "Seu pedido foi enviado"},
+			"es-419": {Body: "Tu pedido fue enviado"},
+		},
+		Fallbacks: map[string][]string{"es-MX": {"es-419", "es"}},
+		Default:   "en",
+	}
+	tests := map[string]string{
+		"pt-BR": "pt",
+		"pt_BR": "pt",
+		"es-MX": "es-419",
+		"de-DE": "en",
+		"EN":    "en",
+	}
+	for locale, want := range tests {
+		_, got, err := l.Resolve(locale)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", locale, want, got, err)
+		}
+	}
+
+	var message PushMessage
+	if err := l.Localize(&message, "pt-BR"); err != nil {
+		t.Fatal(err)
+	}
+	if message.Body != "Seu pedido foi enviado" || message.Locale != "pt" {
+		t.Errorf("Unexpected localized message %+v", message)
+	}
+	if _, _, err := (&Localizer{}).Resolve("fr"); err != ErrNoLocalizedContent {
+		t.Errorf("Expected ErrNoLocalizedContent, got %v", err)
+	}
+}
+
+func TestDeliveryEventLocale(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	var events []DeliveryEvent
+	client := NewPushClient(&ClientConfig{
+		Host:      srv.URL,
+		EventSink: EventSinkFunc(func(event DeliveryEvent) { events = append(events, event) }),
+	})
+	message := testMessages(1)[0]
+	message.Locale = "pt"
+	if _, err := client.Publish(&message); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Locale != "pt" {
+		t.Errorf("Expected the locale in the delivery event, got %+v", events)
+	}
+}
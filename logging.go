@@ -0,0 +1,178 @@
+package expo
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// RedactToken hides most of a push token, keeping enough to tell tokens
+// apart in logs: ExponentPushToken[abcd…]
+func RedactToken(token ExponentPushToken) string {
+	s := string(token)
+	start := strings.IndexByte(s, '[') + 1
+	if len(s)-start <= 4 {
+		return s
+	}
+	suffix := "…"
+	if strings.HasSuffix(s, "]") {
+		suffix += "]"
+	}
+	return s[:start+4] + suffix
+}
+
+// log writes a record to the client's logger, with the labels of the call
+func (c *PushClient) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if c.logger == nil || !c.logger.Enabled(ctx, level) {
+		return
+	}
+	if labels := CallLabels(ctx); len(labels) > 0 {
+		group := make([]any, 0, len(labels))
+		for k, v := range labels {
+			group = append(group, slog.String(k, v))
+		}
+		attrs = append(attrs, slog.Group("labels", group...))
+	}
+	c.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// tokensAttr logs tokens, redacted unless ClientConfig.LogTokens is set
+func (c *PushClient) tokensAttr(tokens []ExponentPushToken) slog.Attr {
+	logged := make([]string, len(tokens))
+	for i, token := range tokens {
+		if c.logTokens {
+			logged[i] = string(token)
+		} else {
+			logged[i] = RedactToken(token)
+		}
+	}
+	return slog.Any("tokens", logged)
+}
+
+// messageAttr logs a message from Expo, with the tokens it quotes, e.g. in
+// DeviceNotRegistered errors, redacted unless ClientConfig.LogTokens is set
+func (c *PushClient) messageAttr(message string) slog.Attr {
+	if c.logTokens {
+		return slog.String("message", message)
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(message, "PushToken[")
+		if i < 0 {
+			break
+		}
+		start := i
+		if strings.HasSuffix(message[:i], "Exponent") {
+			start -= len("Exponent")
+		} else if strings.HasSuffix(message[:i], "Expo") {
+			start -= len("Expo")
+		}
+		end := strings.IndexByte(message[i:], ']')
+		if end < 0 {
+			break
+		}
+		end += i + 1
+		b.WriteString(message[:start])
+		b.WriteString(RedactToken(ExponentPushToken(message[start:end])))
+		message = message[end:]
+	}
+	b.WriteString(message)
+	return slog.String("message", b.String())
+}
+
+// logRequest logs the start of a request to path, and returns the context
+// to send it with and a function logging its end. With
+// ClientConfig.SlowRequestThreshold, the context traces the phases of the
+// request, so a slow one is logged with its timings.
+func (c *PushClient) logRequest(ctx context.Context, path string, stats *BatchStats) (context.Context, func(status int, err error)) {
+	if c.logger == nil {
+		return ctx, func(int, error) {}
+	}
+	attrs := []slog.Attr{slog.String("path", path), slog.Int("bytes", stats.PayloadBytes)}
+	if stats.Messages > 0 {
+		attrs = append(attrs, slog.Int("messages", stats.Messages), slog.Int("recipients", stats.Recipients))
+	}
+	c.log(ctx, slog.LevelDebug, "expo request started", attrs...)
+	var timings *requestTimings
+	if c.slowRequest > 0 {
+		timings = new(requestTimings)
+		ctx = timings.trace(ctx)
+	}
+	start := time.Now()
+	return ctx, func(status int, err error) {
+		elapsed := time.Since(start)
+		attrs := []slog.Attr{
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Duration("duration", elapsed),
+			slog.Int("wireBytes", stats.WireBytes),
+		}
+		if err != nil {
+			c.log(ctx, slog.LevelWarn, "expo request failed", append(attrs,
+				slog.String("code", string(ErrorCodeOf(err))), slog.Any("error", err))...)
+		} else {
+			c.log(ctx, slog.LevelDebug, "expo request finished", attrs...)
+		}
+		if timings != nil && elapsed >= c.slowRequest {
+			attrs = append(attrs, slog.Duration("threshold", c.slowRequest))
+			if stats.Messages > 0 {
+				attrs = append(attrs, slog.Int("messages", stats.Messages), slog.Int("recipients", stats.Recipients))
+			}
+			c.log(ctx, slog.LevelWarn, "expo request slow", append(attrs, timings.attr(start))...)
+		}
+	}
+}
+
+// requestTimings records when the phases of a request ended
+type requestTimings struct {
+	conn      time.Time
+	reused    bool
+	wrote     time.Time
+	firstByte time.Time
+}
+
+func (t *requestTimings) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.conn = time.Now()
+			t.reused = info.Reused
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.wrote = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	})
+}
+
+// attr logs the phases reached: the wait for a connection, the upload of
+// the body and the wait for Expo to answer
+func (t *requestTimings) attr(start time.Time) slog.Attr {
+	var phases []any
+	if !t.conn.IsZero() {
+		phases = append(phases, slog.Duration("connect", t.conn.Sub(start)), slog.Bool("reused", t.reused))
+		if !t.wrote.IsZero() {
+			phases = append(phases, slog.Duration("upload", t.wrote.Sub(t.conn)))
+			if !t.firstByte.IsZero() {
+				phases = append(phases, slog.Duration("server", t.firstByte.Sub(t.wrote)))
+			}
+		}
+	}
+	return slog.Group("timings", phases...)
+}
+
+// logTicketErrors logs the failed tickets of a request
+func (c *PushClient) logTicketErrors(ctx context.Context, tickets []PushResponse) {
+	if c.logger == nil {
+		return
+	}
+	for i := range tickets {
+		if tickets[i].isSuccess() {
+			continue
+		}
+		c.log(ctx, slog.LevelWarn, "expo ticket error",
+			slog.String("code", string(tickets[i].ErrorCode())),
+			c.messageAttr(tickets[i].Message),
+			slog.String("correlationId", tickets[i].PushMessage.CorrelationID),
+			c.tokensAttr(tickets[i].PushMessage.To))
+	}
+}
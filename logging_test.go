@@ -0,0 +1,85 @@
+package expo
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactToken(t *testing.T) {
+	for token, want := range map[ExponentPushToken]string{
+		"ExponentPushToken[abcdefgh]": "ExponentPushToken[abcd…]",
+		"ExponentPushToken[abc]":      "ExponentPushToken[abc]",
+		"abcdefgh":                    "abcd…",
+	} {
+		if got := RedactToken(token); got != want {
+			t.Errorf("RedactToken(%q) = %q, want %q", token, got, want)
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		r := okTickets(messages)
+		r.Data[1] = PushResponse{Status: errorStatus,
+			Message: `"ExponentPushToken[second-token]" is not a registered push notification recipient`,
+			Details: map[string]string{"error": ErrorDeviceNotRegistered}}
+		return r
+	})
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := New(WithHost(srv.URL), WithLogger(logger))
+	messages := []PushMessage{
+		{To: []ExponentPushToken{"ExponentPushToken[first-token]"}},
+		{To: []ExponentPushToken{"ExponentPushToken[second-token]"}},
+	}
+	if _, err := client.PublishMultiple(messages); err != nil {
+		t.Fatal(err)
+	}
+	logs := buf.String()
+	for _, want := range []string{
+		"expo publish chunked", "chunkSizes=[2]",
+		"expo request started", "expo request finished", "status=200",
+		"expo ticket error", "code=DeviceNotRegistered", "ExponentPushToken[seco…]",
+		`ExponentPushToken[seco…]\" is not a registered`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Logs lack %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "second-token") {
+		t.Errorf("Token not redacted:\n%s", logs)
+	}
+}
+
+func TestSlowRequestWarning(t *testing.T) {
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		time.Sleep(20 * time.Millisecond)
+		return okTickets(messages)
+	})
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	client := New(WithHost(srv.URL), WithLogger(logger), WithSlowRequestThreshold(10*time.Millisecond))
+	if _, err := client.PublishMultipleContext(context.Background(), testMessages(3), WithLabels(map[string]string{"app": "shop"})); err != nil {
+		t.Fatal(err)
+	}
+	logs := buf.String()
+	for _, want := range []string{
+		"expo request slow", "threshold=10ms", "messages=3", "recipients=3",
+		"timings.connect=", "timings.server=", "labels.app=shop",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Logs lack %q:\n%s", want, logs)
+		}
+	}
+
+	buf.Reset()
+	client = New(WithHost(srv.URL), WithLogger(logger), WithSlowRequestThreshold(time.Minute))
+	client.PublishMultiple(testMessages(1))
+	if buf.Len() > 0 {
+		t.Errorf("Expected no warning under the threshold:\n%s", buf.String())
+	}
+}
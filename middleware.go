@@ -0,0 +1,65 @@
+package expo
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// RoundTripFunc sends an HTTP request and returns its response, like an
+// http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the sending of requests, e.g. to log them, record
+// metrics, refresh credentials or inject faults. It returns a RoundTripFunc
+// that may change the request, call next any number of times, or answer
+// without calling it.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// middlewareChain sends the requests of the client through the middleware
+// registered with Use, then through base
+type middlewareChain struct {
+	base       http.RoundTripper
+	middleware atomic.Pointer[[]Middleware]
+}
+
+func (m *middlewareChain) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := m.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	next := RoundTripFunc(base.RoundTrip)
+	if middleware := m.middleware.Load(); middleware != nil {
+		for i := len(*middleware) - 1; i >= 0; i-- {
+			next = (*middleware)[i](next)
+		}
+	}
+	return next(req)
+}
+
+func (m *middlewareChain) use(middleware []Middleware) {
+	for {
+		old := m.middleware.Load()
+		var chain []Middleware
+		if old != nil {
+			chain = append(chain, *old...)
+		}
+		chain = append(chain, middleware...)
+		if m.middleware.CompareAndSwap(old, &chain) {
+			return
+		}
+	}
+}
+
+// Use adds middleware around every HTTP request the client sends, retries
+// included. The first middleware added is the outermost, seeing requests
+// first and responses last. Requests already in flight are unaffected.
+func (c *PushClient) Use(middleware ...Middleware) {
+	if c.middleware != nil {
+		c.middleware.use(middleware)
+	}
+}
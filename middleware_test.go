@@ -0,0 +1,76 @@
+package expo
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" in")
+				resp, err := next(req)
+				order = append(order, name+" out")
+				return resp, err
+			}
+		}
+	}
+	client := New(WithHost(srv.URL), WithMiddleware(trace("a")))
+	client.Use(trace("b"))
+	if _, err := client.PublishMultiple(testMessages(1)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a in", "b in", "b out", "a out"}
+	if len(order) != len(want) {
+		t.Fatalf("Incorrect order %v", order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("Incorrect order %v, want %v", order, want)
+			break
+		}
+	}
+
+	// Fault injection answers without reaching the server
+	injected := errors.New("injected")
+	client.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return nil, injected
+		}
+	})
+	if _, err := client.PublishMultiple(testMessages(1)); !errors.Is(err, injected) {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Server called %d times, want 1", calls.Load())
+	}
+}
+
+func TestMiddlewareWithHTTPClient(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	var transported, wrapped int
+	transport := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		transported++
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	httpClient := &http.Client{Transport: transport}
+	client := New(WithHost(srv.URL), WithHTTPClient(httpClient), WithMiddleware(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			wrapped++
+			return next(req)
+		}
+	}))
+	if _, err := client.PublishMultiple(testMessages(1)); err != nil {
+		t.Fatal(err)
+	}
+	if transported != 1 || wrapped != 1 {
+		t.Errorf("Request transported %d and wrapped %d times, want 1", transported, wrapped)
+	}
+	if _, ok := httpClient.Transport.(RoundTripFunc); !ok {
+		t.Error("The caller's client was modified")
+	}
+}
@@ -0,0 +1,155 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TokenOnboarding keeps garbage tokens out of campaign audiences: imported
+// tokens stay pending until a verification push to them is confirmed
+// delivered by its receipt.
+type TokenOnboarding struct {
+	Client *PushClient
+	Store  TokenStore
+}
+
+// Import adds tokens as pending. Tokens already in the store are left as they are.
+func (o *TokenOnboarding) Import(ctx context.Context, records []TokenRecord) (int, error) {
+	now := time.Now()
+	imported := 0
+	for _, record := range records {
+		_, err := o.Store.Get(ctx, record.Token)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrTokenNotFound) {
+			return imported, err
+		}
+		record.State = TokenStatePending
+		record.VerificationTicket = ""
+		record.CreatedAt = now
+		record.UpdatedAt = now
+		if err := o.Store.Put(ctx, &record); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// SendVerification sends a silent push to every pending token not yet
+// verified. Tokens Expo rejects outright become invalid; the others keep
+// the ticket whose receipt, see CheckReceipts, settles their state.
+func (o *TokenOnboarding) SendVerification(ctx context.Context) error {
+	pending, err := o.Store.List(ctx, TokenFilter{State: TokenStatePending})
+	if err != nil {
+		return err
+	}
+	var tokens []ExponentPushToken
+	byToken := make(map[ExponentPushToken]*TokenRecord)
+	for i := range pending {
+		if pending[i].VerificationTicket == "" {
+			tokens = append(tokens, pending[i].Token)
+			byToken[pending[i].Token] = &pending[i]
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	checks, err := o.Client.CheckTokens(ctx, tokens)
+	now := time.Now()
+	for _, check := range checks {
+		record := byToken[check.Token]
+		switch check.Health {
+		case TokenHealthy:
+			record.VerificationTicket = check.TicketID
+		case TokenUnregistered:
+			record.State = TokenStateInvalid
+		default:
+			// Try again on the next run
+			continue
+		}
+		record.UpdatedAt = now
+		if err := o.Store.Put(ctx, record); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// ApplyReceipt settles the pending token verified by ticketID: it becomes
+// active if the receipt confirms delivery, and invalid if the receipt
+// reports DeviceNotRegistered. Other errors leave it pending for another
+// verification.
+func (o *TokenOnboarding) ApplyReceipt(ctx context.Context, ticketID string, delivered bool, errorCode string) error {
+	pending, err := o.Store.List(ctx, TokenFilter{State: TokenStatePending})
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		if pending[i].VerificationTicket == ticketID {
+			return o.settle(ctx, &pending[i], delivered, ErrorCode(errorCode))
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// CheckReceipts fetches the receipts of the verification pushes and applies
+// the ready ones, see ApplyReceipt. It returns how many tokens it settled.
+func (o *TokenOnboarding) CheckReceipts(ctx context.Context) (int, error) {
+	pending, err := o.Store.List(ctx, TokenFilter{State: TokenStatePending})
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for _, record := range pending {
+		if record.VerificationTicket != "" {
+			ids = append(ids, record.VerificationTicket)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	// Apply the receipts fetched before a failure too
+	receipts, err := o.Client.GetReceiptsContext(ctx, ids)
+	settled := 0
+	for i := range pending {
+		receipt, ok := receipts[pending[i].VerificationTicket]
+		if !ok {
+			continue
+		}
+		if err := o.settle(ctx, &pending[i], receipt.Status == SuccessStatus, receipt.ErrorCode()); err != nil {
+			return settled, err
+		}
+		settled++
+	}
+	return settled, err
+}
+
+func (o *TokenOnboarding) settle(ctx context.Context, record *TokenRecord, delivered bool, errorCode ErrorCode) error {
+	switch {
+	case delivered:
+		record.State = TokenStateActive
+	case errorCode == ErrorCodeDeviceNotRegistered:
+		record.State = TokenStateInvalid
+	}
+	record.VerificationTicket = ""
+	record.UpdatedAt = time.Now()
+	return o.Store.Put(ctx, record)
+}
+
+// Audience returns the active tokens, the ones campaigns should target
+func (o *TokenOnboarding) Audience(ctx context.Context) ([]ExponentPushToken, error) {
+	active, err := o.Store.List(ctx, TokenFilter{State: TokenStateActive})
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]ExponentPushToken, len(active))
+	for i := range active {
+		tokens[i] = active[i].Token
+	}
+	return tokens, nil
+}
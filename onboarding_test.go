@@ -0,0 +1,91 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenOnboarding(t *testing.T) {
+	ctx := context.Background()
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		r := okTickets(messages)
+		for i := range r.Data {
+			r.Data[i].ID = string(messages[i].To[0])
+		}
+		return r
+	})
+	store := NewMemoryTokenStore()
+	o := &TokenOnboarding{Client: NewPushClient(&ClientConfig{Host: srv.URL}), Store: store}
+	good, gone := ExponentPushToken("ExponentPushToken[good]"), ExponentPushToken("ExponentPushToken[gone]")
+	n, err := o.Import(ctx, []TokenRecord{{Token: good, UserID: "u1"}, {Token: gone}, {Token: "garbage"}})
+	if err != nil || n != 3 {
+		t.Fatalf("Expected 3 tokens imported, got %d, %v", n, err)
+	}
+	if err := o.SendVerification(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if audience, _ := o.Audience(ctx); len(audience) != 0 {
+		t.Errorf("Pending tokens must not be in the audience, got %v", audience)
+	}
+	// The tickets are named after their tokens by the test server
+	if err := o.ApplyReceipt(ctx, string(good), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.ApplyReceipt(ctx, string(gone), false, ErrorDeviceNotRegistered); err != nil {
+		t.Fatal(err)
+	}
+	audience, _ := o.Audience(ctx)
+	if len(audience) != 1 || audience[0] != good {
+		t.Errorf("Expected only the verified token in the audience, got %v", audience)
+	}
+	for token, want := range map[ExponentPushToken]TokenState{gone: TokenStateInvalid, "garbage": TokenStateInvalid} {
+		if r, _ := store.Get(ctx, token); r.State != want {
+			t.Errorf("%s: expected %s, got %s", token, want, r.State)
+		}
+	}
+}
+
+func TestTokenOnboardingCheckReceipts(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/--/api/v2/push/send" {
+			var messages []PushMessage
+			json.NewDecoder(r.Body).Decode(&messages)
+			tickets := okTickets(messages)
+			for i := range tickets.Data {
+				tickets.Data[i].ID = string(messages[i].To[0])
+			}
+			json.NewEncoder(w).Encode(tickets)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"ExponentPushToken[good]": map[string]any{"status": "ok"},
+			"ExponentPushToken[gone]": map[string]any{"status": "error", "details": map[string]string{"error": ErrorDeviceNotRegistered}},
+		}})
+	}))
+	defer srv.Close()
+	store := NewMemoryTokenStore()
+	o := &TokenOnboarding{Client: NewPushClient(&ClientConfig{Host: srv.URL}), Store: store}
+	o.Import(ctx, []TokenRecord{{Token: "ExponentPushToken[good]"}, {Token: "ExponentPushToken[gone]"}, {Token: "ExponentPushToken[later]"}})
+	if err := o.SendVerification(ctx); err != nil {
+		t.Fatal(err)
+	}
+	settled, err := o.CheckReceipts(ctx)
+	if err != nil || settled != 2 {
+		t.Fatalf("Expected 2 tokens settled, got %d, %v", settled, err)
+	}
+	for token, want := range map[ExponentPushToken]TokenState{
+		"ExponentPushToken[good]":  TokenStateActive,
+		"ExponentPushToken[gone]":  TokenStateInvalid,
+		"ExponentPushToken[later]": TokenStatePending,
+	} {
+		if r, _ := store.Get(ctx, token); r.State != want {
+			t.Errorf("%s: expected %s, got %s", token, want, r.State)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package expo
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// CallOption configures a single publish call, for workloads where
+// client-wide settings are too coarse, e.g. transactional and bulk sends
+// sharing one client
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	noRetry bool
+	timeout time.Duration
+	labels  map[string]string
+}
+
+type callOptionsKey struct{}
+
+// WithNoRetry disables automatic retries for the call
+func WithNoRetry() CallOption {
+	return func(o *callOptions) {
+		o.noRetry = true
+	}
+}
+
+// WithTimeout bounds the duration of the call, including rate limiting and retries
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithLabels attaches labels to the call. They are available to rate
+// limiters, transports and hooks through CallLabels.
+func WithLabels(labels map[string]string) CallOption {
+	return func(o *callOptions) {
+		if o.labels == nil {
+			o.labels = make(map[string]string, len(labels))
+		}
+		maps.Copy(o.labels, labels)
+	}
+}
+
+// withDefaultLabels sets labels not already set by an outer call
+func withDefaultLabels(labels map[string]string) CallOption {
+	return func(o *callOptions) {
+		if o.labels == nil {
+			o.labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			if _, ok := o.labels[k]; !ok {
+				o.labels[k] = v
+			}
+		}
+	}
+}
+
+// CallLabels returns the labels attached with WithLabels to the call that ctx belongs to
+func CallLabels(ctx context.Context) map[string]string {
+	return callOptionsFrom(ctx).labels
+}
+
+// withCallOptions applies opts on top of those already in ctx, so options
+// set by an outer call, e.g. a campaign, carry over to the requests it makes
+func withCallOptions(ctx context.Context, opts []CallOption) (context.Context, context.CancelFunc) {
+	if len(opts) == 0 {
+		return ctx, func() {}
+	}
+	o := *callOptionsFrom(ctx)
+	o.labels = maps.Clone(o.labels)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx = context.WithValue(ctx, callOptionsKey{}, &o)
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return ctx, func() {}
+}
+
+func callOptionsFrom(ctx context.Context) *callOptions {
+	if o, ok := ctx.Value(callOptionsKey{}).(*callOptions); ok {
+		return o
+	}
+	return &callOptions{}
+}
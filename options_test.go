@@ -0,0 +1,67 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type labelRecorder struct {
+	labels map[string]string
+}
+
+func (r *labelRecorder) Wait(ctx context.Context, _ int) error {
+	r.labels = CallLabels(ctx)
+	return nil
+}
+
+func TestPublishContextOptions(t *testing.T) {
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		time.Sleep(50 * time.Millisecond)
+		return okTickets(messages)
+	})
+	limiter := &labelRecorder{}
+	client := NewPushClient(&ClientConfig{Host: srv.URL, RateLimiter: limiter})
+	message := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}}
+
+	_, err := client.PublishContext(context.Background(), message, WithLabels(map[string]string{"kind": "bulk"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limiter.labels["kind"] != "bulk" {
+		t.Errorf("Labels not available to the call: %v", limiter.labels)
+	}
+
+	_, err = client.PublishContext(context.Background(), message, WithTimeout(5*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		time.Sleep(50 * time.Millisecond)
+		return okTickets(messages)
+	})
+	client := New(
+		WithConfig(ClientConfig{Host: "http://unused", ChunkConcurrency: 4}),
+		WithHost(srv.URL),
+		WithRetries(3),
+		WithRequestTimeout(10*time.Millisecond),
+	)
+	if client.host != srv.URL || client.retryPolicy.MaxAttempts != 3 || client.chunks != 4 {
+		t.Errorf("Options not applied: host %s, retry %+v, chunks %d", client.host, client.retryPolicy, client.chunks)
+	}
+	message := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}}
+	if _, err := client.Publish(message); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the client timeout to apply, got %v", err)
+	}
+	// A per-call timeout takes precedence
+	if _, err := client.PublishContext(context.Background(), message, WithTimeout(time.Second)); err != nil {
+		t.Errorf("Expected the call timeout to override the client's, got %v", err)
+	}
+	if calls.Load() == 0 {
+		t.Error("Expected requests to reach the server")
+	}
+}
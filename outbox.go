@@ -0,0 +1,162 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SequencedEvent is a delivery event numbered by an EventOutbox. Sequence
+// numbers start at 1 and have no holes, so consumers can drop redeliveries
+// and detect lost events, see SequenceChecker.
+type SequencedEvent struct {
+	Seq uint64 `json:"seq"`
+	DeliveryEvent
+}
+
+// EventDeliverer delivers events to a consumer. Returning nil acknowledges
+// the whole batch; on error the batch is delivered again later.
+type EventDeliverer interface {
+	DeliverEvents(ctx context.Context, events []SequencedEvent) error
+}
+
+// OutboxStore keeps the events of an EventOutbox until they are
+// acknowledged. Use a durable store to keep events across restarts.
+type OutboxStore interface {
+	// Append numbers event with the next sequence number and stores it
+	Append(event DeliveryEvent) (uint64, error)
+	// Pending returns up to limit unacknowledged events, oldest first
+	Pending(limit int) ([]SequencedEvent, error)
+	// Ack removes the events up to and including seq
+	Ack(seq uint64) error
+}
+
+// MemoryOutboxStore is an in-process OutboxStore
+type MemoryOutboxStore struct {
+	mu     sync.Mutex
+	next   uint64
+	events []SequencedEvent
+}
+
+// NewMemoryOutboxStore creates an empty MemoryOutboxStore
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{}
+}
+
+// Append stores event
+func (s *MemoryOutboxStore) Append(event DeliveryEvent) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.events = append(s.events, SequencedEvent{Seq: s.next, DeliveryEvent: event})
+	return s.next, nil
+}
+
+// Pending returns the oldest unacknowledged events
+func (s *MemoryOutboxStore) Pending(limit int) ([]SequencedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.events))
+	return append([]SequencedEvent(nil), s.events[:n]...), nil
+}
+
+// Ack removes the events up to seq
+func (s *MemoryOutboxStore) Ack(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := 0
+	for i < len(s.events) && s.events[i].Seq <= seq {
+		i++
+	}
+	s.events = s.events[i:]
+	return nil
+}
+
+// EventOutbox is an EventSink that numbers events and delivers them in
+// order until the consumer acknowledges them. Delivery is at least once;
+// with the sequence numbers consumers get exactly-once processing.
+type EventOutbox struct {
+	Store OutboxStore
+	Sink  EventDeliverer
+	// BatchSize is the number of events per delivery. Defaults to 100.
+	BatchSize int
+	// Interval is how often Run delivers pending events. Defaults to one second.
+	Interval time.Duration
+	// ErrorReporter receives store and delivery errors
+	ErrorReporter ErrorReporter
+}
+
+// NewEventOutbox creates an outbox delivering to sink, with an in-memory store
+func NewEventOutbox(sink EventDeliverer) *EventOutbox {
+	return &EventOutbox{Store: NewMemoryOutboxStore(), Sink: sink}
+}
+
+// HandleEvent stores the event for delivery
+func (o *EventOutbox) HandleEvent(event DeliveryEvent) {
+	if _, err := o.Store.Append(event); err != nil && o.ErrorReporter != nil {
+		o.ErrorReporter(err)
+	}
+}
+
+// Flush delivers pending events until none are left or a delivery fails
+func (o *EventOutbox) Flush(ctx context.Context) error {
+	size := o.BatchSize
+	if size <= 0 {
+		size = 100
+	}
+	for {
+		events, err := o.Store.Pending(size)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		if err := o.Sink.DeliverEvents(ctx, events); err != nil {
+			return err
+		}
+		if err := o.Store.Ack(events[len(events)-1].Seq); err != nil {
+			return err
+		}
+	}
+}
+
+// Run delivers pending events every Interval until ctx is done
+func (o *EventOutbox) Run(ctx context.Context) error {
+	return supervise(ctx, "event outbox", o.ErrorReporter, func(ctx context.Context) error {
+		interval := o.Interval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			if err := o.Flush(ctx); err != nil && o.ErrorReporter != nil {
+				o.ErrorReporter(err)
+			}
+		}
+	})
+}
+
+// SequenceChecker helps consumers of an EventOutbox process each event once
+// and notice lost events. It is not safe for concurrent use.
+type SequenceChecker struct {
+	// Last is the sequence number of the last event processed. Persist it
+	// with the consumer's state to resume after a restart.
+	Last uint64
+}
+
+// Check reports whether the event numbered seq was already processed, and
+// how many events are missing before it. It advances Last past new events.
+func (c *SequenceChecker) Check(seq uint64) (duplicate bool, missing uint64) {
+	if seq <= c.Last {
+		return true, 0
+	}
+	missing = seq - c.Last - 1
+	c.Last = seq
+	return false, missing
+}
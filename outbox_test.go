@@ -0,0 +1,58 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type flakyDeliverer struct {
+	fail      bool
+	delivered []SequencedEvent
+}
+
+func (d *flakyDeliverer) DeliverEvents(_ context.Context, events []SequencedEvent) error {
+	d.delivered = append(d.delivered, events...)
+	if d.fail {
+		d.fail = false
+		return errors.New("consumer down")
+	}
+	return nil
+}
+
+func TestEventOutboxRedeliversUntilAcknowledged(t *testing.T) {
+	sink := &flakyDeliverer{fail: true}
+	outbox := NewEventOutbox(sink)
+	outbox.BatchSize = 2
+	for i := 0; i < 3; i++ {
+		outbox.HandleEvent(DeliveryEvent{Type: EventSent})
+	}
+	if err := outbox.Flush(context.Background()); err == nil {
+		t.Fatal("Expected the first delivery to fail")
+	}
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var checker SequenceChecker
+	var processed, duplicates int
+	for _, event := range sink.delivered {
+		duplicate, missing := checker.Check(event.Seq)
+		if missing > 0 {
+			t.Errorf("Gap of %d events before %d", missing, event.Seq)
+		}
+		if duplicate {
+			duplicates++
+		} else {
+			processed++
+		}
+	}
+	if processed != 3 || duplicates != 2 {
+		t.Errorf("Expected 3 events processed and 2 redelivered, got %d and %d", processed, duplicates)
+	}
+	if _, missing := checker.Check(6); missing != 2 {
+		t.Errorf("Expected a gap of 2, got %d", missing)
+	}
+}
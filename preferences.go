@@ -0,0 +1,138 @@
+package expo
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DataKeyCategory holds the category of a notification, e.g. "marketing",
+// which users can turn off in their Preferences
+const DataKeyCategory = "category"
+
+// Suppression reasons for messages filtered by user preferences
+const (
+	// SuppressedPreferences is the reason for a message to a user who muted
+	// notifications, or its category or channel
+	SuppressedPreferences = "Preferences"
+	// SuppressedQuietHours is the reason for a message sent during the
+	// user's quiet hours
+	SuppressedQuietHours = "QuietHours"
+	// SuppressedDailyLimit is the reason for a message to a user who already
+	// got Preferences.MaxPerDay notifications in the last 24 hours
+	SuppressedDailyLimit = "DailyLimit"
+)
+
+// SetCategory sets the category consulted against user preferences
+func (m *PushMessage) SetCategory(category string) *PushMessage {
+	return m.setData(DataKeyCategory, category)
+}
+
+// Category returns the category, if any
+func (m *PushMessage) Category() string {
+	return m.Data[DataKeyCategory]
+}
+
+// Preferences are a user's notification settings, consulted at send time
+// when the client has a PreferenceStore
+type Preferences struct {
+	// Muted turns off all notifications
+	Muted bool
+	// DisabledCategories are the categories, see SetCategory, the user
+	// turned off
+	DisabledCategories []string
+	// DisabledChannels are the Android channels the user turned off
+	DisabledChannels []string
+	// QuietHours is when the user doesn't want to be notified
+	QuietHours *QuietHours
+	// MaxPerDay caps notifications over the last 24 hours. Zero is unlimited.
+	MaxPerDay int
+}
+
+// QuietHours is a daily period without notifications. It spans midnight when
+// From is after To, e.g. 22:00 to 7:00.
+type QuietHours struct {
+	// From and To are offsets from local midnight
+	From time.Duration
+	To   time.Duration
+	// Location is the user's time zone. Defaults to UTC.
+	Location *time.Location
+}
+
+// Contains reports whether t falls within the quiet hours
+func (q *QuietHours) Contains(t time.Time) bool {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if q.From <= q.To {
+		return offset >= q.From && offset < q.To
+	}
+	return offset >= q.From || offset < q.To
+}
+
+// PreferenceStore returns the preferences of the user owning a token.
+// Implementations must be safe for concurrent use.
+type PreferenceStore interface {
+	// Preferences returns nil when the user has no preferences
+	Preferences(ctx context.Context, token ExponentPushToken) (*Preferences, error)
+}
+
+// MemoryPreferenceStore is an in-process PreferenceStore
+type MemoryPreferenceStore struct {
+	mu    sync.RWMutex
+	prefs map[ExponentPushToken]Preferences
+}
+
+// NewMemoryPreferenceStore creates an empty MemoryPreferenceStore
+func NewMemoryPreferenceStore() *MemoryPreferenceStore {
+	return &MemoryPreferenceStore{prefs: make(map[ExponentPushToken]Preferences)}
+}
+
+// Set stores the preferences for token
+func (s *MemoryPreferenceStore) Set(token ExponentPushToken, prefs Preferences) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs[token] = prefs
+}
+
+// Delete removes the preferences for token
+func (s *MemoryPreferenceStore) Delete(token ExponentPushToken) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.prefs, token)
+}
+
+// Preferences returns the preferences for token
+func (s *MemoryPreferenceStore) Preferences(_ context.Context, token ExponentPushToken) (*Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prefs, ok := s.prefs[token]
+	if !ok {
+		return nil, nil
+	}
+	return &prefs, nil
+}
+
+// checkPreferences returns the reason message must not be sent to token,
+// or "" if it may
+func (c *PushClient) checkPreferences(ctx context.Context, message *PushMessage, token ExponentPushToken, now time.Time) (string, error) {
+	prefs, err := c.preferences.Preferences(ctx, token)
+	if err != nil || prefs == nil {
+		return "", err
+	}
+	switch {
+	case prefs.Muted,
+		slices.Contains(prefs.DisabledCategories, message.Category()) && message.Category() != "",
+		slices.Contains(prefs.DisabledChannels, message.ChannelID) && message.ChannelID != "":
+		return SuppressedPreferences, nil
+	case prefs.QuietHours != nil && prefs.QuietHours.Contains(now):
+		return SuppressedQuietHours, nil
+	case prefs.MaxPerDay > 0 && c.sendStats.SendCount(token, now.Add(-24*time.Hour)) >= prefs.MaxPerDay:
+		return SuppressedDailyLimit, nil
+	}
+	return "", nil
+}
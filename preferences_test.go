@@ -0,0 +1,44 @@
+package expo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHours(t *testing.T) {
+	night := &QuietHours{From: 22 * time.Hour, To: 7 * time.Hour}
+	for hour, want := range map[int]bool{21: false, 22: true, 2: true, 7: false, 12: false} {
+		at := time.Date(2024, 6, 1, hour, 0, 0, 0, time.UTC)
+		if got := night.Contains(at); got != want {
+			t.Errorf("%02d:00: expected %v, got %v", hour, want, got)
+		}
+	}
+}
+
+func TestPreferencesSuppressMessages(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	store := NewMemoryPreferenceStore()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Preferences: store})
+	messages := testMessages(4)
+	store.Set(messages[0].To[0], Preferences{Muted: true})
+	store.Set(messages[1].To[0], Preferences{DisabledCategories: []string{"marketing"}})
+	store.Set(messages[2].To[0], Preferences{MaxPerDay: 1})
+	for i := range messages {
+		messages[i].SetCategory("marketing")
+	}
+
+	want := []string{SuppressedPreferences, SuppressedPreferences, "", ""}
+	for round := 0; round < 2; round++ {
+		responses, err := client.PublishMultiple(messages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, r := range responses {
+			if got := r.Details["reason"]; got != want[i] {
+				t.Errorf("Round %d, message %d: expected reason %q, got %q", round, i, want[i], got)
+			}
+		}
+		// The second round goes over the daily limit
+		want[2] = SuppressedDailyLimit
+	}
+}
@@ -1,17 +1,17 @@
 package expo
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"strings"
-
-	fastshot "github.com/opus-domini/fast-shot"
 )
 
 // ExponentPushToken is a valid Expo push token
 type ExponentPushToken string
 
 // ErrMalformedToken is returned if a token does not start with 'ExponentPushToken'
-var ErrMalformedToken = errors.New("token should start with ExponentPushToken")
+var ErrMalformedToken = newCodedError(ErrorCodeInvalidToken, "token should start with ExponentPushToken")
 
 // NewExponentPushToken returns a token and may return an error if the input token is invalid
 func NewExponentPushToken(token string) (ExponentPushToken, error) {
@@ -21,6 +21,104 @@ func NewExponentPushToken(token string) (ExponentPushToken, error) {
 	return ExponentPushToken(token), nil
 }
 
+// ErrNoRecipients is returned when publishing a message without recipients
+var ErrNoRecipients = newCodedError(ErrorCodeNoRecipients, "no recipients")
+
+// MaxMessageBytes is the size Expo accepts for a notification, as
+// estimated by MessageSize
+const MaxMessageBytes = 4096
+
+// ErrMessageTooBig matches the errors of messages over the size limit,
+// whether Expo rejected them or the client caught them before sending
+var ErrMessageTooBig = newCodedError(ErrorCodeMessageTooBig, "message too big")
+
+// MessageSize estimates the size of the notification payload of message:
+// the size of its JSON without the recipients
+func MessageSize(message *PushMessage) int {
+	content := *message
+	content.To = nil
+	data, err := json.Marshal(content)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// IsExpoPushToken reports whether token has the format of an Expo push
+// token, ExponentPushToken[...] or ExpoPushToken[...]. It runs once per
+// recipient, so it doesn't allocate.
+func IsExpoPushToken(token string) bool {
+	const expo, exponent = "ExpoPushToken[", "ExponentPushToken["
+	var id string
+	switch {
+	case len(token) > len(exponent) && token[:len(exponent)] == exponent:
+		id = token[len(exponent):]
+	case len(token) > len(expo) && token[:len(expo)] == expo:
+		id = token[len(expo):]
+	default:
+		return false
+	}
+	return len(id) > 1 && id[len(id)-1] == ']'
+}
+
+// validateRecipients returns an error if a message has no recipients, or
+// an *InvalidTokensError listing the empty recipients and, if strict, the
+// ones that aren't Expo push tokens. Valid messages cost no allocation.
+func validateRecipients(messages []PushMessage, strict bool) error {
+	var invalid []InvalidToken
+	for i := range messages {
+		to := messages[i].To
+		if len(to) == 0 {
+			return ErrNoRecipients
+		}
+		for j, recipient := range to {
+			if recipient == "" || strict && !IsExpoPushToken(string(recipient)) {
+				invalid = append(invalid, InvalidToken{Message: i, Recipient: j, Token: recipient})
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return &InvalidTokensError{Tokens: invalid}
+	}
+	return nil
+}
+
+// InvalidToken is a recipient rejected before sending
+type InvalidToken struct {
+	// Message is the index of the message in the publish call
+	Message int
+	// Recipient is the index of the token in the message's To
+	Recipient int
+	Token     ExponentPushToken
+}
+
+// InvalidTokensError is returned by Publish and PublishMultiple, before
+// anything is sent, when recipients are empty or, with
+// ClientConfig.ValidateTokens, aren't Expo push tokens
+type InvalidTokensError struct {
+	Tokens []InvalidToken
+}
+
+func (e *InvalidTokensError) Error() string {
+	first := e.Tokens[0]
+	message := fmt.Sprintf("invalid push token %q (message %d, recipient %d)", first.Token, first.Message, first.Recipient)
+	if len(e.Tokens) > 1 {
+		message += fmt.Sprintf(" and %d more", len(e.Tokens)-1)
+	}
+	return message
+}
+
+// Messages returns the indexes of the messages with invalid tokens, in order
+func (e *InvalidTokensError) Messages() []int {
+	var indexes []int
+	for _, token := range e.Tokens {
+		if len(indexes) == 0 || indexes[len(indexes)-1] != token.Message {
+			indexes = append(indexes, token.Message)
+		}
+	}
+	return indexes
+}
+
 const (
 	// DefaultPriority is the standard priority used in PushMessage
 	DefaultPriority = "default"
@@ -30,29 +128,58 @@ const (
 	HighPriority = "high"
 )
 
+// Interruption levels of PushMessage, for iOS 15 and later
+const (
+	// InterruptionLevelActive is the default: the notification lights up
+	// the screen and may play a sound
+	InterruptionLevelActive = "active"
+	// InterruptionLevelCritical bypasses the mute switch and Do Not Disturb.
+	// It needs an entitlement from Apple.
+	InterruptionLevelCritical = "critical"
+	// InterruptionLevelPassive adds the notification to the list without
+	// lighting up the screen or playing a sound
+	InterruptionLevelPassive = "passive"
+	// InterruptionLevelTimeSensitive breaks through Focus modes
+	InterruptionLevelTimeSensitive = "time-sensitive"
+)
+
+// MaxMessagesPerRequest is the maximum number of messages Expo accepts in a
+// single push request
+const MaxMessagesPerRequest = 100
+
 // PushMessage is an object that describes a push notification request.
-// Fields:
+// Zero values are not sent, so Expo applies its defaults. Fields:
 //
-//		To: an ExponentPushToken
-//		Data: A dict of extra data to pass inside of the push notification.
-//		      The total notification payload must be at most 4096 bytes.
-//		Title: The title to display in the notification. On iOS, this is
-//		       displayed only on Apple Watch.
-//		Body: The message to display in the notification.
-//		Sound: A sound to play when the recipient receives this
-//		       notification. Specify "default" to play the device's default
-//		       notification sound, or omit this field to play no sound.
-//		TTLSeconds: The number of seconds for which the message may be kept around
-//		     for redelivery if it hasn't been delivered yet. Defaults to 0.
-//		Expiration: UNIX timestamp for when this message expires. It has
-//		        the same effect as ttl, and is just an absolute timestamp
-//		        instead of a relative one.
-//		Priority: Delivery priority of the message. Use the *Priority constants
-//	         specified above.
-//		Badge: An integer representing the unread notification count. This
-//		       currently only affects iOS. Specify 0 to clear the badge count.
-//		ChannelID: ID of the Notification Channel through which to display this
-//	        notification on Android devices.
+//	To: the recipients, ExponentPushTokens
+//	Body: The message to display in the notification.
+//	Data: A dict of extra data to pass inside of the push notification.
+//	      The total notification payload must be at most 4096 bytes.
+//	Sound: A sound to play when the recipient receives this notification.
+//	       Specify "default" to play the device's default notification
+//	       sound, or omit this field to play no sound. iOS only. See
+//	       CriticalSound for critical alerts.
+//	Title: The title to display in the notification.
+//	TTLSeconds: The number of seconds for which the message may be kept
+//	            around for redelivery if it hasn't been delivered yet.
+//	Expiration: UNIX timestamp for when this message expires. It has the
+//	            same effect as ttl, and is just an absolute timestamp
+//	            instead of a relative one.
+//	Priority: Delivery priority of the message. Use the *Priority constants
+//	          specified above.
+//	Badge: The number to display in the badge on the app icon. iOS only.
+//	       Set ClearBadge to clear it.
+//	ChannelID: ID of the Notification Channel through which to display
+//	           this notification on Android devices.
+//	Subtitle: The subtitle to display under the title. iOS only.
+//	CategoryID: ID of the notification category registered by the app,
+//	            which sets the actions shown with the notification. Not to
+//	            be confused with the preference category of SetCategory.
+//	MutableContent: Lets the app's notification service extension modify
+//	                the notification before it is shown. iOS only.
+//	InterruptionLevel: How the notification interrupts the user. Use the
+//	                   InterruptionLevel* constants. iOS only.
+//	ContentAvailable: Wakes the app in the background to handle the
+//	                  notification. iOS only; sent as _contentAvailable.
 type PushMessage struct {
 	To         []ExponentPushToken `json:"to"`
 	Body       string              `json:"body"`
@@ -64,17 +191,139 @@ type PushMessage struct {
 	Priority   string              `json:"priority,omitempty"`
 	Badge      int                 `json:"badge,omitempty"`
 	ChannelID  string              `json:"channelId,omitempty"`
+	// ClearBadge sends a Badge of 0, which clears the badge; a zero Badge
+	// is otherwise not sent
+	ClearBadge bool `json:"-"`
+	// CriticalSound is sent as the sound, in its object form, instead of
+	// Sound. Critical sounds need an entitlement from Apple.
+	CriticalSound     *CriticalSound `json:"-"`
+	Subtitle          string         `json:"subtitle,omitempty"`
+	CategoryID        string         `json:"categoryId,omitempty"`
+	MutableContent    bool           `json:"mutableContent,omitempty"`
+	InterruptionLevel string         `json:"interruptionLevel,omitempty"`
+	ContentAvailable  bool           `json:"_contentAvailable,omitempty"`
+	// CollapseKey marks messages that supersede each other, e.g. live score
+	// updates. The AsyncPublisher drops a queued message for a token when a
+	// newer one with the same key is enqueued. It is not sent to Expo.
+	CollapseKey string `json:"-"`
+	// Locale is the locale of the content, set by Localizer. It is reported
+	// in delivery events and not sent to Expo.
+	Locale string `json:"-"`
+	// CorrelationID traces the message end to end: it is kept through
+	// queueing and chunking and reported with its ticket, receipt and
+	// delivery events. The client assigns one when it accepts a message
+	// without. It is not sent to Expo.
+	CorrelationID string `json:"-"`
+	// IdempotencyKey identifies the notification across retries and
+	// restarts, e.g. "order-42-shipped". With ClientConfig.DedupWindow, a
+	// message is sent at most once per key and token within the window,
+	// even if its content changed; a shared DedupCache extends this across
+	// processes and restarts. The AsyncPublisher and the MessageQueues of
+	// QueuePublisher also drop messages whose key is already queued. It is
+	// not sent to Expo.
+	IdempotencyKey string `json:"-"`
+}
+
+// CriticalSound is the object form of the sound of a PushMessage, for
+// critical alerts on iOS
+type CriticalSound struct {
+	Critical bool `json:"critical,omitempty"`
+	// Name is "default" or empty for the default sound
+	Name string `json:"name,omitempty"`
+	// Volume is between 0 and 1. Zero plays at full volume.
+	Volume float64 `json:"volume,omitempty"`
+}
+
+// MarshalJSON encodes the message as Expo expects it, with CriticalSound as
+// the sound and a zero Badge when ClearBadge is set
+func (m PushMessage) MarshalJSON() ([]byte, error) {
+	type message PushMessage
+	if m.CriticalSound == nil && !m.ClearBadge {
+		return json.Marshal(message(m))
+	}
+	v := struct {
+		message
+		Sound any  `json:"sound,omitempty"`
+		Badge *int `json:"badge,omitempty"`
+	}{message: message(m)}
+	if m.CriticalSound != nil {
+		v.Sound = m.CriticalSound
+	} else if m.Sound != "" {
+		v.Sound = m.Sound
+	}
+	if m.ClearBadge || m.Badge != 0 {
+		v.Badge = &m.Badge
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON
+func (m *PushMessage) UnmarshalJSON(data []byte) error {
+	type message PushMessage
+	v := struct {
+		*message
+		Sound json.RawMessage `json:"sound"`
+		Badge *int            `json:"badge"`
+	}{message: (*message)(m)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case len(v.Sound) > 0 && v.Sound[0] == '{':
+		m.CriticalSound = new(CriticalSound)
+		if err := json.Unmarshal(v.Sound, m.CriticalSound); err != nil {
+			return err
+		}
+	case len(v.Sound) > 0:
+		if err := json.Unmarshal(v.Sound, &m.Sound); err != nil {
+			return err
+		}
+	}
+	if v.Badge != nil {
+		m.Badge = *v.Badge
+		m.ClearBadge = m.Badge == 0
+	}
+	return nil
 }
 
 // Response is the HTTP response returned from an Expo publish HTTP request
 type Response struct {
-	Data   []PushResponse      `json:"data"`
-	Errors []map[string]string `json:"errors"`
+	Data   []PushResponse `json:"data"`
+	Errors []APIError     `json:"errors"`
+}
+
+// APIError is an error object Expo returns for a whole request, as opposed
+// to the per-message errors in PushResponse
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// IsTransient tells whether retrying the request may succeed
+	IsTransient bool            `json:"isTransient"`
+	Details     json.RawMessage `json:"details,omitempty"`
 }
 
 // SuccessStatus is the status returned from Expo on a success
 const SuccessStatus = "ok"
 
+// errorStatus is the status returned from Expo on a failure
+const errorStatus = "error"
+
+// SuppressedStatus is the status of a response for a message the client
+// decided not to send. The reason is stored in Details["reason"].
+const SuppressedStatus = "suppressed"
+
+// SuppressedDuplicate is the suppression reason for a message that was
+// already sent to the same recipients within the dedup window
+const SuppressedDuplicate = "Duplicate"
+
+// SuppressedFrequencyCap is the suppression reason for a message to a token
+// that already reached the configured FrequencyCap
+const SuppressedFrequencyCap = "FrequencyCap"
+
+// SuppressedSuperseded is the suppression reason for a queued message to a
+// token that was replaced by a newer message with the same CollapseKey
+const SuppressedSuperseded = "Superseded"
+
 // ErrorDeviceNotRegistered indicates the token is invalid
 const ErrorDeviceNotRegistered = "DeviceNotRegistered"
 
@@ -84,6 +333,14 @@ const ErrorMessageTooBig = "MessageTooBig"
 // ErrorMessageRateExceeded indicates messages have been sent too frequently
 const ErrorMessageRateExceeded = "MessageRateExceeded"
 
+// ErrorMismatchSenderID indicates the FCM credentials of the project don't
+// match the sender ID the app was built with
+const ErrorMismatchSenderID = "MismatchSenderId"
+
+// ErrorInvalidCredentials indicates the push credentials of the project
+// are invalid or missing
+const ErrorInvalidCredentials = "InvalidCredentials"
+
 // PushResponse is a wrapper class for a push notification response.
 // A successful single push notification:
 //
@@ -94,17 +351,34 @@ const ErrorMessageRateExceeded = "MessageRateExceeded"
 //	{'status': 'error',
 //	 'message': '"adsf" is not a registered push notification recipient'}
 type PushResponse struct {
+	// PushMessage is the message of the ticket, with only the recipients
+	// it was sent to
 	PushMessage PushMessage
 	ID          string            `json:"id"`
 	Status      string            `json:"status"`
 	Message     string            `json:"message"`
 	Details     map[string]string `json:"details"`
+	// Original is the message as passed to Publish. Set by the publish
+	// methods.
+	Original PushMessage `json:"-"`
+	// Sent is the JSON of the message exactly as sent to Expo; nil if it
+	// wasn't sent. Compare it with Original to see what the client changed.
+	Sent json.RawMessage `json:"-"`
 }
 
 func (r *PushResponse) isSuccess() bool {
 	return r.Status == SuccessStatus
 }
 
+// ErrorCode returns the error code of a failed ticket, or "" if it
+// succeeded or Expo gave none
+func (r *PushResponse) ErrorCode() ErrorCode {
+	if r.isSuccess() {
+		return ""
+	}
+	return ErrorCode(r.Details["error"])
+}
+
 // ValidateResponse returns an error if the response indicates that one occurred.
 // Clients should handle these errors, since these require custom handling
 // to properly resolve.
@@ -115,21 +389,29 @@ func (r *PushResponse) ValidateResponse() error {
 	err := &PushResponseError{
 		Response: r,
 	}
+	if r.Status == SuppressedStatus {
+		return &SuppressedError{
+			PushResponseError: *err,
+		}
+	}
+	if r.Status == DivertedStatus {
+		return &DivertedError{
+			PushResponseError: *err,
+		}
+	}
 	// Handle specific errors if we have information
-	if r.Details != nil {
-		e := r.Details["error"]
-		if e == ErrorDeviceNotRegistered {
-			return &DeviceNotRegisteredError{
-				PushResponseError: *err,
-			}
-		} else if e == ErrorMessageTooBig {
-			return &MessageTooBigError{
-				PushResponseError: *err,
-			}
-		} else if e == ErrorMessageRateExceeded {
-			return &MessageRateExceededError{
-				PushResponseError: *err,
-			}
+	switch r.ErrorCode() {
+	case ErrorCodeDeviceNotRegistered:
+		return &DeviceNotRegisteredError{
+			PushResponseError: *err,
+		}
+	case ErrorCodeMessageTooBig:
+		return &MessageTooBigError{
+			PushResponseError: *err,
+		}
+	case ErrorCodeMessageRateExceeded:
+		return &MessageRateExceededError{
+			PushResponseError: *err,
 		}
 	}
 	return err
@@ -155,16 +437,54 @@ type DeviceNotRegisteredError struct {
 
 // MessageTooBigError is raised when the notification was too large.
 // On Android and iOS, the total payload must be at most 4096 bytes.
+// It matches ErrMessageTooBig with errors.Is.
 type MessageTooBigError struct {
 	PushResponseError
 }
 
+// Is reports whether target is ErrMessageTooBig
+func (e *MessageTooBigError) Is(target error) bool {
+	return target == ErrMessageTooBig
+}
+
 // MessageRateExceededError is raised when you are sending messages too frequently to a device
 // You should implement exponential backoff and slowly retry sending messages.
 type MessageRateExceededError struct {
 	PushResponseError
 }
 
+// SuppressedError is raised when the client did not send the message.
+// Details["reason"] on the response explains why.
+type SuppressedError struct {
+	PushResponseError
+}
+
+// DivertedError is raised when a Diverter took the message while the
+// circuit was open. It was not sent yet, but may be once Expo recovers.
+type DivertedError struct {
+	PushResponseError
+}
+
+// tooBigResponse is the response of a message over the size limit, which
+// was not sent
+func tooBigResponse(message PushMessage, size, limit int) PushResponse {
+	return PushResponse{
+		PushMessage: message,
+		Status:      errorStatus,
+		Message:     fmt.Sprintf("message is %d bytes, over the limit of %d", size, limit),
+		Details:     map[string]string{"error": ErrorMessageTooBig},
+	}
+}
+
+func suppressedResponse(message PushMessage, reason string) PushResponse {
+	return PushResponse{
+		PushMessage: message,
+		Status:      SuppressedStatus,
+		Message:     "message suppressed: " + reason,
+		Details:     map[string]string{"reason": reason},
+	}
+}
+
 // PushServerError is raised when the push token server is not behaving as expected
 // For example, invalid push notification arguments result in a different
 // style of error. Instead of a "data" array containing errors per
@@ -178,15 +498,15 @@ type MessageRateExceededError struct {
 // ]}
 type PushServerError struct {
 	Message      string
-	Response     *fastshot.Response
+	Response     *http.Response
 	ResponseData *Response
-	Errors       []map[string]string
+	Errors       []APIError
 }
 
 // NewPushServerError creates a new PushServerError object
-func NewPushServerError(message string, response *fastshot.Response,
+func NewPushServerError(message string, response *http.Response,
 	responseData *Response,
-	errors []map[string]string) *PushServerError {
+	errors []APIError) *PushServerError {
 	return &PushServerError{
 		Message:      message,
 		Response:     response,
@@ -198,3 +518,42 @@ func NewPushServerError(message string, response *fastshot.Response,
 func (e *PushServerError) Error() string {
 	return e.Message
 }
+
+func (e *PushServerError) apiErrors() []APIError {
+	return e.Errors
+}
+
+// maxSnippetLength is the number of body bytes kept in NonJSONResponseError
+const maxSnippetLength = 512
+
+// NonJSONResponseError is returned when Expo, or something in front of it such
+// as a captive portal, proxy or maintenance page, answers with a body that
+// isn't a JSON push response
+type NonJSONResponseError struct {
+	StatusCode  int
+	ContentType string
+	// Snippet holds the start of the body, truncated to 512 bytes
+	Snippet string
+	// Err is the JSON decoding error, if any
+	Err error
+}
+
+func newNonJSONResponseError(resp *http.Response, body []byte, err error) *NonJSONResponseError {
+	if len(body) > maxSnippetLength {
+		body = body[:maxSnippetLength]
+	}
+	e := &NonJSONResponseError{Snippet: string(body), Err: err}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		e.ContentType = resp.Header.Get("Content-Type")
+	}
+	return e
+}
+
+func (e *NonJSONResponseError) Error() string {
+	return fmt.Sprintf("non-JSON response (%d, %s): %q", e.StatusCode, e.ContentType, e.Snippet)
+}
+
+func (e *NonJSONResponseError) Unwrap() error {
+	return e.Err
+}
@@ -1,46 +1,234 @@
 package expo
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-
-	fastshot "github.com/opus-domini/fast-shot"
-	"github.com/opus-domini/fast-shot/constant/mime"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	// DefaultHost is the default Expo host
 	DefaultHost = "https://exp.host"
-	// DefaultBaseAPIURL is the default path for API requests
+	// DefaultBaseAPIURL is the default path for API requests.
+	// It is the BaseAPIURL of DefaultAPIVersion.
 	DefaultBaseAPIURL = "/--/api/v2"
 )
 
-func DefaultHTTPClient(host, accessToken string) fastshot.ClientHttpMethods {
-	builder := fastshot.NewClient(host)
-	builder.Header().AddContentType("application/json")
-	builder.Header().AddAccept(mime.JSON)
-	if accessToken != "" {
-		builder.Header().Add("Authorization", "Bearer "+accessToken)
-	}
-	return builder.Build()
+// DefaultHTTPClient creates the HTTP client used unless ClientConfig.HTTPClient
+// is set. Requests go through http.DefaultTransport, and are bounded by
+// contexts rather than a client timeout.
+func DefaultHTTPClient() *http.Client {
+	return &http.Client{}
 }
 
 // PushClient is an object used for making push notification requests
 type PushClient struct {
-	host        string
-	apiURL      string
-	accessToken string
-	httpClient  fastshot.ClientHttpMethods
+	host         string
+	apiURL       string
+	apiVersion   APIVersion
+	accessToken  string
+	tokenSource  *CachedSecret
+	httpClient   *http.Client
+	dedupWindow  time.Duration
+	dedupCache   DedupCache
+	sendStats    SendStats
+	frequencyCap FrequencyCap
+	rateLimiter  RateLimiter
+	retryPolicy  RetryPolicy
+	chunker      Chunker
+	chunks       int
+	// requestBytes bounds the JSON size of requests, lowered when the host
+	// rejects one as too large; zero if unbounded
+	requestBytes atomic.Int64
+	preferences  PreferenceStore
+	consent      ConsentStore
+	// compression gzips request bodies, unless the host rejected them
+	// and compressionOffUntil, in Unix nanoseconds, isn't over
+	compression          bool
+	compressionThreshold int
+	compressionOffUntil  atomic.Int64
+	validateTokens       bool
+	maxMessageBytes      int
+	batchObserver        BatchObserver
+	// maxResponseBytes bounds the size of response bodies read
+	maxResponseBytes int64
+	errorBodyLimit   int64
+	// errorReporter receives errors from background workers using the client
+	errorReporter ErrorReporter
+	eventSink     EventSink
+	alertSink     AlertSink
+	labels        map[string]string
+	timeout       time.Duration
+	schemas       *SchemaRegistry
+	logger        *slog.Logger
+	logTokens     bool
+	// middleware wraps the transport of the HTTP client
+	middleware *middlewareChain
+	// sendCorrelationID adds the correlation IDs to the data of messages
+	sendCorrelationID bool
+	// dryRun answers requests with synthetic tickets and receipts
+	dryRun bool
+	// onDeviceNotRegistered receives the tokens reported dead
+	onDeviceNotRegistered func(token ExponentPushToken)
+	// breaker stops sending while Expo is failing
+	breaker  *CircuitBreaker
+	diverter Diverter
+	// slowRequest is the duration from which requests are logged as slow
+	slowRequest time.Duration
+	// ids creates correlation IDs and the IDs of queued messages
+	ids IDGenerator
 }
 
 // ClientConfig specifies params that can optionally be specified for alternate
 // Expo config and path setup when sending API requests
 type ClientConfig struct {
-	Host        string
-	APIURL      string
+	Host string
+	// APIURL overrides the path prefix of API requests, which otherwise
+	// follows APIVersion
+	APIURL string
+	// APIVersion selects the Expo push API version. Defaults to DefaultAPIVersion.
+	APIVersion  APIVersion
 	AccessToken string
-	HTTPClient  fastshot.ClientHttpMethods
+	// AccessTokenSource provides the access token, fetched on use and cached
+	// for DefaultSecretTTL unless it is a *CachedSecret. It takes precedence
+	// over AccessToken. The token is fetched again when Expo rejects it.
+	AccessTokenSource SecretSource
+	// HTTPClient sends the requests, e.g. one with its own proxy, TLS
+	// config or timeout. Defaults to DefaultHTTPClient.
+	HTTPClient *http.Client
+	// Transport carries the requests of the default HTTP client.
+	// It is ignored when HTTPClient is set.
+	Transport http.RoundTripper
+	// Dial tunes how the default transport connects, e.g. to force IPv4.
+	// It is ignored when Transport or HTTPClient is set.
+	Dial *DialOptions
+	// Schemas validates the data of messages against the schema of their
+	// category before sending; messages breaking it fail the call with an
+	// *InvalidDataError
+	Schemas *SchemaRegistry
+	// SendCorrelationID sends the correlation ID of every message in
+	// Data[DataKeyCorrelationID], so the app can report opens, see
+	// Tracker.OpenHandler
+	SendCorrelationID bool
+	// Logger receives structured logs of requests, chunking, retries and
+	// failed tickets. Nil disables logging.
+	Logger *slog.Logger
+	// LogTokens logs push tokens in full instead of redacted, see RedactToken
+	LogTokens bool
+	// SlowRequestThreshold logs a warning, with its timings and batch size,
+	// for every request to Expo taking this long or longer, so creeping
+	// slowness shows before it turns into timeouts. Disabled if zero.
+	SlowRequestThreshold time.Duration
+	// IDGenerator creates correlation IDs and the IDs of scheduled and
+	// queued messages. Defaults to NewUUIDv7; wrap NewULID in an
+	// IDGeneratorFunc, or use a Snowflake, for storage expecting those.
+	IDGenerator IDGenerator
+	// Middleware wraps the requests of the HTTP client, see PushClient.Use
+	Middleware []Middleware
+	// DedupWindow enables duplicate-send suppression. An identical message,
+	// or one with the same IdempotencyKey, to the same token within the
+	// window is not sent again.
+	DedupWindow time.Duration
+	// DedupCache stores message fingerprints for DedupWindow. Defaults to an
+	// in-memory cache; use a shared cache to suppress across processes.
+	DedupCache DedupCache
+	// SendStats records successful sends per token. Defaults to an in-memory
+	// store when FrequencyCap is set.
+	SendStats SendStats
+	// FrequencyCap limits how many notifications a token receives per period
+	FrequencyCap FrequencyCap
+	// RateLimiter throttles requests by the number of notifications they hold
+	RateLimiter RateLimiter
+	// Retry retries requests that fail with a transient error, e.g. a
+	// network blip or a 5xx response. Disabled by default, except for 429
+	// responses, which are attempted DefaultRateLimitAttempts times
+	// honouring Retry-After. WithNoRetry disables retries for a call.
+	Retry RetryPolicy
+	// Chunker groups the messages of PublishMultiple into requests.
+	// Defaults to CountChunker, chunks of MaxMessagesPerRequest messages.
+	Chunker Chunker
+	// ChunkConcurrency is the number of chunks PublishMultiple sends at
+	// once. Defaults to 1, sending them one after the other.
+	ChunkConcurrency int
+	// MaxRequestBytes splits the chunks of the Chunker whose JSON is
+	// larger, so batches with large data payloads don't make oversized
+	// requests. Unbounded if zero. Either way, a chunk rejected with 413 is
+	// split in halves and later requests stay under half its size.
+	MaxRequestBytes int
+	// Preferences are consulted for every recipient at send time. Messages
+	// the user opted out of are suppressed. SendStats defaults to an
+	// in-memory store to enforce Preferences.MaxPerDay.
+	Preferences PreferenceStore
+	// Consent, when set, blocks messages to tokens without granted consent.
+	// They are suppressed with SuppressedNoConsent.
+	Consent ConsentStore
+	// MaxResponseBytes bounds the size of response bodies read.
+	// Defaults to DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+	// ErrorBodyLimit is how much of a non-2xx response body is read and
+	// attached to the returned error. Defaults to DefaultErrorBodyLimit;
+	// a negative limit discards the body.
+	ErrorBodyLimit int64
+	// Compress gzips request bodies, trading CPU for bandwidth on large
+	// batches. If the host, or a proxy in front of it, rejects compressed
+	// bodies with 415, the batch is resent uncompressed and compression is
+	// paused for an hour.
+	Compress bool
+	// CompressionThreshold is the body size, in bytes, from which bodies
+	// are compressed, e.g. 1024: small bodies barely shrink and aren't
+	// worth the CPU. Zero compresses every body.
+	CompressionThreshold int
+	// BatchObserver receives the size and outcome of every request, see
+	// BatchReport
+	BatchObserver BatchObserver
+	// ValidateTokens rejects publish calls with recipients that aren't Expo
+	// push tokens, see IsExpoPushToken, with an *InvalidTokensError listing
+	// them. Empty tokens are always rejected.
+	ValidateTokens bool
+	// MaxMessageBytes is the size, estimated by MessageSize, over which
+	// messages get a MessageTooBig response without being sent. Defaults
+	// to MaxMessageBytes; negative disables the check.
+	MaxMessageBytes int
+	// EventSink receives a DeliveryEvent for every recipient of every message
+	EventSink EventSink
+	// Labels are attached to every call, e.g. {"app": "shop"}. Per-call
+	// labels set with WithLabels take precedence.
+	Labels map[string]string
+	// Timeout bounds every call like WithTimeout, which takes precedence.
+	// Unbounded if zero.
+	Timeout time.Duration
+	// AlertSink receives alerts, e.g. when Expo rejects the access token.
+	// Errors delivering alerts go to ErrorReporter.
+	AlertSink AlertSink
+	// ErrorReporter receives errors and recovered panics from background
+	// workers using this client, such as the Scheduler
+	ErrorReporter ErrorReporter
+	// WarmUp opens a connection to the host in the background on creation,
+	// so the first send doesn't pay cold-connection latency. See
+	// PushClient.WarmUp to wait for it instead.
+	WarmUp bool
+	// DryRun validates, chunks and encodes messages as usual but never
+	// calls the host: every message gets a successful ticket whose ID
+	// starts with DryRunTicketPrefix, and every receipt reports delivery.
+	// Events, stats and hooks see these results like real ones, e.g. to
+	// exercise campaign logic in staging.
+	DryRun bool
+	// OnDeviceNotRegistered is called with every token a ticket or receipt
+	// reports as DeviceNotRegistered, e.g. to remove it from the database.
+	// It is called synchronously by the call that got the ticket or
+	// receipt, so it must not block for long.
+	OnDeviceNotRegistered func(token ExponentPushToken)
+	// CircuitBreaker, when set, stops sending after consecutive failed
+	// requests: calls fail with ErrCircuitOpen until it closes again
+	CircuitBreaker *CircuitBreaker
+	// Diverter takes the messages of calls made while the circuit is open
+	// instead of failing them, e.g. an IncidentQueue
+	Diverter Diverter
 }
 
 // NewPushClient creates a new Exponent push client
@@ -49,26 +237,121 @@ func NewPushClient(config *ClientConfig) *PushClient {
 	c := new(PushClient)
 	host := DefaultHost
 	apiURL := DefaultBaseAPIURL
+	apiVersion := DefaultAPIVersion
 	accessToken := ""
-	httpClient := DefaultHTTPClient(host, accessToken)
+	var httpClient *http.Client
+	var transport http.RoundTripper
 	if config != nil {
 		if config.Host != "" {
 			host = config.Host
 		}
+		if config.APIVersion != "" {
+			apiVersion = config.APIVersion
+			apiURL = apiVersion.BaseAPIURL()
+		}
 		if config.APIURL != "" {
 			apiURL = config.APIURL
 		}
 		if config.AccessToken != "" {
 			accessToken = config.AccessToken
 		}
+		if source := config.AccessTokenSource; source != nil {
+			cached, ok := source.(*CachedSecret)
+			if !ok {
+				cached = NewCachedSecret(source, DefaultSecretTTL)
+			}
+			c.tokenSource = cached
+			// The header is set per request instead
+			accessToken = ""
+		}
 		if config.HTTPClient != nil {
 			httpClient = config.HTTPClient
 		}
+		transport = config.Transport
+		if transport == nil && config.Dial != nil {
+			transport = config.Dial.transport()
+		}
+		if config.DedupWindow > 0 {
+			c.dedupWindow = config.DedupWindow
+			c.dedupCache = config.DedupCache
+			if c.dedupCache == nil {
+				c.dedupCache = NewMemoryDedupCache()
+			}
+		}
+		c.sendStats = config.SendStats
+		var retention time.Duration
+		if config.FrequencyCap.Max > 0 {
+			c.frequencyCap = config.FrequencyCap
+			retention = config.FrequencyCap.Period
+		}
+		c.preferences = config.Preferences
+		c.consent = config.Consent
+		c.compression = config.Compress
+		c.compressionThreshold = config.CompressionThreshold
+		c.batchObserver = config.BatchObserver
+		c.validateTokens = config.ValidateTokens
+		c.maxMessageBytes = config.MaxMessageBytes
+		if c.preferences != nil {
+			retention = max(retention, 24*time.Hour)
+		}
+		if c.sendStats == nil && retention > 0 {
+			c.sendStats = NewMemorySendStats(retention)
+		}
+		c.rateLimiter = config.RateLimiter
+		c.retryPolicy = config.Retry
+		c.chunker = config.Chunker
+		c.chunks = config.ChunkConcurrency
+		c.requestBytes.Store(int64(max(config.MaxRequestBytes, 0)))
+		c.errorReporter = config.ErrorReporter
+		c.eventSink = config.EventSink
+		c.alertSink = config.AlertSink
+		c.labels = config.Labels
+		c.timeout = config.Timeout
+		c.logger = config.Logger
+		c.schemas = config.Schemas
+		c.sendCorrelationID = config.SendCorrelationID
+		c.dryRun = config.DryRun
+		c.onDeviceNotRegistered = config.OnDeviceNotRegistered
+		c.breaker = config.CircuitBreaker
+		c.diverter = config.Diverter
+		c.logTokens = config.LogTokens
+		c.slowRequest = config.SlowRequestThreshold
+		c.ids = config.IDGenerator
+		c.maxResponseBytes = config.MaxResponseBytes
+		c.errorBodyLimit = config.ErrorBodyLimit
+	}
+	if c.chunker == nil {
+		c.chunker = CountChunker{}
+	}
+	if c.maxMessageBytes == 0 {
+		c.maxMessageBytes = MaxMessageBytes
+	}
+	if c.maxResponseBytes <= 0 {
+		c.maxResponseBytes = DefaultMaxResponseBytes
 	}
-	c.host = host
+	if c.errorBodyLimit == 0 {
+		c.errorBodyLimit = DefaultErrorBodyLimit
+	}
+	if httpClient == nil {
+		httpClient = DefaultHTTPClient()
+		httpClient.Transport = transport
+	}
+	// The client is copied so the caller's keeps its transport
+	wrapped := *httpClient
+	c.middleware = &middlewareChain{base: httpClient.Transport}
+	if config != nil {
+		c.middleware.use(config.Middleware)
+	}
+	wrapped.Transport = c.middleware
+	httpClient = &wrapped
+	c.host = strings.TrimSuffix(host, "/")
 	c.apiURL = apiURL
+	c.apiVersion = apiVersion
 	c.httpClient = httpClient
 	c.accessToken = accessToken
+	if config != nil && config.WarmUp {
+		go c.warmUp()
+	}
 	return c
 }
 
@@ -77,80 +360,409 @@ func NewPushClient(config *ClientConfig) *PushClient {
 // @return an array of PushResponse objects which contains the results.
 // @return error if any requests failed
 func (c *PushClient) Publish(message *PushMessage) (PushResponse, error) {
-	responses, err := c.PublishMultiple([]PushMessage{*message})
+	return c.PublishContext(context.Background(), message)
+}
+
+// PublishContext sends a single push notification with a context and per-call options
+func (c *PushClient) PublishContext(ctx context.Context, message *PushMessage, opts ...CallOption) (PushResponse, error) {
+	responses, err := c.PublishMultipleContext(ctx, []PushMessage{*message}, opts...)
 	if err != nil {
 		return PushResponse{}, err
 	}
 	return responses[0], nil
 }
 
-// PublishMultiple sends multiple push notifications at once
+// PublishMultiple sends multiple push notifications at once. Messages are
+// sent in chunks, see ClientConfig.Chunker and ClientConfig.ChunkConcurrency.
 // @param push_messages: An array of PushMessage objects.
-// @return an array of PushResponse objects which contains the results.
-// @return error if the request failed
+// @return an array of PushResponse objects which contains the results, in
+// the order of the messages.
+// @return error if a request failed. If other chunks were delivered, their
+// responses are returned with the error; the messages of the failed chunks
+// have zero responses.
 func (c *PushClient) PublishMultiple(messages []PushMessage) ([]PushResponse, error) {
-	return c.publishInternal(messages)
+	return c.PublishMultipleContext(context.Background(), messages)
+}
+
+// PublishMultipleContext sends multiple push notifications at once with a
+// context and per-call options
+func (c *PushClient) PublishMultipleContext(ctx context.Context, messages []PushMessage, opts ...CallOption) ([]PushResponse, error) {
+	ctx, cancel := withCallOptions(ctx, c.callDefaults(opts))
+	defer cancel()
+	return c.publishInternal(ctx, messages)
+}
+
+// callDefaults prepends the client-wide call options to opts, which take
+// precedence
+func (c *PushClient) callDefaults(opts []CallOption) []CallOption {
+	var defaults []CallOption
+	if len(c.labels) > 0 {
+		defaults = append(defaults, withDefaultLabels(c.labels))
+	}
+	if c.timeout > 0 {
+		defaults = append(defaults, WithTimeout(c.timeout))
+	}
+	if len(defaults) == 0 {
+		return opts
+	}
+	return append(defaults, opts...)
 }
 
-func (c *PushClient) publishInternal(messages []PushMessage) ([]PushResponse, error) {
+func (c *PushClient) publishInternal(ctx context.Context, messages []PushMessage) ([]PushResponse, error) {
 	// Validate the messages
-	for _, message := range messages {
-		if len(message.To) == 0 {
-			return nil, errors.New("no recipients")
+	if err := validateRecipients(messages, c.validateTokens); err != nil {
+		return nil, err
+	}
+	if c.schemas != nil {
+		var violations []DataViolation
+		for i := range messages {
+			violations = c.schemas.validate(i, &messages[i], violations)
+		}
+		if len(violations) > 0 {
+			return nil, &InvalidDataError{Violations: violations}
+		}
+	}
+
+	messages = c.withCorrelationIDs(messages)
+
+	// Drop recipients that must not receive their message
+	responses := make([]PushResponse, len(messages))
+	outgoing := make([]PushMessage, 0, len(messages))
+	index := make([]int, 0, len(messages))
+	dropped := make([]filterResult, len(messages))
+	var recorded []string
+	var err error
+	for i, message := range messages {
+		var f filterResult
+		f, err = c.filter(ctx, message)
+		if err != nil {
+			for _, key := range recorded {
+				c.dedupCache.Remove(key)
+			}
+			return nil, err
+		}
+		if c.sendCorrelationID && len(f.message.To) > 0 {
+			f.message = withCorrelationData(f.message)
 		}
-		for _, recipient := range message.To {
-			if recipient == "" {
-				return nil, errors.New("invalid push token")
+		if len(f.message.To) > 0 && c.maxMessageBytes > 0 {
+			if size := MessageSize(&f.message); size > c.maxMessageBytes {
+				// Expo would reject it, so don't spend a request on it
+				for _, key := range f.keys {
+					c.dedupCache.Remove(key)
+				}
+				f.keys = nil
+				dropped[i] = f
+				responses[i] = tooBigResponse(f.message, size, c.maxMessageBytes)
+				continue
 			}
 		}
+		dropped[i] = f
+		recorded = append(recorded, f.keys...)
+		if len(f.message.To) == 0 {
+			responses[i] = suppressedResponse(messages[i], f.reason)
+			continue
+		}
+		outgoing = append(outgoing, f.message)
+		index = append(index, i)
+	}
+	if len(outgoing) == 0 {
+		setOriginals(responses, messages)
+		c.emitResponses(ctx, responses, dropped)
+		return responses, nil
 	}
 
-	// Send request
-	resp, err := c.httpClient.POST(fmt.Sprintf("%s/push/send", c.apiURL)).Body().AsJSON(messages).Send()
+	groups, err := chunkMessagesWith(c.chunker, outgoing)
 	if err != nil {
+		for _, key := range recorded {
+			c.dedupCache.Remove(key)
+		}
 		return nil, err
 	}
-
-	// Check that we didn't receive an invalid response
-	err = checkStatus(&resp)
+	groups = splitGroups(outgoing, groups, int(c.requestBytes.Load()))
+	chunks := make([][]PushMessage, len(groups))
+	for k, group := range groups {
+		chunks[k] = make([]PushMessage, len(group))
+		for i, j := range group {
+			chunks[k][i] = outgoing[j]
+		}
+	}
+	if c.logger != nil {
+		sizes := make([]int, len(chunks))
+		for k := range chunks {
+			sizes[k] = len(chunks[k])
+		}
+		c.log(ctx, slog.LevelDebug, "expo publish chunked",
+			slog.Int("messages", len(messages)),
+			slog.Int("outgoing", len(outgoing)),
+			slog.Any("chunkSizes", sizes))
+	}
+	results := c.sendChunks(ctx, chunks)
+	now := time.Now()
+	delivered := false
+	for k, result := range results {
+		// A failed chunk split after a 413 may have delivered its leading
+		// messages
+		if len(result.tickets) > 0 {
+			delivered = true
+		}
+		if result.err != nil {
+			if err == nil {
+				err = result.err
+			}
+			// The rest wasn't delivered, so a later attempt must not be
+			// suppressed
+			for _, j := range groups[k][len(result.tickets):] {
+				for _, key := range dropped[index[j]].keys {
+					c.dedupCache.Remove(key)
+				}
+			}
+			c.emitFailure(ctx, chunks[k][len(result.tickets):], result.err)
+		}
+		for i, r := range result.tickets {
+			responses[index[groups[k][i]]] = r
+			if r.Status == DivertedStatus {
+				// The message will be published again once Expo recovers
+				for _, key := range dropped[index[groups[k][i]]].keys {
+					c.dedupCache.Remove(key)
+				}
+			}
+			if c.sendStats != nil && r.isSuccess() {
+				for _, token := range r.PushMessage.To {
+					c.sendStats.RecordSend(token, now)
+				}
+			}
+		}
+	}
 	if err != nil {
-		return nil, err
+		c.alertFailure(ctx, err)
+		if !delivered {
+			return nil, err
+		}
+	}
+	setOriginals(responses, messages)
+	c.notifyDeadTickets(responses)
+	c.emitResponses(ctx, responses, dropped)
+	return responses, err
+}
+
+// setOriginals records the message passed to Publish on its response
+func setOriginals(responses []PushResponse, messages []PushMessage) {
+	for i := range responses {
+		responses[i].Original = messages[i]
+	}
+}
+
+// chunkResult is the outcome of sending a chunk of a publish call
+type chunkResult struct {
+	tickets []PushResponse
+	err     error
+}
+
+// sendChunks sends chunks, ChunkConcurrency at a time. Once a chunk fails,
+// the chunks not yet started fail with the same error.
+func (c *PushClient) sendChunks(ctx context.Context, chunks [][]PushMessage) []chunkResult {
+	results := make([]chunkResult, len(chunks))
+	sendChunk := func(k int) error {
+		var err error
+		if c.rateLimiter != nil {
+			err = c.rateLimiter.Wait(ctx, countRecipients(chunks[k]))
+		}
+		if err == nil {
+			results[k].tickets, err = c.sendGuarded(ctx, chunks[k])
+		}
+		results[k].err = err
+		return err
+	}
+	if len(chunks) == 1 {
+		sendChunk(0)
+		return results
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed error
+	)
+	sem := make(chan struct{}, max(c.chunks, 1))
+	for k := range chunks {
+		sem <- struct{}{}
+		mu.Lock()
+		err := failed
+		mu.Unlock()
+		if err != nil {
+			results[k].err = err
+			<-sem
+			continue
+		}
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			// A failure is recorded before the slot is released, so with a
+			// single slot no chunk starts after a failed one
+			defer func() { <-sem }()
+			if err := sendChunk(k); err != nil {
+				mu.Lock()
+				if failed == nil {
+					failed = err
+				}
+				mu.Unlock()
+			}
+		}(k)
+	}
+	wg.Wait()
+	return results
+}
+
+// filterResult is a message stripped of the recipients that must not receive it
+type filterResult struct {
+	message PushMessage
+	// reason is why the last recipient was dropped
+	reason  string
+	dropped []droppedToken
+	// keys are the dedup keys recorded for the remaining recipients
+	keys []string
+}
+
+type droppedToken struct {
+	token  ExponentPushToken
+	reason string
+}
+
+func (f *filterResult) drop(token ExponentPushToken, reason string) {
+	f.reason = reason
+	f.dropped = append(f.dropped, droppedToken{token, reason})
+}
+
+// filter drops the recipients that must not receive message
+func (c *PushClient) filter(ctx context.Context, message PushMessage) (filterResult, error) {
+	var f filterResult
+	now := time.Now()
+	if c.consent != nil {
+		to := make([]ExponentPushToken, 0, len(message.To))
+		for _, token := range message.To {
+			ok, err := c.consented(ctx, token)
+			if err != nil {
+				return f, err
+			}
+			if ok {
+				to = append(to, token)
+			} else {
+				f.drop(token, SuppressedNoConsent)
+			}
+		}
+		message.To = to
+	}
+	if c.preferences != nil {
+		to := make([]ExponentPushToken, 0, len(message.To))
+		for _, token := range message.To {
+			reason, err := c.checkPreferences(ctx, &message, token, now)
+			if err != nil {
+				return f, err
+			}
+			if reason == "" {
+				to = append(to, token)
+			} else {
+				f.drop(token, reason)
+			}
+		}
+		message.To = to
+	}
+	if c.frequencyCap.Max > 0 {
+		since := now.Add(-c.frequencyCap.Period)
+		to := make([]ExponentPushToken, 0, len(message.To))
+		for _, token := range message.To {
+			if c.sendStats.SendCount(token, since) < c.frequencyCap.Max {
+				to = append(to, token)
+			} else {
+				f.drop(token, SuppressedFrequencyCap)
+			}
+		}
+		message.To = to
+	}
+	if c.dedupCache != nil && len(message.To) > 0 {
+		var duplicates []ExponentPushToken
+		message, f.keys, duplicates = c.dedup(message)
+		for _, token := range duplicates {
+			f.drop(token, SuppressedDuplicate)
+		}
 	}
+	f.message = message
+	return f, nil
+}
 
-	// Ensure body is closed after reading
-	defer resp.RawBody().Close()
+func (c *PushClient) send(ctx context.Context, messages []PushMessage) (tickets []PushResponse, err error) {
+	stats := BatchStats{Messages: len(messages), Recipients: countRecipients(messages)}
+	if c.batchObserver != nil {
+		start := time.Now()
+		defer func() {
+			stats.Duration = time.Since(start)
+			stats.Err = err
+			c.batchObserver.ObserveBatch(stats)
+		}()
+	}
 
-	// Validate the response format first
-	var r *Response
-	err = json.NewDecoder(resp.RawBody()).Decode(&r)
+	// Send request. The messages are encoded one by one, so every ticket
+	// can keep the JSON of its own message.
+	path, err := c.endpoint(opSend)
 	if err != nil {
-		// The response isn't json
 		return nil, err
 	}
-	// If there are errors with the entire request, raise an error now.
-	if r.Errors != nil {
-		return nil, NewPushServerError("Invalid server response", &resp, r, r.Errors)
+	data, sent, err := encodeMessages(messages)
+	if err != nil {
+		return nil, err
 	}
-	// We expect the response to have a 'data' field with the responses.
-	if r.Data == nil {
-		return nil, NewPushServerError("Invalid server response", &resp, r, nil)
+	if c.dryRun {
+		return c.sendDryRun(ctx, messages, data, sent, &stats), nil
 	}
-	// Sanity check the response
-	if len(messages) != len(r.Data) {
-		message := "Mismatched response length. Expected %d receipts but only received %d"
-		errorMessage := fmt.Sprintf(message, len(messages), len(r.Data))
-		return nil, NewPushServerError(errorMessage, &resp, r, nil)
+	resp, err := c.postJSON(ctx, path, data, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	// The body is drained and closed once read, and decoded values don't
+	// reference the buffer, so it goes back to the pool when we return
+	buf, err := readBody(resp.Body, c.maxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(buf)
+	body := buf.Bytes()
+	stats.ResponseBytes = len(body)
+
+	tickets, err = decodeTickets(resp, body, len(messages))
+	if err != nil {
+		return nil, err
 	}
 	// Add the original message to each response for reference
-	for i := range r.Data {
-		r.Data[i].PushMessage = messages[i]
+	for i := range tickets {
+		tickets[i].PushMessage = messages[i]
+		tickets[i].Sent = sent[i]
 	}
-	return r.Data, nil
+	c.logTicketErrors(ctx, tickets)
+	return tickets, nil
 }
 
-func checkStatus(resp *fastshot.Response) error {
-	if resp.StatusCode() >= 200 && resp.StatusCode() <= 299 {
-		return nil
+// encodeMessages returns the JSON array of messages, as json.Marshal would,
+// and the JSON of every message within it
+func encodeMessages(messages []PushMessage) ([]byte, []json.RawMessage, error) {
+	data := []byte{'['}
+	bounds := make([]int, 0, len(messages)+1)
+	for i := range messages {
+		if i > 0 {
+			data = append(data, ',')
+		}
+		message, err := json.Marshal(&messages[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		bounds = append(bounds, len(data))
+		data = append(data, message...)
+	}
+	bounds = append(bounds, len(data)+1)
+	data = append(data, ']')
+	sent := make([]json.RawMessage, len(messages))
+	for i := range sent {
+		// Skip the comma before the next message
+		sent[i] = data[bounds[i] : bounds[i+1]-1 : bounds[i+1]-1]
 	}
-	return fmt.Errorf("invalid response (%d %s)", resp.StatusCode(), resp.Status())
+	return data, sent, nil
 }
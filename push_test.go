@@ -1,7 +1,16 @@
 package expo
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateResponseErrorStatus(t *testing.T) {
@@ -76,4 +85,336 @@ func TestValidateResponseErrorMessageRateExceeded(t *testing.T) {
 	if typed.Response != response {
 		t.Error("Didn't return called response")
 	}
-}
\ No newline at end of file
+}
+
+func TestGroupingHelpers(t *testing.T) {
+	message := &PushMessage{}
+	message.SetThreadID("room-1").SetGroup("orders", "3 order updates")
+	if message.ThreadID() != "room-1" || message.Data[DataKeyThreadID] != "room-1" {
+		t.Error("Thread ID not stored in data")
+	}
+	if message.GroupID() != "orders" || message.Data[DataKeyGroupSummary] != "3 order updates" {
+		t.Error("Group not stored in data")
+	}
+}
+
+func TestAPIVersionEndpoint(t *testing.T) {
+	client := NewPushClient(nil)
+	path, err := client.endpoint(opSend)
+	if err != nil || path != DefaultBaseAPIURL+"/push/send" {
+		t.Errorf("Incorrect default endpoint %q (%v)", path, err)
+	}
+	client = NewPushClient(&ClientConfig{APIVersion: "v3"})
+	if _, err := client.endpoint(opSend); !errors.Is(err, ErrUnsupportedAPIVersion) {
+		t.Errorf("Expected unsupported version error, got %v", err)
+	}
+}
+
+func TestPublishNonJSONResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>Down for maintenance</html>"))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	_, err := client.Publish(&PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}})
+	var typed *NonJSONResponseError
+	if !errors.As(err, &typed) {
+		t.Fatalf("Expected NonJSONResponseError, got %v", err)
+	}
+	if typed.StatusCode != http.StatusOK || typed.ContentType != "text/html" || typed.Snippet != "<html>Down for maintenance</html>" {
+		t.Errorf("Incorrect error details: %+v", typed)
+	}
+}
+
+func TestPublishMultipleChunks(t *testing.T) {
+	for _, concurrency := range []int{0, 3} {
+		srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+			if len(messages) > MaxMessagesPerRequest {
+				t.Errorf("Expected at most %d messages per request, got %d", MaxMessagesPerRequest, len(messages))
+			}
+			r := okTickets(messages)
+			for i := range r.Data {
+				r.Data[i].ID = string(messages[i].To[0])
+			}
+			return r
+		})
+		client := NewPushClient(&ClientConfig{Host: srv.URL, ChunkConcurrency: concurrency})
+		messages := testMessages(250)
+		responses, err := client.PublishMultiple(messages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("Expected 3 requests, got %d", calls.Load())
+		}
+		for i, r := range responses {
+			if r.ID != string(messages[i].To[0]) {
+				t.Fatalf("Expected responses in message order, got %s at %d", r.ID, i)
+			}
+		}
+	}
+}
+
+func TestPublishMultipleChunkFailure(t *testing.T) {
+	var failed int
+	sink := EventSinkFunc(func(e DeliveryEvent) {
+		if e.Type == EventFailed {
+			failed++
+		}
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		if messages[0].To[0] == "ExponentPushToken[100]" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, EventSink: sink})
+	responses, err := client.PublishMultiple(testMessages(250))
+	if err == nil {
+		t.Fatal("Expected the failed chunk's error")
+	}
+	if len(responses) != 250 || responses[99].Status != SuccessStatus || responses[100].Status != "" || responses[249].Status != "" {
+		t.Errorf("Expected the responses of the delivered chunk only")
+	}
+	if failed != 150 {
+		t.Errorf("Expected failure events for the 150 undelivered messages, got %d", failed)
+	}
+}
+
+func TestIsExpoPushToken(t *testing.T) {
+	for token, want := range map[string]bool{
+		"ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx]": true,
+		"ExpoPushToken[xxxxxxxxxxxxxxxxxxxxxx]":     true,
+		"ExponentPushToken[]":                       false,
+		"ExponentPushToken[abc":                     false,
+		"ExponentPushToken":                         false,
+		"ExponentPushToken[":                        false,
+		"ExpoPushToken[x]":                          true,
+		"expoPushToken[x]":                          false,
+		"abc":                                       false,
+	} {
+		if got := IsExpoPushToken(token); got != want {
+			t.Errorf("IsExpoPushToken(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
+
+func TestValidateRecipientsAllocs(t *testing.T) {
+	messages := testMessages(100)
+	for i := range messages {
+		messages[i].To[0] = "ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx]"
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := validateRecipients(messages, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Validation allocated %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkValidateRecipients(b *testing.B) {
+	messages := testMessages(1000)
+	for i := range messages {
+		messages[i].To[0] = "ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx]"
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validateRecipients(messages, true)
+	}
+}
+
+func TestPublishInvalidTokens(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, ValidateTokens: true})
+	messages := testMessages(3)
+	messages[0].To = append(messages[0].To, "abc")
+	messages[2].To = []ExponentPushToken{""}
+	_, err := client.PublishMultiple(messages)
+	var invalid *InvalidTokensError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected InvalidTokensError, got %v", err)
+	}
+	want := []InvalidToken{{Message: 0, Recipient: 1, Token: "abc"}, {Message: 2, Recipient: 0, Token: ""}}
+	if !reflect.DeepEqual(invalid.Tokens, want) {
+		t.Errorf("Incorrect invalid tokens: %+v", invalid.Tokens)
+	}
+	if indexes := invalid.Messages(); !reflect.DeepEqual(indexes, []int{0, 2}) {
+		t.Errorf("Incorrect message indexes: %v", indexes)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("Expected nothing sent, got %d requests", calls.Load())
+	}
+}
+
+func TestPushResponseErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		response PushResponse
+		want     ErrorCode
+	}{
+		{PushResponse{Status: SuccessStatus}, ""},
+		{PushResponse{Status: "error"}, ""},
+		{PushResponse{Status: "error", Details: map[string]string{"error": "MismatchSenderId"}}, ErrorCodeMismatchSenderID},
+		{PushResponse{Status: "error", Details: map[string]string{"error": "InvalidCredentials"}}, ErrorCodeInvalidCredentials},
+		{PushResponse{Status: "error", Details: map[string]string{"error": "SomethingNew"}}, "SomethingNew"},
+	} {
+		if got := tt.response.ErrorCode(); got != tt.want {
+			t.Errorf("ErrorCode() of %+v = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestPublishMessageTooBig(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	messages := testMessages(3)
+	messages[1].Body = strings.Repeat("x", MaxMessageBytes)
+	responses, err := client.PublishMultiple(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected one request, got %d", calls.Load())
+	}
+	if err := responses[1].ValidateResponse(); !errors.Is(err, ErrMessageTooBig) {
+		t.Errorf("Expected ErrMessageTooBig, got %v", err)
+	}
+	if responses[0].ValidateResponse() != nil || responses[2].ValidateResponse() != nil {
+		t.Errorf("Expected the other messages sent, got %+v", responses)
+	}
+	// Nothing is sent when every message is too big
+	if _, err := client.Publish(&messages[1]); err != nil || calls.Load() != 1 {
+		t.Errorf("Expected no request, got %d, %v", calls.Load(), err)
+	}
+}
+
+func TestPublishOriginalAndSent(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var messages []PushMessage
+		body, _ = io.ReadAll(r.Body)
+		json.Unmarshal(body, &messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, DedupWindow: time.Hour})
+	first := PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi"}
+	if _, err := client.Publish(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second message reaches only b, a already got it
+	messages := []PushMessage{
+		{To: []ExponentPushToken{"ExponentPushToken[a]", "ExponentPushToken[b]"}, Body: "hi"},
+		{To: []ExponentPushToken{"ExponentPushToken[c]"}, Body: "other"},
+	}
+	responses, err := client.PublishMultiple(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses[0].Original.To) != 2 {
+		t.Errorf("Incorrect original %+v", responses[0].Original)
+	}
+	var sent PushMessage
+	if err := json.Unmarshal(responses[0].Sent, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.To) != 1 || sent.To[0] != "ExponentPushToken[b]" {
+		t.Errorf("Incorrect sent message %s", responses[0].Sent)
+	}
+	want, _ := json.Marshal([]json.RawMessage{responses[0].Sent, responses[1].Sent})
+	if string(body) != string(want) {
+		t.Errorf("Sent messages %s don't match the request %s", want, body)
+	}
+
+	// Suppressed messages weren't sent
+	responses, _ = client.PublishMultiple(messages[:1])
+	if responses[0].Sent != nil || len(responses[0].Original.To) != 2 {
+		t.Errorf("Incorrect suppressed response %+v", responses[0])
+	}
+}
+
+func TestPushMessageJSON(t *testing.T) {
+	to := []ExponentPushToken{"ExponentPushToken[a]"}
+	tests := []struct {
+		message PushMessage
+		want    string
+	}{
+		{PushMessage{To: to, Body: "hi"}, `{"to":["ExponentPushToken[a]"],"body":"hi"}`},
+		{PushMessage{To: to, Body: "hi", Sound: "default", Badge: 3},
+			`{"to":["ExponentPushToken[a]"],"body":"hi","sound":"default","badge":3}`},
+		{PushMessage{To: to, Body: "hi", ClearBadge: true},
+			`{"to":["ExponentPushToken[a]"],"body":"hi","badge":0}`},
+		{PushMessage{To: to, Body: "hi", Sound: "ignored", CriticalSound: &CriticalSound{Critical: true, Name: "default", Volume: 0.5}},
+			`{"to":["ExponentPushToken[a]"],"body":"hi","sound":{"critical":true,"name":"default","volume":0.5}}`},
+		{PushMessage{
+			To: to, Body: "hi", Subtitle: "sub", CategoryID: "reply", MutableContent: true,
+			InterruptionLevel: InterruptionLevelTimeSensitive, ContentAvailable: true,
+		}, `{"to":["ExponentPushToken[a]"],"body":"hi","subtitle":"sub","categoryId":"reply",` +
+			`"mutableContent":true,"interruptionLevel":"time-sensitive","_contentAvailable":true}`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(&test.message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.want {
+			t.Errorf("Incorrect JSON %s, want %s", data, test.want)
+		}
+		// The message survives a round trip, e.g. through a persistent queue
+		var decoded PushMessage
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.CriticalSound != nil {
+			test.message.Sound = ""
+		}
+		if !reflect.DeepEqual(decoded, test.message) {
+			t.Errorf("Incorrect decoded message %+v, want %+v", decoded, test.message)
+		}
+	}
+}
+
+// BenchmarkPublishParallel measures the synchronous hot path across cores,
+// without a network: the transport answers with canned tickets. It covers
+// BatchReport and CachedSecret; a DedupCache and ID generation still take
+// shared locks.
+func BenchmarkPublishParallel(b *testing.B) {
+	const size = 10
+	body, _ := json.Marshal(okTickets(testMessages(size)))
+	transport := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		io.Copy(io.Discard, req.Body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(string(body))),
+			Request:    req,
+		}, nil
+	})
+	report := &BatchReport{}
+	client := NewPushClient(&ClientConfig{
+		Transport:     transport,
+		BatchObserver: report,
+		AccessTokenSource: SecretFunc(func(context.Context) (string, error) {
+			return "token", nil
+		}),
+	})
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		messages := testMessages(size)
+		for pb.Next() {
+			if _, err := client.PublishMultiple(messages); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,284 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// QueuedMessage is a message waiting in a MessageQueue
+type QueuedMessage struct {
+	ID      string
+	Message PushMessage
+	// Tag is why the message was queued, e.g. the ID of an incident
+	Tag        string
+	EnqueuedAt time.Time
+}
+
+// localFields are the fields of a message that aren't sent to Expo, which
+// persistent stores must keep too
+type localFields struct {
+	CollapseKey    string `json:"collapseKey,omitempty"`
+	Locale         string `json:"locale,omitempty"`
+	CorrelationID  string `json:"correlationId,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+func localFieldsOf(m *PushMessage) localFields {
+	return localFields{m.CollapseKey, m.Locale, m.CorrelationID, m.IdempotencyKey}
+}
+
+func (f localFields) restore(m *PushMessage) {
+	m.CollapseKey = f.CollapseKey
+	m.Locale = f.Locale
+	m.CorrelationID = f.CorrelationID
+	m.IdempotencyKey = f.IdempotencyKey
+}
+
+// queuedMessageJSON is QueuedMessage in JSON
+type queuedMessageJSON struct {
+	ID      string      `json:"id"`
+	Message PushMessage `json:"message"`
+	localFields
+	Tag        string    `json:"tag,omitempty"`
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+}
+
+// MarshalJSON encodes the message with its fields that aren't sent to Expo,
+// e.g. CorrelationID, so persistent queues keep them
+func (m QueuedMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(queuedMessageJSON{m.ID, m.Message, localFieldsOf(&m.Message), m.Tag, m.EnqueuedAt})
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON
+func (m *QueuedMessage) UnmarshalJSON(data []byte) error {
+	var v queuedMessageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = QueuedMessage{ID: v.ID, Message: v.Message, Tag: v.Tag, EnqueuedAt: v.EnqueuedAt}
+	v.localFields.restore(&m.Message)
+	return nil
+}
+
+// MessageQueue holds messages waiting to be sent, oldest first.
+// Implementations must be safe for concurrent use; persistent ones keep the
+// messages across restarts.
+type MessageQueue interface {
+	// Push appends messages to the queue. A message whose IdempotencyKey
+	// is already queued, or repeated in messages, is dropped; Push returns
+	// the IDs of the dropped messages.
+	Push(ctx context.Context, messages []QueuedMessage) (duplicates []string, err error)
+	// Peek returns up to n messages from the head of the queue without
+	// removing them. Queues shared by several processes may lease them, so
+	// the other processes don't send them too.
+	Peek(ctx context.Context, n int) ([]QueuedMessage, error)
+	// Remove deletes messages by ID, e.g. once they were sent. Unknown IDs
+	// are ignored.
+	Remove(ctx context.Context, ids []string) error
+	// Len returns the number of queued messages
+	Len(ctx context.Context) (int, error)
+}
+
+// MemoryMessageQueue is an in-memory MessageQueue. Its messages are lost
+// when the process exits.
+type MemoryMessageQueue struct {
+	mu       sync.Mutex
+	messages []QueuedMessage
+	// keys are the idempotency keys of the queued messages
+	keys map[string]bool
+}
+
+// NewMemoryMessageQueue creates an empty in-memory queue
+func NewMemoryMessageQueue() *MemoryMessageQueue {
+	return &MemoryMessageQueue{}
+}
+
+// Push appends messages to the queue, dropping those whose idempotency key
+// is already queued
+func (q *MemoryMessageQueue) Push(_ context.Context, messages []QueuedMessage) ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var duplicates []string
+	for _, m := range messages {
+		if key := m.Message.IdempotencyKey; key != "" {
+			if q.keys[key] {
+				duplicates = append(duplicates, m.ID)
+				continue
+			}
+			if q.keys == nil {
+				q.keys = make(map[string]bool)
+			}
+			q.keys[key] = true
+		}
+		q.messages = append(q.messages, m)
+	}
+	return duplicates, nil
+}
+
+// Peek returns up to n messages from the head of the queue
+func (q *MemoryMessageQueue) Peek(_ context.Context, n int) ([]QueuedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.messages[:min(n, len(q.messages))]), nil
+}
+
+// Remove deletes messages by ID
+func (q *MemoryMessageQueue) Remove(_ context.Context, ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = slices.DeleteFunc(q.messages, func(m QueuedMessage) bool {
+		if !slices.Contains(ids, m.ID) {
+			return false
+		}
+		delete(q.keys, m.Message.IdempotencyKey)
+		return true
+	})
+	return nil
+}
+
+// Len returns the number of queued messages
+func (q *MemoryMessageQueue) Len(context.Context) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages), nil
+}
+
+// DefaultQueueInterval is how often QueuePublisher.Run looks for queued
+// messages
+const DefaultQueueInterval = time.Second
+
+// QueuePublisher sends messages through a MessageQueue: Enqueue stores
+// them and Run publishes them in order, removing each only once it got a
+// ticket. With a persistent queue, the notifications pending when the
+// process stops, e.g. for a deploy, are sent after it restarts.
+//
+// Messages with an IdempotencyKey are queued once. A message sent right
+// before a crash, while still queued, is sent again after the restart
+// unless the client has a DedupWindow and a DedupCache that outlives the
+// process, e.g. contrib/redis' DedupCache.
+type QueuePublisher struct {
+	Client *PushClient
+	Queue  MessageQueue
+	// RateLimiter paces the sends, on top of the client's own
+	RateLimiter RateLimiter
+	// Interval is how often Run looks for queued messages. Defaults to
+	// DefaultQueueInterval.
+	Interval time.Duration
+	// ErrorReporter receives the errors of Run
+	ErrorReporter ErrorReporter
+	// OnDropped is called for every message removed from the queue
+	// without a ticket because its request failed for good, e.g. to keep
+	// it in a dead letter store
+	OnDropped func(message QueuedMessage, err error)
+}
+
+// Enqueue stores messages for sending and returns their queue IDs. The
+// queue drops the messages whose IdempotencyKey is already queued; their ID
+// is empty.
+func (p *QueuePublisher) Enqueue(ctx context.Context, messages ...PushMessage) ([]string, error) {
+	now := time.Now()
+	queued := make([]QueuedMessage, len(messages))
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = p.Client.NewID()
+		queued[i] = QueuedMessage{ID: ids[i], Message: message, EnqueuedAt: now}
+	}
+	duplicates, err := p.Queue.Push(ctx, queued)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ids {
+		if slices.Contains(duplicates, ids[i]) {
+			ids[i] = ""
+		}
+	}
+	return ids, nil
+}
+
+// Flush sends the queued messages until the queue is empty and returns how
+// many it sent. When a request fails transiently, see IsTransient, the
+// messages not sent yet stay queued; when it fails for good, its messages
+// are removed and passed to OnDropped.
+func (p *QueuePublisher) Flush(ctx context.Context) (int, error) {
+	return drainQueue(ctx, p.Client, p.Queue, p.RateLimiter, p.OnDropped)
+}
+
+// Run flushes the queue every Interval until ctx is done
+func (p *QueuePublisher) Run(ctx context.Context) error {
+	return supervise(ctx, "queue publisher", p.ErrorReporter, func(ctx context.Context) error {
+		interval := p.Interval
+		if interval <= 0 {
+			interval = DefaultQueueInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				if _, err := p.Flush(ctx); err != nil && ctx.Err() == nil && p.ErrorReporter != nil {
+					p.ErrorReporter(err)
+				}
+			}
+		}
+	})
+}
+
+// drainQueue publishes the messages of queue, oldest first, until it is
+// empty. Messages are removed once they got a response, even a failed
+// ticket, and kept when their request failed transiently or the circuit is
+// open. Those of a request that failed for good are removed and passed to
+// onDropped.
+func drainQueue(ctx context.Context, client *PushClient, queue MessageQueue, limiter RateLimiter, onDropped func(QueuedMessage, error)) (int, error) {
+	n := 0
+	for {
+		batch, err := queue.Peek(ctx, MaxMessagesPerRequest)
+		if err != nil || len(batch) == 0 {
+			return n, err
+		}
+		messages := make([]PushMessage, len(batch))
+		for i := range batch {
+			messages[i] = batch[i].Message
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx, countRecipients(messages)); err != nil {
+				return n, err
+			}
+		}
+		responses, err := client.PublishMultipleContext(ctx, messages)
+		// A batch failing for good, e.g. with an invalid token, would block
+		// the queue behind it forever
+		permanent := err != nil && !IsTransient(err) && !errors.Is(err, ErrCircuitOpen) && ctx.Err() == nil
+		var done []string
+		var dropped []QueuedMessage
+		for i := range batch {
+			if i < len(responses) && responses[i].Status != "" {
+				done = append(done, batch[i].ID)
+			} else if permanent {
+				done = append(done, batch[i].ID)
+				dropped = append(dropped, batch[i])
+			}
+		}
+		if len(done) > 0 {
+			if err := queue.Remove(ctx, done); err != nil {
+				return n, err
+			}
+			n += len(done) - len(dropped)
+		}
+		if onDropped != nil {
+			for _, m := range dropped {
+				onDropped(m, err)
+			}
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
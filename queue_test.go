@@ -0,0 +1,153 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueuedMessageJSON(t *testing.T) {
+	in := QueuedMessage{ID: "q1", Tag: "t", EnqueuedAt: time.Unix(1, 0).UTC(), Message: PushMessage{
+		To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi", CorrelationID: "c1",
+		IdempotencyKey: "k1", Locale: "fr", CollapseKey: "ck"}}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out QueuedMessage
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != in.ID || out.Tag != in.Tag || !out.EnqueuedAt.Equal(in.EnqueuedAt) || out.Message.Body != "hi" ||
+		out.Message.CorrelationID != "c1" || out.Message.IdempotencyKey != "k1" || out.Message.Locale != "fr" || out.Message.CollapseKey != "ck" {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+}
+
+func TestQueuePublisher(t *testing.T) {
+	failing := true
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		for _, m := range messages {
+			sent = append(sent, string(m.To[0]))
+		}
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	publisher := &QueuePublisher{Client: New(WithHost(srv.URL)), Queue: NewMemoryMessageQueue()}
+	if _, err := publisher.Enqueue(ctx, testMessages(3)...); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := publisher.Flush(ctx); n != 0 || err == nil {
+		t.Errorf("Expected the flush to fail, got %d, %v", n, err)
+	}
+	if n, _ := publisher.Queue.Len(ctx); n != 3 {
+		t.Fatalf("Expected the messages to stay queued, got %d", n)
+	}
+	failing = false
+	if n, err := publisher.Flush(ctx); n != 3 || err != nil {
+		t.Errorf("Expected 3 messages sent, got %d, %v", n, err)
+	}
+	if n, _ := publisher.Queue.Len(ctx); n != 0 || len(sent) != 3 || sent[0] != "ExponentPushToken[0]" || sent[2] != "ExponentPushToken[2]" {
+		t.Errorf("Expected the messages sent in order, got %v with %d queued", sent, n)
+	}
+}
+
+// crashingQueue fails the first Remove, as if the process died after
+// sending
+type crashingQueue struct {
+	MessageQueue
+	crashed bool
+}
+
+func (q *crashingQueue) Remove(ctx context.Context, ids []string) error {
+	if !q.crashed {
+		q.crashed = true
+		return errors.New("crash")
+	}
+	return q.MessageQueue.Remove(ctx, ids)
+}
+
+func TestQueuePublisherIdempotencyKey(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	ctx := context.Background()
+	// The cache outlives the publishers, like a shared one would
+	cache := NewMemoryDedupCache()
+	queue := &crashingQueue{MessageQueue: NewMemoryMessageQueue()}
+	message := testMessages(1)[0]
+	message.IdempotencyKey = "order-42-shipped"
+
+	first := &QueuePublisher{Client: New(WithHost(srv.URL), WithDedup(time.Hour, cache)), Queue: queue}
+	ids, err := first.Enqueue(ctx, message, message)
+	if err != nil || len(ids) != 2 || ids[0] == "" || ids[1] != "" {
+		t.Fatalf("Expected an ID for the first message only, got %q, %v", ids, err)
+	}
+	first.Enqueue(ctx, message)
+	if n, _ := queue.Len(ctx); n != 1 {
+		t.Fatalf("Expected the key queued once, got %d messages", n)
+	}
+	if _, err := first.Flush(ctx); err == nil {
+		t.Fatal("Expected the flush to fail")
+	}
+
+	// After the restart the message is still queued but not sent again
+	second := &QueuePublisher{Client: New(WithHost(srv.URL), WithDedup(time.Hour, cache)), Queue: queue}
+	if n, err := second.Flush(ctx); n != 1 || err != nil {
+		t.Fatalf("Expected the message removed, got %d, %v", n, err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the message sent once, got %d requests", calls.Load())
+	}
+	// Once removed, the key can be queued again
+	second.Enqueue(ctx, message)
+	if n, _ := queue.Len(ctx); n != 1 {
+		t.Errorf("Expected the key queued again, got %d messages", n)
+	}
+}
+
+func TestQueuePublisherDropsPermanentFailures(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	ctx := context.Background()
+	var dropped []QueuedMessage
+	publisher := &QueuePublisher{
+		Client: New(WithHost(srv.URL)),
+		Queue:  NewMemoryMessageQueue(),
+		OnDropped: func(message QueuedMessage, err error) {
+			if err == nil {
+				t.Error("Expected the error of the dropped message")
+			}
+			dropped = append(dropped, message)
+		},
+	}
+	invalid := testMessages(1)[0]
+	invalid.To = []ExponentPushToken{""}
+	if _, err := publisher.Enqueue(ctx, invalid); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := publisher.Flush(ctx); n != 0 || err == nil {
+		t.Errorf("Expected the flush to fail, got %d, %v", n, err)
+	}
+	if n, _ := publisher.Queue.Len(ctx); n != 0 || len(dropped) != 1 {
+		t.Fatalf("Expected the message dropped, got %d queued, %d dropped", n, len(dropped))
+	}
+	// The queue isn't stuck behind it
+	if _, err := publisher.Enqueue(ctx, testMessages(2)...); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := publisher.Flush(ctx); n != 2 || err != nil || calls.Load() != 1 {
+		t.Errorf("Expected 2 messages sent, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,284 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by Quota.Admit, with the decision, when a job
+// doesn't fit its budgets under QuotaReject
+var ErrQuotaExceeded = newCodedError(ErrorCodeQuotaExceeded, "quota exceeded")
+
+// ErrQuotaNoScheduler is returned by Quota.Admit under QuotaSchedule when
+// the quota has no Scheduler
+var ErrQuotaNoScheduler = newCodedError(ErrorCodeInvalidArgument, "quota schedules overflow without a scheduler")
+
+// QuotaPolicy decides what happens to a job that doesn't fit its budgets
+type QuotaPolicy string
+
+const (
+	// QuotaReject refuses the whole job. Nothing is counted against the budgets.
+	QuotaReject QuotaPolicy = "reject"
+	// QuotaTrim admits the messages that fit and drops the others
+	QuotaTrim QuotaPolicy = "trim"
+	// QuotaSchedule admits the messages that fit and schedules the others
+	// at the start of the next window
+	QuotaSchedule QuotaPolicy = "schedule"
+)
+
+// QuotaUsage counts the notifications admitted per budget and day.
+// Implementations must be safe for concurrent use; share one between
+// processes to enforce budgets across them.
+type QuotaUsage interface {
+	// Reserve counts up to n notifications against key for the day starting
+	// at day, without going over limit, and returns how many it counted
+	Reserve(ctx context.Context, key string, day time.Time, n, limit int) (int, error)
+	// Release uncounts n notifications reserved against key for day
+	Release(ctx context.Context, key string, day time.Time, n int) error
+	// Used returns the number of notifications counted against key for day
+	Used(ctx context.Context, key string, day time.Time) (int, error)
+}
+
+// Quota checks jobs against daily budgets before they start, e.g. to cap
+// what a tenant may send per day. Budgets count notifications, one per
+// recipient, per calendar day in Location.
+type Quota struct {
+	// Daily is the budget shared by every job, unlimited if zero
+	Daily int
+	// Tenants holds the budgets of tenants, e.g. Registry tenants. Jobs of
+	// other tenants are only bound by Daily.
+	Tenants map[string]int
+	// Policy applies to jobs that don't fit. Defaults to QuotaReject.
+	Policy QuotaPolicy
+	// Scheduler receives the overflow under QuotaSchedule. Scheduled
+	// messages aren't checked against the budgets of the next window.
+	Scheduler *Scheduler
+	// Usage counts admitted notifications. Defaults to an in-memory store.
+	Usage QuotaUsage
+	// Location sets the day boundaries. Defaults to UTC.
+	Location *time.Location
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// BudgetUsage is the state of a budget after a decision
+type BudgetUsage struct {
+	// Key is "daily" or "tenant:" followed by the tenant
+	Key   string
+	Limit int
+	Used  int
+}
+
+// QuotaDecision reports how Quota.Admit handled a job
+type QuotaDecision struct {
+	Policy QuotaPolicy
+	Tenant string
+	// Requested is the number of notifications of the job
+	Requested int
+	// Admitted is the number of notifications that may be sent now
+	Admitted int
+	// Overflow is the number of notifications rejected, trimmed or
+	// scheduled, depending on Policy
+	Overflow int
+	Budgets  []BudgetUsage
+	// Messages are the admitted messages, in order
+	Messages []PushMessage
+	// Scheduled holds the IDs of the overflow messages scheduled for
+	// NextWindow under QuotaSchedule
+	Scheduled  []string
+	NextWindow time.Time
+}
+
+func (d *QuotaDecision) String() string {
+	var b strings.Builder
+	switch {
+	case d.Overflow == 0:
+		fmt.Fprintf(&b, "admitted %d notifications", d.Requested)
+	case d.Policy == QuotaTrim:
+		fmt.Fprintf(&b, "admitted %d of %d notifications, trimmed %d", d.Admitted, d.Requested, d.Overflow)
+	case d.Policy == QuotaSchedule:
+		fmt.Fprintf(&b, "admitted %d of %d notifications, scheduled %d for %s",
+			d.Admitted, d.Requested, d.Overflow, d.NextWindow.Format(time.RFC3339))
+	default:
+		fmt.Fprintf(&b, "rejected %d notifications, %d over budget", d.Requested, d.Overflow)
+	}
+	for i, budget := range d.Budgets {
+		sep := ", "
+		if i == 0 {
+			sep = "; "
+		}
+		fmt.Fprintf(&b, "%s%s %d/%d", sep, budget.Key, budget.Used, budget.Limit)
+	}
+	return b.String()
+}
+
+// Admit checks a job of tenant, which may be empty, against the budgets
+// and counts the messages it admits against them. Messages are admitted in
+// order until one doesn't fit. Under QuotaReject, a job that doesn't fit
+// is refused with ErrQuotaExceeded, returned with the decision.
+func (q *Quota) Admit(ctx context.Context, tenant string, messages []PushMessage) (*QuotaDecision, error) {
+	q.mu.Lock()
+	if q.Usage == nil {
+		q.Usage = NewMemoryQuotaUsage()
+	}
+	usage := q.Usage
+	q.mu.Unlock()
+	policy := q.Policy
+	if policy == "" {
+		policy = QuotaReject
+	}
+	if policy == QuotaSchedule && q.Scheduler == nil {
+		return nil, ErrQuotaNoScheduler
+	}
+	day := q.day()
+	decision := &QuotaDecision{
+		Policy:     policy,
+		Tenant:     tenant,
+		Requested:  countRecipients(messages),
+		NextWindow: day.AddDate(0, 0, 1),
+	}
+	budgets := q.budgets(tenant)
+
+	// Reserve as much of the job as every budget allows
+	granted := decision.Requested
+	reserved := make([]int, len(budgets))
+	for i, budget := range budgets {
+		n, err := usage.Reserve(ctx, budget.Key, day, granted, budget.Limit)
+		if err != nil {
+			q.release(ctx, usage, budgets[:i], day, reserved, 0)
+			return nil, err
+		}
+		reserved[i] = n
+		granted = min(granted, n)
+	}
+
+	admitted := len(messages)
+	if granted < decision.Requested {
+		admitted = 0
+		if policy != QuotaReject {
+			for n := 0; admitted < len(messages) && n+len(messages[admitted].To) <= granted; admitted++ {
+				n += len(messages[admitted].To)
+			}
+		}
+	}
+	decision.Messages = messages[:admitted]
+	decision.Admitted = countRecipients(decision.Messages)
+	decision.Overflow = decision.Requested - decision.Admitted
+	if err := q.release(ctx, usage, budgets, day, reserved, decision.Admitted); err != nil {
+		return nil, err
+	}
+	for _, budget := range budgets {
+		used, err := usage.Used(ctx, budget.Key, day)
+		if err != nil {
+			return nil, err
+		}
+		budget.Used = used
+		decision.Budgets = append(decision.Budgets, budget)
+	}
+
+	if decision.Overflow == 0 {
+		return decision, nil
+	}
+	switch policy {
+	case QuotaReject:
+		return decision, ErrQuotaExceeded
+	case QuotaSchedule:
+		for _, message := range messages[admitted:] {
+			decision.Scheduled = append(decision.Scheduled, q.Scheduler.Schedule(message, decision.NextWindow))
+		}
+	}
+	return decision, nil
+}
+
+// budgets returns the budgets a job of tenant is bound by
+func (q *Quota) budgets(tenant string) []BudgetUsage {
+	var budgets []BudgetUsage
+	if q.Daily > 0 {
+		budgets = append(budgets, BudgetUsage{Key: "daily", Limit: q.Daily})
+	}
+	if limit, ok := q.Tenants[tenant]; ok && tenant != "" {
+		budgets = append(budgets, BudgetUsage{Key: "tenant:" + tenant, Limit: limit})
+	}
+	return budgets
+}
+
+// release gives back what was reserved beyond keep from every budget
+func (q *Quota) release(ctx context.Context, usage QuotaUsage, budgets []BudgetUsage, day time.Time, reserved []int, keep int) error {
+	var errs []error
+	for i, budget := range budgets {
+		if n := reserved[i] - keep; n > 0 {
+			errs = append(errs, usage.Release(ctx, budget.Key, day, n))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// day returns the start of the current day in Location
+func (q *Quota) day() time.Time {
+	now := time.Now
+	if q.now != nil {
+		now = q.now
+	}
+	location := q.Location
+	if location == nil {
+		location = time.UTC
+	}
+	t := now().In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+// MemoryQuotaUsage is an in-process QuotaUsage. It only keeps the counts
+// of the current and previous day.
+type MemoryQuotaUsage struct {
+	mu     sync.Mutex
+	counts map[quotaKey]int
+}
+
+type quotaKey struct {
+	key string
+	day int64
+}
+
+// NewMemoryQuotaUsage creates an empty in-memory QuotaUsage
+func NewMemoryQuotaUsage() *MemoryQuotaUsage {
+	return &MemoryQuotaUsage{counts: make(map[quotaKey]int)}
+}
+
+// Reserve counts up to n notifications against key for day within limit
+func (m *MemoryQuotaUsage) Reserve(_ context.Context, key string, day time.Time, n, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := day.AddDate(0, 0, -1).Unix()
+	for k := range m.counts {
+		if k.day < cutoff {
+			delete(m.counts, k)
+		}
+	}
+	k := quotaKey{key, day.Unix()}
+	n = max(min(n, limit-m.counts[k]), 0)
+	m.counts[k] += n
+	return n, nil
+}
+
+// Release uncounts n notifications reserved against key for day
+func (m *MemoryQuotaUsage) Release(_ context.Context, key string, day time.Time, n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := quotaKey{key, day.Unix()}
+	m.counts[k] = max(m.counts[k]-n, 0)
+	return nil
+}
+
+// Used returns the number of notifications counted against key for day
+func (m *MemoryQuotaUsage) Used(_ context.Context, key string, day time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[quotaKey{key, day.Unix()}], nil
+}
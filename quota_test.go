@@ -0,0 +1,140 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestQuota(daily int, policy QuotaPolicy) *Quota {
+	now := time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC)
+	return &Quota{Daily: daily, Policy: policy, now: func() time.Time { return now }}
+}
+
+func TestQuotaReject(t *testing.T) {
+	q := newTestQuota(5, QuotaReject)
+	ctx := context.Background()
+	if decision, err := q.Admit(ctx, "", testMessages(3)); err != nil || decision.Admitted != 3 {
+		t.Fatalf("Expected the first job admitted, got %v, %v", decision, err)
+	}
+	decision, err := q.Admit(ctx, "", testMessages(3))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if decision.Admitted != 0 || decision.Overflow != 3 || len(decision.Messages) != 0 {
+		t.Errorf("Incorrect decision: %+v", decision)
+	}
+	if decision.Budgets[0].Used != 3 {
+		t.Errorf("Expected the rejected job not counted, got %+v", decision.Budgets)
+	}
+	if s := decision.String(); s != "rejected 3 notifications, 3 over budget; daily 3/5" {
+		t.Errorf("Incorrect report: %s", s)
+	}
+}
+
+func TestQuotaTrimTenant(t *testing.T) {
+	q := newTestQuota(10, QuotaTrim)
+	q.Tenants = map[string]int{"acme": 3}
+	messages := testMessages(3)
+	messages[1].To = append(messages[1].To, "ExponentPushToken[x]", "ExponentPushToken[y]")
+	decision, err := q.Admit(context.Background(), "acme", messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second message has three recipients and doesn't fit with the first
+	if len(decision.Messages) != 1 || decision.Admitted != 1 || decision.Overflow != 4 {
+		t.Errorf("Incorrect decision: %+v", decision)
+	}
+	if s := decision.String(); s != "admitted 1 of 5 notifications, trimmed 4; daily 1/10, tenant:acme 1/3" {
+		t.Errorf("Incorrect report: %s", s)
+	}
+}
+
+func TestQuotaSchedule(t *testing.T) {
+	q := newTestQuota(2, QuotaSchedule)
+	q.Scheduler = &Scheduler{}
+	decision, err := q.Admit(context.Background(), "", testMessages(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decision.Messages) != 2 || len(decision.Scheduled) != 1 {
+		t.Fatalf("Incorrect decision: %+v", decision)
+	}
+	next := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	pending := q.Scheduler.Pending()
+	if len(pending) != 1 || !pending[0].At.Equal(next) || !decision.NextWindow.Equal(next) {
+		t.Errorf("Expected the overflow scheduled for %v, got %+v", next, pending)
+	}
+	if !strings.Contains(decision.String(), "scheduled 1 for 2024-05-02T00:00:00Z") {
+		t.Errorf("Incorrect report: %s", decision)
+	}
+}
+
+func TestCampaignQuota(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	defer srv.Close()
+	campaign := &Campaign{
+		ID:       "quota",
+		Messages: testMessages(5),
+		Client:   NewPushClient(&ClientConfig{Host: srv.URL}),
+		Quota:    newTestQuota(3, QuotaTrim),
+	}
+	if err := campaign.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if p := campaign.Progress(); p.ChunksSent != 1 || len(campaign.Messages) != 3 {
+		t.Errorf("Expected 3 messages sent, got %+v", p)
+	}
+	if d := campaign.QuotaDecision(); d == nil || d.Overflow != 2 {
+		t.Errorf("Incorrect decision: %+v", d)
+	}
+}
+
+func TestCampaignQuotaResume(t *testing.T) {
+	requests := 0
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		// The second chunk fails, so the campaign stops after the first
+		if requests++; requests == 2 {
+			return Response{Errors: []APIError{{Code: "INTERNAL_SERVER_ERROR", Message: "down"}}}
+		}
+		return okTickets(messages)
+	})
+	client := New(WithHost(srv.URL), WithRetryPolicy(RetryPolicy{}))
+	checkpoints := NewMemoryCheckpointStore()
+	first := &Campaign{
+		ID:          "quota",
+		Messages:    testMessages(5),
+		ChunkSize:   1,
+		Client:      client,
+		Checkpoints: checkpoints,
+		Quota:       newTestQuota(3, QuotaTrim),
+	}
+	if err := first.Run(context.Background()); err == nil {
+		t.Fatal("Expected the second chunk to fail")
+	}
+
+	// Another process resumes the campaign from the untrimmed messages
+	var sent []ExponentPushToken
+	second := &Campaign{
+		ID:          "quota",
+		Messages:    testMessages(5),
+		ChunkSize:   1,
+		Client:      client,
+		Checkpoints: checkpoints,
+		Quota:       newTestQuota(3, QuotaTrim),
+		OnChunk: func(_ int, responses []PushResponse) {
+			sent = append(sent, responses[0].PushMessage.To...)
+		},
+	}
+	if err := second.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	messages := testMessages(3)
+	if len(sent) != 2 || sent[0] != messages[1].To[0] || sent[1] != messages[2].To[0] {
+		t.Errorf("Expected only the admitted messages left sent, got %v in %d requests", sent, calls.Load())
+	}
+}
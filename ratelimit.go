@@ -0,0 +1,81 @@
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit is the number of notifications per second Expo accepts
+// per project before throttling
+const DefaultRateLimit = 600
+
+// RateLimiter throttles outgoing notifications. Implementations shared
+// between processes, such as the one in contrib/redis, let several replicas
+// of a sender collectively respect Expo's limits.
+type RateLimiter interface {
+	// Wait blocks until n notifications may be sent or ctx is done
+	Wait(ctx context.Context, n int) error
+}
+
+// TokenBucketLimiter is an in-process RateLimiter
+type TokenBucketLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTokenBucketLimiter creates a limiter allowing rate notifications per
+// second with bursts of up to burst notifications
+func NewTokenBucketLimiter(rate float64, burst int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until n notifications may be sent or ctx is done.
+// Requests are served in order: each one reserves its tokens, possibly going
+// into debt, and waits for the debt to be repaid.
+func (l *TokenBucketLimiter) Wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// Give back the reservation
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func countRecipients(messages []PushMessage) int {
+	n := 0
+	for i := range messages {
+		n += len(messages[i].To)
+	}
+	return n
+}
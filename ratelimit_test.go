@@ -0,0 +1,36 @@
+package expo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketLimiterBurst(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1000, 10)
+	start := time.Now()
+	if err := limiter.Wait(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 5*time.Millisecond {
+		t.Error("Burst within budget was delayed")
+	}
+	if err := limiter.Wait(context.Background(), 20); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("Request over budget was not delayed (%v)", elapsed)
+	}
+}
+
+func TestTokenBucketLimiterCancel(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, 100); err == nil {
+		t.Error("Expected context error")
+	}
+	if limiter.tokens < 0 {
+		t.Error("Cancelled reservation was not returned")
+	}
+}
@@ -0,0 +1,173 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MaxReceiptIDsPerRequest is the maximum number of ticket IDs Expo accepts
+// in a single receipts request
+const MaxReceiptIDsPerRequest = 1000
+
+// PushReceipt is the outcome of the delivery of a ticket to Apple or Google.
+// A successful delivery:
+//
+//	{'status': 'ok'}
+//
+// A token that is no longer registered:
+//
+//	{'status': 'error',
+//	 'message': '"ExponentPushToken[...]" is not a registered push notification recipient',
+//	 'details': {'error': 'DeviceNotRegistered'}}
+type PushReceipt struct {
+	// ID is the ticket ID the receipt is for
+	ID string `json:"-"`
+	// CorrelationID is the correlation ID of the ticket's message, when
+	// fetched with GetTicketReceipts
+	CorrelationID string            `json:"-"`
+	Status        string            `json:"status"`
+	Message       string            `json:"message"`
+	Details       map[string]string `json:"details"`
+}
+
+// ValidateReceipt returns an error if the delivery failed, typed like the
+// errors of ValidateResponse, e.g. *DeviceNotRegisteredError when the token
+// must no longer be used
+func (r *PushReceipt) ValidateReceipt() error {
+	response := &PushResponse{ID: r.ID, Status: r.Status, Message: r.Message, Details: r.Details}
+	return response.ValidateResponse()
+}
+
+// ErrorCode returns the error code of a failed delivery, or "" if it
+// succeeded or Expo gave none
+func (r *PushReceipt) ErrorCode() ErrorCode {
+	if r.Status == SuccessStatus {
+		return ""
+	}
+	return ErrorCode(r.Details["error"])
+}
+
+// receiptsResponse is the body of a push/getReceipts response
+type receiptsResponse struct {
+	Data   map[string]PushReceipt `json:"data"`
+	Errors []APIError             `json:"errors"`
+}
+
+// GetReceipts fetches the receipts of tickets returned by Publish
+// @param ids: the IDs of the tickets
+// @return the receipts by ticket ID. Receipts that aren't ready yet, or
+// expired, are missing from the map.
+// @return error if a request failed
+func (c *PushClient) GetReceipts(ids []string) (map[string]PushReceipt, error) {
+	return c.GetReceiptsContext(context.Background(), ids)
+}
+
+// GetReceiptsContext fetches the receipts of tickets with a context and
+// per-call options. Duplicate IDs are requested once, and IDs are requested
+// in chunks of MaxReceiptIDsPerRequest; the receipts of the chunks fetched
+// before a failure are returned with the error.
+func (c *PushClient) GetReceiptsContext(ctx context.Context, ids []string, opts ...CallOption) (map[string]PushReceipt, error) {
+	receipts, err := c.fetchReceipts(ctx, ids, opts)
+	c.notifyDeadReceipts(receipts, nil)
+	return receipts, err
+}
+
+func (c *PushClient) fetchReceipts(ctx context.Context, ids []string, opts []CallOption) (map[string]PushReceipt, error) {
+	ctx, cancel := withCallOptions(ctx, c.callDefaults(opts))
+	defer cancel()
+	ids = uniqueIDs(ids)
+	receipts := make(map[string]PushReceipt, len(ids))
+	for start := 0; start < len(ids); start += MaxReceiptIDsPerRequest {
+		end := min(start+MaxReceiptIDsPerRequest, len(ids))
+		err := c.retry(ctx, func() error {
+			return c.getReceipts(ctx, ids[start:end], receipts)
+		})
+		if err != nil {
+			return receipts, err
+		}
+	}
+	return receipts, nil
+}
+
+// uniqueIDs returns ids without duplicates, in order. ids is returned as is
+// when it has none.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	for i, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			continue
+		}
+		// Copy on the first duplicate only
+		unique := append([]string(nil), ids[:i]...)
+		for _, id := range ids[i+1:] {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				unique = append(unique, id)
+			}
+		}
+		return unique
+	}
+	return ids
+}
+
+// GetTicketReceipts fetches the receipts of tickets returned by Publish,
+// by ticket ID, and sets their CorrelationID from the tickets' messages.
+// Tickets without an ID, e.g. suppressed messages, are skipped.
+func (c *PushClient) GetTicketReceipts(ctx context.Context, tickets []PushResponse, opts ...CallOption) (map[string]PushReceipt, error) {
+	ids := make([]string, 0, len(tickets))
+	correlationIDs := make(map[string]string, len(tickets))
+	tokens := make(map[string][]ExponentPushToken, len(tickets))
+	for i := range tickets {
+		if id := tickets[i].ID; id != "" {
+			ids = append(ids, id)
+			correlationIDs[id] = tickets[i].PushMessage.CorrelationID
+			tokens[id] = tickets[i].PushMessage.To
+		}
+	}
+	receipts, err := c.fetchReceipts(ctx, ids, opts)
+	for id, receipt := range receipts {
+		receipt.CorrelationID = correlationIDs[id]
+		receipts[id] = receipt
+	}
+	c.notifyDeadReceipts(receipts, tokens)
+	return receipts, err
+}
+
+func (c *PushClient) getReceipts(ctx context.Context, ids []string, receipts map[string]PushReceipt) error {
+	if c.dryRun {
+		dryRunReceipts(ids, receipts)
+		return nil
+	}
+	var stats BatchStats
+	resp, err := c.post(ctx, opGetReceipts, map[string][]string{"ids": ids}, &stats)
+	if err != nil {
+		return err
+	}
+	buf, err := readBody(resp.Body, c.maxResponseBytes)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	body := buf.Bytes()
+
+	var r *receiptsResponse
+	if err := json.Unmarshal(body, &r); err != nil || r == nil {
+		return newNonJSONResponseError(resp, body, err)
+	}
+	if r.Errors != nil {
+		return NewPushServerError("Invalid server response", resp, nil, r.Errors)
+	}
+	if r.Data == nil {
+		return NewPushServerError("Invalid server response", resp, nil, nil)
+	}
+	for id, receipt := range r.Data {
+		if receipt.Status != SuccessStatus && receipt.Status != errorStatus {
+			return NewPushServerError(fmt.Sprintf("Invalid receipt status %q for %s", receipt.Status, id), resp, nil, nil)
+		}
+		receipt.ID = id
+		receipts[id] = receipt
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package expo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newReceiptServer(t *testing.T, handler func(ids []string) any) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/--/api/v2/push/getReceipts" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		json.NewEncoder(w).Encode(handler(body.IDs))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestGetReceipts(t *testing.T) {
+	srv, _ := newReceiptServer(t, func(ids []string) any {
+		return map[string]any{"data": map[string]any{
+			"ok":   map[string]any{"status": "ok"},
+			"gone": map[string]any{"status": "error", "message": "not registered", "details": map[string]string{"error": ErrorDeviceNotRegistered}},
+		}}
+	})
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	receipts, err := client.GetReceipts([]string{"ok", "gone", "later"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 2 {
+		t.Errorf("Expected receipts not ready yet to be missing, got %v", receipts)
+	}
+	if r := receipts["ok"]; r.ID != "ok" || r.ValidateReceipt() != nil {
+		t.Errorf("Expected a successful receipt, got %+v", r)
+	}
+	gone := receipts["gone"]
+	var notRegistered *DeviceNotRegisteredError
+	if err := gone.ValidateReceipt(); !errors.As(err, &notRegistered) {
+		t.Errorf("Expected DeviceNotRegisteredError, got %v", err)
+	}
+	if code := gone.ErrorCode(); code != ErrorCodeDeviceNotRegistered {
+		t.Errorf("Expected DeviceNotRegistered, got %q", code)
+	}
+}
+
+func TestGetReceiptsChunks(t *testing.T) {
+	srv, calls := newReceiptServer(t, func(ids []string) any {
+		if len(ids) > MaxReceiptIDsPerRequest {
+			t.Errorf("Expected at most %d IDs per request, got %d", MaxReceiptIDsPerRequest, len(ids))
+		}
+		data := make(map[string]PushReceipt)
+		for _, id := range ids {
+			data[id] = PushReceipt{Status: SuccessStatus}
+		}
+		return map[string]any{"data": data}
+	})
+	ids := make([]string, 2500)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	receipts, err := NewPushClient(&ClientConfig{Host: srv.URL}).GetReceipts(ids)
+	if err != nil || len(receipts) != len(ids) {
+		t.Fatalf("Expected %d receipts, got %d, %v", len(ids), len(receipts), err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 requests, got %d", *calls)
+	}
+
+	// Duplicates don't take room in a request
+	*calls = 0
+	receipts, err = NewPushClient(&ClientConfig{Host: srv.URL}).GetReceipts(append(ids[:1000:1000], ids[:500]...))
+	if err != nil || len(receipts) != 1000 || *calls != 1 {
+		t.Errorf("Expected 1000 receipts in 1 request, got %d in %d, %v", len(receipts), *calls, err)
+	}
+}
+
+func TestGetReceiptsErrors(t *testing.T) {
+	srv, _ := newReceiptServer(t, func(ids []string) any {
+		return Response{Errors: []APIError{{Code: "VALIDATION_ERROR", Message: "bad ids"}}}
+	})
+	_, err := NewPushClient(&ClientConfig{Host: srv.URL}).GetReceipts([]string{"a"})
+	var serverErr *PushServerError
+	if !errors.As(err, &serverErr) || len(serverErr.Errors) != 1 {
+		t.Errorf("Expected a PushServerError with the API errors, got %v", err)
+	}
+}
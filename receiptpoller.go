@@ -0,0 +1,199 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReceiptDelay is how long ReceiptPoller waits after a ticket
+	// before fetching its receipt, as Expo recommends
+	DefaultReceiptDelay = 15 * time.Minute
+	// DefaultReceiptInterval is how often ReceiptPoller polls, and retries
+	// receipts that weren't ready yet
+	DefaultReceiptInterval = time.Minute
+	// DefaultReceiptMaxAge is how long ReceiptPoller waits for a receipt
+	// before giving up; Expo keeps receipts for about a day
+	DefaultReceiptMaxAge = 24 * time.Hour
+)
+
+// ErrReceiptExpired is the error of a ReceiptResult whose receipt never
+// became available within MaxAge
+var ErrReceiptExpired = newCodedError(ErrorCodeReceiptExpired, "receipt expired")
+
+// ReceiptResult is the outcome of a ticket polled by ReceiptPoller
+type ReceiptResult struct {
+	TicketID      string
+	CorrelationID string
+	// Receipt is nil if Err is set
+	Receipt *PushReceipt
+	Err     error
+}
+
+// ReceiptPoller fetches the receipts of tickets in the background: it waits
+// Delay after a ticket is added, fetches receipts in batches, retries the
+// ones not ready yet every Interval and delivers every outcome to OnReceipt
+// and Results. Pair it with ClientConfig.OnDeviceNotRegistered to prune tokens.
+type ReceiptPoller struct {
+	Client *PushClient
+	// Delay is the wait before the first fetch. Defaults to DefaultReceiptDelay.
+	Delay time.Duration
+	// Interval is how often Run polls. Defaults to DefaultReceiptInterval.
+	Interval time.Duration
+	// MaxAge is how long a receipt is waited for before it is delivered
+	// with ErrReceiptExpired. Defaults to DefaultReceiptMaxAge.
+	MaxAge time.Duration
+	// OnReceipt is called with every result, from the polling goroutine
+	OnReceipt func(result ReceiptResult)
+	// Results, when set, receives every result. Polling blocks until the
+	// channel takes it, unless Backpressure says otherwise.
+	Results      chan<- ReceiptResult
+	Backpressure Backpressure[ReceiptResult]
+	// ErrorReporter receives the errors of the receipt requests
+	ErrorReporter ErrorReporter
+
+	mu      sync.Mutex
+	pending map[string]*pendingReceipt
+	now     func() time.Time
+}
+
+type pendingReceipt struct {
+	correlationID string
+	added         time.Time
+	due           time.Time
+}
+
+// Add queues tickets by ID
+func (p *ReceiptPoller) Add(ticketIDs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range ticketIDs {
+		p.add(id, "")
+	}
+}
+
+// AddTickets queues the tickets returned by Publish, keeping the
+// correlation IDs of their messages. Tickets without an ID, e.g. failed or
+// suppressed ones, are skipped.
+func (p *ReceiptPoller) AddTickets(tickets []PushResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range tickets {
+		p.add(tickets[i].ID, tickets[i].PushMessage.CorrelationID)
+	}
+}
+
+func (p *ReceiptPoller) add(id, correlationID string) {
+	if id == "" {
+		return
+	}
+	if p.pending == nil {
+		p.pending = make(map[string]*pendingReceipt)
+	}
+	delay := p.Delay
+	if delay <= 0 {
+		delay = DefaultReceiptDelay
+	}
+	now := p.clock()
+	p.pending[id] = &pendingReceipt{correlationID: correlationID, added: now, due: now.Add(delay)}
+}
+
+// Pending returns the number of tickets waiting for their receipt
+func (p *ReceiptPoller) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// Poll fetches the receipts of the tickets that are due and delivers them.
+// Tickets whose receipt isn't ready are retried after Interval, or
+// delivered with ErrReceiptExpired once older than MaxAge.
+func (p *ReceiptPoller) Poll(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultReceiptInterval
+	}
+	maxAge := p.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultReceiptMaxAge
+	}
+	now := p.clock()
+	p.mu.Lock()
+	var ids []string
+	for id, pending := range p.pending {
+		if !pending.due.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	p.mu.Unlock()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Receipts fetched before a failed request are still delivered
+	receipts, err := p.Client.GetReceiptsContext(ctx, ids)
+	var results []ReceiptResult
+	p.mu.Lock()
+	for _, id := range ids {
+		pending, ok := p.pending[id]
+		if !ok {
+			continue
+		}
+		result := ReceiptResult{TicketID: id, CorrelationID: pending.correlationID}
+		if receipt, ok := receipts[id]; ok {
+			receipt.CorrelationID = pending.correlationID
+			result.Receipt = &receipt
+		} else if err == nil && now.Sub(pending.added) >= maxAge {
+			result.Err = ErrReceiptExpired
+		} else {
+			pending.due = now.Add(interval)
+			continue
+		}
+		delete(p.pending, id)
+		results = append(results, result)
+	}
+	p.mu.Unlock()
+
+	for _, result := range results {
+		if p.OnReceipt != nil {
+			p.OnReceipt(result)
+		}
+		// Undelivered results are lost with the context
+		if p.Results != nil && !p.Backpressure.deliver(ctx, p.Results, result) {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// Run polls every Interval until ctx is done
+func (p *ReceiptPoller) Run(ctx context.Context) error {
+	return supervise(ctx, "receipt poller", p.ErrorReporter, func(ctx context.Context) error {
+		interval := p.Interval
+		if interval <= 0 {
+			interval = DefaultReceiptInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				if err := p.Poll(ctx); err != nil && ctx.Err() == nil && p.ErrorReporter != nil {
+					p.ErrorReporter(err)
+				}
+			}
+		}
+	})
+}
+
+func (p *ReceiptPoller) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
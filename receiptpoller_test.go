@@ -0,0 +1,66 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReceiptPoller(t *testing.T) {
+	ready := map[string]bool{"a": true}
+	srv, calls := newReceiptServer(t, func(ids []string) any {
+		data := make(map[string]any)
+		for _, id := range ids {
+			if ready[id] {
+				data[id] = map[string]any{"status": "ok"}
+			}
+		}
+		return map[string]any{"data": data}
+	})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	results := make(chan ReceiptResult, 10)
+	poller := &ReceiptPoller{
+		Client:  NewPushClient(&ClientConfig{Host: srv.URL}),
+		Results: results,
+		now:     func() time.Time { return now },
+	}
+	poller.AddTickets([]PushResponse{
+		{ID: "a", PushMessage: PushMessage{CorrelationID: "msg-a"}},
+		{ID: "b"},
+		{Status: SuppressedStatus},
+	})
+	ctx := context.Background()
+	if err := poller.Poll(ctx); err != nil || *calls != 0 {
+		t.Fatalf("Expected no request before the delay, got %d, %v", *calls, err)
+	}
+
+	now = now.Add(DefaultReceiptDelay)
+	if err := poller.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-results; r.TicketID != "a" || r.CorrelationID != "msg-a" || r.Receipt == nil || r.Receipt.CorrelationID != "msg-a" {
+		t.Errorf("Incorrect result: %+v", r)
+	}
+	if poller.Pending() != 1 {
+		t.Errorf("Expected b still pending, got %d", poller.Pending())
+	}
+
+	// Not due again until the interval passed
+	poller.Poll(ctx)
+	if *calls != 1 {
+		t.Errorf("Expected b retried after the interval, got %d requests", *calls)
+	}
+	now = now.Add(DefaultReceiptMaxAge)
+	if err := poller.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-results; r.TicketID != "b" || !errors.Is(r.Err, ErrReceiptExpired) {
+		t.Errorf("Expected b expired, got %+v", r)
+	}
+	if poller.Pending() != 0 {
+		t.Errorf("Expected nothing pending, got %d", poller.Pending())
+	}
+}
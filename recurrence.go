@@ -0,0 +1,175 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"slices"
+	"time"
+)
+
+// Frequency is how often a RecurrenceRule repeats
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	// Monthly repeats on the day of the month of the recipient's Start
+	Monthly
+)
+
+// ErrInvalidRecurrence is returned for a recurrence that never occurs
+var ErrInvalidRecurrence = newCodedError(ErrorCodeInvalidArgument, "invalid recurrence rule")
+
+// RecurrenceRule describes when a recurring notification is sent, in the
+// spirit of an iCalendar RRULE. Times are local to each recipient, e.g.
+// "every Monday at 9:00" follows the recipient's time zone.
+type RecurrenceRule struct {
+	Frequency Frequency
+	// Interval repeats every Interval days, weeks or months. Defaults to 1.
+	Interval int
+	// Weekdays are the days of weekly rules. Defaults to the weekday of the
+	// recipient's Start.
+	Weekdays []time.Weekday
+	Hour     int
+	Minute   int
+}
+
+// RecurrenceAnchor is a recipient of a recurring notification
+type RecurrenceAnchor struct {
+	Token ExponentPushToken
+	// Location is the recipient's time zone. Defaults to UTC.
+	Location *time.Location
+	// Start is when the recurrence begins for the recipient, and what
+	// intervals count from. Defaults to when it is scheduled.
+	Start time.Time
+}
+
+// Recurrence sends Message to every recipient on the occurrences of Rule
+type Recurrence struct {
+	Rule RecurrenceRule
+	// Message is the content sent; its To is replaced by each recipient
+	Message    PushMessage
+	Recipients []RecurrenceAnchor
+	// Skip is consulted before each occurrence, e.g. to skip users that were
+	// recently active. Returning true skips the occurrence for the token.
+	Skip func(token ExponentPushToken, at time.Time) bool
+}
+
+// Next returns the first occurrence after the given time for a recipient
+// in loc whose recurrence started at start, or the zero time if there is none
+func (r RecurrenceRule) Next(after, start time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	interval := max(r.Interval, 1)
+	first := start.In(loc)
+	if after.Before(start) {
+		after = start.Add(-time.Nanosecond)
+	}
+	local := after.In(loc)
+	// A year of days is enough to cover any interval up to a year of months
+	for d := 0; d <= 366*interval; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, r.Hour, r.Minute, 0, 0, loc)
+		if !day.After(after) || !r.matches(day, first, interval) {
+			continue
+		}
+		return day
+	}
+	return time.Time{}
+}
+
+func (r RecurrenceRule) matches(day, first time.Time, interval int) bool {
+	days := civilDays(day) - civilDays(first)
+	switch r.Frequency {
+	case Daily:
+		return days%interval == 0
+	case Weekly:
+		weekdays := r.Weekdays
+		if len(weekdays) == 0 {
+			weekdays = []time.Weekday{first.Weekday()}
+		}
+		// Weeks count from the Sunday starting the first week
+		weeks := (days + int(first.Weekday())) / 7
+		return slices.Contains(weekdays, day.Weekday()) && weeks%interval == 0
+	case Monthly:
+		months := (day.Year()-first.Year())*12 + int(day.Month()-first.Month())
+		return day.Day() == first.Day() && months%interval == 0
+	}
+	return false
+}
+
+// civilDays is the number of days since the Unix epoch of the date of t
+// in its own location
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// recurrenceState is a scheduled Recurrence with the next occurrence of
+// each recipient
+type recurrenceState struct {
+	Recurrence
+	next []time.Time
+}
+
+// ScheduleRecurring adds a recurrence to the scheduler and returns its ID.
+// Occurrences are sent like scheduled messages, with the recurrence ID.
+func (s *Scheduler) ScheduleRecurring(recurrence Recurrence) (string, error) {
+	now := time.Now()
+	state := &recurrenceState{Recurrence: recurrence}
+	state.Recipients = slices.Clone(recurrence.Recipients)
+	state.next = make([]time.Time, len(state.Recipients))
+	for i := range state.Recipients {
+		anchor := &state.Recipients[i]
+		if anchor.Start.IsZero() {
+			anchor.Start = now
+		}
+		state.next[i] = recurrence.Rule.Next(now, anchor.Start, anchor.Location)
+		if state.next[i].IsZero() {
+			return "", ErrInvalidRecurrence
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recurring == nil {
+		s.recurring = make(map[string]*recurrenceState)
+	}
+	id := s.Client.NewID()
+	s.recurring[id] = state
+	return id, nil
+}
+
+// CancelRecurring stops a recurrence
+func (s *Scheduler) CancelRecurring(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recurring[id]; !ok {
+		return ErrScheduledNotFound
+	}
+	delete(s.recurring, id)
+	return nil
+}
+
+// occurrences returns the occurrences due at now and advances the
+// recurrences past them. s.mu must be held.
+func (s *Scheduler) occurrences(now time.Time) []ScheduledMessage {
+	var due []ScheduledMessage
+	for id, state := range s.recurring {
+		for i, anchor := range state.Recipients {
+			at := state.next[i]
+			if at.IsZero() || at.After(now) {
+				continue
+			}
+			// Occurrences missed while not running are sent once
+			state.next[i] = state.Rule.Next(now, anchor.Start, anchor.Location)
+			if state.Skip != nil && state.Skip(anchor.Token, at) {
+				continue
+			}
+			message := state.Message
+			message.To = []ExponentPushToken{anchor.Token}
+			// Every occurrence is a message of its own
+			message.CorrelationID = s.Client.NewID()
+			due = append(due, ScheduledMessage{ID: id, Message: message, At: at})
+		}
+	}
+	return due
+}
@@ -0,0 +1,97 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceRuleNext(t *testing.T) {
+	saoPaulo := time.FixedZone("BRT", -3*60*60)
+	// Sunday 2024-06-02
+	start := time.Date(2024, 6, 2, 12, 0, 0, 0, saoPaulo)
+	tests := []struct {
+		name  string
+		rule  RecurrenceRule
+		after time.Time
+		want  time.Time
+	}{
+		{
+			"every monday 9am",
+			RecurrenceRule{Frequency: Weekly, Weekdays: []time.Weekday{time.Monday}, Hour: 9},
+			start,
+			time.Date(2024, 6, 3, 9, 0, 0, 0, saoPaulo),
+		},
+		{
+			"every other monday",
+			RecurrenceRule{Frequency: Weekly, Interval: 2, Weekdays: []time.Weekday{time.Monday}, Hour: 9},
+			time.Date(2024, 6, 3, 10, 0, 0, 0, saoPaulo),
+			time.Date(2024, 6, 17, 9, 0, 0, 0, saoPaulo),
+		},
+		{
+			"daily later today",
+			RecurrenceRule{Frequency: Daily, Hour: 18, Minute: 30},
+			start,
+			time.Date(2024, 6, 2, 18, 30, 0, 0, saoPaulo),
+		},
+		{
+			"monthly",
+			RecurrenceRule{Frequency: Monthly, Hour: 8},
+			start,
+			time.Date(2024, 7, 2, 8, 0, 0, 0, saoPaulo),
+		},
+	}
+	for _, tt := range tests {
+		got := tt.rule.Next(tt.after, start, saoPaulo)
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSchedulerRecurring(t *testing.T) {
+	var sent []PushMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		sent = append(sent, messages...)
+		return okTickets(messages)
+	})
+	scheduler := &Scheduler{Client: NewPushClient(&ClientConfig{Host: srv.URL})}
+	active, idle := ExponentPushToken("ExponentPushToken[active]"), ExponentPushToken("ExponentPushToken[idle]")
+	start := time.Now().Add(-36 * time.Hour)
+	id, err := scheduler.ScheduleRecurring(Recurrence{
+		Rule:    RecurrenceRule{Frequency: Daily, Hour: start.UTC().Hour(), Minute: start.UTC().Minute()},
+		Message: PushMessage{Body: "daily digest"},
+		Recipients: []RecurrenceAnchor{
+			{Token: active, Start: start},
+			{Token: idle, Start: start},
+		},
+		Skip: func(token ExponentPushToken, at time.Time) bool { return token == active },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is due right after scheduling
+	if err := scheduler.tick(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("Expected nothing to be sent yet, got %+v", sent)
+	}
+	// Pretend a day went by
+	scheduler.mu.Lock()
+	for i := range scheduler.recurring[id].next {
+		scheduler.recurring[id].next[i] = scheduler.recurring[id].next[i].Add(-24 * time.Hour)
+	}
+	scheduler.mu.Unlock()
+	if err := scheduler.tick(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].To[0] != idle {
+		t.Errorf("Expected the digest to be sent to the idle user only, got %+v", sent)
+	}
+	if err := scheduler.CancelRecurring(id); err != nil {
+		t.Error(err)
+	}
+}
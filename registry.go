@@ -0,0 +1,165 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownTenant is returned for a tenant that isn't registered
+var ErrUnknownTenant = newCodedError(ErrorCodeNotFound, "unknown tenant")
+
+// Registry holds the clients of the Expo projects served by a shared
+// gateway, one per tenant. Each tenant may have its own delivery event
+// webhook, so every team's systems are notified independently.
+type Registry struct {
+	// FlushInterval is how often Run posts webhook events.
+	// Defaults to one second.
+	FlushInterval time.Duration
+	// ErrorReporter receives webhook errors from Run
+	ErrorReporter ErrorReporter
+
+	mu      sync.RWMutex
+	tenants map[string]*tenant
+}
+
+type tenant struct {
+	client  *PushClient
+	webhook *EventWebhook
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{tenants: make(map[string]*tenant)}
+}
+
+// Register creates the client of a tenant from config, replacing any
+// previous one. Calls are labeled with LabelTenant, and their delivery
+// events go to the tenant's webhook as well as to config.EventSink.
+func (r *Registry) Register(id string, config ClientConfig, webhook *EventWebhook) *PushClient {
+	config.Labels = maps.Clone(config.Labels)
+	if config.Labels == nil {
+		config.Labels = make(map[string]string, 1)
+	}
+	config.Labels[LabelTenant] = id
+	sink := EventSink(tenantSink{r, id})
+	if config.EventSink != nil {
+		sink = MultiEventSink(config.EventSink, sink)
+	}
+	config.EventSink = sink
+	client := NewPushClient(&config)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants[id] = &tenant{client: client, webhook: webhook}
+	return client
+}
+
+// SetWebhook replaces the webhook of a tenant; nil removes it
+func (r *Registry) SetWebhook(id string, webhook *EventWebhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tenants[id]
+	if !ok {
+		return ErrUnknownTenant
+	}
+	t.webhook = webhook
+	return nil
+}
+
+// Remove unregisters a tenant
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, id)
+}
+
+// Client returns the client of a tenant
+func (r *Registry) Client(id string) (*PushClient, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return t.client, nil
+}
+
+// Tenants returns the IDs of the registered tenants, sorted
+func (r *Registry) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Run posts the events buffered by the tenants' webhooks every
+// FlushInterval until ctx is done
+func (r *Registry) Run(ctx context.Context) error {
+	return supervise(ctx, "registry webhooks", r.ErrorReporter, func(ctx context.Context) error {
+		interval := r.FlushInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				r.flush(context.WithoutCancel(ctx))
+				return ctx.Err()
+			case <-ticker.C:
+				r.flush(ctx)
+			}
+		}
+	})
+}
+
+// flush posts the events of every webhook, one tenant not holding up another
+func (r *Registry) flush(ctx context.Context) {
+	r.mu.RLock()
+	var webhooks []*EventWebhook
+	for _, t := range r.tenants {
+		if t.webhook != nil {
+			webhooks = append(webhooks, t.webhook)
+		}
+	}
+	r.mu.RUnlock()
+	var wg sync.WaitGroup
+	for _, w := range webhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Flush(ctx); err != nil && r.ErrorReporter != nil {
+				r.ErrorReporter(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// tenantSink routes events to the current webhook of a tenant
+type tenantSink struct {
+	registry *Registry
+	id       string
+}
+
+func (s tenantSink) HandleEvent(event DeliveryEvent) {
+	s.registry.mu.RLock()
+	var webhook *EventWebhook
+	if t, ok := s.registry.tenants[s.id]; ok {
+		webhook = t.webhook
+	}
+	s.registry.mu.RUnlock()
+	if webhook != nil {
+		webhook.HandleEvent(event)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegistryTenantWebhooks(t *testing.T) {
+	push, _ := newTestServer(t, okTickets)
+	received := make(map[string][]DeliveryEvent)
+	hooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifySignature(r, []byte("secret-"+r.URL.Path[1:]), time.Minute); err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		var body struct{ Events []DeliveryEvent }
+		json.NewDecoder(r.Body).Decode(&body)
+		received[r.URL.Path] = append(received[r.URL.Path], body.Events...)
+	}))
+	defer hooks.Close()
+
+	registry := NewRegistry()
+	for _, id := range []string{"shop", "chat"} {
+		registry.Register(id, ClientConfig{Host: push.URL}, &EventWebhook{
+			URL:    hooks.URL + "/" + id,
+			Secret: []byte("secret-" + id),
+			Types:  []EventType{EventSent},
+		})
+	}
+	shop, err := registry.Client("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shop.PublishMultiple(testMessages(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Client("mail"); err != ErrUnknownTenant {
+		t.Errorf("Expected ErrUnknownTenant, got %v", err)
+	}
+	registry.flush(context.Background())
+
+	events := received["/shop"]
+	if len(events) != 2 || events[0].Labels[LabelTenant] != "shop" {
+		t.Errorf("Expected 2 shop events, got %+v", events)
+	}
+	if len(received["/chat"]) != 0 {
+		t.Errorf("Chat tenant received shop events: %+v", received["/chat"])
+	}
+}
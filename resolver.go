@@ -0,0 +1,157 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultDNSCacheTTL is how long a CachingResolver keeps addresses unless
+// configured otherwise
+const DefaultDNSCacheTTL = time.Minute
+
+// CachingResolver caches host lookups of long-lived senders, cutting DNS
+// load while still following IP changes of the host. The standard resolver
+// doesn't expose record TTLs, so entries are kept for TTL; a failed
+// connection, a read or write error on an open one, or a failed request
+// evicts the host's entry so the next dial resolves it again.
+// When a lookup fails, the last known addresses are used.
+type CachingResolver struct {
+	// TTL is how long addresses are reused. Defaults to DefaultDNSCacheTTL.
+	TTL time.Duration
+	// Resolver does the lookups. Defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// LookupHost returns the addresses of host, from the cache when fresh
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	now := time.Now()
+	r.mu.Lock()
+	entry, ok := r.entries[host]
+	r.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		if ok {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultDNSCacheTTL
+	}
+	r.mu.Lock()
+	if r.entries == nil {
+		r.entries = make(map[string]dnsEntry)
+	}
+	r.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// Invalidate evicts host, so its next lookup goes to DNS
+func (r *CachingResolver) Invalidate(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, host)
+}
+
+// dial connects to the first reachable address of addr's host. If none is
+// reachable the host is evicted, in case its addresses changed.
+func (r *CachingResolver) dial(ctx context.Context, dialer *net.Dialer, network, addr string, ipv4Only bool) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, ip := range ips {
+		if ipv4Only && net.ParseIP(ip).To4() == nil {
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return &resolvedConn{Conn: conn, resolver: r, host: host}, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	r.Invalidate(host)
+	if firstErr == nil {
+		firstErr = &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+	}
+	return nil, firstErr
+}
+
+// resolvedConn evicts its host when reading or writing fails, e.g. on a
+// pooled connection to an address the host moved away from
+type resolvedConn struct {
+	net.Conn
+	resolver *CachingResolver
+	host     string
+}
+
+func (c *resolvedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.check(err)
+	return n, err
+}
+
+func (c *resolvedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.check(err)
+	return n, err
+}
+
+func (c *resolvedConn) check(err error) {
+	// The host closing an idle connection, or the transport closing it,
+	// is no reason to look the host up again
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		c.resolver.Invalidate(c.host)
+	}
+}
+
+// resolverTransport evicts the host of failed requests, e.g. when the TLS
+// handshake fails because the address now belongs to another host
+type resolverTransport struct {
+	*http.Transport
+	resolver *CachingResolver
+}
+
+func (t *resolverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil && req.Context().Err() == nil {
+		t.resolver.Invalidate(req.URL.Hostname())
+	}
+	return resp, err
+}
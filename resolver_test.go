@@ -0,0 +1,77 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachingResolver(t *testing.T) {
+	// The A and AAAA lookups dial concurrently
+	var lookups atomic.Int64
+	r := &CachingResolver{
+		TTL: time.Hour,
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				lookups.Add(1)
+				return nil, &net.DNSError{Err: "unreachable", IsTemporary: true}
+			},
+		},
+	}
+	// Seed the cache as if exp.host had resolved to a dead address
+	r.entries = map[string]dnsEntry{"exp.host": {addrs: []string{"127.0.0.1"}, expires: time.Now().Add(time.Hour)}}
+	addrs, err := r.LookupHost(context.Background(), "exp.host")
+	if err != nil || len(addrs) != 1 || lookups.Load() != 0 {
+		t.Fatalf("Expected a cached lookup, got %v, %v after %d lookups", addrs, err, lookups.Load())
+	}
+
+	dialer := &net.Dialer{Timeout: time.Second}
+	if _, err := r.dial(context.Background(), dialer, "tcp", "exp.host:1", false); err == nil {
+		t.Fatal("Expected the dial to fail")
+	}
+	// The failed connection evicted the entry, so the next dial resolves again
+	r.dial(context.Background(), dialer, "tcp", "exp.host:1", false)
+	if lookups.Load() == 0 {
+		t.Error("Expected a new lookup after a connection error")
+	}
+}
+
+func TestCachingResolverEvictsOnErrors(t *testing.T) {
+	r := &CachingResolver{TTL: time.Hour}
+	seed := func() {
+		r.entries = map[string]dnsEntry{"exp.host": {addrs: []string{"127.0.0.1"}, expires: time.Now().Add(time.Hour)}}
+	}
+	cached := func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		_, ok := r.entries["exp.host"]
+		return ok
+	}
+
+	// A pooled connection whose peer went away
+	seed()
+	client, server := net.Pipe()
+	server.Close()
+	conn := &resolvedConn{Conn: client, resolver: r, host: "exp.host"}
+	if _, err := conn.Write([]byte("x")); err == nil || cached() {
+		t.Errorf("Expected a write error to evict the host, got %v", err)
+	}
+
+	// A request failing after the connection was made
+	seed()
+	transport := &resolverTransport{
+		Transport: &http.Transport{DialContext: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("handshake failed")
+		}},
+		resolver: r,
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://exp.host/", nil)
+	if _, err := transport.RoundTrip(req); err == nil || cached() {
+		t.Errorf("Expected a request error to evict the host, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// DefaultRetryBaseDelay is the delay before the first retry unless
+	// RetryPolicy says otherwise
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+	// DefaultRetryMaxDelay bounds the delay between retries unless
+	// RetryPolicy says otherwise
+	DefaultRetryMaxDelay = 10 * time.Second
+	// DefaultRateLimitAttempts is the number of attempts of requests
+	// throttled with 429, even when RetryPolicy disables other retries
+	DefaultRateLimitAttempts = 3
+	// DefaultMaxRetryAfter is the longest Retry-After the client waits
+	// unless RetryPolicy says otherwise
+	DefaultMaxRetryAfter = time.Minute
+)
+
+// RetryPolicy retries requests that fail with a transient error, see
+// IsTransient, with exponential backoff and full jitter. When the server
+// sends Retry-After, the client waits that long instead. Push requests
+// that failed while Expo was processing them, e.g. on a dropped
+// connection, may be delivered twice when retried; combine retries with
+// DedupWindow where that matters.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first. Zero or
+	// one disables retries.
+	MaxAttempts int
+	// BaseDelay is the delay cap of the first retry, doubled on every
+	// retry. Defaults to DefaultRetryBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay bounds the delay between retries. Defaults to DefaultRetryMaxDelay.
+	MaxDelay time.Duration
+	// MaxRetryAfter is the longest Retry-After the client waits; longer
+	// ones fail the call with the *RateLimitError or *ServerError.
+	// Defaults to DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+	// OnRetry is called before every retry with the number of the attempt
+	// about to be made, counting from 2, and the error that caused it,
+	// e.g. to count retries in a metric
+	OnRetry func(attempt int, err error)
+}
+
+// delay returns a random delay before retry n, counting from 0
+func (p RetryPolicy) delay(n int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	limit := p.MaxDelay
+	if limit <= 0 {
+		limit = DefaultRetryMaxDelay
+	}
+	ceiling := limit
+	if n < 30 && base<<n < limit {
+		ceiling = base << n
+	}
+	return rand.N(ceiling) + 1
+}
+
+// wait returns how long to wait before retry n after err, and false if
+// the server asked to wait longer than MaxRetryAfter
+func (p RetryPolicy) wait(n int, err error) (time.Duration, bool) {
+	var httpErr interface{ retryAfter() time.Duration }
+	if !errors.As(err, &httpErr) || httpErr.retryAfter() <= 0 {
+		return p.delay(n), true
+	}
+	limit := p.MaxRetryAfter
+	if limit <= 0 {
+		limit = DefaultMaxRetryAfter
+	}
+	return httpErr.retryAfter(), httpErr.retryAfter() <= limit
+}
+
+// retry calls fn until it succeeds, fails with an error that isn't
+// transient, or the attempts of the client's RetryPolicy run out. Calls
+// made with WithNoRetry are attempted once.
+func (c *PushClient) retry(ctx context.Context, fn func() error) error {
+	noRetry := callOptionsFrom(ctx).noRetry
+	for n := 0; ; n++ {
+		err := fn()
+		if err == nil || noRetry || !IsTransient(err) {
+			return err
+		}
+		attempts := c.retryPolicy.MaxAttempts
+		var rateLimited *RateLimitError
+		if errors.As(err, &rateLimited) {
+			attempts = max(attempts, DefaultRateLimitAttempts)
+		}
+		if n+1 >= attempts {
+			return err
+		}
+		delay, ok := c.retryPolicy.wait(n, err)
+		if !ok {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			// Waiting would only end in a deadline error
+			return err
+		}
+		if c.retryPolicy.OnRetry != nil {
+			c.retryPolicy.OnRetry(n+2, err)
+		}
+		c.log(ctx, slog.LevelInfo, "retrying expo request",
+			slog.Int("attempt", n+2),
+			slog.Duration("delay", delay),
+			slog.String("code", string(ErrorCodeOf(err))),
+			slog.Any("error", err))
+		if err := sleepUntil(ctx, time.Now().Add(delay)); err != nil {
+			return err
+		}
+	}
+}
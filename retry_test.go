@@ -0,0 +1,125 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFlakyServer(t *testing.T, failures int, status int) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	srv, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+	retry := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Retry: retry})
+	if _, err := client.PublishMultiple(testMessages(1)); err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *calls)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		opts   []CallOption
+		want   int
+	}{
+		{"attempts exhausted", http.StatusBadGateway, nil, 2},
+		{"not transient", http.StatusBadRequest, nil, 1},
+		{"no retry option", http.StatusBadGateway, []CallOption{WithNoRetry()}, 1},
+	}
+	for _, tt := range tests {
+		srv, calls := newFlakyServer(t, 5, tt.status)
+		client := NewPushClient(&ClientConfig{Host: srv.URL, Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+		if _, err := client.PublishMultipleContext(context.Background(), testMessages(1), tt.opts...); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if *calls != tt.want {
+			t.Errorf("%s: expected %d attempts, got %d", tt.name, tt.want, *calls)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for n, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 100; i++ {
+			if d := policy.delay(n); d <= 0 || d > ceiling {
+				t.Fatalf("Retry %d: delay %v out of (0, %v]", n, d, ceiling)
+			}
+		}
+	}
+	if d := policy.delay(100); d > time.Second {
+		t.Errorf("Expected MaxDelay to bound late retries, got %v", d)
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	srv, calls := newFlakyServer(t, 2, http.StatusTooManyRequests)
+	// Throttled requests are retried without configuring retries
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Retry: RetryPolicy{BaseDelay: time.Millisecond}})
+	if _, err := client.PublishMultiple(testMessages(1)); err != nil {
+		t.Fatalf("Expected the throttled request to be retried, got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *calls)
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	_, err := client.PublishMultiple(testMessages(1))
+	var rateLimited *RateLimitError
+	if !errors.As(err, &rateLimited) || rateLimited.RetryAfter != 2*time.Minute {
+		t.Fatalf("Expected a RateLimitError with RetryAfter, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retry past MaxRetryAfter, got %d attempts", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-5":                            0,
+		"soon":                          0,
+		"Sat, 01 Jun 2024 12:00:10 GMT": 10 * time.Second,
+		"Sat, 01 Jun 2024 11:00:00 GMT": 0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("%q: expected %v, got %v", value, want, got)
+		}
+	}
+}
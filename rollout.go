@@ -0,0 +1,155 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MetadataAppVersion is the TokenRecord.Metadata key holding the version of
+// the app the token was registered from, e.g. "2.4.1"
+const MetadataAppVersion = "appVersion"
+
+// Reasons a token is left out of a VersionAudience
+const (
+	ExcludedOutdated       = "outdated"
+	ExcludedUnknownVersion = "unknown version"
+)
+
+// ExcludedToken is a token left out of a staged rollout
+type ExcludedToken struct {
+	Token  ExponentPushToken
+	UserID string
+	// Version is the app version of the token, empty if unknown
+	Version string
+	Reason  string
+}
+
+// VersionAudience is the audience of a staged rollout: the tokens on an app
+// version that handles the new notifications, and the ones left out
+type VersionAudience struct {
+	MinVersion string
+	Tokens     []ExponentPushToken
+	Excluded   []ExcludedToken
+}
+
+// AudienceByVersion selects the tokens of store matching filter whose
+// app version, in Metadata[MetadataAppVersion], is MinVersion or later,
+// e.g. the apps that understand a new data schema. Tokens without a
+// version are excluded.
+func AudienceByVersion(ctx context.Context, store TokenStore, filter TokenFilter, minVersion string) (*VersionAudience, error) {
+	records, err := store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	audience := &VersionAudience{MinVersion: minVersion}
+	for i := range records {
+		record := &records[i]
+		version := record.Metadata[MetadataAppVersion]
+		switch {
+		case version == "":
+			audience.exclude(record, version, ExcludedUnknownVersion)
+		case CompareVersions(version, minVersion) < 0:
+			audience.exclude(record, version, ExcludedOutdated)
+		default:
+			audience.Tokens = append(audience.Tokens, record.Token)
+		}
+	}
+	return audience, nil
+}
+
+func (a *VersionAudience) exclude(record *TokenRecord, version, reason string) {
+	a.Excluded = append(a.Excluded, ExcludedToken{
+		Token:   record.Token,
+		UserID:  record.UserID,
+		Version: version,
+		Reason:  reason,
+	})
+}
+
+// ExcludedVersions counts the excluded tokens by app version, with
+// tokens of unknown version under ""
+func (a *VersionAudience) ExcludedVersions() map[string]int {
+	counts := make(map[string]int)
+	for _, excluded := range a.Excluded {
+		counts[excluded.Version]++
+	}
+	return counts
+}
+
+func (a *VersionAudience) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d tokens on %s or later", len(a.Tokens), a.MinVersion)
+	if len(a.Excluded) == 0 {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "; excluded %d", len(a.Excluded))
+	counts := a.ExcludedVersions()
+	versions := make([]string, 0, len(counts))
+	for version := range counts {
+		versions = append(versions, version)
+	}
+	slices.SortFunc(versions, func(x, y string) int { return -CompareVersions(x, y) })
+	for i, version := range versions {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		name := version
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Fprintf(&b, "%s%s %d", sep, name, counts[version])
+	}
+	return b.String()
+}
+
+// CompareVersions compares dotted app versions such as "2.10.1" and "2.9",
+// returning -1, 0 or +1. Numeric parts compare as numbers, missing parts
+// count as 0 and a pre-release suffix, as in "2.0.0-beta", sorts before
+// the release.
+func CompareVersions(a, b string) int {
+	a, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	b, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(partsA), len(partsB)) {
+		x, y := "0", "0"
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if c := comparePart(x, y); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return strings.Compare(preA, preB)
+}
+
+func comparePart(x, y string) int {
+	nx, errX := strconv.ParseUint(x, 10, 64)
+	ny, errY := strconv.ParseUint(y, 10, 64)
+	if errX != nil || errY != nil {
+		return strings.Compare(x, y)
+	}
+	switch {
+	case nx < ny:
+		return -1
+	case nx > ny:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"2.10.0", "2.9", 1},
+		{"2.0", "2.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"2.0.0-beta", "2.0.0", -1},
+		{"1.9.9", "2", -1},
+	} {
+		if got := CompareVersions(c.a, c.b); got != c.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestAudienceByVersion(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	for token, version := range map[ExponentPushToken]string{
+		"ExponentPushToken[a]": "2.4.0",
+		"ExponentPushToken[b]": "2.10.1",
+		"ExponentPushToken[c]": "2.3.9",
+		"ExponentPushToken[d]": "",
+	} {
+		store.Put(ctx, &TokenRecord{Token: token, State: TokenStateActive,
+			Metadata: map[string]string{MetadataAppVersion: version}})
+	}
+	audience, err := AudienceByVersion(ctx, store, TokenFilter{State: TokenStateActive}, "2.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audience.Tokens) != 2 || audience.Tokens[0] != "ExponentPushToken[a]" || audience.Tokens[1] != "ExponentPushToken[b]" {
+		t.Errorf("Incorrect tokens %v", audience.Tokens)
+	}
+	if len(audience.Excluded) != 2 || audience.Excluded[0].Reason != ExcludedOutdated ||
+		audience.Excluded[1].Reason != ExcludedUnknownVersion {
+		t.Errorf("Incorrect exclusions %+v", audience.Excluded)
+	}
+	if s := audience.String(); s != "2 tokens on 2.4 or later; excluded 2: 2.3.9 1, unknown 1" {
+		t.Errorf("Incorrect report %q", s)
+	}
+}
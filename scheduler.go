@@ -0,0 +1,439 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LeaderLock elects a single leader among the replicas of a deployment.
+// contrib/redis provides an implementation backed by a Redis key.
+type LeaderLock interface {
+	// TryAcquire tries to become, or remain, the leader for ttl and reports
+	// whether this replica is the leader
+	TryAcquire(ctx context.Context, ttl time.Duration) (bool, error)
+	// Release gives up leadership so another replica can take over
+	Release(ctx context.Context) error
+}
+
+// ErrScheduledNotFound is returned for a scheduled message that doesn't
+// exist, or was already sent or canceled
+var ErrScheduledNotFound = newCodedError(ErrorCodeNotFound, "scheduled message not found")
+
+// ScheduledMessage is a message waiting to be sent at a given time
+type ScheduledMessage struct {
+	ID      string
+	Message PushMessage
+	At      time.Time
+}
+
+// scheduledMessageJSON is ScheduledMessage in JSON
+type scheduledMessageJSON struct {
+	ID      string      `json:"id"`
+	Message PushMessage `json:"message"`
+	localFields
+	At time.Time `json:"at"`
+}
+
+// MarshalJSON encodes the message with its fields that aren't sent to Expo,
+// e.g. CorrelationID, so a ScheduleStore keeps them
+func (m ScheduledMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(scheduledMessageJSON{m.ID, m.Message, localFieldsOf(&m.Message), m.At})
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON
+func (m *ScheduledMessage) UnmarshalJSON(data []byte) error {
+	var v scheduledMessageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = ScheduledMessage{ID: v.ID, Message: v.Message, At: v.At}
+	v.localFields.restore(&m.Message)
+	return nil
+}
+
+// ScheduleStore persists the messages of a Scheduler, so scheduled sends
+// survive restarts. contrib/sqlstore provides an implementation.
+// Implementations must be safe for concurrent use.
+type ScheduleStore interface {
+	// Save creates or replaces a scheduled message
+	Save(ctx context.Context, message ScheduledMessage) error
+	// Delete removes a message once sent or canceled. Unknown IDs are ignored.
+	Delete(ctx context.Context, id string) error
+	// Load returns every stored message
+	Load(ctx context.Context) ([]ScheduledMessage, error)
+}
+
+// Scheduler sends messages at a given time. With a Lock, only the elected
+// leader sends, so several replicas running the same schedule don't send
+// duplicates.
+type Scheduler struct {
+	Client *PushClient
+	// Lock elects the replica that sends. Without one this replica always sends.
+	Lock LeaderLock
+	// Store persists one-off messages from Schedule until they are sent or
+	// canceled. Replicas may share it: Run reloads it every Interval, so
+	// the leader sends the messages scheduled on any replica. A message is
+	// deleted once Expo answered for it or its request failed for good; the
+	// messages of a request that failed transiently, see IsTransient, or of
+	// a send a crash interrupted, are sent again. Recurrences aren't
+	// persisted.
+	Store ScheduleStore
+	// Interval is how often due messages are checked. Defaults to one second.
+	Interval time.Duration
+	// Blackout defers due non-exempt messages until the blackout ends
+	Blackout *BlackoutCalendar
+	// OnSent is called with the outcome of every message sent
+	OnSent func(scheduled ScheduledMessage, response PushResponse, err error)
+
+	mu        sync.Mutex
+	pending   map[string]*ScheduledMessage
+	recurring map[string]*recurrenceState
+	// deferred are the IDs of the occurrences of recurrences waiting in
+	// pending, which aren't in the Store
+	deferred map[string]bool
+	// inFlight are the due messages being sent
+	inFlight []ScheduledMessage
+}
+
+// Schedule queues message to be sent at the given time and returns its ID.
+// Errors saving it to the Store go to the client's ErrorReporter; use
+// ScheduleContext to handle them.
+func (s *Scheduler) Schedule(message PushMessage, at time.Time) string {
+	id, err := s.ScheduleContext(context.Background(), message, at)
+	if err != nil && s.Client.errorReporter != nil {
+		s.Client.errorReporter(err)
+	}
+	return id
+}
+
+// ScheduleIn queues message to be sent after delay and returns its ID
+func (s *Scheduler) ScheduleIn(message PushMessage, delay time.Duration) string {
+	return s.Schedule(message, time.Now().Add(delay))
+}
+
+// ScheduleContext queues message to be sent at the given time, saving it to
+// the Store first, and returns its ID. A message the Store failed to save
+// isn't scheduled.
+func (s *Scheduler) ScheduleContext(ctx context.Context, message PushMessage, at time.Time) (string, error) {
+	if message.CorrelationID == "" {
+		message.CorrelationID = s.Client.NewID()
+	}
+	scheduled := ScheduledMessage{ID: s.Client.NewID(), Message: message, At: at}
+	if s.Store != nil {
+		if err := s.Store.Save(ctx, scheduled); err != nil {
+			return "", err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string]*ScheduledMessage)
+	}
+	s.pending[scheduled.ID] = &scheduled
+	return scheduled.ID, nil
+}
+
+// Restore replaces the one-off messages pending with those of the Store,
+// e.g. those scheduled before a restart or by another replica, and returns
+// how many it loaded. Run calls it every Interval.
+func (s *Scheduler) Restore(ctx context.Context) (int, error) {
+	if s.Store == nil {
+		return 0, nil
+	}
+	stored, err := s.Store.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string]*ScheduledMessage, len(stored))
+	}
+	// Messages sent or canceled by other replicas are gone from the Store
+	for id := range s.pending {
+		if !s.deferred[id] {
+			delete(s.pending, id)
+		}
+	}
+	for i := range stored {
+		s.pending[stored[i].ID] = &stored[i]
+	}
+	return len(stored), nil
+}
+
+// CancelScheduled revokes a pending message, e.g. a reminder whose
+// triggering condition disappeared
+func (s *Scheduler) CancelScheduled(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[id]; !ok {
+		return ErrScheduledNotFound
+	}
+	delete(s.pending, id)
+	if s.deferred[id] {
+		delete(s.deferred, id)
+		return nil
+	}
+	if s.Store != nil {
+		return s.Store.Delete(context.Background(), id)
+	}
+	return nil
+}
+
+// Reschedule moves a pending message to a new time and, if message is not
+// nil, replaces its content
+func (s *Scheduler) Reschedule(id string, at time.Time, message *PushMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scheduled, ok := s.pending[id]
+	if !ok {
+		return ErrScheduledNotFound
+	}
+	scheduled.At = at
+	if message != nil {
+		correlationID := scheduled.Message.CorrelationID
+		scheduled.Message = *message
+		if scheduled.Message.CorrelationID == "" {
+			scheduled.Message.CorrelationID = correlationID
+		}
+	}
+	if s.Store != nil {
+		// A rescheduled occurrence is stored as a one-off message
+		delete(s.deferred, id)
+		return s.Store.Save(context.Background(), *scheduled)
+	}
+	return nil
+}
+
+// Len returns the number of messages waiting to be sent
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Pending returns a snapshot of the messages waiting to be sent, oldest
+// first. Occurrences of recurrences are only included once due.
+func (s *Scheduler) Pending() []ScheduledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make([]ScheduledMessage, 0, len(s.pending))
+	for _, scheduled := range s.pending {
+		pending = append(pending, cloneScheduled(*scheduled))
+	}
+	sortScheduled(pending)
+	return pending
+}
+
+// InFlight returns a snapshot of the due messages being sent
+func (s *Scheduler) InFlight() []ScheduledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inFlight := make([]ScheduledMessage, len(s.inFlight))
+	for i := range s.inFlight {
+		inFlight[i] = cloneScheduled(s.inFlight[i])
+	}
+	return inFlight
+}
+
+// NextScheduled returns the next n messages to be sent, soonest first,
+// including the next occurrence of every recurrence recipient. Blackout
+// deferrals and Recurrence.Skip are only applied once the messages are due.
+func (s *Scheduler) NextScheduled(n int) []ScheduledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next []ScheduledMessage
+	for _, scheduled := range s.pending {
+		next = append(next, *scheduled)
+	}
+	for id, state := range s.recurring {
+		for i, anchor := range state.Recipients {
+			if state.next[i].IsZero() {
+				continue
+			}
+			message := state.Message
+			message.To = []ExponentPushToken{anchor.Token}
+			next = append(next, ScheduledMessage{ID: id, Message: message, At: state.next[i]})
+		}
+	}
+	sortScheduled(next)
+	next = next[:min(n, len(next))]
+	for i := range next {
+		next[i] = cloneScheduled(next[i])
+	}
+	return next
+}
+
+func sortScheduled(messages []ScheduledMessage) {
+	sort.Slice(messages, func(i, j int) bool { return messages[i].At.Before(messages[j].At) })
+}
+
+// cloneScheduled copies the slices and maps of a scheduled message, so
+// snapshots don't share them with the scheduler
+func cloneScheduled(scheduled ScheduledMessage) ScheduledMessage {
+	scheduled.Message.To = slices.Clone(scheduled.Message.To)
+	scheduled.Message.Data = maps.Clone(scheduled.Message.Data)
+	return scheduled
+}
+
+// Run sends due messages until ctx is done. It releases the leader lock,
+// if any, before returning. Panics, e.g. in OnSent, are reported to the
+// client's ErrorReporter and the scheduler restarts, putting back the due
+// messages it hadn't sent yet.
+func (s *Scheduler) Run(ctx context.Context) error {
+	return supervise(ctx, "scheduler", s.Client.errorReporter, s.run)
+}
+
+func (s *Scheduler) run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if s.Lock != nil {
+		defer s.Lock.Release(context.WithoutCancel(ctx))
+	}
+	s.requeueInFlight()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.tick(ctx, interval); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, interval time.Duration) error {
+	// Skip the tick rather than send from a stale view of the Store
+	if _, err := s.Restore(ctx); err != nil {
+		if s.Client.errorReporter != nil && ctx.Err() == nil {
+			s.Client.errorReporter(err)
+		}
+		return ctx.Err()
+	}
+	if s.Lock != nil {
+		// Leadership outlives a few missed ticks before another replica takes over
+		leader, err := s.Lock.TryAcquire(ctx, 3*interval)
+		if err != nil || !leader {
+			return nil
+		}
+	}
+	due := s.due(time.Now())
+	// The messages in flight aren't cleared if OnSent panics, so run puts
+	// the unsent ones back when the scheduler restarts
+	for start := 0; start < len(due); start += MaxMessagesPerRequest {
+		s.setInFlight(due[start:])
+		end := min(start+MaxMessagesPerRequest, len(due))
+		batch := due[start:end]
+		messages := make([]PushMessage, len(batch))
+		for i := range batch {
+			messages[i] = batch[i].Message
+		}
+		responses, err := s.Client.PublishMultipleContext(ctx, messages)
+		// Messages of a request that failed for good, e.g. with an invalid
+		// token, would fail again on every tick
+		retry := err != nil && (IsTransient(err) || ctx.Err() != nil)
+		if err != nil && !retry && s.Client.errorReporter != nil {
+			s.Client.errorReporter(err)
+		}
+		if s.Store != nil {
+			for i := range batch {
+				// Messages without a response stay stored and are retried
+				// if the failure is transient
+				if retry && (i >= len(responses) || responses[i].Status == "") {
+					continue
+				}
+				if err := s.Store.Delete(ctx, batch[i].ID); err != nil && s.Client.errorReporter != nil {
+					s.Client.errorReporter(err)
+				}
+			}
+		}
+		s.setInFlight(due[end:])
+		if s.OnSent == nil {
+			continue
+		}
+		for i := range batch {
+			var response PushResponse
+			if err == nil {
+				response = responses[i]
+			}
+			s.OnSent(batch[i], response, err)
+		}
+	}
+	s.setInFlight(nil)
+	return ctx.Err()
+}
+
+func (s *Scheduler) setInFlight(messages []ScheduledMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight = messages
+}
+
+// requeueInFlight puts back the messages a panicking tick took but didn't
+// send
+func (s *Scheduler) requeueInFlight() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.inFlight) > 0 && s.pending == nil {
+		s.pending = make(map[string]*ScheduledMessage, len(s.inFlight))
+	}
+	for i := range s.inFlight {
+		scheduled := s.inFlight[i]
+		id := scheduled.ID
+		// Occurrences carry the ID of their recurrence
+		if _, ok := s.recurring[id]; ok {
+			id = s.Client.NewID()
+			if s.deferred == nil {
+				s.deferred = make(map[string]bool)
+			}
+			s.deferred[id] = true
+		}
+		s.pending[id] = &scheduled
+	}
+	s.inFlight = nil
+}
+
+// due removes and returns the messages due at now, oldest first
+func (s *Scheduler) due(now time.Time) []ScheduledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, occurrence := range s.occurrences(now) {
+		// Deferred occurrences wait like one-off messages
+		if s.pending == nil {
+			s.pending = make(map[string]*ScheduledMessage)
+		}
+		id := s.Client.NewID()
+		s.pending[id] = &occurrence
+		if s.deferred == nil {
+			s.deferred = make(map[string]bool)
+		}
+		s.deferred[id] = true
+	}
+	var due []ScheduledMessage
+	for id, scheduled := range s.pending {
+		if scheduled.At.After(now) {
+			continue
+		}
+		if until := s.Blackout.DeferUntil(now, scheduled.Message.Priority); !until.IsZero() {
+			scheduled.At = until
+			continue
+		}
+		due = append(due, *scheduled)
+		delete(s.pending, id)
+		delete(s.deferred, id)
+	}
+	sortScheduled(due)
+	return due
+}
@@ -0,0 +1,303 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type staticLock bool
+
+func (l staticLock) TryAcquire(context.Context, time.Duration) (bool, error) { return bool(l), nil }
+func (l staticLock) Release(context.Context) error                           { return nil }
+
+func TestSchedulerSendsDueMessages(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	var sent []string
+	scheduler := &Scheduler{
+		Client: NewPushClient(&ClientConfig{Host: srv.URL}),
+		OnSent: func(s ScheduledMessage, _ PushResponse, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			sent = append(sent, s.ID)
+		},
+	}
+	due := scheduler.Schedule(testMessages(1)[0], time.Now().Add(-time.Second))
+	scheduler.Schedule(testMessages(1)[0], time.Now().Add(time.Hour))
+	if err := scheduler.tick(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0] != due || calls.Load() != 1 {
+		t.Errorf("Expected only the due message to be sent, got %v", sent)
+	}
+}
+
+func TestSchedulerFollowerDoesNotSend(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	scheduler := &Scheduler{
+		Client: NewPushClient(&ClientConfig{Host: srv.URL}),
+		Lock:   staticLock(false),
+	}
+	scheduler.Schedule(testMessages(1)[0], time.Now().Add(-time.Second))
+	if err := scheduler.tick(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 0 {
+		t.Error("Follower replica sent a scheduled message")
+	}
+}
+
+func TestSchedulerCancelAndReschedule(t *testing.T) {
+	var sent []PushMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		sent = append(sent, messages...)
+		return okTickets(messages)
+	})
+	scheduler := &Scheduler{Client: NewPushClient(&ClientConfig{Host: srv.URL})}
+	past := time.Now().Add(-time.Second)
+	canceled := scheduler.Schedule(testMessages(1)[0], past)
+	amended := scheduler.Schedule(testMessages(1)[0], time.Now().Add(time.Hour))
+	if err := scheduler.CancelScheduled(canceled); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.CancelScheduled(canceled); err != ErrScheduledNotFound {
+		t.Errorf("Expected ErrScheduledNotFound, got %v", err)
+	}
+	message := testMessages(1)[0]
+	message.Body = "amended"
+	if err := scheduler.Reschedule(amended, past, &message); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.tick(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].Body != "amended" {
+		t.Errorf("Expected only the amended message to be sent, got %+v", sent)
+	}
+}
+
+func TestSchedulerIntrospection(t *testing.T) {
+	var scheduler *Scheduler
+	var inFlight []ScheduledMessage
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		inFlight = scheduler.InFlight()
+		return okTickets(messages)
+	})
+	scheduler = &Scheduler{Client: NewPushClient(&ClientConfig{Host: srv.URL})}
+	now := time.Now()
+	soon := scheduler.Schedule(testMessages(1)[0], now.Add(time.Minute))
+	later := scheduler.Schedule(testMessages(1)[0], now.Add(time.Hour))
+	due := scheduler.Schedule(testMessages(1)[0], now.Add(-time.Second))
+	recurring, err := scheduler.ScheduleRecurring(Recurrence{
+		Rule:       RecurrenceRule{Frequency: Daily, Hour: now.UTC().Add(30 * time.Minute).Hour(), Minute: now.UTC().Add(30 * time.Minute).Minute()},
+		Message:    PushMessage{Body: "digest"},
+		Recipients: []RecurrenceAnchor{{Token: "ExponentPushToken[r]", Start: now}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := scheduler.NextScheduled(3)
+	if len(next) != 3 || next[0].ID != due || next[1].ID != soon || next[2].ID != recurring {
+		t.Errorf("Expected the due, soon and recurring messages first, got %+v", next)
+	}
+	next[0].Message.To[0] = "changed"
+	if pending := scheduler.Pending(); len(pending) != 3 || pending[2].ID != later || pending[0].Message.To[0] == "changed" {
+		t.Errorf("Expected a snapshot of the 3 one-off messages, got %+v", pending)
+	}
+
+	if err := scheduler.tick(context.Background(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(inFlight) != 1 || inFlight[0].ID != due {
+		t.Errorf("Expected the due message in flight while sent, got %+v", inFlight)
+	}
+	if len(scheduler.InFlight()) != 0 || len(scheduler.Pending()) != 2 {
+		t.Errorf("Expected nothing in flight after the tick")
+	}
+}
+
+// memoryScheduleStore is a ScheduleStore keeping the JSON of the messages,
+// like a persistent store would
+type memoryScheduleStore struct {
+	mu       sync.Mutex
+	messages map[string][]byte
+}
+
+func (s *memoryScheduleStore) Save(_ context.Context, message ScheduledMessage) error {
+	data, err := json.Marshal(message)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[message.ID] = data
+	return err
+}
+
+func (s *memoryScheduleStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, id)
+	return nil
+}
+
+func (s *memoryScheduleStore) Load(context.Context) ([]ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []ScheduledMessage
+	for _, data := range s.messages {
+		var m ScheduledMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func TestSchedulerStoreSurvivesRestart(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	client := New(WithHost(srv.URL))
+	store := &memoryScheduleStore{messages: make(map[string][]byte)}
+	ctx := context.Background()
+	first := &Scheduler{Client: client, Store: store}
+	message := testMessages(1)[0]
+	message.CorrelationID = "corr"
+	due, err := first.ScheduleContext(ctx, message, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled := first.ScheduleIn(message, time.Hour)
+	later := first.ScheduleIn(message, time.Hour)
+	if err := first.CancelScheduled(canceled); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new process restores the messages still pending
+	var sent []ScheduledMessage
+	second := &Scheduler{Client: client, Store: store, OnSent: func(s ScheduledMessage, _ PushResponse, _ error) {
+		sent = append(sent, s)
+	}}
+	if n, err := second.Restore(ctx); n != 2 || err != nil {
+		t.Fatalf("Expected 2 messages restored, got %d, %v", n, err)
+	}
+	if err := second.tick(ctx, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].ID != due || sent[0].Message.CorrelationID != "corr" || calls.Load() != 1 {
+		t.Errorf("Expected the due message to be sent, got %+v", sent)
+	}
+	if stored, _ := store.Load(ctx); len(stored) != 1 || stored[0].ID != later {
+		t.Errorf("Expected only the later message to stay stored, got %+v", stored)
+	}
+}
+
+func TestSchedulerStoreSharedByReplicas(t *testing.T) {
+	fail := false
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		if fail {
+			return Response{Errors: []APIError{{Code: "INTERNAL_SERVER_ERROR", Message: "down", IsTransient: true}}}
+		}
+		return okTickets(messages)
+	})
+	client := New(WithHost(srv.URL), WithRetryPolicy(RetryPolicy{}))
+	store := &memoryScheduleStore{messages: make(map[string][]byte)}
+	ctx := context.Background()
+	leader := &Scheduler{Client: client, Store: store, Lock: staticLock(true)}
+	follower := &Scheduler{Client: client, Store: store, Lock: staticLock(false)}
+
+	// The leader sends what the follower scheduled
+	if _, err := follower.ScheduleContext(ctx, testMessages(1)[0], time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	leader.tick(ctx, time.Second)
+	if stored, _ := store.Load(ctx); len(stored) != 1 || calls.Load() == 0 {
+		t.Fatalf("Expected the message kept after a failed request, got %d stored", len(stored))
+	}
+	fail = false
+	leader.tick(ctx, time.Second)
+	if stored, _ := store.Load(ctx); len(stored) != 0 {
+		t.Fatalf("Expected the message deleted once sent, got %d stored", len(stored))
+	}
+
+	// Once leader, the follower doesn't send it again
+	sent := calls.Load()
+	follower.Lock = staticLock(true)
+	follower.tick(ctx, time.Second)
+	if calls.Load() != sent || follower.Len() != 0 {
+		t.Errorf("Expected the message sent once, got %d more requests", calls.Load()-sent)
+	}
+}
+
+func TestSchedulerDropsPermanentFailures(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	client := New(WithHost(srv.URL), WithRetryPolicy(RetryPolicy{}))
+	store := &memoryScheduleStore{messages: make(map[string][]byte)}
+	ctx := context.Background()
+	var failed []error
+	scheduler := &Scheduler{Client: client, Store: store, OnSent: func(_ ScheduledMessage, _ PushResponse, err error) {
+		failed = append(failed, err)
+	}}
+	messages := testMessages(2)
+	messages[1].To = []ExponentPushToken{""}
+	for _, message := range messages {
+		if _, err := scheduler.ScheduleContext(ctx, message, time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scheduler.tick(ctx, time.Second)
+	if stored, _ := store.Load(ctx); len(stored) != 0 {
+		t.Errorf("Expected the batch deleted after a permanent failure, got %d stored", len(stored))
+	}
+	if len(failed) != 2 || failed[0] == nil {
+		t.Errorf("Expected the failure reported for both messages, got %v", failed)
+	}
+	scheduler.tick(ctx, time.Second)
+	if len(failed) != 2 || calls.Load() != 0 {
+		t.Errorf("Expected the batch not sent again, got %d more outcomes, %d requests", len(failed)-2, calls.Load())
+	}
+}
+
+func TestSchedulerRequeuesAfterPanic(t *testing.T) {
+	var received atomic.Int64
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		received.Add(int64(len(messages)))
+		return okTickets(messages)
+	})
+	var panicked atomic.Bool
+	var sent atomic.Int64
+	scheduler := &Scheduler{
+		Client:   NewPushClient(&ClientConfig{Host: srv.URL}),
+		Interval: 10 * time.Millisecond,
+		OnSent: func(ScheduledMessage, PushResponse, error) {
+			if panicked.CompareAndSwap(false, true) {
+				panic("OnSent failed")
+			}
+			sent.Add(1)
+		},
+	}
+	n := MaxMessagesPerRequest + 1
+	for _, message := range testMessages(n) {
+		scheduler.Schedule(message, time.Now().Add(-time.Second))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go scheduler.Run(ctx)
+	for sent.Load() < 1 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Leave time for duplicates to show up
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if received.Load() != int64(n) || calls.Load() != 2 {
+		t.Errorf("Expected the unsent batch to be sent after the panic, got %d messages in %d requests", received.Load(), calls.Load())
+	}
+	if scheduler.Len() != 0 || len(scheduler.InFlight()) != 0 {
+		t.Error("Expected nothing left pending or in flight")
+	}
+}
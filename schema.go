@@ -0,0 +1,250 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// DataSchema is the contract of the data of a notification category: the
+// keys the app's handler reads and the values it accepts. It is the subset
+// of JSON Schema that applies to the string values of PushMessage.Data,
+// parsed by ParseDataSchema:
+//
+//	{
+//		"properties": {
+//			"orderId": {"type": "string", "pattern": "^[0-9a-f]{24}$"},
+//			"status": {"enum": ["shipped", "delivered"]},
+//			"eta": {"type": "integer", "minimum": 0}
+//		},
+//		"required": ["orderId", "status"],
+//		"additionalProperties": false
+//	}
+type DataSchema struct {
+	Properties map[string]*PropertySchema `json:"properties"`
+	Required   []string                   `json:"required"`
+	// AdditionalProperties false rejects keys missing from Properties,
+	// except DataKeyCategory
+	AdditionalProperties *bool `json:"additionalProperties"`
+}
+
+// PropertySchema constrains the value of a data key
+type PropertySchema struct {
+	// Type is "string", the default, or "integer", "number" or "boolean",
+	// which the value must parse as
+	Type      string   `json:"type"`
+	Enum      []any    `json:"enum"`
+	Pattern   string   `json:"pattern"`
+	MinLength *int     `json:"minLength"`
+	MaxLength *int     `json:"maxLength"`
+	Minimum   *float64 `json:"minimum"`
+	Maximum   *float64 `json:"maximum"`
+
+	pattern *regexp.Regexp
+}
+
+// ParseDataSchema parses a JSON Schema document
+func ParseDataSchema(data []byte) (*DataSchema, error) {
+	var s DataSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("data schema: %w", err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *DataSchema) compile() error {
+	for key, property := range s.Properties {
+		switch property.Type {
+		case "", "string", "integer", "number", "boolean":
+		default:
+			return fmt.Errorf("data schema: %s: unsupported type %q", key, property.Type)
+		}
+		if property.Pattern != "" && property.pattern == nil {
+			re, err := regexp.Compile(property.Pattern)
+			if err != nil {
+				return fmt.Errorf("data schema: %s: %w", key, err)
+			}
+			property.pattern = re
+		}
+	}
+	return nil
+}
+
+// Validate returns the reasons data breaks the schema, by key
+func (s *DataSchema) Validate(data map[string]string) map[string]string {
+	violations := make(map[string]string)
+	for _, key := range s.Required {
+		if _, ok := data[key]; !ok {
+			violations[key] = "required"
+		}
+	}
+	for key, value := range data {
+		property, ok := s.Properties[key]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties && key != DataKeyCategory {
+				violations[key] = "not allowed"
+			}
+			continue
+		}
+		if reason := property.check(value); reason != "" {
+			violations[key] = reason
+		}
+	}
+	return violations
+}
+
+// check returns why value breaks the property, or ""
+func (p *PropertySchema) check(value string) string {
+	var number float64
+	var err error
+	switch p.Type {
+	case "integer":
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		number = float64(n)
+	case "number":
+		number, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return "not a valid " + p.Type
+	}
+	if len(p.Enum) > 0 && !slices.ContainsFunc(p.Enum, func(v any) bool { return fmt.Sprint(v) == value }) {
+		return fmt.Sprintf("not one of %v", p.Enum)
+	}
+	if p.pattern != nil && !p.pattern.MatchString(value) {
+		return "does not match " + p.Pattern
+	}
+	length := utf8.RuneCountInString(value)
+	if p.MinLength != nil && length < *p.MinLength {
+		return fmt.Sprintf("shorter than %d", *p.MinLength)
+	}
+	if p.MaxLength != nil && length > *p.MaxLength {
+		return fmt.Sprintf("longer than %d", *p.MaxLength)
+	}
+	numeric := p.Type == "integer" || p.Type == "number"
+	if numeric && p.Minimum != nil && number < *p.Minimum {
+		return fmt.Sprintf("less than %v", *p.Minimum)
+	}
+	if numeric && p.Maximum != nil && number > *p.Maximum {
+		return fmt.Sprintf("greater than %v", *p.Maximum)
+	}
+	return ""
+}
+
+// SchemaRegistry holds the data schemas of notification categories, see
+// SetCategory. Set it as ClientConfig.Schemas to validate messages before
+// they are sent. It is safe for concurrent use.
+type SchemaRegistry struct {
+	// RequireSchema rejects messages whose category has no schema.
+	// Messages without a category are never validated.
+	RequireSchema bool
+
+	mu      sync.RWMutex
+	schemas map[string]*DataSchema
+}
+
+// NewSchemaRegistry creates an empty registry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: make(map[string]*DataSchema)}
+}
+
+// Register sets the schema of category, replacing any previous one
+func (r *SchemaRegistry) Register(category string, schema *DataSchema) error {
+	if err := schema.compile(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.schemas == nil {
+		r.schemas = make(map[string]*DataSchema)
+	}
+	r.schemas[category] = schema
+	return nil
+}
+
+// RegisterJSON parses a JSON Schema document and registers it for category
+func (r *SchemaRegistry) RegisterJSON(category string, schema []byte) error {
+	s, err := ParseDataSchema(schema)
+	if err != nil {
+		return err
+	}
+	return r.Register(category, s)
+}
+
+// Schema returns the schema of category, or nil
+func (r *SchemaRegistry) Schema(category string) *DataSchema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemas[category]
+}
+
+// validate appends the violations of message, at index i, to violations
+func (r *SchemaRegistry) validate(i int, message *PushMessage, violations []DataViolation) []DataViolation {
+	category := message.Category()
+	if category == "" {
+		return violations
+	}
+	schema := r.Schema(category)
+	if schema == nil {
+		if r.RequireSchema {
+			violations = append(violations, DataViolation{Message: i, Category: category, Reason: "no schema"})
+		}
+		return violations
+	}
+	found := schema.Validate(message.Data)
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		violations = append(violations, DataViolation{Message: i, Category: category, Key: key, Reason: found[key]})
+	}
+	return violations
+}
+
+// DataViolation is a data key of a message that breaks the schema of its
+// category
+type DataViolation struct {
+	// Message is the index of the message in the publish call
+	Message  int
+	Category string
+	// Key is empty when the category has no schema
+	Key    string
+	Reason string
+}
+
+// InvalidDataError is returned by Publish and PublishMultiple, before
+// anything is sent, when the data of messages breaks the schema of their
+// category, see ClientConfig.Schemas
+type InvalidDataError struct {
+	Violations []DataViolation
+}
+
+func (e *InvalidDataError) Error() string {
+	first := e.Violations[0]
+	message := fmt.Sprintf("invalid %s data (message %d)", first.Category, first.Message)
+	if first.Key != "" {
+		message += fmt.Sprintf(": %s: %s", first.Key, first.Reason)
+	} else {
+		message += ": " + first.Reason
+	}
+	if len(e.Violations) > 1 {
+		message += fmt.Sprintf(" and %d more", len(e.Violations)-1)
+	}
+	return message
+}
+
+// ErrorCode returns ErrorCodeInvalidData
+func (e *InvalidDataError) ErrorCode() ErrorCode {
+	return ErrorCodeInvalidData
+}
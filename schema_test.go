@@ -0,0 +1,78 @@
+package expo
+
+import (
+	"errors"
+	"testing"
+)
+
+const orderSchema = `{
+	"properties": {
+		"orderId": {"type": "string", "pattern": "^[0-9]+$"},
+		"status": {"enum": ["shipped", "delivered"]},
+		"eta": {"type": "integer", "minimum": 0}
+	},
+	"required": ["orderId", "status"],
+	"additionalProperties": false
+}`
+
+func TestDataSchemaValidate(t *testing.T) {
+	schema, err := ParseDataSchema([]byte(orderSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := schema.Validate(map[string]string{
+		DataKeyCategory: "order", "orderId": "x1", "eta": "-5", "extra": "1",
+	})
+	want := map[string]string{
+		"orderId": "does not match ^[0-9]+$",
+		"status":  "required",
+		"eta":     "less than 0",
+		"extra":   "not allowed",
+	}
+	if len(violations) != len(want) {
+		t.Errorf("Incorrect violations %v", violations)
+	}
+	for key, reason := range want {
+		if violations[key] != reason {
+			t.Errorf("%s: got %q, want %q", key, violations[key], reason)
+		}
+	}
+	if v := schema.Validate(map[string]string{"orderId": "42", "status": "shipped", "eta": "3"}); len(v) != 0 {
+		t.Errorf("Valid data rejected: %v", v)
+	}
+	if _, err := ParseDataSchema([]byte(`{"properties": {"a": {"type": "array"}}}`)); err == nil {
+		t.Error("Expected an error for an unsupported type")
+	}
+}
+
+func TestPublishValidatesDataSchema(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	schemas := NewSchemaRegistry()
+	if err := schemas.RegisterJSON("order", []byte(orderSchema)); err != nil {
+		t.Fatal(err)
+	}
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Schemas: schemas})
+	messages := testMessages(2)
+	messages[1].SetCategory("order")
+	messages[1].Data["orderId"] = "42"
+	_, err := client.PublishMultiple(messages)
+	var invalid *InvalidDataError
+	if !errors.As(err, &invalid) || len(invalid.Violations) != 1 || invalid.Violations[0].Message != 1 ||
+		invalid.Violations[0].Key != "status" {
+		t.Fatalf("Expected a status violation, got %v", err)
+	}
+	if ErrorCodeOf(err) != ErrorCodeInvalidData || calls.Load() != 0 {
+		t.Errorf("Incorrect code %q or %d calls", ErrorCodeOf(err), calls.Load())
+	}
+
+	messages[1].Data["status"] = "shipped"
+	if _, err := client.PublishMultiple(messages); err != nil {
+		t.Errorf("Valid messages rejected: %v", err)
+	}
+
+	schemas.RequireSchema = true
+	messages[0].SetCategory("marketing")
+	if _, err := client.PublishMultiple(messages); !errors.As(err, &invalid) || invalid.Violations[0].Reason != "no schema" {
+		t.Errorf("Expected a missing schema, got %v", err)
+	}
+}
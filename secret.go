@@ -0,0 +1,126 @@
+package expo
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSecretTTL is how long ClientConfig.AccessTokenSource values are
+// cached before they are fetched again
+const DefaultSecretTTL = 5 * time.Minute
+
+// ErrSecretNotFound is returned when a secret source has no value
+var ErrSecretNotFound = newCodedError(ErrorCodeNotFound, "secret not found")
+
+// SecretSource provides a secret such as the access token, so it can be
+// kept out of process arguments and plain environment dumps. contrib/secrets
+// provides sources for AWS Secrets Manager, GCP Secret Manager and Vault.
+type SecretSource interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// SecretFunc adapts a function to the SecretSource interface
+type SecretFunc func(ctx context.Context) (string, error)
+
+// Secret calls f
+func (f SecretFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// EnvSecret reads a secret from an environment variable
+func EnvSecret(name string) SecretSource {
+	return SecretFunc(func(context.Context) (string, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", ErrSecretNotFound
+		}
+		return value, nil
+	})
+}
+
+// FileSecret reads a secret from a file, e.g. a mounted Kubernetes secret.
+// The file is read again on every call, so rotations are picked up.
+func FileSecret(path string) SecretSource {
+	return SecretFunc(func(context.Context) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return "", ErrSecretNotFound
+		}
+		return value, nil
+	})
+}
+
+// secretRetryDelay is how long a CachedSecret keeps its previous value
+// after a failed refresh before trying again
+const secretRetryDelay = 10 * time.Second
+
+// CachedSecret caches the value of a source for TTL. When a refresh fails,
+// the previous value keeps being used, and the refresh is retried every
+// few seconds until it succeeds. Reading a fresh value takes no lock.
+type CachedSecret struct {
+	Source SecretSource
+	// TTL defaults to DefaultSecretTTL
+	TTL time.Duration
+
+	// mu serializes refreshes
+	mu     sync.Mutex
+	cached atomic.Pointer[cachedSecret]
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// NewCachedSecret caches the values of source for ttl
+func NewCachedSecret(source SecretSource, ttl time.Duration) *CachedSecret {
+	return &CachedSecret{Source: source, TTL: ttl}
+}
+
+// Secret returns the cached value, refreshing it once expired
+func (s *CachedSecret) Secret(ctx context.Context) (string, error) {
+	if cached := s.cached.Load(); cached != nil && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another caller may have refreshed it while we waited
+	now := time.Now()
+	cached := s.cached.Load()
+	if cached != nil && now.Before(cached.expires) {
+		return cached.value, nil
+	}
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultSecretTTL
+	}
+	value, err := s.Source.Secret(ctx)
+	if err != nil {
+		if cached != nil {
+			// Don't call a failing source on every request
+			s.cached.Store(&cachedSecret{value: cached.value, expires: now.Add(min(ttl, secretRetryDelay))})
+			return cached.value, nil
+		}
+		return "", err
+	}
+	s.cached.Store(&cachedSecret{value: value, expires: now.Add(ttl)})
+	return value, nil
+}
+
+// Invalidate makes the next call fetch the secret again, e.g. after the
+// value was rejected
+func (s *CachedSecret) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached := s.cached.Load(); cached != nil {
+		s.cached.Store(&cachedSecret{value: cached.value})
+	}
+}
@@ -0,0 +1,68 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccessTokenSourceRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("old\n"), 0o600)
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+	client := NewPushClient(&ClientConfig{Host: srv.URL, AccessTokenSource: FileSecret(path)})
+
+	var authErr *AuthError
+	if _, err := client.Publish(&testMessages(1)[0]); !errors.As(err, &authErr) {
+		t.Fatalf("Expected an AuthError, got %v", err)
+	}
+	// After rotation the rejected token is not reused from the cache
+	os.WriteFile(path, []byte("new\n"), 0o600)
+	if _, err := client.Publish(&testMessages(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer old" || seen[1] != "Bearer new" {
+		t.Errorf("Unexpected authorization headers %q", seen)
+	}
+}
+
+func TestCachedSecretKeepsValueOnError(t *testing.T) {
+	fail := false
+	calls := 0
+	secret := NewCachedSecret(SecretFunc(func(context.Context) (string, error) {
+		calls++
+		if fail {
+			return "", errors.New("vault down")
+		}
+		return "token", nil
+	}), 0)
+	if v, err := secret.Secret(context.Background()); err != nil || v != "token" {
+		t.Fatal(v, err)
+	}
+	fail = true
+	secret.Invalidate()
+	for i := 0; i < 3; i++ {
+		if v, err := secret.Secret(context.Background()); err != nil || v != "token" {
+			t.Errorf("Expected the previous value, got %q, %v", v, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the failed refresh to back off, got %d calls", calls)
+	}
+}
@@ -0,0 +1,168 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSendWindow is returned by ParseSendWindow for a malformed spec
+var ErrInvalidSendWindow = newCodedError(ErrorCodeInvalidArgument, "invalid send window")
+
+// SendWindow is a recurring daily period during which a job may send, e.g.
+// 9:00 to 20:00 on weekdays in the service's time zone. A campaign with a
+// window spreads its chunks evenly over what is left of the open window
+// instead of bursting when it opens, and waits for the next opening when
+// it closes.
+type SendWindow struct {
+	// Start and End are times of day, as offsets from midnight. A window
+	// ending before it starts spans midnight.
+	Start time.Duration
+	End   time.Duration
+	// Weekdays are the days the window opens on. Defaults to every day.
+	Weekdays []time.Weekday
+	// Location defaults to UTC
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseSendWindow parses a window in the form of a crontab-like spec: a
+// range of times of day, optionally followed by days as a range or a
+// list, e.g. "09:00-20:00", "09:00-20:00 mon-fri" or "22:00-06:00 sat,sun".
+func ParseSendWindow(spec string, loc *time.Location) (*SendWindow, error) {
+	invalid := fmt.Errorf("%w %q", ErrInvalidSendWindow, spec)
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, invalid
+	}
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return nil, invalid
+	}
+	w := &SendWindow{Location: loc}
+	var err1, err2 error
+	w.Start, err1 = parseTimeOfDay(from)
+	w.End, err2 = parseTimeOfDay(to)
+	if err1 != nil || err2 != nil || w.Start == w.End {
+		return nil, invalid
+	}
+	if len(fields) == 1 {
+		return w, nil
+	}
+	for _, part := range strings.Split(strings.ToLower(fields[1]), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		d1, ok1 := weekdays[first]
+		d2, ok2 := weekdays[last]
+		if !ok1 || isRange && !ok2 {
+			return nil, invalid
+		}
+		if !isRange {
+			d2 = d1
+		}
+		// Ranges may wrap around the week, e.g. fri-mon
+		for d := d1; ; d = (d + 1) % 7 {
+			if !slices.Contains(w.Weekdays, d) {
+				w.Weekdays = append(w.Weekdays, d)
+			}
+			if d == d2 {
+				break
+			}
+		}
+	}
+	return w, nil
+}
+
+// parseTimeOfDay parses "15:04" into an offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Next returns the opening holding t, or the next one if the window is
+// closed at t. It returns zero times if the window never opens.
+func (w *SendWindow) Next(t time.Time) (start, end time.Time) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	// The opening of the day before may span midnight into today
+	for d := -1; d <= 7; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+		if len(w.Weekdays) > 0 && !slices.Contains(w.Weekdays, day.Weekday()) {
+			continue
+		}
+		start = atTimeOfDay(day, w.Start)
+		end = atTimeOfDay(day, w.End)
+		if w.End <= w.Start {
+			end = atTimeOfDay(day.AddDate(0, 0, 1), w.End)
+		}
+		if t.Before(end) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// atTimeOfDay returns the wall clock time offset from midnight on day, so
+// a window keeps its hours across daylight saving changes
+func atTimeOfDay(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(offset/time.Minute), 0, 0, day.Location())
+}
+
+// Open reports whether the window is open at t
+func (w *SendWindow) Open(t time.Time) bool {
+	start, end := w.Next(t)
+	return !t.Before(start) && t.Before(end)
+}
+
+// NextSend returns when the next of remaining chunks of a job should be
+// sent, given the time the previous one was, so the chunks are spread
+// evenly over what is left of the opening. The first chunk of an opening
+// is sent when it opens. It returns now if the window never opens.
+func (w *SendWindow) NextSend(now, last time.Time, remaining int) time.Time {
+	start, end := w.Next(now)
+	if start.IsZero() {
+		return now
+	}
+	if now.Before(start) || last.Before(start) {
+		return maxTime(now, start)
+	}
+	// The remaining chunks and the end of the window share what is left
+	// equally, so the last chunk isn't sent as it closes
+	next := last.Add(end.Sub(last) / time.Duration(max(remaining, 1)+1))
+	return maxTime(now, next)
+}
+
+// awaitWindow waits until the next of remaining chunks may be sent, see
+// SendWindow.NextSend. It returns ErrCampaignPaused if ctx is done first.
+func (c *Campaign) awaitWindow(ctx context.Context, last time.Time, remaining int) error {
+	if c.Window == nil {
+		return nil
+	}
+	now := time.Now()
+	if next := c.Window.NextSend(now, last, remaining); next.After(now) {
+		if err := sleepUntil(ctx, next); err != nil {
+			return ErrCampaignPaused
+		}
+	}
+	return nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
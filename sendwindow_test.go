@@ -0,0 +1,89 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSendWindow(t *testing.T) {
+	w, err := ParseSendWindow("22:00-06:30 fri-mon,wed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday, time.Wednesday}
+	if w.Start != 22*time.Hour || w.End != 6*time.Hour+30*time.Minute || len(w.Weekdays) != len(want) {
+		t.Fatalf("Unexpected window %+v", w)
+	}
+	for i := range want {
+		if w.Weekdays[i] != want[i] {
+			t.Errorf("Unexpected weekdays %v, want %v", w.Weekdays, want)
+			break
+		}
+	}
+	for _, spec := range []string{"", "09:00", "09:00-09:00", "9am-5pm", "09:00-17:00 someday", "09:00-17:00 mon fri"} {
+		if _, err := ParseSendWindow(spec, nil); !errors.Is(err, ErrInvalidSendWindow) {
+			t.Errorf("Expected ErrInvalidSendWindow for %q, got %v", spec, err)
+		}
+	}
+}
+
+func TestSendWindowNext(t *testing.T) {
+	w, _ := ParseSendWindow("22:00-06:00 mon-fri", time.UTC)
+	// Saturday 2:00 is still in Friday's opening
+	sat := time.Date(2024, 6, 8, 2, 0, 0, 0, time.UTC)
+	start, end := w.Next(sat)
+	if !start.Equal(time.Date(2024, 6, 7, 22, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 6, 8, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected opening %v to %v", start, end)
+	}
+	if !w.Open(sat) {
+		t.Error("Expected the window to be open")
+	}
+	// Saturday noon waits for Monday night
+	start, _ = w.Next(sat.Add(10 * time.Hour))
+	if !start.Equal(time.Date(2024, 6, 10, 22, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected next opening %v", start)
+	}
+}
+
+func TestSendWindowNextSend(t *testing.T) {
+	w, _ := ParseSendWindow("09:00-19:00", time.UTC)
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	// Nothing goes before the window opens, and the first chunk goes then
+	if next := w.NextSend(at(7, 0), time.Time{}, 5); !next.Equal(at(9, 0)) {
+		t.Errorf("Expected the opening, got %v", next)
+	}
+	// The other chunks are spread over the rest of the window
+	last := at(9, 0)
+	for _, want := range []time.Time{at(11, 0), at(13, 0), at(15, 0), at(17, 0)} {
+		remaining := 5 - (last.Hour()-9)/2 - 1
+		next := w.NextSend(last, last, remaining)
+		if !next.Equal(want) {
+			t.Fatalf("Expected %v, got %v", want, next)
+		}
+		last = next
+	}
+	// A chunk due after closing waits for the next day
+	if next := w.NextSend(at(19, 30), at(18, 0), 3); !next.Equal(at(33, 0)) {
+		t.Errorf("Expected the next opening, got %v", next)
+	}
+}
+
+func TestCampaignWindowPause(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	// A window opening on another day of the week only
+	w := &SendWindow{Start: 0, End: time.Minute, Weekdays: []time.Weekday{(time.Now().UTC().Weekday() + 3) % 7}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	campaign := &Campaign{ID: "night", Messages: testMessages(3), Window: w, Client: NewPushClient(&ClientConfig{Host: srv.URL})}
+	if err := campaign.Run(ctx); !errors.Is(err, ErrCampaignPaused) || calls.Load() != 0 {
+		t.Errorf("Expected the campaign to wait for the window, got %v after %d requests", err, calls.Load())
+	}
+}
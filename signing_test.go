@@ -0,0 +1,32 @@
+package expo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSigningTransport(t *testing.T) {
+	key := []byte("relay-secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifySignature(r, key, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	defer srv.Close()
+
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Transport: NewSigningTransport(key, nil)})
+	if _, err := client.Publish(&testMessages(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	forged := NewPushClient(&ClientConfig{Host: srv.URL, Transport: NewSigningTransport([]byte("wrong"), nil)})
+	if _, err := forged.Publish(&testMessages(1)[0]); err == nil {
+		t.Error("Expected the relay to reject a request signed with the wrong key")
+	}
+}
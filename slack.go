@@ -0,0 +1,68 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SlackAlertSink posts alerts to a Slack incoming webhook
+type SlackAlertSink struct {
+	WebhookURL string
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewSlackAlertSink creates a sink posting to the given incoming webhook URL
+func NewSlackAlertSink(webhookURL string) *SlackAlertSink {
+	return &SlackAlertSink{WebhookURL: webhookURL}
+}
+
+// SendAlert posts the alert as a Slack message
+func (s *SlackAlertSink) SendAlert(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]string{"text": slackText(alert)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	closeBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("slack webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func slackText(alert Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":rotating_light: *Expo push %s*: %s", alert.Kind, alert.Message)
+	if alert.Err != nil {
+		fmt.Fprintf(&b, "\n> %s", alert.Err)
+	}
+	keys := make([]string, 0, len(alert.Labels))
+	for k := range alert.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n%s: `%s`", k, alert.Labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"sync"
+	"time"
+)
+
+// SLOTracker is an EventSink tracking delivery success against a service
+// level objective. Failures caused by the recipient, such as
+// DeviceNotRegistered, don't count against the objective.
+type SLOTracker struct {
+	// Objective is the target success ratio, e.g. 0.99
+	Objective float64
+	// Window is the period the objective applies to. Defaults to 30 days.
+	Window time.Duration
+	// Resolution is the granularity of the counters. Defaults to one minute.
+	Resolution time.Duration
+
+	mu      sync.Mutex
+	buckets map[int64]*sloBucket
+}
+
+type sloBucket struct {
+	total  int
+	failed int
+}
+
+// SLOStatus is a snapshot of an SLOTracker
+type SLOStatus struct {
+	Total  int
+	Failed int
+	// SuccessRatio is 1 when nothing was sent
+	SuccessRatio float64
+	// BudgetRemaining is the share of the error budget left over the
+	// window. It goes negative once the budget is exhausted.
+	BudgetRemaining float64
+	// BurnRate is how fast the budget is spent; 1 spends it exactly over
+	// the window
+	BurnRate float64
+}
+
+// NewSLOTracker creates a tracker for the given objective and window
+func NewSLOTracker(objective float64, window time.Duration) *SLOTracker {
+	return &SLOTracker{Objective: objective, Window: window}
+}
+
+// HandleEvent counts sent and failed deliveries
+func (s *SLOTracker) HandleEvent(event DeliveryEvent) {
+	if event.Type != EventSent && event.Type != EventFailed {
+		return
+	}
+	if event.Type == EventFailed && event.ErrorCode == ErrorDeviceNotRegistered {
+		return
+	}
+	at := event.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets == nil {
+		s.buckets = make(map[int64]*sloBucket)
+	}
+	key := at.UnixNano() / int64(s.resolution())
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &sloBucket{}
+		s.buckets[key] = b
+		s.expire(at)
+	}
+	b.total++
+	if event.Type == EventFailed {
+		b.failed++
+	}
+}
+
+// Status reports delivery over the whole window
+func (s *SLOTracker) Status() SLOStatus {
+	return s.StatusOver(s.window())
+}
+
+// StatusOver reports delivery over the last period, e.g. one hour for a
+// fast burn rate alert. BudgetRemaining is relative to the budget of the
+// period.
+func (s *SLOTracker) StatusOver(period time.Duration) SLOStatus {
+	since := time.Now().Add(-period).UnixNano() / int64(s.resolution())
+	var status SLOStatus
+	s.mu.Lock()
+	for key, b := range s.buckets {
+		if key >= since {
+			status.Total += b.total
+			status.Failed += b.failed
+		}
+	}
+	s.mu.Unlock()
+
+	status.SuccessRatio = 1
+	if status.Total > 0 {
+		status.SuccessRatio = 1 - float64(status.Failed)/float64(status.Total)
+	}
+	budget := 1 - s.Objective
+	if budget <= 0 {
+		// A 100% objective has no budget to spend
+		if status.Failed > 0 {
+			status.BudgetRemaining = -1
+		} else {
+			status.BudgetRemaining = 1
+		}
+		return status
+	}
+	status.BurnRate = (1 - status.SuccessRatio) / budget
+	status.BudgetRemaining = 1 - status.BurnRate
+	return status
+}
+
+// WithinBudget reports whether error budget is left over the window.
+// Campaign tooling can consult it before launching non-critical bulk sends.
+func (s *SLOTracker) WithinBudget() bool {
+	return s.Status().BudgetRemaining > 0
+}
+
+func (s *SLOTracker) expire(now time.Time) {
+	cutoff := now.Add(-s.window()).UnixNano() / int64(s.resolution())
+	for key := range s.buckets {
+		if key < cutoff {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *SLOTracker) window() time.Duration {
+	if s.Window <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return s.Window
+}
+
+func (s *SLOTracker) resolution() time.Duration {
+	if s.Resolution <= 0 {
+		return time.Minute
+	}
+	return s.Resolution
+}
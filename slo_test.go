@@ -0,0 +1,41 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSLOTracker(t *testing.T) {
+	slo := NewSLOTracker(0.9, time.Hour)
+	now := time.Now()
+	for i := 0; i < 95; i++ {
+		slo.HandleEvent(DeliveryEvent{Type: EventSent, Time: now})
+	}
+	for i := 0; i < 5; i++ {
+		slo.HandleEvent(DeliveryEvent{Type: EventFailed, Time: now, ErrorCode: ErrorRequestFailed})
+	}
+	// Recipient errors don't count against the objective
+	slo.HandleEvent(DeliveryEvent{Type: EventFailed, Time: now, ErrorCode: ErrorDeviceNotRegistered})
+	// Events older than the window are ignored
+	slo.HandleEvent(DeliveryEvent{Type: EventFailed, Time: now.Add(-2 * time.Hour)})
+
+	status := slo.Status()
+	if status.Total != 100 || status.Failed != 5 {
+		t.Fatalf("Unexpected counts %+v", status)
+	}
+	if math.Abs(status.BurnRate-0.5) > 1e-9 || math.Abs(status.BudgetRemaining-0.5) > 1e-9 {
+		t.Errorf("Unexpected burn rate %+v", status)
+	}
+	if !slo.WithinBudget() {
+		t.Error("Expected to be within budget")
+	}
+	for i := 0; i < 10; i++ {
+		slo.HandleEvent(DeliveryEvent{Type: EventFailed, Time: now})
+	}
+	if slo.WithinBudget() {
+		t.Errorf("Expected budget to be exhausted: %+v", slo.Status())
+	}
+}
@@ -0,0 +1,138 @@
+package expo
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SendStats records successful sends per token. It feeds the frequency cap
+// and send-rate reporting. Implementations must be safe for concurrent use.
+//
+// It is kept apart from the TokenStore: the frequency cap reads and updates
+// it for every recipient of every publish, and its rolling counts need
+// every send time within the period, not a counter on the token record.
+// MostNotifiedUsers joins both to report per user. The TokenStore records
+// the last success of each token, see RecordTokenSuccess.
+type SendStats interface {
+	// RecordSend records a successful send to token at the given time
+	RecordSend(token ExponentPushToken, at time.Time)
+	// SendCount returns the number of sends to token since the given time
+	SendCount(token ExponentPushToken, since time.Time) int
+	// LastSend returns the time of the most recent send to token
+	LastSend(token ExponentPushToken) (time.Time, bool)
+}
+
+// SendReporter is implemented by SendStats that can rank tokens by sends,
+// such as MemorySendStats. MostNotifiedUsers uses it.
+type SendReporter interface {
+	// MostNotifiedTokens returns up to limit tokens with the most sends in
+	// the last period, most notified first. A limit of 0 returns all of them.
+	MostNotifiedTokens(period time.Duration, limit int) []TokenSendCount
+}
+
+// FrequencyCap limits how many notifications a token receives per period.
+// Messages over the cap are suppressed with SuppressedFrequencyCap.
+type FrequencyCap struct {
+	Max    int
+	Period time.Duration
+}
+
+// TokenSendCount is the number of sends to a token within a reporting period
+type TokenSendCount struct {
+	Token    ExponentPushToken
+	Count    int
+	LastSend time.Time
+}
+
+// UserSendCount is the number of sends to the tokens of a user within a
+// reporting period
+type UserSendCount struct {
+	UserID   string
+	Count    int
+	LastSend time.Time
+}
+
+// MemorySendStats is an in-process SendStats that keeps send timestamps
+// for a bounded retention period
+type MemorySendStats struct {
+	mu        sync.Mutex
+	retention time.Duration
+	sends     map[ExponentPushToken][]time.Time
+	now       func() time.Time
+}
+
+// NewMemorySendStats creates a MemorySendStats keeping sends for retention.
+// Counts for periods longer than retention are truncated.
+func NewMemorySendStats(retention time.Duration) *MemorySendStats {
+	return &MemorySendStats{
+		retention: retention,
+		sends:     make(map[ExponentPushToken][]time.Time),
+		now:       time.Now,
+	}
+}
+
+// RecordSend records a successful send to token at the given time
+func (s *MemorySendStats) RecordSend(token ExponentPushToken, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := s.now().Add(-s.retention)
+	times := s.sends[token]
+	// Timestamps are kept sorted, so expired ones are at the front
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	times = times[i:]
+	// Concurrent publishes may record their sends out of order
+	j := sort.Search(len(times), func(j int) bool { return times[j].After(at) })
+	s.sends[token] = slices.Insert(times, j, at)
+}
+
+// SendCount returns the number of sends to token since the given time
+func (s *MemorySendStats) SendCount(token ExponentPushToken, since time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return countSince(s.sends[token], since)
+}
+
+// LastSend returns the time of the most recent send to token
+func (s *MemorySendStats) LastSend(token ExponentPushToken) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	times := s.sends[token]
+	if len(times) == 0 {
+		return time.Time{}, false
+	}
+	return times[len(times)-1], true
+}
+
+// MostNotifiedTokens returns up to limit tokens with the most sends in the
+// last period, most notified first. A limit of 0 returns all of them.
+func (s *MemorySendStats) MostNotifiedTokens(period time.Duration, limit int) []TokenSendCount {
+	s.mu.Lock()
+	since := s.now().Add(-period)
+	counts := make([]TokenSendCount, 0, len(s.sends))
+	for token, times := range s.sends {
+		if n := countSince(times, since); n > 0 {
+			counts = append(counts, TokenSendCount{Token: token, Count: n, LastSend: times[len(times)-1]})
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Token < counts[j].Token
+	})
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+func countSince(times []time.Time, since time.Time) int {
+	i := sort.Search(len(times), func(i int) bool { return !times[i].Before(since) })
+	return len(times) - i
+}
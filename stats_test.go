@@ -0,0 +1,53 @@
+package expo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemorySendStatsCounts(t *testing.T) {
+	stats := NewMemorySendStats(time.Hour)
+	now := time.Unix(10000, 0)
+	stats.now = func() time.Time { return now }
+	stats.RecordSend("a", now.Add(-30*time.Minute))
+	stats.RecordSend("a", now.Add(-time.Minute))
+	stats.RecordSend("b", now.Add(-time.Minute))
+
+	if n := stats.SendCount("a", now.Add(-10*time.Minute)); n != 1 {
+		t.Errorf("Expected 1 recent send, got %d", n)
+	}
+	if last, ok := stats.LastSend("a"); !ok || !last.Equal(now.Add(-time.Minute)) {
+		t.Error("Incorrect last send")
+	}
+	// A concurrent publish records an earlier send last
+	stats.RecordSend("b", now.Add(-20*time.Minute))
+	if last, _ := stats.LastSend("b"); !last.Equal(now.Add(-time.Minute)) || stats.SendCount("b", now.Add(-10*time.Minute)) != 1 {
+		t.Errorf("Expected sends recorded out of order to be sorted, got last send %v", last)
+	}
+	top := stats.MostNotifiedTokens(time.Hour, 1)
+	if len(top) != 1 || top[0].Token != "a" || top[0].Count != 2 {
+		t.Errorf("Incorrect most notified tokens: %+v", top)
+	}
+}
+
+func TestPublishFrequencyCap(t *testing.T) {
+	srv, calls := newTestServer(t, okTickets)
+	client := NewPushClient(&ClientConfig{
+		Host:         srv.URL,
+		FrequencyCap: FrequencyCap{Max: 1, Period: time.Hour},
+	})
+	message := &PushMessage{To: []ExponentPushToken{"ExponentPushToken[a]"}, Body: "hi"}
+	if _, err := client.Publish(message); err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.Publish(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Details["reason"] != SuppressedFrequencyCap {
+		t.Errorf("Expected frequency cap suppression, got %+v", response)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 request, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,185 @@
+package expo
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultStreamConcurrency is the number of chunks PublishStream sends at once
+// unless StreamOptions says otherwise
+const DefaultStreamConcurrency = 4
+
+// StreamOptions configures PublishStream
+type StreamOptions struct {
+	// ChunkSize is the number of messages per request, at most MaxMessagesPerRequest
+	ChunkSize int
+	// Concurrency is the number of chunks sent at once
+	Concurrency int
+	// Ordered releases chunk results in submission order instead of as soon
+	// as each chunk completes. Results of fast chunks are buffered until
+	// the slower chunks before them complete.
+	Ordered bool
+	// PerTokenOrder delivers messages to the same token to Expo in
+	// submission order: a chunk is only sent once every earlier chunk with
+	// a recipient in common has completed. Chunks without shared
+	// recipients are still sent concurrently.
+	PerTokenOrder bool
+	// Backpressure applies when the results aren't read as fast as chunks
+	// complete. By default, sending waits for the reader.
+	Backpressure Backpressure[ChunkResult]
+}
+
+// ChunkResult is the outcome of sending one chunk of a stream
+type ChunkResult struct {
+	// Chunk is the index of the chunk in submission order
+	Chunk int
+	// Offset is the index of the chunk's first message in the submitted slice
+	Offset    int
+	Responses []PushResponse
+	Err       error
+}
+
+// PublishStream sends messages in concurrent chunks and streams the result of
+// each chunk on the returned channel, which is closed once all chunks are done.
+// Callers must drain the channel or cancel ctx; after cancellation, chunks not
+// yet sent are skipped and pending results are discarded.
+func (c *PushClient) PublishStream(ctx context.Context, messages []PushMessage, opts StreamOptions) <-chan ChunkResult {
+	size := chunkSize(opts.ChunkSize)
+	chunks := chunkMessages(messages, size)
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultStreamConcurrency
+	}
+
+	var deps [][]int
+	var done []chan struct{}
+	if opts.PerTokenOrder {
+		deps = chunkDependencies(chunks)
+		done = make([]chan struct{}, len(chunks))
+		for i := range done {
+			done[i] = make(chan struct{})
+		}
+	}
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range chunks {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan ChunkResult)
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(chunks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Chunks are handed out in order, so the chunks waited on
+				// are already with other workers and this can't deadlock
+				err := waitChunks(ctx, done, deps, i)
+				var responses []PushResponse
+				if err == nil {
+					responses, err = c.PublishMultipleContext(ctx, chunks[i])
+				}
+				if done != nil {
+					close(done[i])
+				}
+				results <- ChunkResult{Chunk: i, Offset: i * size, Responses: responses, Err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	out := opts.Backpressure.channel()
+	go func() {
+		defer close(out)
+		emit := func(r ChunkResult) bool {
+			return opts.Backpressure.deliver(ctx, out, r)
+		}
+		next := 0
+		buffered := make(map[int]ChunkResult)
+		open := true
+		for r := range results {
+			// Keep draining after cancellation so workers can exit
+			if !open {
+				continue
+			}
+			if !opts.Ordered {
+				open = emit(r)
+				continue
+			}
+			buffered[r.Chunk] = r
+			for open {
+				r, ok := buffered[next]
+				if !ok {
+					break
+				}
+				delete(buffered, next)
+				next++
+				open = emit(r)
+			}
+		}
+	}()
+	return out
+}
+
+// chunkDependencies returns, for each chunk, the earlier chunks that last
+// held each of its recipients
+func chunkDependencies(chunks [][]PushMessage) [][]int {
+	last := make(map[ExponentPushToken]int)
+	deps := make([][]int, len(chunks))
+	for i, chunk := range chunks {
+		seen := make(map[int]bool)
+		for _, message := range chunk {
+			for _, token := range message.To {
+				if j, ok := last[token]; ok && j != i && !seen[j] {
+					seen[j] = true
+					deps[i] = append(deps[i], j)
+				}
+				last[token] = i
+			}
+		}
+	}
+	return deps
+}
+
+// waitChunks waits until the chunks chunk i depends on have completed
+func waitChunks(ctx context.Context, done []chan struct{}, deps [][]int, i int) error {
+	if deps == nil {
+		return nil
+	}
+	for _, j := range deps[i] {
+		select {
+		case <-done[j]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func chunkSize(size int) int {
+	if size <= 0 || size > MaxMessagesPerRequest {
+		return MaxMessagesPerRequest
+	}
+	return size
+}
+
+// chunkMessages splits messages into consecutive chunks of at most size messages
+func chunkMessages(messages []PushMessage, size int) [][]PushMessage {
+	var chunks [][]PushMessage
+	for start := 0; start < len(messages); start += size {
+		end := min(start+size, len(messages))
+		chunks = append(chunks, messages[start:end])
+	}
+	return chunks
+}
@@ -0,0 +1,103 @@
+package expo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishStreamOrdered(t *testing.T) {
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		// Make earlier chunks slower so they complete last
+		if messages[0].To[0] == "ExponentPushToken[0]" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return okTickets(messages)
+	})
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	results := client.PublishStream(context.Background(), testMessages(50), StreamOptions{
+		ChunkSize: 10,
+		Ordered:   true,
+	})
+	next := 0
+	for r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Chunk != next || r.Offset != next*10 || len(r.Responses) != 10 {
+			t.Errorf("Expected chunk %d, got %d at offset %d", next, r.Chunk, r.Offset)
+		}
+		next++
+	}
+	if next != 5 {
+		t.Errorf("Expected 5 chunks, got %d", next)
+	}
+}
+
+func TestPublishStreamPerTokenOrder(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		// The first chunk is slow, so without ordering the update to the
+		// same token in the second chunk would arrive first
+		if messages[0].Body == "first" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		received = append(received, messages[0].Body)
+		mu.Unlock()
+		return okTickets(messages)
+	})
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	messages := testMessages(2)
+	messages[0].Body = "first"
+	messages[1].To = messages[0].To
+	messages[1].Body = "second"
+	results := client.PublishStream(context.Background(), messages, StreamOptions{
+		ChunkSize:     1,
+		PerTokenOrder: true,
+	})
+	for r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+	if len(received) != 2 || received[0] != "first" {
+		t.Errorf("Expected messages in submission order, got %v", received)
+	}
+}
+
+func TestPublishStreamBackpressure(t *testing.T) {
+	srv, _ := newTestServer(t, okTickets)
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	for _, policy := range []SlowConsumerPolicy{SlowConsumerDrop, SlowConsumerSpill} {
+		var dropped, spilled []int
+		results := client.PublishStream(context.Background(), testMessages(50), StreamOptions{
+			ChunkSize:   10,
+			Concurrency: 1,
+			Backpressure: Backpressure[ChunkResult]{
+				Policy: policy,
+				Buffer: 2,
+				Spill: func(r ChunkResult) error {
+					spilled = append(spilled, r.Chunk)
+					return nil
+				},
+				OnDrop: func(r ChunkResult) { dropped = append(dropped, r.Chunk) },
+			},
+		})
+		// Read only once every chunk was sent
+		time.Sleep(100 * time.Millisecond)
+		read := 0
+		for range results {
+			read++
+		}
+		lost := dropped
+		if policy == SlowConsumerSpill {
+			lost = spilled
+		}
+		if read != 2 || len(lost) != 3 || len(dropped)+len(spilled) != 3 {
+			t.Errorf("%s: expected 2 results read and 3 set aside, got %d read, dropped %v, spilled %v", policy, read, dropped, spilled)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ErrorReporter receives errors from background work, such as schedulers and
+// pollers, that has no caller to return them to
+type ErrorReporter func(err error)
+
+// PanicError reports a panic recovered in a background goroutine
+type PanicError struct {
+	// Worker names the goroutine that panicked
+	Worker string
+	Value  any
+	Stack  []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Worker, e.Value)
+}
+
+const (
+	minRestartDelay = 100 * time.Millisecond
+	maxRestartDelay = 30 * time.Second
+	// healthyRun is how long a worker must run before panicking for its
+	// restart delay to start over
+	healthyRun = time.Minute
+)
+
+// supervise runs fn until it returns or ctx is done. When fn panics the panic
+// is reported and fn is restarted after an exponentially growing delay, so a
+// single bad message cannot silently stop a background worker. The delay
+// starts over once fn ran healthily for a while.
+func supervise(ctx context.Context, worker string, report ErrorReporter, fn func(context.Context) error) error {
+	var delay time.Duration
+	for {
+		start := time.Now()
+		err, panicked := runRecovered(ctx, worker, report, fn)
+		if !panicked {
+			return err
+		}
+		delay = restartDelay(delay, time.Since(start))
+		if err := sleepUntil(ctx, time.Now().Add(delay)); err != nil {
+			return err
+		}
+	}
+}
+
+// restartDelay returns how long to wait before restarting a worker that
+// panicked after running for ran, given the previous delay
+func restartDelay(previous, ran time.Duration) time.Duration {
+	if previous == 0 || ran >= healthyRun {
+		return minRestartDelay
+	}
+	return min(2*previous, maxRestartDelay)
+}
+
+func runRecovered(ctx context.Context, worker string, report ErrorReporter, fn func(context.Context) error) (err error, panicked bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			panicked = true
+			if report != nil {
+				report(&PanicError{Worker: worker, Value: v, Stack: debug.Stack()})
+			}
+		}
+	}()
+	return fn(ctx), false
+}
+
+// sleepUntil waits until t or until ctx is done
+func sleepUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
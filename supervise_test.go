@@ -0,0 +1,44 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuperviseRestartsAfterPanic(t *testing.T) {
+	var reported []error
+	runs := 0
+	err := supervise(context.Background(), "worker", func(err error) { reported = append(reported, err) },
+		func(context.Context) error {
+			runs++
+			if runs < 3 {
+				panic("bad message")
+			}
+			return errors.New("done")
+		})
+	if err == nil || err.Error() != "done" {
+		t.Errorf("Expected worker error, got %v", err)
+	}
+	if runs != 3 || len(reported) != 2 {
+		t.Fatalf("Expected 2 restarts, got %d runs and %d reports", runs, len(reported))
+	}
+	var panicErr *PanicError
+	if !errors.As(reported[0], &panicErr) || panicErr.Worker != "worker" || len(panicErr.Stack) == 0 {
+		t.Errorf("Incorrect panic report: %v", reported[0])
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	var delay time.Duration
+	for i := 0; i < 20; i++ {
+		delay = restartDelay(delay, time.Second)
+	}
+	if delay != maxRestartDelay {
+		t.Errorf("Expected crash loops to back off to %v, got %v", maxRestartDelay, delay)
+	}
+	if delay = restartDelay(delay, time.Hour); delay != minRestartDelay {
+		t.Errorf("Expected the delay to start over after a healthy run, got %v", delay)
+	}
+}
@@ -0,0 +1,192 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TieredTokenStore composes a fast cache, e.g. Redis, in front of a durable
+// backing store, e.g. Postgres, for gateways that look tokens up on the hot
+// path. Reads go through the cache and fill it from the backing store on a
+// miss. Writes reach the backing store synchronously, or in the background
+// with WriteBehind.
+type TieredTokenStore struct {
+	Cache   TokenStore
+	Backing TokenStore
+	// WriteBehind acknowledges writes once they are in the cache and
+	// applies them to the backing store on Flush. Writes not yet flushed
+	// are lost if the process dies.
+	WriteBehind bool
+	// FlushInterval is how often Run flushes write-behind writes. Defaults
+	// to one second.
+	FlushInterval time.Duration
+	// ErrorReporter receives cache errors, which don't fail operations,
+	// and the flush errors of Run
+	ErrorReporter ErrorReporter
+
+	mu sync.Mutex
+	// pending are the latest writes not yet flushed, by token
+	pending map[ExponentPushToken]*pendingWrite
+}
+
+// pendingWrite is a write-behind write, a nil record for a deletion
+type pendingWrite struct {
+	record *TokenRecord
+}
+
+// NewTieredTokenStore creates a read-through, write-through store with
+// cache in front of backing
+func NewTieredTokenStore(cache, backing TokenStore) *TieredTokenStore {
+	return &TieredTokenStore{Cache: cache, Backing: backing}
+}
+
+var _ TokenStore = (*TieredTokenStore)(nil)
+
+// Get returns the record of token from the cache, loading it from the
+// backing store on a miss
+func (s *TieredTokenStore) Get(ctx context.Context, token ExponentPushToken) (*TokenRecord, error) {
+	s.mu.Lock()
+	write, ok := s.pending[token]
+	s.mu.Unlock()
+	if ok {
+		if write.record == nil {
+			return nil, ErrTokenNotFound
+		}
+		clone := *write.record
+		return &clone, nil
+	}
+	record, err := s.Cache.Get(ctx, token)
+	if err == nil {
+		return record, nil
+	}
+	if !errors.Is(err, ErrTokenNotFound) {
+		s.report(err)
+	}
+	record, err = s.Backing.Get(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	s.report(s.Cache.Put(ctx, record))
+	return record, nil
+}
+
+// Put stores record in both stores, or queues it for the backing store
+// with WriteBehind
+func (s *TieredTokenStore) Put(ctx context.Context, record *TokenRecord) error {
+	if s.WriteBehind {
+		clone := *record
+		s.queue(record.Token, &clone)
+		s.report(s.Cache.Put(ctx, record))
+		return nil
+	}
+	if err := s.Backing.Put(ctx, record); err != nil {
+		return err
+	}
+	s.report(s.Cache.Put(ctx, record))
+	return nil
+}
+
+// List flushes pending writes and lists the backing store, since the cache
+// only holds the tokens looked up recently
+func (s *TieredTokenStore) List(ctx context.Context, filter TokenFilter) ([]TokenRecord, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	return s.Backing.List(ctx, filter)
+}
+
+// Delete removes the record of token from both stores, or queues the
+// removal from the backing store with WriteBehind
+func (s *TieredTokenStore) Delete(ctx context.Context, token ExponentPushToken) error {
+	if s.WriteBehind {
+		s.queue(token, nil)
+	} else if err := s.Backing.Delete(ctx, token); err != nil {
+		return err
+	}
+	if err := s.Cache.Delete(ctx, token); !errors.Is(err, ErrTokenNotFound) {
+		s.report(err)
+	}
+	return nil
+}
+
+// Pending returns the number of write-behind writes not yet flushed
+func (s *TieredTokenStore) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Flush applies the write-behind writes to the backing store. Failed
+// writes stay queued for the next flush.
+func (s *TieredTokenStore) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	pending := make(map[ExponentPushToken]*pendingWrite, len(s.pending))
+	for token, write := range s.pending {
+		pending[token] = write
+	}
+	s.mu.Unlock()
+	var errs []error
+	for token, write := range pending {
+		var err error
+		if write.record == nil {
+			if err = s.Backing.Delete(ctx, token); errors.Is(err, ErrTokenNotFound) {
+				err = nil
+			}
+		} else {
+			err = s.Backing.Put(ctx, write.record)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Writes stay visible to Get until flushed, and a newer write to
+		// the token stays queued
+		s.mu.Lock()
+		if s.pending[token] == write {
+			delete(s.pending, token)
+		}
+		s.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Run flushes write-behind writes every FlushInterval until ctx is done,
+// then flushes a last time
+func (s *TieredTokenStore) Run(ctx context.Context) error {
+	return supervise(ctx, "tiered token store", s.ErrorReporter, func(ctx context.Context) error {
+		interval := s.FlushInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.report(s.Flush(context.WithoutCancel(ctx)))
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			s.report(s.Flush(ctx))
+		}
+	})
+}
+
+func (s *TieredTokenStore) queue(token ExponentPushToken, record *TokenRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[ExponentPushToken]*pendingWrite)
+	}
+	s.pending[token] = &pendingWrite{record: record}
+}
+
+func (s *TieredTokenStore) report(err error) {
+	if err != nil && s.ErrorReporter != nil {
+		s.ErrorReporter(err)
+	}
+}
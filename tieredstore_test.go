@@ -0,0 +1,62 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTieredTokenStoreReadThrough(t *testing.T) {
+	ctx := context.Background()
+	cache, backing := NewMemoryTokenStore(), NewMemoryTokenStore()
+	backing.Put(ctx, &TokenRecord{Token: "a", State: TokenStateActive})
+	store := NewTieredTokenStore(cache, backing)
+
+	if record, err := store.Get(ctx, "a"); err != nil || record.State != TokenStateActive {
+		t.Fatalf("Expected the backing record, got %v, %v", record, err)
+	}
+	if _, err := cache.Get(ctx, "a"); err != nil {
+		t.Errorf("Expected a miss to fill the cache, got %v", err)
+	}
+	if _, err := store.Get(ctx, "missing"); err != ErrTokenNotFound {
+		t.Errorf("Expected ErrTokenNotFound, got %v", err)
+	}
+
+	store.Put(ctx, &TokenRecord{Token: "b", State: TokenStatePending})
+	if _, err := backing.Get(ctx, "b"); err != nil {
+		t.Errorf("Expected writes to reach the backing store, got %v", err)
+	}
+}
+
+func TestTieredTokenStoreWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	cache, backing := NewMemoryTokenStore(), NewMemoryTokenStore()
+	backing.Put(ctx, &TokenRecord{Token: "old", State: TokenStateActive})
+	store := NewTieredTokenStore(cache, backing)
+	store.WriteBehind = true
+
+	store.Put(ctx, &TokenRecord{Token: "a", State: TokenStatePending})
+	store.Put(ctx, &TokenRecord{Token: "a", State: TokenStateActive})
+	store.Delete(ctx, "old")
+	if _, err := backing.Get(ctx, "a"); err != ErrTokenNotFound {
+		t.Errorf("Expected the write to wait for a flush, got %v", err)
+	}
+	if record, err := store.Get(ctx, "a"); err != nil || record.State != TokenStateActive {
+		t.Errorf("Expected the latest write before the flush, got %v, %v", record, err)
+	}
+	if _, err := store.Get(ctx, "old"); err != ErrTokenNotFound {
+		t.Errorf("Expected the pending deletion to hide the token, got %v", err)
+	}
+	if store.Pending() != 2 {
+		t.Errorf("Expected writes to a token to coalesce, got %d pending", store.Pending())
+	}
+
+	records, err := store.List(ctx, TokenFilter{})
+	if err != nil || len(records) != 1 || records[0].Token != "a" || records[0].State != TokenStateActive {
+		t.Errorf("Expected List to flush first, got %v, %v", records, err)
+	}
+	if store.Pending() != 0 {
+		t.Errorf("Expected no pending writes after a flush, got %d", store.Pending())
+	}
+}
@@ -0,0 +1,96 @@
+//go:build !expominimal
+
+package expo
+
+import "context"
+
+// TokenHealth classifies a push token
+type TokenHealth string
+
+const (
+	// TokenHealthy is a token Expo accepted a notification for
+	TokenHealthy TokenHealth = "healthy"
+	// TokenUnregistered is a token that can no longer receive notifications,
+	// because it is malformed or the app was uninstalled
+	TokenUnregistered TokenHealth = "unregistered"
+	// TokenUnknown is a token whose probe failed for another reason
+	TokenUnknown TokenHealth = "unknown"
+)
+
+// TokenCheck is the health of a token probed by CheckTokens
+type TokenCheck struct {
+	Token  ExponentPushToken
+	Health TokenHealth
+	// TicketID is the ticket of the probe, if Expo accepted it
+	TicketID string
+	// Err explains why the token is unregistered or unknown
+	Err error
+}
+
+// CheckTokens classifies tokens as healthy, unregistered or unknown by sending
+// each one a silent notification, e.g. before an expensive campaign. Probes
+// honour the rate limiter but bypass deduplication and frequency capping,
+// and are not recorded in the send stats. An error is returned only if ctx
+// is done before all tokens were probed.
+func (c *PushClient) CheckTokens(ctx context.Context, tokens []ExponentPushToken) ([]TokenCheck, error) {
+	checks := make([]TokenCheck, len(tokens))
+	probes := make([]PushMessage, 0, len(tokens))
+	index := make([]int, 0, len(tokens))
+	for i, token := range tokens {
+		checks[i].Token = token
+		if !IsExpoPushToken(string(token)) {
+			checks[i].Health = TokenUnregistered
+			checks[i].Err = ErrMalformedToken
+			continue
+		}
+		probes = append(probes, silentProbe(token))
+		index = append(index, i)
+	}
+	for start := 0; start < len(probes); start += MaxMessagesPerRequest {
+		end := min(start+MaxMessagesPerRequest, len(probes))
+		chunk := probes[start:end]
+		var err error
+		if c.rateLimiter != nil {
+			err = c.rateLimiter.Wait(ctx, len(chunk))
+		}
+		if err != nil {
+			return checks, err
+		}
+		tickets, err := c.send(ctx, chunk)
+		for i := range chunk {
+			check := &checks[index[start+i]]
+			if err != nil {
+				check.Health = TokenUnknown
+				check.Err = err
+				continue
+			}
+			check.TicketID = tickets[i].ID
+			check.Health, check.Err = classifyTicket(&tickets[i])
+		}
+		if ctx.Err() != nil {
+			return checks, ctx.Err()
+		}
+	}
+	return checks, nil
+}
+
+// silentProbe is a notification the device doesn't display
+func silentProbe(token ExponentPushToken) PushMessage {
+	return PushMessage{
+		To:       []ExponentPushToken{token},
+		Priority: NormalPriority,
+		Data:     map[string]string{"probe": "1"},
+	}
+}
+
+func classifyTicket(ticket *PushResponse) (TokenHealth, error) {
+	err := ticket.ValidateResponse()
+	switch err.(type) {
+	case nil:
+		return TokenHealthy, nil
+	case *DeviceNotRegisteredError:
+		return TokenUnregistered, err
+	default:
+		return TokenUnknown, err
+	}
+}
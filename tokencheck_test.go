@@ -0,0 +1,33 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckTokens(t *testing.T) {
+	srv, _ := newTestServer(t, func(messages []PushMessage) Response {
+		r := okTickets(messages)
+		for i, m := range messages {
+			if m.To[0] == "ExponentPushToken[gone]" {
+				r.Data[i] = PushResponse{Status: "error", Message: "gone", Details: map[string]string{"error": ErrorDeviceNotRegistered}}
+			}
+		}
+		return r
+	})
+	client := NewPushClient(&ClientConfig{Host: srv.URL})
+	checks, err := client.CheckTokens(context.Background(), []ExponentPushToken{
+		"ExponentPushToken[ok]", "ExponentPushToken[gone]", "garbage", "ExpoPushToken[new]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TokenHealth{TokenHealthy, TokenUnregistered, TokenUnregistered, TokenHealthy}
+	for i, check := range checks {
+		if check.Health != want[i] {
+			t.Errorf("%s: expected %s, got %s", check.Token, want[i], check.Health)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTokenNotFound is returned for a token that isn't in the store
+var ErrTokenNotFound = newCodedError(ErrorCodeNotFound, "token not found")
+
+// TokenState is the lifecycle state of a stored token
+type TokenState string
+
+const (
+	// TokenStatePending is an imported token awaiting verification
+	TokenStatePending TokenState = "pending"
+	// TokenStateActive is a verified token, part of campaign audiences
+	TokenStateActive TokenState = "active"
+	// TokenStateInvalid is a token verification showed can't receive
+	// notifications
+	TokenStateInvalid TokenState = "invalid"
+	// TokenStateDeleted is a soft deleted token, restorable with RestoreToken
+	// until purged
+	TokenStateDeleted TokenState = "deleted"
+)
+
+// DefaultTokenRetention is how long soft deleted tokens are kept before
+// PurgeDeletedTokens removes them, unless told otherwise
+const DefaultTokenRetention = 30 * 24 * time.Hour
+
+// ErrTokenNotDeleted is returned when restoring a token that isn't deleted
+var ErrTokenNotDeleted = newCodedError(ErrorCodeConflict, "token not deleted")
+
+// TokenRecord is a stored push token
+type TokenRecord struct {
+	Token    ExponentPushToken
+	UserID   string
+	State    TokenState
+	Metadata map[string]string
+	// VerificationTicket is the ticket of the verification push, until its
+	// receipt settles the state of a pending token
+	VerificationTicket string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	// DeletedAt is when the token was soft deleted, and RestoreState the
+	// state it had then
+	DeletedAt    time.Time
+	RestoreState TokenState
+	// Segments are the groups the token belongs to, e.g. "beta" or "pro"
+	Segments []string
+	// LastSuccessAt is when the token last got a successful ticket, see
+	// RecordTokenSuccess
+	LastSuccessAt time.Time
+}
+
+// TokenFilter selects tokens in TokenStore.List. Zero fields match any token.
+type TokenFilter struct {
+	State  TokenState
+	UserID string
+	// Segment matches the tokens in the segment
+	Segment string
+}
+
+func (f TokenFilter) matches(r *TokenRecord) bool {
+	return (f.State == "" || r.State == f.State) && (f.UserID == "" || r.UserID == f.UserID) &&
+		(f.Segment == "" || slices.Contains(r.Segments, f.Segment))
+}
+
+// TokenStore persists push tokens. Implementations must be safe for
+// concurrent use.
+type TokenStore interface {
+	// Get returns ErrTokenNotFound for unknown tokens
+	Get(ctx context.Context, token ExponentPushToken) (*TokenRecord, error)
+	// Put creates or replaces a record
+	Put(ctx context.Context, record *TokenRecord) error
+	// List returns the records matching filter, ordered by token
+	List(ctx context.Context, filter TokenFilter) ([]TokenRecord, error)
+	// Delete removes a record for good. Prefer SoftDeleteToken, so
+	// mistaken removals can be undone.
+	Delete(ctx context.Context, token ExponentPushToken) error
+}
+
+// MarkTokenInvalid marks a token that can't receive notifications, e.g. one
+// reported DeviceNotRegistered, so it leaves every audience
+func MarkTokenInvalid(ctx context.Context, store TokenStore, token ExponentPushToken) error {
+	record, err := store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	if record.State == TokenStateInvalid || record.State == TokenStateDeleted {
+		return nil
+	}
+	record.State = TokenStateInvalid
+	record.UpdatedAt = time.Now()
+	return store.Put(ctx, record)
+}
+
+// RecordTokenSuccess records that a token got a successful ticket at t,
+// e.g. to find the tokens that went stale
+func RecordTokenSuccess(ctx context.Context, store TokenStore, token ExponentPushToken, t time.Time) error {
+	record, err := store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !t.After(record.LastSuccessAt) {
+		return nil
+	}
+	record.LastSuccessAt = t
+	return store.Put(ctx, record)
+}
+
+// PublishToStore sends message to every token of store matching filter,
+// e.g. TokenFilter{UserID: id} or TokenFilter{Segment: "beta"}, instead of
+// to message.To. The filter defaults to active tokens. Each token gets its
+// own copy of message, so the response of a token tells its outcome: tokens
+// with a successful ticket get LastSuccessAt recorded, and those reported
+// DeviceNotRegistered are marked invalid. It fails with ErrNoRecipients when
+// no token matches.
+func (c *PushClient) PublishToStore(ctx context.Context, store TokenStore, filter TokenFilter, message PushMessage, opts ...CallOption) ([]PushResponse, error) {
+	if filter.State == "" {
+		filter.State = TokenStateActive
+	}
+	records, err := store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNoRecipients
+	}
+	messages := make([]PushMessage, len(records))
+	for i := range records {
+		messages[i] = message
+		messages[i].To = []ExponentPushToken{records[i].Token}
+	}
+	responses, err := c.PublishMultipleContext(ctx, messages, opts...)
+	now := time.Now()
+	for i := range responses {
+		r := &responses[i]
+		var storeErr error
+		switch {
+		case r.isSuccess():
+			storeErr = RecordTokenSuccess(ctx, store, records[i].Token, now)
+		case r.ErrorCode() == ErrorCodeDeviceNotRegistered:
+			storeErr = MarkTokenInvalid(ctx, store, records[i].Token)
+		}
+		if err == nil {
+			err = storeErr
+		}
+	}
+	return responses, err
+}
+
+// SoftDeleteToken marks a token deleted. It leaves every audience but can
+// be restored with RestoreToken until PurgeDeletedTokens removes it, so
+// accidental mass prunes, e.g. from a misclassified error spike, are
+// recoverable.
+func SoftDeleteToken(ctx context.Context, store TokenStore, token ExponentPushToken) error {
+	record, err := store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	if record.State == TokenStateDeleted {
+		return nil
+	}
+	now := time.Now()
+	record.RestoreState = record.State
+	record.State = TokenStateDeleted
+	record.DeletedAt = now
+	record.UpdatedAt = now
+	return store.Put(ctx, record)
+}
+
+// RestoreToken undoes SoftDeleteToken, returning the token to the state it
+// had when deleted
+func RestoreToken(ctx context.Context, store TokenStore, token ExponentPushToken) error {
+	record, err := store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	if record.State != TokenStateDeleted {
+		return ErrTokenNotDeleted
+	}
+	record.State = record.RestoreState
+	if record.State == "" {
+		record.State = TokenStateActive
+	}
+	record.RestoreState = ""
+	record.DeletedAt = time.Time{}
+	record.UpdatedAt = time.Now()
+	return store.Put(ctx, record)
+}
+
+// RestoreTokensDeletedSince restores every token soft deleted since the
+// given time, e.g. to undo a bad prune, and returns how many it restored
+func RestoreTokensDeletedSince(ctx context.Context, store TokenStore, since time.Time) (int, error) {
+	deleted, err := store.List(ctx, TokenFilter{State: TokenStateDeleted})
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, record := range deleted {
+		if record.DeletedAt.Before(since) {
+			continue
+		}
+		if err := RestoreToken(ctx, store, record.Token); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// PurgeDeletedTokens removes for good the tokens soft deleted longer than
+// retention ago, DefaultTokenRetention if zero, and returns how many it removed
+func PurgeDeletedTokens(ctx context.Context, store TokenStore, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		retention = DefaultTokenRetention
+	}
+	deleted, err := store.List(ctx, TokenFilter{State: TokenStateDeleted})
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention)
+	purged := 0
+	for _, record := range deleted {
+		if record.DeletedAt.After(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, record.Token); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// MostNotifiedUsers returns up to limit users with the most sends to their
+// tokens in the last period, most notified first, from the sends reported
+// by stats and the owners recorded in store. Tokens without a user, and
+// deleted or invalid tokens, are left out. A limit of 0 returns all of them.
+func MostNotifiedUsers(ctx context.Context, stats SendReporter, store TokenStore, period time.Duration, limit int) ([]UserSendCount, error) {
+	tokens := stats.MostNotifiedTokens(period, 0)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	records, err := store.List(ctx, TokenFilter{})
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[ExponentPushToken]string, len(records))
+	for _, record := range records {
+		// The user no longer has the device
+		if record.State == TokenStateDeleted || record.State == TokenStateInvalid {
+			continue
+		}
+		users[record.Token] = record.UserID
+	}
+	byUser := make(map[string]*UserSendCount)
+	var counts []*UserSendCount
+	for _, token := range tokens {
+		user := users[token.Token]
+		if user == "" {
+			continue
+		}
+		count, ok := byUser[user]
+		if !ok {
+			count = &UserSendCount{UserID: user}
+			byUser[user] = count
+			counts = append(counts, count)
+		}
+		count.Count += token.Count
+		if token.LastSend.After(count.LastSend) {
+			count.LastSend = token.LastSend
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].UserID < counts[j].UserID
+	})
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	result := make([]UserSendCount, len(counts))
+	for i, count := range counts {
+		result[i] = *count
+	}
+	return result, nil
+}
+
+// MemoryTokenStore is an in-process TokenStore
+type MemoryTokenStore struct {
+	mu      sync.RWMutex
+	records map[ExponentPushToken]TokenRecord
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{records: make(map[ExponentPushToken]TokenRecord)}
+}
+
+// Get returns the record of token
+func (s *MemoryTokenStore) Get(_ context.Context, token ExponentPushToken) (*TokenRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[token]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &record, nil
+}
+
+// Put stores record
+func (s *MemoryTokenStore) Put(_ context.Context, record *TokenRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Token] = *record
+	return nil
+}
+
+// List returns the records matching filter
+func (s *MemoryTokenStore) List(_ context.Context, filter TokenFilter) ([]TokenRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []TokenRecord
+	for _, record := range s.records {
+		if filter.matches(&record) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Token < records[j].Token })
+	return records, nil
+}
+
+// Delete removes token
+func (s *MemoryTokenStore) Delete(_ context.Context, token ExponentPushToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[token]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(s.records, token)
+	return nil
+}
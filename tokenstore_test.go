@@ -0,0 +1,119 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPublishToStore(t *testing.T) {
+	srv, calls := newTestServer(t, func(messages []PushMessage) Response {
+		r := Response{}
+		for _, m := range messages {
+			ticket := PushResponse{Status: SuccessStatus, ID: "ticket"}
+			if m.To[0] == "ExponentPushToken[dead]" {
+				ticket = PushResponse{Status: errorStatus, Details: map[string]string{"error": ErrorDeviceNotRegistered}}
+			}
+			r.Data = append(r.Data, ticket)
+		}
+		return r
+	})
+	client := New(WithHost(srv.URL))
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	store.Put(ctx, &TokenRecord{Token: "ExponentPushToken[a]", UserID: "u1", State: TokenStateActive, Segments: []string{"beta"}})
+	store.Put(ctx, &TokenRecord{Token: "ExponentPushToken[dead]", UserID: "u1", State: TokenStateActive})
+	store.Put(ctx, &TokenRecord{Token: "ExponentPushToken[b]", UserID: "u2", State: TokenStateActive, Segments: []string{"beta"}})
+	store.Put(ctx, &TokenRecord{Token: "ExponentPushToken[c]", UserID: "u1", State: TokenStatePending})
+
+	if beta, _ := store.List(ctx, TokenFilter{Segment: "beta"}); len(beta) != 2 {
+		t.Errorf("Expected 2 tokens in the segment, got %v", beta)
+	}
+	responses, err := client.PublishToStore(ctx, store, TokenFilter{UserID: "u1"}, PushMessage{Body: "hi"})
+	if err != nil || len(responses) != 2 || calls.Load() != 1 {
+		t.Fatalf("Expected the active tokens of u1 in one request, got %+v, %v", responses, err)
+	}
+	if record, _ := store.Get(ctx, "ExponentPushToken[a]"); record.LastSuccessAt.IsZero() {
+		t.Error("Expected the success to be recorded")
+	}
+	if record, _ := store.Get(ctx, "ExponentPushToken[dead]"); record.State != TokenStateInvalid {
+		t.Errorf("Expected the dead token to be marked invalid, got %s", record.State)
+	}
+	if _, err := client.PublishToStore(ctx, store, TokenFilter{UserID: "u3"}, PushMessage{Body: "hi"}); err != ErrNoRecipients {
+		t.Errorf("Expected ErrNoRecipients, got %v", err)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	for _, token := range []ExponentPushToken{"a", "b", "c"} {
+		store.Put(ctx, &TokenRecord{Token: token, State: TokenStateActive})
+	}
+	before := time.Now()
+	for _, token := range []ExponentPushToken{"a", "b"} {
+		if err := SoftDeleteToken(ctx, store, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if active, _ := store.List(ctx, TokenFilter{State: TokenStateActive}); len(active) != 1 {
+		t.Errorf("Expected deleted tokens to leave the audience, got %v", active)
+	}
+	if err := RestoreToken(ctx, store, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreToken(ctx, store, "a"); err != ErrTokenNotDeleted {
+		t.Errorf("Expected ErrTokenNotDeleted, got %v", err)
+	}
+	if n, err := RestoreTokensDeletedSince(ctx, store, before); err != nil || n != 1 {
+		t.Errorf("Expected 1 token restored, got %d, %v", n, err)
+	}
+	if active, _ := store.List(ctx, TokenFilter{State: TokenStateActive}); len(active) != 3 {
+		t.Errorf("Expected all tokens restored, got %v", active)
+	}
+
+	SoftDeleteToken(ctx, store, "c")
+	if n, _ := PurgeDeletedTokens(ctx, store, time.Hour); n != 0 {
+		t.Errorf("Purged %d tokens within retention", n)
+	}
+	record, _ := store.Get(ctx, "c")
+	record.DeletedAt = time.Now().Add(-2 * time.Hour)
+	store.Put(ctx, record)
+	if n, _ := PurgeDeletedTokens(ctx, store, time.Hour); n != 1 {
+		t.Errorf("Expected 1 token purged, got %d", n)
+	}
+	if _, err := store.Get(ctx, "c"); err != ErrTokenNotFound {
+		t.Errorf("Expected the purged token to be gone, got %v", err)
+	}
+}
+
+func TestMostNotifiedUsers(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	for token, user := range map[ExponentPushToken]string{"a": "alice", "b": "alice", "c": "bob", "d": "", "e": "carol"} {
+		if err := store.Put(ctx, &TokenRecord{Token: token, UserID: user, State: TokenStateActive}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// carol removed their only device
+	if err := SoftDeleteToken(ctx, store, "e"); err != nil {
+		t.Fatal(err)
+	}
+	stats := NewMemorySendStats(time.Hour)
+	now := time.Now()
+	// bob's token is the most notified, but alice has two
+	for token, sends := range map[ExponentPushToken]int{"a": 2, "b": 2, "c": 3, "d": 5, "e": 9} {
+		for i := 0; i < sends; i++ {
+			stats.RecordSend(token, now.Add(-time.Minute))
+		}
+	}
+	top, err := MostNotifiedUsers(ctx, stats, store, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].UserID != "alice" || top[0].Count != 4 || top[1].UserID != "bob" || top[1].Count != 3 {
+		t.Errorf("Incorrect most notified users: %+v", top)
+	}
+}
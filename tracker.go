@@ -0,0 +1,97 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTrackerRetention is how long a Tracker keeps events unless
+// configured otherwise
+const DefaultTrackerRetention = 30 * 24 * time.Hour
+
+// Tracker is an EventSink that keeps delivery events in memory for reporting.
+// Use it as ClientConfig.EventSink, alone or through MultiEventSink.
+type Tracker struct {
+	// Retention is how long events are kept. Defaults to DefaultTrackerRetention.
+	Retention time.Duration
+
+	mu     sync.Mutex
+	events []DeliveryEvent
+}
+
+// NewTracker creates an empty Tracker
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// HandleEvent records the event
+func (t *Tracker) HandleEvent(event DeliveryEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+	// Trim expired events once they make up a good part of the slice
+	if len(t.events)%1024 == 0 {
+		t.expire(time.Now())
+	}
+}
+
+// Events returns the events recorded in [start, end), oldest first
+func (t *Tracker) Events(start, end time.Time) []DeliveryEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var events []DeliveryEvent
+	for _, event := range t.events {
+		if !event.Time.Before(start) && event.Time.Before(end) {
+			events = append(events, event)
+		}
+	}
+	// Events from concurrent calls may be recorded slightly out of order
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+// ReplayEvents re-emits the events recorded since the given time to sink,
+// oldest first, e.g. to rebuild downstream analytics after a consumer outage.
+// It returns the number of events replayed. Buffering sinks such as
+// EventWebhook hold at most MaxPending events, so flush them along the way
+// or size them for the replay.
+func (t *Tracker) ReplayEvents(since time.Time, sink EventSink) int {
+	events := t.Events(since, time.Now().Add(time.Nanosecond))
+	for _, event := range events {
+		sink.HandleEvent(event)
+	}
+	return len(events)
+}
+
+// Trace returns the events of the message with the given correlation ID,
+// oldest first
+func (t *Tracker) Trace(correlationID string) []DeliveryEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var events []DeliveryEvent
+	for _, event := range t.events {
+		if event.CorrelationID == correlationID {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+func (t *Tracker) expire(now time.Time) {
+	retention := t.Retention
+	if retention <= 0 {
+		retention = DefaultTrackerRetention
+	}
+	cutoff := now.Add(-retention)
+	kept := t.events[:0]
+	for _, event := range t.events {
+		if !event.Time.Before(cutoff) {
+			kept = append(kept, event)
+		}
+	}
+	t.events = kept
+}
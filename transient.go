@@ -0,0 +1,43 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// IsTransient reports whether retrying the request that failed with err may
+// succeed. When Expo returned error objects, their isTransient flag decides.
+// Otherwise throttling, server errors and network failures are transient,
+// while cancellations, invalid requests and authentication errors are not.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var expo interface{ apiErrors() []APIError }
+	if errors.As(err, &expo) && len(expo.apiErrors()) > 0 {
+		for _, e := range expo.apiErrors() {
+			if !e.IsTransient {
+				return false
+			}
+		}
+		return true
+	}
+	var status interface{ statusCode() int }
+	if errors.As(err, &status) {
+		code := status.statusCode()
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// The connection was closed while reading the response
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,42 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err       error
+		transient bool
+	}{
+		"nil":          {nil, false},
+		"cancelled":    {context.Canceled, false},
+		"server":       {&ServerError{HTTPError{StatusCode: 502}}, true},
+		"rate limit":   {&RateLimitError{HTTPError{StatusCode: 429}}, true},
+		"client":       {&ClientError{HTTPError{StatusCode: 400}}, false},
+		"auth":         {&AuthError{HTTPError{StatusCode: 401}}, false},
+		"network":      {fmt.Errorf("send: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), true},
+		"expo flag":    {NewPushServerError("x", nil, nil, []APIError{{IsTransient: true}}), true},
+		"expo no flag": {&ServerError{HTTPError{StatusCode: 500, PushServerError: PushServerError{Errors: []APIError{{IsTransient: false}}}}}, false},
+	} {
+		if got := IsTransient(tc.err); got != tc.transient {
+			t.Errorf("%s: expected %v, got %v", name, tc.transient, got)
+		}
+	}
+}
+
+func TestDecodeIsTransient(t *testing.T) {
+	var r Response
+	body := `{"errors":[{"code":"INTERNAL_SERVER_ERROR","message":"An unknown error occurred.","isTransient":true}]}`
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 1 || !r.Errors[0].IsTransient {
+		t.Errorf("isTransient not parsed: %+v", r.Errors)
+	}
+}
@@ -0,0 +1,216 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetadataExperience is the TokenRecord.Metadata key holding the Expo
+// project the token belongs to, e.g. "@acme/shop"
+const MetadataExperience = "experience"
+
+// TriageSampleSize is the number of tokens a TriageGroup keeps as examples
+const TriageSampleSize = 5
+
+// Token age buckets of a TriageGroup
+const (
+	TokenAgeDay     = "<1d"
+	TokenAgeWeek    = "1-7d"
+	TokenAgeMonth   = "7-30d"
+	TokenAgeQuarter = "30-90d"
+	TokenAgeOlder   = ">90d"
+)
+
+// TriageReport groups the failed receipts of a batch by cause, turning a
+// wall of receipt errors into a checklist, largest group first
+type TriageReport struct {
+	Generated time.Time     `json:"generated"`
+	Receipts  int           `json:"receipts"`
+	Failures  int           `json:"failures"`
+	Groups    []TriageGroup `json:"groups"`
+}
+
+// TriageGroup is the failures sharing an error code, experience, app
+// version and token age. The last three are empty when unknown.
+type TriageGroup struct {
+	ErrorCode  ErrorCode `json:"errorCode"`
+	Experience string    `json:"experience,omitempty"`
+	AppVersion string    `json:"appVersion,omitempty"`
+	TokenAge   string    `json:"tokenAge,omitempty"`
+	Count      int       `json:"count"`
+	// Action is the usual fix for the error code
+	Action string `json:"action"`
+	// Message is the message of the first receipt of the group
+	Message string              `json:"message,omitempty"`
+	Tokens  []ExponentPushToken `json:"tokens,omitempty"`
+}
+
+// triageActions are the fixes of the error codes Expo reports
+var triageActions = map[ErrorCode]string{
+	ErrorCodeDeviceNotRegistered: "Stop sending to these tokens and remove them from the token store",
+	ErrorCodeMessageTooBig:       "Shrink the payloads under MaxMessageBytes",
+	ErrorCodeMessageRateExceeded: "Send less often to these devices",
+	ErrorCodeMismatchSenderID:    "Check the FCM credentials uploaded for the experience",
+	ErrorCodeInvalidCredentials:  "Upload valid push credentials for the experience",
+}
+
+// Triage builds the report of receipts, keyed by ticket ID as returned by
+// GetReceiptsContext. The token of a receipt is the one in its details or,
+// failing that, tokens[ticket ID]. When store is set, the experience, app
+// version and age of the tokens come from their records, see
+// MetadataExperience and MetadataAppVersion; tokens not in the store are
+// grouped as unknown.
+func Triage(ctx context.Context, receipts map[string]PushReceipt, tokens map[string]ExponentPushToken, store TokenStore) (*TriageReport, error) {
+	now := time.Now()
+	report := &TriageReport{Generated: now, Receipts: len(receipts)}
+	// Walk the receipts in order, so samples and messages are stable
+	ids := make([]string, 0, len(receipts))
+	for id := range receipts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	type groupKey struct {
+		code                          ErrorCode
+		experience, version, tokenAge string
+	}
+	index := make(map[groupKey]int)
+	for _, id := range ids {
+		receipt := receipts[id]
+		if receipt.Status == SuccessStatus {
+			continue
+		}
+		report.Failures++
+		key := groupKey{code: receipt.ErrorCode()}
+		if key.code == "" {
+			key.code = ErrorCodeUnknown
+		}
+		token := ExponentPushToken(receipt.Details[DetailExpoPushToken])
+		if token == "" {
+			token = tokens[id]
+		}
+		if store != nil && token != "" {
+			record, err := store.Get(ctx, token)
+			if err != nil && !errors.Is(err, ErrTokenNotFound) {
+				return nil, err
+			}
+			if record != nil {
+				key.experience = record.Metadata[MetadataExperience]
+				key.version = record.Metadata[MetadataAppVersion]
+				if !record.CreatedAt.IsZero() {
+					key.tokenAge = tokenAgeBucket(now.Sub(record.CreatedAt))
+				}
+			}
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Groups)
+			index[key] = i
+			group := TriageGroup{
+				ErrorCode:  key.code,
+				Experience: key.experience,
+				AppVersion: key.version,
+				TokenAge:   key.tokenAge,
+				Action:     triageActions[key.code],
+				Message:    receipt.Message,
+			}
+			if group.Action == "" {
+				group.Action = "Investigate the receipt messages"
+			}
+			report.Groups = append(report.Groups, group)
+		}
+		group := &report.Groups[i]
+		group.Count++
+		if token != "" && len(group.Tokens) < TriageSampleSize {
+			group.Tokens = append(group.Tokens, token)
+		}
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Count > report.Groups[j].Count
+	})
+	return report, nil
+}
+
+func tokenAgeBucket(age time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case age < day:
+		return TokenAgeDay
+	case age < 7*day:
+		return TokenAgeWeek
+	case age < 30*day:
+		return TokenAgeMonth
+	case age < 90*day:
+		return TokenAgeQuarter
+	}
+	return TokenAgeOlder
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *TriageReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes a row per group, after a header row. Sample tokens are
+// separated by spaces.
+func (r *TriageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"error_code", "experience", "app_version", "token_age", "count", "action", "message", "tokens"})
+	for _, g := range r.Groups {
+		tokens := make([]string, len(g.Tokens))
+		for i, token := range g.Tokens {
+			tokens[i] = string(token)
+		}
+		cw.Write([]string{string(g.ErrorCode), g.Experience, g.AppVersion, g.TokenAge,
+			strconv.Itoa(g.Count), g.Action, g.Message, strings.Join(tokens, " ")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes the report as a checklist, a task per group
+func (r *TriageReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Receipt triage\n\n%d of %d receipts failed, %s.\n\n",
+		r.Failures, r.Receipts, r.Generated.UTC().Format(time.RFC3339))
+	for _, g := range r.Groups {
+		fmt.Fprintf(&b, "- [ ] **%s** × %d", g.ErrorCode, g.Count)
+		var scope []string
+		if g.Experience != "" {
+			scope = append(scope, "experience "+g.Experience)
+		}
+		if g.AppVersion != "" {
+			scope = append(scope, "app "+g.AppVersion)
+		}
+		if g.TokenAge != "" {
+			scope = append(scope, "tokens "+g.TokenAge+" old")
+		}
+		if len(scope) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(scope, ", "))
+		}
+		fmt.Fprintf(&b, ": %s\n", g.Action)
+		if g.Message != "" {
+			fmt.Fprintf(&b, "  - Message: %s\n", g.Message)
+		}
+		if len(g.Tokens) > 0 {
+			tokens := make([]string, len(g.Tokens))
+			for i, token := range g.Tokens {
+				tokens[i] = "`" + string(token) + "`"
+			}
+			fmt.Fprintf(&b, "  - Tokens: %s\n", strings.Join(tokens, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
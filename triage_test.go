@@ -0,0 +1,64 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriage(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	for _, token := range []ExponentPushToken{"ExponentPushToken[a]", "ExponentPushToken[b]"} {
+		store.Put(ctx, &TokenRecord{Token: token, State: TokenStateActive, CreatedAt: old,
+			Metadata: map[string]string{MetadataExperience: "@acme/shop", MetadataAppVersion: "1.2.0"}})
+	}
+	dead := map[string]string{"error": ErrorDeviceNotRegistered}
+	receipts := map[string]PushReceipt{
+		"r1": {Status: errorStatus, Message: "not registered", Details: dead},
+		"r2": {Status: errorStatus, Details: map[string]string{"error": ErrorDeviceNotRegistered, DetailExpoPushToken: "ExponentPushToken[b]"}},
+		"r3": {Status: errorStatus, Details: map[string]string{"error": ErrorMessageTooBig}},
+		"r4": {Status: SuccessStatus},
+	}
+	tokens := map[string]ExponentPushToken{"r1": "ExponentPushToken[a]", "r3": "ExponentPushToken[x]"}
+	report, err := Triage(ctx, receipts, tokens, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Receipts != 4 || report.Failures != 3 || len(report.Groups) != 2 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	g := report.Groups[0]
+	if g.ErrorCode != ErrorCodeDeviceNotRegistered || g.Count != 2 || g.Experience != "@acme/shop" ||
+		g.AppVersion != "1.2.0" || g.TokenAge != TokenAgeQuarter || g.Message != "not registered" || len(g.Tokens) != 2 {
+		t.Errorf("Unexpected first group %+v", g)
+	}
+	// A token missing from the store is grouped as unknown
+	if g := report.Groups[1]; g.ErrorCode != ErrorCodeMessageTooBig || g.Experience != "" || g.TokenAge != "" {
+		t.Errorf("Unexpected second group %+v", g)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 3 || rows[1][0] != ErrorDeviceNotRegistered || rows[1][4] != "2" {
+		t.Errorf("Unexpected CSV %v, %v", rows, err)
+	}
+	buf.Reset()
+	report.WriteMarkdown(&buf)
+	if md := buf.String(); !strings.Contains(md, "- [ ] **DeviceNotRegistered** × 2 (experience @acme/shop, app 1.2.0, tokens 30-90d old)") {
+		t.Errorf("Unexpected markdown:\n%s", md)
+	}
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil || !strings.Contains(buf.String(), `"errorCode": "MessageTooBig"`) {
+		t.Errorf("Unexpected JSON %s, %v", buf.String(), err)
+	}
+}
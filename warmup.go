@@ -0,0 +1,41 @@
+package expo
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultWarmUpTimeout bounds the warm-up request started by ClientConfig.WarmUp
+const DefaultWarmUpTimeout = 10 * time.Second
+
+// WarmUp opens a connection to the host ahead of the first send, paying for
+// DNS resolution, the TLS handshake and HTTP/2 setup up front. The
+// connection stays in the transport's idle pool for the next request. Any
+// HTTP response counts as success; only connection errors are returned.
+// It does nothing in dry-run mode.
+func (c *PushClient) WarmUp(ctx context.Context) error {
+	if c.dryRun {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.host+c.apiURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	closeBody(resp.Body)
+	return nil
+}
+
+// warmUp runs WarmUp in the background, reporting failures to the
+// client's ErrorReporter
+func (c *PushClient) warmUp() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultWarmUpTimeout)
+	defer cancel()
+	if err := c.WarmUp(ctx); err != nil && c.errorReporter != nil {
+		c.errorReporter(err)
+	}
+}
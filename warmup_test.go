@@ -0,0 +1,43 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWarmUpReusesConnection(t *testing.T) {
+	var conns, heads atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var messages []PushMessage
+		json.NewDecoder(r.Body).Decode(&messages)
+		json.NewEncoder(w).Encode(okTickets(messages))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := NewPushClient(&ClientConfig{Host: srv.URL, Transport: &http.Transport{}})
+	if err := client.WarmUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Publish(&testMessages(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if heads.Load() != 1 || conns.Load() != 1 {
+		t.Errorf("Expected the send to reuse the warm connection, got %d connections", conns.Load())
+	}
+}
@@ -0,0 +1,145 @@
+//go:build !expominimal
+
+package expo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultWebhookMaxPending is how many events an EventWebhook buffers
+// before dropping new ones, unless configured otherwise
+const DefaultWebhookMaxPending = 10000
+
+// EventWebhook is an EventSink that posts delivery events to a URL in
+// batches, as {"events": [...]}. It can also deliver for an EventOutbox.
+// With a Secret, requests carry the same signature headers as
+// SigningTransport, so receivers can check them with VerifySignature.
+type EventWebhook struct {
+	URL    string
+	Secret []byte
+	// Types filters the events posted. Empty posts every type.
+	Types []EventType
+	// Interval is how often buffered events are posted by Run.
+	// Defaults to one second.
+	Interval time.Duration
+	// MaxPending bounds the buffered events. Defaults to DefaultWebhookMaxPending.
+	MaxPending int
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	// ErrorReporter receives errors posting events and dropped event counts
+	ErrorReporter ErrorReporter
+
+	mu      sync.Mutex
+	pending []DeliveryEvent
+	dropped int
+}
+
+// HandleEvent buffers the event if it passes the filter
+func (w *EventWebhook) HandleEvent(event DeliveryEvent) {
+	if len(w.Types) > 0 && !slices.Contains(w.Types, event.Type) {
+		return
+	}
+	max := w.MaxPending
+	if max <= 0 {
+		max = DefaultWebhookMaxPending
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) >= max {
+		w.dropped++
+		return
+	}
+	w.pending = append(w.pending, event)
+}
+
+// Flush posts the buffered events. Events of a failed post are put back
+// to be retried.
+func (w *EventWebhook) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	events := w.pending
+	w.pending = nil
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+	if dropped > 0 && w.ErrorReporter != nil {
+		w.ErrorReporter(fmt.Errorf("webhook %s: dropped %d events over the pending limit", w.URL, dropped))
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := w.post(ctx, events); err != nil {
+		w.mu.Lock()
+		w.pending = append(events, w.pending...)
+		w.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run posts buffered events every Interval until ctx is done
+func (w *EventWebhook) Run(ctx context.Context) error {
+	return supervise(ctx, "event webhook", w.ErrorReporter, func(ctx context.Context) error {
+		interval := w.Interval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				w.flush(context.WithoutCancel(ctx))
+				return ctx.Err()
+			case <-ticker.C:
+				w.flush(ctx)
+			}
+		}
+	})
+}
+
+func (w *EventWebhook) flush(ctx context.Context) {
+	if err := w.Flush(ctx); err != nil && w.ErrorReporter != nil {
+		w.ErrorReporter(err)
+	}
+}
+
+// DeliverEvents posts events from an EventOutbox right away, as
+// {"events": [...]} with their sequence numbers
+func (w *EventWebhook) DeliverEvents(ctx context.Context, events []SequencedEvent) error {
+	return w.post(ctx, events)
+}
+
+func (w *EventWebhook) post(ctx context.Context, events any) error {
+	body, err := json.Marshal(map[string]any{"events": events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.Secret) > 0 {
+		setSignature(req, w.Secret, body)
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	closeBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: unexpected status %s", w.URL, resp.Status)
+	}
+	return nil
+}